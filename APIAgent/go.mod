@@ -6,6 +6,7 @@ require (
 	github.com/apex/log v1.9.0
 	github.com/gin-gonic/gin v1.10.0
 	github.com/google/uuid v1.6.0
+	github.com/lexieqin/Geek/llm v0.0.0
 	github.com/sashabaranov/go-openai v1.36.0
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -38,3 +39,5 @@ require (
 	golang.org/x/text v0.15.0 // indirect
 	google.golang.org/protobuf v1.34.1 // indirect
 )
+
+replace github.com/lexieqin/Geek/llm => ../llm
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/xingyunyang01/APIAgent/pkg/controllers"
+	"github.com/lexieqin/Geek/llm"
 	"github.com/xingyunyang01/APIAgent/pkg/core/tools"
 	"github.com/xingyunyang01/APIAgent/pkg/models"
 	"github.com/xingyunyang01/APIAgent/pkg/services"
@@ -24,7 +25,12 @@ func main() {
 
 	tools, err := tools.ParseOpenAPIToToolBundle(&api)
 
-	chatCompletionService := services.NewChatCompletionService(sc, tools)
+	provider, err := llm.NewOpenAI(sc.LLM)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	chatCompletionService := services.NewChatCompletionService(sc, tools, provider)
 
 	chatCompletionCtl := controllers.NewChatCompletionCtl(chatCompletionService)
 
@@ -19,11 +19,13 @@ func (chat *ChatCompletionCtl) ChatCompletion() func(c *gin.Context) {
 		var message models.ChatMeessage
 		if err := c.ShouldBindJSON(&message); err != nil {
 			c.JSON(400, gin.H{"error": "解析请求体失败: " + err.Error()})
+			return
 		}
 
-		response, err := chat.chatCompletionService.ChatCompletion(message.Message)
+		response, err := chat.chatCompletionService.ChatCompletion(c.Request.Context(), message.Message)
 		if err != nil {
 			c.JSON(400, gin.H{"error": "询问失败: " + err.Error()})
+			return
 		}
 
 		c.JSON(200, gin.H{"message": response})
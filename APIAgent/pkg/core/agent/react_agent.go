@@ -2,6 +2,7 @@ package agent
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -10,6 +11,7 @@ import (
 	outputparser "github.com/xingyunyang01/APIAgent/pkg/core/agent/output_parser"
 	promptTemplate "github.com/xingyunyang01/APIAgent/pkg/core/agent/template"
 	"github.com/xingyunyang01/APIAgent/pkg/core/ai"
+	"github.com/lexieqin/Geek/llm"
 	"github.com/xingyunyang01/APIAgent/pkg/core/tools"
 	"github.com/xingyunyang01/APIAgent/pkg/models"
 )
@@ -98,17 +100,20 @@ func organizeReActTemplate(instruction string, tools []models.ApiToolBundle, que
 	return result.String()
 }
 
-func Run(sc *models.Config, toolBundles []models.ApiToolBundle, query string) (string, error) {
+func Run(ctx context.Context, provider llm.Provider, sc *models.Config, toolBundles []models.ApiToolBundle, query string) (string, error) {
 	prompt := organizeReActTemplate(sc.Instruction, toolBundles, query)
 	ai.MessageStore.AddForUser(prompt)
 
 	var action string
 	var actionInput map[string]interface{}
-	var err error
 
 	iteration_steps := 1
 	for {
-		first_response := ai.NormalChat(ai.MessageStore.ToMessage())
+		first_response, err := provider.Chat(ctx, llm.Request{Messages: ai.MessageStore.ToMessage()})
+		if err != nil {
+			fmt.Println("Error:", err)
+			return "", err
+		}
 		fmt.Printf("========第%d轮回答========\n", iteration_steps)
 		fmt.Println(first_response.Content)
 		ai.MessageStore.AddForAssistant(first_response.Content)
@@ -0,0 +1,205 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lexieqin/Geek/llm"
+	"github.com/lexieqin/Geek/llm/replay"
+	"github.com/xingyunyang01/APIAgent/pkg/core/ai"
+	"github.com/xingyunyang01/APIAgent/pkg/core/tools"
+	"github.com/xingyunyang01/APIAgent/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// fakeGinTools stands in for the ginTools endpoints the OpenAPI spec describes
+type fakeGinTools struct {
+	*httptest.Server
+
+	mu   sync.Mutex
+	hits []string
+}
+
+func startFakeGinTools(t *testing.T) *fakeGinTools {
+	t.Helper()
+	f := &fakeGinTools{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.hits = append(f.hits, r.URL.RequestURI())
+		f.mu.Unlock()
+
+		if r.URL.Path != "/pods" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":[{"name":"web-1","status":"CrashLoopBackOff"}]}`))
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeGinTools) Hits() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.hits...)
+}
+
+const podsAPI = `
+openapi: 3.1.0
+info:
+  title: ginTools
+  description: Kubernetes resources
+  version: v1.0.0
+servers:
+  - url: %s
+paths:
+  /pods:
+    get:
+      description: List the pods of a namespace
+      operationId: listPods
+      parameters:
+        - name: ns
+          in: query
+          description: The namespace
+          required: true
+          schema:
+            type: string
+      deprecated: false
+`
+
+func toolBundles(t *testing.T, url string) []models.ApiToolBundle {
+	t.Helper()
+	var api models.OpenAPI
+	if err := yaml.Unmarshal([]byte(strings.Replace(podsAPI, "%s", url, 1)), &api); err != nil {
+		t.Fatal(err)
+	}
+	bundles, err := tools.ParseOpenAPIToToolBundle(&api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bundles
+}
+
+type reactRun struct {
+	answer string
+	err    error
+	hits   []string
+	keys   []string
+}
+
+// runReAct answers query through a replay server on dir. With a nil upstream the server
+// only replays recorded fixtures.
+func runReAct(t *testing.T, ginTools *fakeGinTools, maxSteps int, query, dir string, upstream llm.Provider) reactRun {
+	t.Helper()
+	replayServer, err := replay.NewServer(dir, upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	llmServer := httptest.NewServer(replayServer)
+	defer llmServer.Close()
+
+	provider, err := llm.NewOpenAI(llm.Config{APIKey: "test", BaseURL: llmServer.URL + "/v1", MaxRetries: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := &models.Config{
+		APIs: models.APIConfig{
+			APIProvider: models.APIProvider{APIKey: models.APIKey{Name: "key", Value: "test", In: "query"}},
+		},
+		Instruction:       "You are a Kubernetes assistant.",
+		MaxIterationSteps: maxSteps,
+	}
+	ai.MessageStore.Clear()
+
+	hitsBefore := len(ginTools.Hits())
+	answer, err := Run(context.Background(), provider, sc, toolBundles(t, ginTools.URL), query)
+	return reactRun{
+		answer: answer,
+		err:    err,
+		hits:   ginTools.Hits()[hitsBefore:],
+		keys:   replayServer.Keys(),
+	}
+}
+
+func action(name, input string) llm.Step {
+	return llm.Reply("Thought: I should use a tool\nAction:\n```\n{\"action\": \"" + name + "\", \"action_input\": " + input + "}\n```")
+}
+
+func TestRunEndToEnd(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxSteps   int
+		script     []llm.Step
+		wantHits   []string
+		wantAnswer string
+		wantErr    bool
+	}{
+		{
+			name:     "tool call then final answer",
+			maxSteps: 3,
+			script: []llm.Step{
+				action("listPods", `{"ns": "default"}`),
+				action("Final Answer", `"web-1 is in CrashLoopBackOff"`),
+			},
+			wantHits:   []string{"/pods?key=test&ns=default"},
+			wantAnswer: "web-1 is in CrashLoopBackOff",
+		},
+		{
+			name:     "stops at the iteration limit",
+			maxSteps: 1,
+			script: []llm.Step{
+				action("listPods", `{"ns": "default"}`),
+			},
+			wantAnswer: "已超出允许的最大迭代次数",
+		},
+		{
+			name:     "a failed model call is returned",
+			maxSteps: 3,
+			script: []llm.Step{
+				llm.Fail(errors.New("model overloaded")),
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ginTools := startFakeGinTools(t)
+			dir := t.TempDir()
+			query := "Why does web-1 not start?"
+
+			check := func(mode string, run reactRun) {
+				t.Helper()
+				if (run.err != nil) != tt.wantErr {
+					t.Errorf("%s: Run() error = %v, want error %v", mode, run.err, tt.wantErr)
+				}
+				if run.answer != tt.wantAnswer {
+					t.Errorf("%s: Run() = %q, want %q", mode, run.answer, tt.wantAnswer)
+				}
+				if !reflect.DeepEqual(run.hits, tt.wantHits) {
+					t.Errorf("%s: ginTools hits = %q, want %q", mode, run.hits, tt.wantHits)
+				}
+			}
+
+			upstream := llm.NewScripted(tt.script...)
+			recorded := runReAct(t, ginTools, tt.maxSteps, query, dir, upstream)
+			check("record", recorded)
+			if upstream.Remaining() != 0 {
+				t.Errorf("record: %d scripted replies left unused", upstream.Remaining())
+			}
+
+			replayed := runReAct(t, ginTools, tt.maxSteps, query, dir, nil)
+			check("replay", replayed)
+			if !reflect.DeepEqual(replayed.keys, recorded.keys) {
+				t.Errorf("replay asked for conversations %v, recorded %v", replayed.keys, recorded.keys)
+			}
+		})
+	}
+}
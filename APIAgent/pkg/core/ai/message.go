@@ -1,10 +1,6 @@
 package ai
 
 import (
-	"context"
-	"log"
-	"os"
-
 	openai "github.com/sashabaranov/go-openai"
 )
 
@@ -16,30 +12,6 @@ func init() {
 
 }
 
-func NewOpenAiClient() *openai.Client {
-	token := os.Getenv("DashScope")
-	dashscope_url := "https://dashscope.aliyuncs.com/compatible-mode/v1"
-	config := openai.DefaultConfig(token)
-	config.BaseURL = dashscope_url
-
-	return openai.NewClientWithConfig(config)
-}
-
-// chat对话
-func NormalChat(message []openai.ChatCompletionMessage) openai.ChatCompletionMessage {
-	c := NewOpenAiClient()
-	rsp, err := c.CreateChatCompletion(context.TODO(), openai.ChatCompletionRequest{
-		Model:    "qwen-max",
-		Messages: message,
-	})
-	if err != nil {
-		log.Println(err)
-		return openai.ChatCompletionMessage{}
-	}
-
-	return rsp.Choices[0].Message
-}
-
 // 定义chat模型
 type ChatMessages []ChatMessage
 type ChatMessage struct {
@@ -1,5 +1,7 @@
 package models
 
+import "github.com/lexieqin/Geek/llm"
+
 type APIKey struct {
 	Name  string `yaml:"name"`
 	Value string `yaml:"value"`
@@ -19,4 +21,6 @@ type Config struct {
 	APIs              APIConfig `yaml:"apis"`
 	Instruction       string    `yaml:"instruction"`
 	MaxIterationSteps int       `yaml:"max_iteration_steps"`
+	// LLM selects the chat model the agent talks to
+	LLM llm.Config `yaml:"llm"`
 }
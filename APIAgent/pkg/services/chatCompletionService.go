@@ -1,19 +1,23 @@
 package services
 
 import (
+	"context"
+
 	"github.com/xingyunyang01/APIAgent/pkg/core/agent"
+	"github.com/lexieqin/Geek/llm"
 	"github.com/xingyunyang01/APIAgent/pkg/models"
 )
 
 type ChatCompletionService struct {
-	sc    *models.Config
-	tools []models.ApiToolBundle
+	sc       *models.Config
+	tools    []models.ApiToolBundle
+	provider llm.Provider
 }
 
-func NewChatCompletionService(sc *models.Config, tools []models.ApiToolBundle) *ChatCompletionService {
-	return &ChatCompletionService{sc: sc, tools: tools}
+func NewChatCompletionService(sc *models.Config, tools []models.ApiToolBundle, provider llm.Provider) *ChatCompletionService {
+	return &ChatCompletionService{sc: sc, tools: tools, provider: provider}
 }
 
-func (s *ChatCompletionService) ChatCompletion(query string) (string, error) {
-	return agent.Run(s.sc, s.tools, query)
+func (s *ChatCompletionService) ChatCompletion(ctx context.Context, query string) (string, error) {
+	return agent.Run(ctx, s.provider, s.sc, s.tools, query)
 }
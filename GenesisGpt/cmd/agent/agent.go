@@ -0,0 +1,494 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/ai"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/audit"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/config"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/policy"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/promptTpl"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/tools"
+	"github.com/lexieqin/Geek/llm"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Mode selects how the agent talks to the model about tools.
+type Mode string
+
+const (
+	// ModeReAct describes the tools in the prompt and scrapes Action / Action Input from the reply.
+	ModeReAct Mode = "react"
+	// ModeTools sends the tools as openai.Tool definitions and runs the returned ToolCalls.
+	ModeTools Mode = "tools"
+)
+
+// ParseMode validates a --mode flag value.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(strings.ToLower(strings.TrimSpace(s))) {
+	case "", ModeReAct:
+		return ModeReAct, nil
+	case ModeTools:
+		return ModeTools, nil
+	default:
+		return "", fmt.Errorf("invalid mode %q: must be %q or %q", s, ModeReAct, ModeTools)
+	}
+}
+
+// DefaultMaxRounds bounds the number of model calls for one query.
+const DefaultMaxRounds = 10
+
+// ErrMaxRounds is returned when the model did not finish within MaxRounds.
+var ErrMaxRounds = errors.New("could not complete the task within the allowed steps")
+
+// confirmationMarker is what HumanTool returns in server mode.
+const confirmationMarker = "[HUMAN_CONFIRMATION_REQUIRED]"
+
+var (
+	confirmationRe = regexp.MustCompile(`\[HUMAN_CONFIRMATION_REQUIRED\]: (.+)`)
+	thoughtRe      = regexp.MustCompile(`Thought:\s*(.*)`)
+	actionRe       = regexp.MustCompile(`Action:\s*(.*?)[\n]`)
+	actionInputRe  = regexp.MustCompile(`Action Input:\s*`)
+)
+
+// EventType identifies a step of the agent loop.
+type EventType string
+
+const (
+	EventToken        EventType = "token"
+	EventResponse     EventType = "response"
+	EventThought      EventType = "thought"
+	EventAction       EventType = "action"
+	EventObservation  EventType = "observation"
+	EventConfirmation EventType = "confirmation"
+	// EventApproval is emitted when a tool call waits for the user's approval
+	EventApproval    EventType = "approval"
+	EventFinalAnswer EventType = "final_answer"
+)
+
+// Event is emitted for every step so callers can print or stream progress.
+type Event struct {
+	Type    EventType `json:"type"`
+	Round   int       `json:"round"`
+	Content string    `json:"content,omitempty"`
+	Tool    string    `json:"tool,omitempty"`
+	Input   string    `json:"input,omitempty"`
+}
+
+// Confirmation is a HumanTool call waiting for the user's answer.
+type Confirmation struct {
+	Prompt     string
+	ToolCallID string
+}
+
+// Action is a tool call the policy only allows once the user approves it.
+type Action struct {
+	Tool  string
+	Input json.RawMessage
+	// ToolCallID is the call to answer in tools mode
+	ToolCallID string
+	// Reason says why approval is needed
+	Reason string
+	// Preview shows what the call would change, such as a dry-run result
+	Preview string
+}
+
+// Result is the outcome of one Run or Resume.
+type Result struct {
+	FinalAnswer string
+	// Complete is false when the model stopped without an explicit final answer.
+	Complete bool
+	// Confirmation is set when the loop paused for human confirmation.
+	Confirmation *Confirmation
+	// Action is set when the loop paused for the user to approve a tool call.
+	Action *Action
+	Rounds int
+}
+
+// Agent drives the conversation between the model and the registered tools.
+type Agent struct {
+	Provider  llm.Provider
+	Registry  *tools.Registry
+	Mode      Mode
+	MaxRounds int
+	// Stream makes the agent use the streaming completion API and emit EventToken for every token.
+	Stream bool
+	// Budget bounds the conversation sent to the model.
+	Budget ContextBudget
+	// OnEvent, if set, is called synchronously for every step.
+	OnEvent func(Event)
+	// Approve, if set, asks the user about an action needing approval and the loop
+	// continues with the answer. Otherwise the loop pauses and returns the Action.
+	Approve func(*Action) bool
+}
+
+// New creates an Agent that asks provider and uses registry in the given mode.
+func New(provider llm.Provider, registry *tools.Registry, mode Mode) *Agent {
+	return &Agent{
+		Provider:  provider,
+		Registry:  registry,
+		Mode:      mode,
+		MaxRounds: DefaultMaxRounds,
+		Budget:    BudgetFromConfig(config.GetConfig().Context),
+	}
+}
+
+func (a *Agent) emit(e Event) {
+	if a.OnEvent != nil {
+		a.OnEvent(e)
+	}
+}
+
+// Run answers query, appending the whole exchange to messages. The tool prompt is
+// added once as a system message, so follow-up queries only add the question.
+func (a *Agent) Run(ctx context.Context, messages *ai.ChatMessages, query string) (*Result, error) {
+	ctx = withRequest(ctx)
+	if a.Mode == ModeTools {
+		ensureSystemPrompt(messages, promptTpl.ToolsTemplate)
+		messages.AddForUser(query)
+	} else {
+		ensureSystemPrompt(messages, BuildPrompt(a.Registry))
+		messages.AddForUser("Question: " + query)
+	}
+	return a.loop(ctx, messages)
+}
+
+// Resume feeds the human's answer to a pending confirmation back to the model and continues.
+func (a *Agent) Resume(ctx context.Context, messages *ai.ChatMessages, pending *Confirmation, answer string) (*Result, error) {
+	ctx = withRequest(ctx)
+	if a.Mode == ModeTools && pending != nil && pending.ToolCallID != "" {
+		messages.AddForTool(answer, "HumanTool", pending.ToolCallID)
+	} else {
+		messages.AddForUser(fmt.Sprintf("Observation: %s", answer))
+	}
+	return a.loop(ctx, messages)
+}
+
+// ResumeAction continues after the user decided on a paused action. An approved
+// action runs with exactly the input it was approved for; the model gets its output.
+func (a *Agent) ResumeAction(ctx context.Context, messages *ai.ChatMessages, action *Action, approved bool) (*Result, error) {
+	ctx = withRequest(ctx)
+	a.AnswerAction(ctx, messages, action, a.decide(ctx, action, approved, 0))
+	return a.loop(ctx, messages)
+}
+
+// AnswerAction gives the model output as the result of action, e.g. when the action
+// was dropped without being run.
+func (a *Agent) AnswerAction(ctx context.Context, messages *ai.ChatMessages, action *Action, output string) {
+	if a.Mode == ModeTools && action.ToolCallID != "" {
+		messages.AddForTool(a.limitObservation(ctx, action.Tool, output), action.Tool, action.ToolCallID)
+	} else {
+		messages.AddForUser(a.limitObservation(ctx, action.Tool, "Observation: "+output))
+	}
+}
+
+// DeniedOutput is what the model is told about an action the user denied.
+const DeniedOutput = "Not executed: the user denied this action. Do not retry it unless the user asks again."
+
+// decide runs an approved action, or records the denial, and returns the observation.
+func (a *Agent) decide(ctx context.Context, action *Action, approved bool, round int) string {
+	if !approved {
+		a.Registry.Record(ctx, action.Tool, action.Input, audit.ConfirmationDenied, DeniedOutput)
+		a.emit(Event{Type: EventObservation, Round: round, Tool: action.Tool, Content: DeniedOutput})
+		return DeniedOutput
+	}
+
+	audit.RequestFrom(ctx).Approve(action.Tool, action.Input)
+	output := a.Registry.Invoke(ctx, action.Tool, action.Input)
+	a.emit(Event{Type: EventObservation, Round: round, Tool: action.Tool, Content: output})
+	return output
+}
+
+// needsApproval reports the action behind a call that may only run once the user approves
+// it, with the tool's preview. A call whose preview fails, or that the policy would refuse
+// even once approved, is not put to the user; refusal is then the observation for the model.
+func (a *Agent) needsApproval(ctx context.Context, tool string, input json.RawMessage, toolCallID string) (action *Action, refusal string) {
+	if len(input) == 0 {
+		input = json.RawMessage("{}")
+	}
+	decision := a.Registry.Check(ctx, tool, input)
+	if decision.Effect != policy.NeedsConfirmation {
+		return nil, ""
+	}
+
+	tool = strings.TrimSpace(tool)
+	preview, pinned, err := a.Registry.Preview(ctx, tool, input)
+	if err != nil {
+		refusal = fmt.Sprintf("Not executed: the preview failed: %v", err)
+		a.Registry.Record(ctx, tool, input, "", refusal)
+		return nil, refusal
+	}
+	if decision := a.Registry.CheckApproved(tool, pinned); decision.Effect == policy.Deny {
+		refusal = decision.Message(tool)
+		a.Registry.Record(ctx, tool, pinned, "", refusal)
+		return nil, refusal
+	}
+	return &Action{Tool: tool, Input: pinned, ToolCallID: toolCallID, Reason: decision.Reason, Preview: preview}, ""
+}
+
+// describe is the text of an EventApproval.
+func (action *Action) describe() string {
+	if action.Preview == "" {
+		return action.Reason
+	}
+	return action.Reason + "\n\n" + action.Preview
+}
+
+// withRequest makes sure ctx carries an audit.Request, which tracks the user's answer
+// to HumanTool for the policy checks of later tool calls.
+func withRequest(ctx context.Context) context.Context {
+	if audit.RequestFrom(ctx) != nil {
+		return ctx
+	}
+	return audit.WithRequest(ctx, &audit.Request{})
+}
+
+// BuildPrompt renders the ReAct system prompt with every tool in registry.
+func BuildPrompt(registry *tools.Registry) string {
+	return fmt.Sprintf(promptTpl.Template, registry.Definitions(), registry.Names())
+}
+
+func (a *Agent) loop(ctx context.Context, messages *ai.ChatMessages) (*Result, error) {
+	maxRounds := a.MaxRounds
+	if maxRounds <= 0 {
+		maxRounds = DefaultMaxRounds
+	}
+
+	for round := 1; round <= maxRounds; round++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		a.compact(ctx, messages)
+
+		var result *Result
+		var err error
+		if a.Mode == ModeTools {
+			result, err = a.toolsRound(ctx, messages, round)
+		} else {
+			result, err = a.reactRound(ctx, messages, round)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if result != nil {
+			result.Rounds = round
+			return result, nil
+		}
+	}
+	return nil, ErrMaxRounds
+}
+
+// reactRound runs one Thought/Action/Observation step. It returns a nil Result when the loop should continue.
+func (a *Agent) reactRound(ctx context.Context, messages *ai.ChatMessages, round int) (*Result, error) {
+	response, err := a.chat(ctx, messages, nil, round)
+	if err != nil {
+		return nil, err
+	}
+	a.emit(Event{Type: EventResponse, Round: round, Content: response.Content})
+
+	if thought := thoughtRe.FindStringSubmatch(response.Content); len(thought) > 1 {
+		a.emit(Event{Type: EventThought, Round: round, Content: strings.TrimSpace(thought[1])})
+	}
+
+	// Check for final answer (capture everything after "Final Answer:")
+	if parts := strings.SplitN(response.Content, "Final Answer:", 2); len(parts) == 2 {
+		messages.AddForAssistant(response.Content)
+		finalAnswer := strings.TrimSpace(parts[1])
+		a.emit(Event{Type: EventFinalAnswer, Round: round, Content: finalAnswer})
+		return &Result{FinalAnswer: finalAnswer, Complete: true}, nil
+	}
+
+	messages.AddForAssistant(response.Content)
+
+	action, actionInput, ok := ParseAction(response.Content)
+	if !ok {
+		// No valid action, treat the reply as the answer
+		a.emit(Event{Type: EventFinalAnswer, Round: round, Content: response.Content})
+		return &Result{FinalAnswer: response.Content}, nil
+	}
+
+	a.emit(Event{Type: EventAction, Round: round, Tool: action, Input: string(actionInput)})
+
+	pending, refusal := a.needsApproval(ctx, action, actionInput, "")
+	if refusal != "" {
+		a.emit(Event{Type: EventObservation, Round: round, Tool: action, Content: refusal})
+		messages.AddForUser("Observation: " + refusal)
+		return nil, nil
+	}
+	if pending != nil {
+		if a.Approve == nil {
+			a.Registry.Record(ctx, pending.Tool, pending.Input, audit.ConfirmationPending, "")
+			a.emit(Event{Type: EventApproval, Round: round, Tool: pending.Tool, Input: string(pending.Input), Content: pending.describe()})
+			return &Result{Action: pending}, nil
+		}
+		// The decision is already emitted as an observation
+		output := a.decide(ctx, pending, a.Approve(pending), round)
+		messages.AddForUser(a.limitObservation(ctx, action, "Observation: "+output))
+		return nil, nil
+	}
+
+	observation := a.Registry.Execute(ctx, action, actionInput)
+
+	if prompt, ok := confirmationPrompt(observation); ok {
+		a.emit(Event{Type: EventConfirmation, Round: round, Tool: action, Content: prompt})
+		return &Result{Confirmation: &Confirmation{Prompt: prompt}}, nil
+	}
+
+	a.emit(Event{Type: EventObservation, Round: round, Tool: action, Content: observation})
+	// The response itself is already in messages as the assistant turn
+	messages.AddForUser(a.limitObservation(ctx, action, observation))
+	return nil, nil
+}
+
+// toolsRound runs one native function-calling step. It returns a nil Result when the loop should continue.
+func (a *Agent) toolsRound(ctx context.Context, messages *ai.ChatMessages, round int) (*Result, error) {
+	response, err := a.chat(ctx, messages, a.Registry.OpenAITools(), round)
+	if err != nil {
+		return nil, err
+	}
+	a.emit(Event{Type: EventResponse, Round: round, Content: response.Content})
+
+	if len(response.ToolCalls) == 0 {
+		messages.AddForAssistant(response.Content)
+		a.emit(Event{Type: EventFinalAnswer, Round: round, Content: response.Content})
+		return &Result{FinalAnswer: response.Content, Complete: true}, nil
+	}
+
+	messages.AddForToolCall(response)
+	if strings.TrimSpace(response.Content) != "" {
+		a.emit(Event{Type: EventThought, Round: round, Content: response.Content})
+	}
+
+	outputs, approvals := a.runToolCalls(ctx, response.ToolCalls, round)
+
+	var pending *Confirmation
+	var pendingAction *Action
+	for i, call := range response.ToolCalls {
+		if prompt, ok := confirmationPrompt(outputs[i]); ok && pending == nil {
+			// Answered by Resume once the human has replied
+			pending = &Confirmation{Prompt: prompt, ToolCallID: call.ID}
+			a.emit(Event{Type: EventConfirmation, Round: round, Tool: call.Function.Name, Content: prompt})
+			continue
+		}
+		if approvals[i] != nil {
+			continue
+		}
+		a.emit(Event{Type: EventObservation, Round: round, Tool: call.Function.Name, Content: outputs[i]})
+		messages.AddForTool(a.limitObservation(ctx, call.Function.Name, outputs[i]), call.Function.Name, call.ID)
+	}
+
+	// Only one question or action can wait for the user at a time
+	for _, action := range approvals {
+		if action == nil {
+			continue
+		}
+		if pending == nil && pendingAction == nil {
+			// Answered by ResumeAction once the user has decided
+			pendingAction = action
+			a.Registry.Record(ctx, action.Tool, action.Input, audit.ConfirmationPending, "")
+			a.emit(Event{Type: EventApproval, Round: round, Tool: action.Tool, Input: string(action.Input), Content: action.describe()})
+			continue
+		}
+		output := "Not executed: another request is waiting for the user. Call this again once it is answered."
+		a.emit(Event{Type: EventObservation, Round: round, Tool: action.Tool, Content: output})
+		messages.AddForTool(output, action.Tool, action.ToolCallID)
+	}
+
+	if pending != nil || pendingAction != nil {
+		return &Result{Confirmation: pending, Action: pendingAction}, nil
+	}
+	return nil, nil
+}
+
+// chat sends messages to the model, streaming tokens as events when Stream is set.
+func (a *Agent) chat(ctx context.Context, messages *ai.ChatMessages, toolDefs []openai.Tool, round int) (openai.ChatCompletionMessage, error) {
+	req := llm.Request{Messages: messages.ToMessage(), Tools: toolDefs}
+	if a.Stream {
+		return a.Provider.ChatStream(ctx, req, func(delta string) {
+			a.emit(Event{Type: EventToken, Round: round, Content: delta})
+		})
+	}
+	return a.Provider.Chat(ctx, req)
+}
+
+// runToolCalls executes all calls of one response in parallel and returns their outputs
+// in order. Calls that need the user's approval are not run: with an Approve hook the
+// user is asked first, otherwise their Action is returned for the loop to pause on.
+func (a *Agent) runToolCalls(ctx context.Context, calls []openai.ToolCall, round int) ([]string, []*Action) {
+	outputs := make([]string, len(calls))
+	approvals := make([]*Action, len(calls))
+
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		a.emit(Event{Type: EventAction, Round: round, Tool: call.Function.Name, Input: call.Function.Arguments})
+
+		input := json.RawMessage(call.Function.Arguments)
+		action, refusal := a.needsApproval(ctx, call.Function.Name, input, call.ID)
+		if refusal != "" {
+			outputs[i] = refusal
+			continue
+		}
+		if action != nil {
+			if a.Approve == nil {
+				approvals[i] = action
+				continue
+			}
+			if !a.Approve(action) {
+				a.Registry.Record(ctx, action.Tool, action.Input, audit.ConfirmationDenied, DeniedOutput)
+				outputs[i] = DeniedOutput
+				continue
+			}
+			// Run what the user saw, which the preview may have pinned down
+			audit.RequestFrom(ctx).Approve(action.Tool, action.Input)
+			input = action.Input
+		}
+
+		wg.Add(1)
+		go func(i int, input json.RawMessage) {
+			defer wg.Done()
+			outputs[i] = a.Registry.Invoke(ctx, call.Function.Name, input)
+		}(i, input)
+	}
+	wg.Wait()
+
+	return outputs, approvals
+}
+
+// ParseAction extracts the tool name and its JSON input from a ReAct reply.
+// The input is decoded as a complete JSON value, so nested objects are kept intact.
+func ParseAction(content string) (string, json.RawMessage, bool) {
+	action := actionRe.FindStringSubmatch(content)
+	if len(action) < 2 {
+		return "", nil, false
+	}
+
+	loc := actionInputRe.FindStringIndex(content)
+	if loc == nil {
+		return "", nil, false
+	}
+
+	var input json.RawMessage
+	if err := json.NewDecoder(strings.NewReader(content[loc[1]:])).Decode(&input); err != nil {
+		return "", nil, false
+	}
+	return strings.TrimSpace(action[1]), input, true
+}
+
+// confirmationPrompt reports whether a tool output is a pending HumanTool request.
+func confirmationPrompt(output string) (string, bool) {
+	if !strings.Contains(output, confirmationMarker) {
+		return "", false
+	}
+	matches := confirmationRe.FindStringSubmatch(output)
+	if len(matches) < 2 {
+		return "", false
+	}
+	return matches[1], true
+}
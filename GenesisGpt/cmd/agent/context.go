@@ -0,0 +1,190 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/ai"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/config"
+	"github.com/lexieqin/Geek/llm"
+	"github.com/sashabaranov/go-openai"
+)
+
+// summaryPrefix marks the system message that replaces compacted rounds.
+const summaryPrefix = "Summary of the earlier conversation:\n"
+
+const compactInstruction = `Summarize the following conversation between a user, a Kubernetes assistant and its tools.
+Keep every resource name, namespace, job ID, error message, finding and decision (including confirmations the user gave or refused).
+Drop raw tool output that is no longer needed. Answer with the summary only.`
+
+const observationInstruction = `Summarize the following output of the %s tool for a Kubernetes assistant.
+Keep every error, warning, failing resource, status and number needed to diagnose the problem. Answer with the summary only.`
+
+// ContextBudget bounds the conversation sent to the model.
+type ContextBudget struct {
+	// MaxTokens is the budget for all messages, 0 disables compaction
+	MaxTokens int
+	// ObservationMaxTokens is the largest tool output kept in the conversation, 0 keeps everything
+	ObservationMaxTokens int
+	// KeepRecentMessages is how many of the latest messages are never compacted
+	KeepRecentMessages int
+	// SummarizeObservations summarizes large tool outputs with the model instead of truncating them
+	SummarizeObservations bool
+}
+
+// BudgetFromConfig converts the context section of the config file.
+func BudgetFromConfig(cfg config.ContextConfig) ContextBudget {
+	return ContextBudget{
+		MaxTokens:             cfg.MaxTokens,
+		ObservationMaxTokens:  cfg.ObservationMaxTokens,
+		KeepRecentMessages:    cfg.KeepRecentMessages,
+		SummarizeObservations: cfg.SummarizeObservations,
+	}
+}
+
+// ensureSystemPrompt adds prompt as a system message after the leading system
+// messages, unless the conversation already has it.
+func ensureSystemPrompt(messages *ai.ChatMessages, prompt string) {
+	insertAt := 0
+	for i, m := range *messages {
+		if m.Msg.Role == ai.RoleSystem && m.Msg.Content == prompt {
+			return
+		}
+		if m.Msg.Role == ai.RoleSystem && insertAt == i && !strings.HasPrefix(m.Msg.Content, summaryPrefix) {
+			insertAt = i + 1
+		}
+	}
+
+	msg := &ai.ChatMessage{Msg: openai.ChatCompletionMessage{Role: ai.RoleSystem, Content: prompt}}
+	rebuilt := make(ai.ChatMessages, 0, len(*messages)+1)
+	rebuilt = append(rebuilt, (*messages)[:insertAt]...)
+	rebuilt = append(rebuilt, msg)
+	rebuilt = append(rebuilt, (*messages)[insertAt:]...)
+	*messages = rebuilt
+}
+
+// limitObservation shrinks a tool output that is over the observation budget,
+// either by summarizing it with the model or by cutting out its middle.
+func (a *Agent) limitObservation(ctx context.Context, tool, observation string) string {
+	limit := a.Budget.ObservationMaxTokens
+	tokens := ai.EstimateTokens(observation)
+	if limit <= 0 || tokens <= limit {
+		return observation
+	}
+
+	if a.Budget.SummarizeObservations {
+		summary := a.summarize(ctx, fmt.Sprintf(observationInstruction, tool), observation)
+		if summary != "" && ai.EstimateTokens(summary) <= limit {
+			return fmt.Sprintf("[%s output of about %d tokens, summarized]\n%s", tool, tokens, summary)
+		}
+	}
+	return ai.TruncateToTokens(observation, limit)
+}
+
+// compact keeps messages within the token budget. The leading system messages and the
+// latest KeepRecentMessages are kept, everything in between is replaced by a summary
+// written by the model. A previous summary is folded into the new one.
+func (a *Agent) compact(ctx context.Context, messages *ai.ChatMessages) {
+	if a.Budget.MaxTokens <= 0 || messages.Tokens() <= a.Budget.MaxTokens {
+		return
+	}
+
+	msgs := *messages
+	head := 0
+	for head < len(msgs) && msgs[head].Msg.Role == ai.RoleSystem && !strings.HasPrefix(msgs[head].Msg.Content, summaryPrefix) {
+		head++
+	}
+
+	tail := len(msgs) - a.Budget.KeepRecentMessages
+	// A tool result must stay right after the assistant message that called it
+	for tail > head && tail < len(msgs) && msgs[tail].Msg.Role == ai.RoleTool {
+		tail--
+	}
+
+	if tail-head >= 2 || (tail-head == 1 && !strings.HasPrefix(msgs[head].Msg.Content, summaryPrefix)) {
+		compacted := msgs[head:tail]
+		summary := a.summarize(ctx, compactInstruction, renderTranscript(compacted))
+		if summary == "" {
+			summary = fmt.Sprintf("[%d earlier messages were removed to fit the context window]", len(compacted))
+		}
+
+		msg := &ai.ChatMessage{Msg: openai.ChatCompletionMessage{Role: ai.RoleSystem, Content: summaryPrefix + summary}}
+		rebuilt := make(ai.ChatMessages, 0, head+1+len(msgs)-tail)
+		rebuilt = append(rebuilt, msgs[:head]...)
+		rebuilt = append(rebuilt, msg)
+		rebuilt = append(rebuilt, msgs[tail:]...)
+		*messages = rebuilt
+	}
+
+	// The recent messages alone may still be too large
+	a.shrink(messages, head)
+}
+
+// minShrunkTokens is the size below which shrink does not cut a message further
+const minShrunkTokens = 32
+
+// shrink cuts the largest messages after the leading system messages, summaries included,
+// until the conversation fits MaxTokens or no message can be cut any further.
+func (a *Agent) shrink(messages *ai.ChatMessages, head int) {
+	done := make(map[int]bool)
+	for excess := messages.Tokens() - a.Budget.MaxTokens; excess > 0; excess = messages.Tokens() - a.Budget.MaxTokens {
+		largest := -1
+		for i := head; i < len(*messages); i++ {
+			m := (*messages)[i]
+			if (m.Msg.Role == ai.RoleSystem && !strings.HasPrefix(m.Msg.Content, summaryPrefix)) || done[i] {
+				continue
+			}
+			if largest < 0 || m.Tokens() > (*messages)[largest].Tokens() {
+				largest = i
+			}
+		}
+		if largest < 0 {
+			return
+		}
+
+		m := (*messages)[largest]
+		tokens := ai.EstimateTokens(m.Msg.Content)
+		target := tokens - excess
+		if target < minShrunkTokens {
+			target = minShrunkTokens
+		}
+		content := ai.TruncateToTokens(m.Msg.Content, target)
+		if ai.EstimateTokens(content) >= tokens {
+			done[largest] = true
+			continue
+		}
+		// Messages may be shared with a stored session, replace rather than modify them
+		shrunk := *m
+		shrunk.Msg.Content = content
+		(*messages)[largest] = &shrunk
+	}
+}
+
+// summarize asks the model to condense text. It returns "" if the call failed,
+// callers then fall back to cutting the text.
+func (a *Agent) summarize(ctx context.Context, instruction, text string) string {
+	rsp, err := a.Provider.Chat(ctx, llm.Request{Messages: []openai.ChatCompletionMessage{
+		{Role: ai.RoleSystem, Content: instruction},
+		{Role: ai.RoleUser, Content: text},
+	}})
+	if err != nil {
+		log.Printf("summarization failed: %v", err)
+		return ""
+	}
+	return strings.TrimSpace(rsp.Content)
+}
+
+// renderTranscript turns messages into plain text for summarization.
+func renderTranscript(messages ai.ChatMessages) string {
+	var b strings.Builder
+	for _, m := range messages {
+		content := strings.TrimPrefix(m.Msg.Content, summaryPrefix)
+		fmt.Fprintf(&b, "%s: %s\n", m.Msg.Role, content)
+		for _, call := range m.Msg.ToolCalls {
+			fmt.Fprintf(&b, "%s called %s with %s\n", m.Msg.Role, call.Function.Name, call.Function.Arguments)
+		}
+	}
+	return b.String()
+}
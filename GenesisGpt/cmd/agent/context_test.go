@@ -0,0 +1,278 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/ai"
+	"github.com/lexieqin/Geek/llm"
+	"github.com/sashabaranov/go-openai"
+)
+
+func message(role, content string) *ai.ChatMessage {
+	return &ai.ChatMessage{Msg: openai.ChatCompletionMessage{Role: role, Content: content}}
+}
+
+func toolCalls(ids ...string) *ai.ChatMessage {
+	msg := &ai.ChatMessage{Msg: openai.ChatCompletionMessage{Role: ai.RoleAssistant}}
+	for _, id := range ids {
+		msg.Msg.ToolCalls = append(msg.Msg.ToolCalls, llm.ToolCall(id, "ListTool", `{"resource":"pods"}`))
+	}
+	return msg
+}
+
+func toolReply(id, content string) *ai.ChatMessage {
+	return &ai.ChatMessage{Msg: openai.ChatCompletionMessage{Role: ai.RoleTool, ToolCallID: id, Content: content}}
+}
+
+// checkToolMessages fails unless every tool message follows the assistant message calling it
+func checkToolMessages(t *testing.T, messages ai.ChatMessages) {
+	t.Helper()
+	called := map[string]bool{}
+	for i, m := range messages {
+		switch {
+		case m.Msg.Role == ai.RoleTool && !called[m.Msg.ToolCallID]:
+			t.Errorf("message %d answers tool call %s, which no preceding message makes: %s", i, m.Msg.ToolCallID, roles(messages))
+		case m.Msg.Role != ai.RoleTool:
+			called = map[string]bool{}
+			for _, call := range m.Msg.ToolCalls {
+				called[call.ID] = true
+			}
+		}
+	}
+}
+
+// roles lists the roles of messages, marking summaries
+func roles(messages ai.ChatMessages) string {
+	var names []string
+	for _, m := range messages {
+		name := m.Msg.Role
+		if strings.HasPrefix(m.Msg.Content, summaryPrefix) {
+			name = "summary"
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, " ")
+}
+
+// debugSession is a conversation of tool rounds, each large enough to exceed small budgets
+func debugSession() ai.ChatMessages {
+	output := strings.Repeat("web-5d9c-x2v   0/1   CrashLoopBackOff   12   30m\n", 10)
+	return ai.ChatMessages{
+		message(ai.RoleSystem, "You are a helpful k8s assistant!"),
+		message(ai.RoleSystem, "tool prompt"),
+		message(ai.RoleUser, "why is web failing?"),
+		toolCalls("call_1", "call_2"),
+		toolReply("call_1", output),
+		toolReply("call_2", output),
+		message(ai.RoleAssistant, "The web pods crash on start."),
+		message(ai.RoleUser, "show the logs"),
+		toolCalls("call_3"),
+		toolReply("call_3", output),
+		message(ai.RoleAssistant, "The database host cannot be resolved."),
+	}
+}
+
+func TestCompactNeverOrphansToolMessages(t *testing.T) {
+	for keep := 0; keep <= len(debugSession()); keep++ {
+		t.Run(fmt.Sprintf("keep %d", keep), func(t *testing.T) {
+			messages := debugSession()
+			a := &Agent{
+				Provider: llm.NewScripted(llm.Reply("web crashes, the database host is unknown")),
+				Budget:   ContextBudget{MaxTokens: 100, KeepRecentMessages: keep},
+			}
+			a.compact(context.Background(), &messages)
+
+			checkToolMessages(t, messages)
+			if messages[0].Msg.Content != "You are a helpful k8s assistant!" || messages[1].Msg.Content != "tool prompt" {
+				t.Errorf("compact() dropped the leading system messages: %s", roles(messages))
+			}
+		})
+	}
+}
+
+func TestCompactResummarizesSummary(t *testing.T) {
+	messages := debugSession()
+	provider := llm.NewScripted(llm.Reply("first summary"), llm.Reply("second summary"))
+	a := &Agent{Provider: provider, Budget: ContextBudget{MaxTokens: 150, KeepRecentMessages: 2}}
+
+	a.compact(context.Background(), &messages)
+	messages.AddForUser("is the database service there?")
+	messages = append(messages, toolCalls("call_4"), toolReply("call_4", strings.Repeat("No resources found in default namespace.\n", 10)))
+	messages.AddForAssistant("The database service is missing.")
+	a.compact(context.Background(), &messages)
+
+	summaries := 0
+	for _, m := range messages {
+		if strings.HasPrefix(m.Msg.Content, summaryPrefix) {
+			summaries++
+			if m.Msg.Content != summaryPrefix+"second summary" {
+				t.Errorf("summary = %q, want the second summary", m.Msg.Content)
+			}
+		}
+	}
+	if summaries != 1 {
+		t.Errorf("compact() left %d summaries, want 1: %s", summaries, roles(messages))
+	}
+	requests := provider.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("compact() asked the model %d times, want 2", len(requests))
+	}
+	transcript := requests[1].Messages[1].Content
+	if !strings.Contains(transcript, "first summary") || strings.Contains(transcript, summaryPrefix) {
+		t.Errorf("second summarization got %q, want the first summary folded in without its prefix", transcript)
+	}
+}
+
+func TestCompactShrinksOversizedMessages(t *testing.T) {
+	tests := []struct {
+		name   string
+		budget ContextBudget
+	}{
+		{name: "observation limit above the budget", budget: ContextBudget{MaxTokens: 400, ObservationMaxTokens: 3000, KeepRecentMessages: 10}},
+		{name: "no observation limit", budget: ContextBudget{MaxTokens: 400, KeepRecentMessages: 10}},
+		{name: "everything compactable", budget: ContextBudget{MaxTokens: 400, ObservationMaxTokens: 3000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := ai.ChatMessages{
+				message(ai.RoleSystem, "You are a helpful k8s assistant!"),
+				message(ai.RoleUser, "why does this fail?\n"+strings.Repeat("panic: runtime error: invalid memory address\n", 200)),
+				message(ai.RoleAssistant, strings.Repeat("容器因空指针异常而崩溃。", 300)),
+				message(ai.RoleUser, "and now?"),
+			}
+			original := messages[1]
+			a := &Agent{
+				Provider: llm.NewScripted(llm.Reply(strings.Repeat("still too long ", 2000))),
+				Budget:   tt.budget,
+			}
+			a.compact(context.Background(), &messages)
+
+			if tokens := messages.Tokens(); tokens > tt.budget.MaxTokens {
+				t.Errorf("compact() left %d tokens, want at most %d: %s", tokens, tt.budget.MaxTokens, roles(messages))
+			}
+			if !strings.HasPrefix(original.Msg.Content, "why does this fail?") || len(original.Msg.Content) < 1000 {
+				t.Error("compact() modified a message instead of replacing it")
+			}
+		})
+	}
+}
+
+func TestCompactWithinBudget(t *testing.T) {
+	messages := debugSession()
+	provider := llm.NewScripted()
+	a := &Agent{Provider: provider, Budget: ContextBudget{MaxTokens: messages.Tokens(), KeepRecentMessages: 2}}
+	a.compact(context.Background(), &messages)
+	if len(messages) != len(debugSession()) || len(provider.Requests()) != 0 {
+		t.Errorf("compact() changed a conversation within the budget: %s", roles(messages))
+	}
+}
+
+func TestCompactWithoutModel(t *testing.T) {
+	messages := debugSession()
+	a := &Agent{
+		Provider: llm.NewScripted(llm.Fail(errors.New("model unavailable"))),
+		Budget:   ContextBudget{MaxTokens: 150, KeepRecentMessages: 2},
+	}
+	a.compact(context.Background(), &messages)
+	if !strings.Contains(messages[2].Msg.Content, "earlier messages were removed") {
+		t.Errorf("compact() without a summary = %s, want a removal note", roles(messages))
+	}
+	checkToolMessages(t, messages)
+}
+
+func TestLimitObservation(t *testing.T) {
+	output := strings.Repeat("Warning  BackOff  pod/web-5d9c-x2v  Back-off restarting failed container\n", 100)
+
+	tests := []struct {
+		name      string
+		budget    ContextBudget
+		steps     []llm.Step
+		want      string
+		wantLimit bool
+	}{
+		{name: "no limit", budget: ContextBudget{}, want: output},
+		{name: "within the limit", budget: ContextBudget{ObservationMaxTokens: 10000}, want: output},
+		{name: "truncated", budget: ContextBudget{ObservationMaxTokens: 200}, wantLimit: true},
+		{
+			name:   "summarized",
+			budget: ContextBudget{ObservationMaxTokens: 200, SummarizeObservations: true},
+			steps:  []llm.Step{llm.Reply("web-5d9c-x2v is in CrashLoopBackOff")},
+			want:   "[EventTool output of about 1825 tokens, summarized]\nweb-5d9c-x2v is in CrashLoopBackOff",
+		},
+		{
+			name:      "summary too long",
+			budget:    ContextBudget{ObservationMaxTokens: 200, SummarizeObservations: true},
+			steps:     []llm.Step{llm.Reply(output)},
+			wantLimit: true,
+		},
+		{
+			name:      "summarization failed",
+			budget:    ContextBudget{ObservationMaxTokens: 200, SummarizeObservations: true},
+			steps:     []llm.Step{llm.Fail(errors.New("model unavailable"))},
+			wantLimit: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Agent{Provider: llm.NewScripted(tt.steps...), Budget: tt.budget}
+			got := a.limitObservation(context.Background(), "EventTool", output)
+			if tt.wantLimit {
+				if tokens := ai.EstimateTokens(got); tokens > tt.budget.ObservationMaxTokens || !strings.Contains(got, "[truncated ") {
+					t.Errorf("limitObservation() = %d tokens without a truncation marker, want at most %d", tokens, tt.budget.ObservationMaxTokens)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("limitObservation() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnsureSystemPrompt(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages ai.ChatMessages
+		want     string
+	}{
+		{
+			name:     "after the leading system messages",
+			messages: ai.ChatMessages{message(ai.RoleSystem, "persona"), message(ai.RoleUser, "hi")},
+			want:     "system:persona system:prompt user:hi",
+		},
+		{
+			name:     "before a summary",
+			messages: ai.ChatMessages{message(ai.RoleSystem, "persona"), message(ai.RoleSystem, summaryPrefix+"earlier"), message(ai.RoleUser, "hi")},
+			want:     "system:persona system:prompt system:" + summaryPrefix + "earlier user:hi",
+		},
+		{
+			name:     "already there",
+			messages: ai.ChatMessages{message(ai.RoleSystem, "persona"), message(ai.RoleSystem, "prompt"), message(ai.RoleUser, "hi")},
+			want:     "system:persona system:prompt user:hi",
+		},
+		{
+			name:     "empty conversation",
+			messages: ai.ChatMessages{},
+			want:     "system:prompt",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ensureSystemPrompt(&tt.messages, "prompt")
+			var got []string
+			for _, m := range tt.messages {
+				got = append(got, m.Msg.Role+":"+m.Msg.Content)
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("ensureSystemPrompt() = %q, want %q", strings.Join(got, " "), tt.want)
+			}
+		})
+	}
+}
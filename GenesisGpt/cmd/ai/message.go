@@ -1,53 +1,9 @@
 package ai
 
 import (
-	"context"
-	"log"
-	"os"
-
 	openai "github.com/sashabaranov/go-openai"
 )
 
-var MessageStore ChatMessages
-
-func init() {
-	MessageStore = make(ChatMessages, 0)
-	MessageStore.Clear() // Clean and initialize
-}
-
-func NewOpenAiClient() *openai.Client {
-	// Get API token from environment variable
-	token := os.Getenv("OPENAI_API_KEY")
-	if token == "" {
-		log.Fatal("OPENAI_API_KEY environment variable is required")
-	}
-	
-	dashscope_url := os.Getenv("OPENAI_BASE_URL")
-	if dashscope_url == "" {
-		dashscope_url = "https://dashscope.aliyuncs.com/compatible-mode/v1"
-	}
-
-	config := openai.DefaultConfig(token)
-	config.BaseURL = dashscope_url
-
-	return openai.NewClientWithConfig(config)
-}
-
-// NormalChat handles the chat conversation
-func NormalChat(message []openai.ChatCompletionMessage) openai.ChatCompletionMessage {
-	c := NewOpenAiClient()
-	rsp, err := c.CreateChatCompletion(context.TODO(), openai.ChatCompletionRequest{
-		Model:    "qwen-max",
-		Messages: message,
-	})
-	if err != nil {
-		log.Println(err)
-		return openai.ChatCompletionMessage{}
-	}
-
-	return rsp.Choices[0].Message
-}
-
 // Define chat model
 type ChatMessages []*ChatMessage
 type ChatMessage struct {
@@ -62,6 +18,14 @@ const (
 	RoleTool      = "tool"
 )
 
+// NewChatMessages creates a conversation holding only the system prompt.
+// Every conversation owns its own ChatMessages, there is no shared store.
+func NewChatMessages() ChatMessages {
+	cm := make(ChatMessages, 0)
+	cm.Clear() // Clean and initialize
+	return cm
+}
+
 // Define personality
 func (cm *ChatMessages) Clear() {
 	*cm = make([]*ChatMessage, 0) // Reinitialize
@@ -93,6 +57,29 @@ func (cm *ChatMessages) AddForAssistant(msg string) {
 	cm.AddFor(msg, RoleAssistant)
 }
 
+// Add Assistant role message carrying the model's tool calls
+func (cm *ChatMessages) AddForToolCall(rsp openai.ChatCompletionMessage) {
+	*cm = append(*cm, &ChatMessage{
+		Msg: openai.ChatCompletionMessage{
+			Role:      RoleAssistant,
+			Content:   rsp.Content,
+			ToolCalls: rsp.ToolCalls,
+		},
+	})
+}
+
+// Add Tool role message answering the tool call toolCallID
+func (cm *ChatMessages) AddForTool(msg string, name string, toolCallID string) {
+	*cm = append(*cm, &ChatMessage{
+		Msg: openai.ChatCompletionMessage{
+			Role:       RoleTool,
+			Content:    msg,
+			Name:       name,
+			ToolCallID: toolCallID,
+		},
+	})
+}
+
 // Assemble prompt
 func (cm *ChatMessages) ToMessage() []openai.ChatCompletionMessage {
 	ret := make([]openai.ChatCompletionMessage, len(*cm))
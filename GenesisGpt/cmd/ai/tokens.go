@@ -0,0 +1,100 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// messageOverhead is the per-message cost of the role and separators in the chat format.
+const messageOverhead = 4
+
+// EstimateTokens approximates how many tokens the model needs for s: about four
+// characters per token for ASCII text and one token per character otherwise, which
+// is close enough for CJK text. It errs on the high side so budgets stay safe.
+func EstimateTokens(s string) int {
+	ascii, other := 0, 0
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// Tokens estimates the size of the message including its tool calls.
+func (m *ChatMessage) Tokens() int {
+	n := messageOverhead + EstimateTokens(m.Msg.Content)
+	for _, call := range m.Msg.ToolCalls {
+		n += EstimateTokens(call.ID) + EstimateTokens(call.Function.Name) + EstimateTokens(call.Function.Arguments)
+	}
+	return n
+}
+
+// Tokens estimates the size of the whole conversation.
+func (cm ChatMessages) Tokens() int {
+	n := 0
+	for _, m := range cm {
+		n += m.Tokens()
+	}
+	return n
+}
+
+// truncationMarker replaces the middle of a truncated text
+const truncationMarker = "\n... [truncated %d lines, about %d tokens] ...\n"
+
+// TruncateToTokens shortens s to at most maxTokens by keeping its beginning and end
+// and replacing the middle with a marker. Lines are kept whole where possible,
+// since errors usually show up at the start or the end of tool output. A maxTokens
+// too small for the marker alone still keeps a few characters on each side of it.
+func TruncateToTokens(s string, maxTokens int) string {
+	total := EstimateTokens(s)
+	if total <= maxTokens || maxTokens <= 0 {
+		return s
+	}
+
+	runes := []rune(s)
+	// Leave room for the marker, counting as many lines and tokens as it can report
+	keep := maxTokens - EstimateTokens(fmt.Sprintf(truncationMarker, len(runes), total))
+	if keep < 2 {
+		keep = 2
+	}
+	headLen := fitRunes(runes, keep*2/3, false)
+	tailLen := fitRunes(runes, keep-keep*2/3, true)
+
+	head := string(runes[:headLen])
+	tail := string(runes[len(runes)-tailLen:])
+	if i := strings.LastIndex(head, "\n"); i > len(head)*4/5 {
+		head = head[:i+1]
+	}
+	if i := strings.Index(tail, "\n"); i >= 0 && i < len(tail)/5 {
+		tail = tail[i+1:]
+	}
+
+	omitted := string(runes[utf8.RuneCountInString(head) : len(runes)-utf8.RuneCountInString(tail)])
+	return strings.TrimRight(head, "\n") +
+		fmt.Sprintf(truncationMarker, strings.Count(omitted, "\n")+1, EstimateTokens(omitted)) + tail
+}
+
+// fitRunes returns how many runes, counted from the start of runes or with fromEnd from
+// its end, fit in maxTokens
+func fitRunes(runes []rune, maxTokens int, fromEnd bool) int {
+	ascii, other := 0, 0
+	for n := range runes {
+		r := runes[n]
+		if fromEnd {
+			r = runes[len(runes)-1-n]
+		}
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+		if (ascii+3)/4+other > maxTokens {
+			return n
+		}
+	}
+	return len(runes)
+}
@@ -0,0 +1,93 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"pod", 1},
+		{"kubectl get pods", 4},
+		{"容器重启", 4},
+		{"pod 重启", 3},
+		{"🚀", 1},
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.s); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateToTokens(t *testing.T) {
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, fmt.Sprintf("line %03d: container web restarted", i))
+	}
+	logs := strings.Join(lines, "\n")
+
+	tests := []struct {
+		name      string
+		s         string
+		maxTokens int
+	}{
+		{name: "log lines", s: logs, maxTokens: 300},
+		{name: "one long line", s: strings.Repeat("x", 5000), maxTokens: 100},
+		{name: "chinese", s: strings.Repeat("容器启动失败，镜像拉取超时。", 100), maxTokens: 100},
+		{name: "emoji", s: strings.Repeat("🚀 deploy ", 300), maxTokens: 80},
+		// An ASCII start and a multi-byte end make the average characters per token a bad
+		// guess for either side
+		{name: "mixed", s: strings.Repeat("a", 2000) + strings.Repeat("错", 500), maxTokens: 120},
+		{name: "budget below the marker", s: strings.Repeat("错误", 100), maxTokens: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TruncateToTokens(tt.s, tt.maxTokens)
+			if !utf8.ValidString(got) {
+				t.Fatalf("TruncateToTokens() split a multi-byte character: %q", got)
+			}
+			marker := strings.Index(got, "\n... [truncated ")
+			if marker < 0 {
+				t.Fatalf("TruncateToTokens() = %q, want a truncation marker", got)
+			}
+			end := strings.Index(got[marker:], "] ...\n") + marker + len("] ...\n")
+			head, tail := got[:marker], got[end:]
+			if head == "" || tail == "" || !strings.HasPrefix(tt.s, head) || !strings.HasSuffix(tt.s, tail) {
+				t.Errorf("TruncateToTokens() kept %q and %q, want the start and end of the text", head, tail)
+			}
+			markerTokens := EstimateTokens(got[marker:end])
+			if tokens := EstimateTokens(got); tokens > tt.maxTokens && tokens > markerTokens+4 {
+				t.Errorf("TruncateToTokens() = %d tokens, want at most %d", tokens, tt.maxTokens)
+			}
+		})
+	}
+
+	if got := TruncateToTokens(logs, 0); got != logs {
+		t.Error("TruncateToTokens() with no limit changed the text")
+	}
+	short := "kubectl get pods"
+	if got := TruncateToTokens(short, 4); got != short {
+		t.Errorf("TruncateToTokens(%q, 4) = %q, want it unchanged", short, got)
+	}
+}
+
+func TestTruncateToTokensKeepsWholeLines(t *testing.T) {
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("%03d Back-off restarting failed container", i))
+	}
+	got := TruncateToTokens(strings.Join(lines, "\n"), 200)
+	for _, line := range strings.Split(got, "\n") {
+		if line != "" && !strings.HasPrefix(line, "... [truncated") && !strings.HasSuffix(line, "container") {
+			t.Errorf("TruncateToTokens() cut the line %q", line)
+		}
+	}
+}
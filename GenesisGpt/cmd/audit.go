@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/audit"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/config"
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Query the audit log of tool invocations",
+	Long: `Query the audit log of tool invocations by session, tool or time range.
+
+--since and --until take an RFC 3339 time, a date (YYYY-MM-DD) or a duration
+meaning that long ago, e.g. "GenesisGpt audit --tool DeleteTool --since 24h".`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, _ := cmd.Flags().GetString("file")
+		if path == "" {
+			cfg, err := config.LoadConfig()
+			if err != nil {
+				return err
+			}
+			path = defaultString(cfg.Audit.Path, audit.DefaultPath)
+		}
+
+		var filter audit.Filter
+		filter.SessionID, _ = cmd.Flags().GetString("session")
+		filter.Tool, _ = cmd.Flags().GetString("tool")
+
+		now := time.Now()
+		for flag, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+			value, _ := cmd.Flags().GetString(flag)
+			if value == "" {
+				continue
+			}
+			parsed, err := audit.ParseTime(value, now)
+			if err != nil {
+				return fmt.Errorf("--%s: %v", flag, err)
+			}
+			*t = parsed
+		}
+
+		entries, err := audit.Read(path, filter)
+		if err != nil {
+			return err
+		}
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			for _, e := range entries {
+				if err := encoder.Encode(e); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		printAuditEntries(cmd.OutOrStdout(), entries)
+		return nil
+	},
+}
+
+// printAuditEntries renders entries as a table, one line per tool call.
+func printAuditEntries(out io.Writer, entries []audit.Entry) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tSESSION\tUSER\tTOOL\tCONFIRMATION\tSTATUS\tDURATION\tINPUT")
+	for _, e := range entries {
+		status := "-"
+		if e.Status != 0 {
+			status = fmt.Sprint(e.Status)
+		}
+		if e.Error != "" {
+			status += " (error)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%dms\t%s\n",
+			e.Time.Local().Format(time.DateTime), e.SessionID, defaultString(e.User, "-"), e.Tool,
+			defaultString(e.Confirmation, "-"), status, e.DurationMs, e.Input)
+	}
+	w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+
+	auditCmd.Flags().String("file", "", "Audit log to read (default from config)")
+	auditCmd.Flags().String("session", "", "Only show calls made in this session")
+	auditCmd.Flags().String("tool", "", "Only show calls of this tool, e.g. DeleteTool")
+	auditCmd.Flags().String("since", "", "Only show calls at or after this time")
+	auditCmd.Flags().String("until", "", "Only show calls at or before this time")
+	auditCmd.Flags().Bool("json", false, "Print the matching entries as JSON lines")
+}
@@ -0,0 +1,257 @@
+// Package audit keeps an append-only JSON lines record of every tool the agent runs,
+// with who asked for it, what the model passed and what the ginTools API answered.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/config"
+)
+
+// DefaultPath is where the log is written when the config names no file.
+const DefaultPath = "audit/audit.jsonl"
+
+// maxOutputLen bounds the tool output kept in an entry, logs can be megabytes.
+const maxOutputLen = 1024
+
+// truncateOutput cuts output to at most maxOutputLen bytes, on a character boundary so
+// the entry stays valid UTF-8.
+func truncateOutput(output string) string {
+	if len(output) <= maxOutputLen {
+		return output
+	}
+	cut := maxOutputLen
+	for cut > 0 && !utf8.RuneStart(output[cut]) {
+		cut--
+	}
+	return output[:cut] + "... [truncated]"
+}
+
+// Confirmation values of entries. HumanTool entries record the user's answer instead.
+const (
+	// ConfirmationPending is recorded while a question or an action waits for the user
+	ConfirmationPending  = "pending"
+	ConfirmationApproved = "approved"
+	ConfirmationDenied   = "denied"
+	// ConfirmationExpired is recorded when an action was not answered in time
+	ConfirmationExpired = "expired"
+)
+
+// Entry is one tool invocation.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	SessionID string    `json:"sessionId,omitempty"`
+	// User is the client that sent the query, as far as it is known
+	User  string `json:"user,omitempty"`
+	Query string `json:"query,omitempty"`
+	Tool  string `json:"tool"`
+	// Input is the raw action input chosen by the model
+	Input json.RawMessage `json:"input,omitempty"`
+	// Confirmation is the user's answer: the HumanTool answer, or the decision on an action
+	Confirmation string `json:"confirmation,omitempty"`
+	// Status is the HTTP status of the last ginTools request made by the tool
+	Status     int    `json:"status,omitempty"`
+	Output     string `json:"output,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// Log appends entries to a file and optionally forwards them to a webhook.
+// A nil *Log records nothing.
+type Log struct {
+	mu      sync.Mutex
+	file    *os.File
+	path    string
+	webhook *webhook
+	closed  bool
+}
+
+// Open opens the log configured in cfg. It returns nil when auditing is disabled.
+func Open(cfg config.AuditConfig) (*Log, error) {
+	if cfg.Disabled {
+		return nil, nil
+	}
+
+	path := cfg.Path
+	if path == "" {
+		path = DefaultPath
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %v", err)
+	}
+
+	l := &Log{file: file, path: path}
+	if cfg.WebhookURL != "" {
+		l.webhook = newWebhook(cfg.WebhookURL, cfg.WebhookTimeout)
+	}
+	return l, nil
+}
+
+// Path returns the file the log is written to.
+func (l *Log) Path() string {
+	if l == nil {
+		return ""
+	}
+	return l.path
+}
+
+// Record appends e to the log. Failures are logged rather than returned, an audit
+// problem must not fail the user's request.
+func (l *Log) Record(e Entry) {
+	if l == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+	e.Output = truncateOutput(e.Output)
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("audit: %v", err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return
+	}
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		log.Printf("audit: failed to write entry: %v", err)
+	}
+	if l.webhook != nil {
+		l.webhook.send(data)
+	}
+}
+
+// Close flushes pending webhook deliveries and closes the file.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	if l.webhook != nil {
+		l.webhook.close()
+	}
+	return l.file.Close()
+}
+
+// Request describes the query a tool runs for. It travels in the context so the
+// registry can attribute every tool call.
+type Request struct {
+	SessionID string
+	User      string
+	Query     string
+
+	mu       sync.Mutex
+	approved map[string]bool
+}
+
+type requestKey struct{}
+
+// WithRequest attaches req to ctx.
+func WithRequest(ctx context.Context, req *Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, req)
+}
+
+// RequestFrom returns the request attached to ctx, or nil.
+func RequestFrom(ctx context.Context) *Request {
+	req, _ := ctx.Value(requestKey{}).(*Request)
+	return req
+}
+
+// Approve records that the user approved calling tool with exactly input.
+func (r *Request) Approve(tool string, input json.RawMessage) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.approved == nil {
+		r.approved = make(map[string]bool)
+	}
+	r.approved[actionKey(tool, input)] = true
+}
+
+// Approved reports whether the user approved calling tool with exactly input.
+func (r *Request) Approved(tool string, input json.RawMessage) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.approved[actionKey(tool, input)]
+}
+
+// actionKey identifies a call; insignificant whitespace in the input is ignored.
+func actionKey(tool string, input json.RawMessage) string {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, input); err != nil {
+		return tool + "\x00" + string(input)
+	}
+	return tool + "\x00" + compact.String()
+}
+
+type userKey struct{}
+
+// WithUser attaches the identity of the client sending the query to ctx.
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFrom returns the user attached by WithUser, or "".
+func UserFrom(ctx context.Context) string {
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
+
+type callKey struct{}
+
+// call collects what the HTTP helpers observe while one tool runs.
+type call struct {
+	mu     sync.Mutex
+	status int
+}
+
+// StartCall returns a context in which the HTTP status of ginTools requests is captured
+// for the tool call, and a function returning the last status seen.
+func StartCall(ctx context.Context) (context.Context, func() int) {
+	c := &call{}
+	return context.WithValue(ctx, callKey{}, c), func() int {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.status
+	}
+}
+
+// SetStatus records the status of an HTTP response received while running a tool.
+func SetStatus(ctx context.Context, status int) {
+	c, ok := ctx.Value(callKey{}).(*call)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.status = status
+}
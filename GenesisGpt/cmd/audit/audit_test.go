@@ -0,0 +1,192 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/config"
+)
+
+func TestRecordAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+	l, err := Open(config.AuditConfig{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	l.Record(Entry{Time: start, SessionID: "a", Tool: "ListTool", Input: json.RawMessage(`{"resource":"pod"}`), Status: 200})
+	l.Record(Entry{Time: start.Add(time.Hour), SessionID: "a", Tool: "DeleteTool", Confirmation: "yes", Status: 200})
+	l.Record(Entry{Time: start.Add(2 * time.Hour), SessionID: "b", Tool: "DeleteTool", Output: strings.Repeat("x", 2*maxOutputLen)})
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"all", Filter{}, []string{"a ListTool", "a DeleteTool", "b DeleteTool"}},
+		{"session", Filter{SessionID: "a"}, []string{"a ListTool", "a DeleteTool"}},
+		{"tool", Filter{Tool: "DeleteTool"}, []string{"a DeleteTool", "b DeleteTool"}},
+		{"time range", Filter{Since: start.Add(30 * time.Minute), Until: start.Add(90 * time.Minute)}, []string{"a DeleteTool"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := Read(path, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.SessionID+" "+e.Tool)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Read() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	entries, _ := Read(path, Filter{SessionID: "b"})
+	if n := len(entries[0].Output); n > maxOutputLen+len("... [truncated]") {
+		t.Errorf("output of %d bytes was not truncated", n)
+	}
+}
+
+func TestTruncateOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{name: "short", output: "pod/web deleted", want: "pod/web deleted"},
+		{name: "exactly the limit", output: strings.Repeat("x", maxOutputLen), want: strings.Repeat("x", maxOutputLen)},
+		{name: "ascii", output: strings.Repeat("x", maxOutputLen+1), want: strings.Repeat("x", maxOutputLen) + "... [truncated]"},
+		// "错" is 3 bytes, so the limit falls inside the character after 341 of them
+		{name: "multi-byte", output: strings.Repeat("错", maxOutputLen), want: strings.Repeat("错", maxOutputLen/3) + "... [truncated]"},
+		{name: "emoji after ascii", output: "x" + strings.Repeat("🚀", maxOutputLen), want: "x" + strings.Repeat("🚀", (maxOutputLen-1)/4) + "... [truncated]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateOutput(tt.output)
+			if got != tt.want {
+				t.Errorf("truncateOutput() = %d bytes, want %d bytes", len(got), len(tt.want))
+			}
+			if !utf8.ValidString(got) {
+				t.Error("truncateOutput() split a character")
+			}
+		})
+	}
+}
+
+func TestRecordAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	for i := 0; i < 2; i++ {
+		l, err := Open(config.AuditConfig{Path: path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.Record(Entry{Tool: "ListTool"})
+		l.Close()
+	}
+
+	entries, err := Read(path, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("got %d entries after reopening, want 2", len(entries))
+	}
+}
+
+func TestWebhookForwardsEntries(t *testing.T) {
+	var mu sync.Mutex
+	var received []Entry
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var e Entry
+		if err := json.Unmarshal(body, &e); err != nil {
+			t.Errorf("webhook body is not an entry: %v", err)
+		}
+		mu.Lock()
+		received = append(received, e)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	l, err := Open(config.AuditConfig{Path: filepath.Join(t.TempDir(), "audit.jsonl"), WebhookURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Record(Entry{SessionID: "a", Tool: "DeleteTool"})
+	l.Record(Entry{SessionID: "a", Tool: "CreateTool"})
+	// Close waits for the queued deliveries
+	l.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 || received[0].Tool != "DeleteTool" || received[1].Tool != "CreateTool" {
+		t.Errorf("webhook received %+v, want the DeleteTool and CreateTool entries", received)
+	}
+}
+
+func TestDisabledLogRecordsNothing(t *testing.T) {
+	l, err := Open(config.AuditConfig{Disabled: true})
+	if err != nil || l != nil {
+		t.Fatalf("Open() = %v, %v, want nil, nil", l, err)
+	}
+	// A nil log must be usable
+	l.Record(Entry{Tool: "ListTool"})
+	if err := l.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"2h", now.Add(-2 * time.Hour)},
+		{"2024-04-30T08:00:00Z", time.Date(2024, 4, 30, 8, 0, 0, 0, time.UTC)},
+		{"2024-04-30", time.Date(2024, 4, 30, 0, 0, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		got, err := ParseTime(tt.in, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("ParseTime(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := ParseTime("yesterday", now); err == nil {
+		t.Error("ParseTime(\"yesterday\") succeeded, want an error")
+	}
+}
+
+func TestRequestApprovesExactCall(t *testing.T) {
+	req := &Request{}
+	req.Approve("DeleteTool", json.RawMessage(`{"resource":"pod", "name":"a"}`))
+
+	if !req.Approved("DeleteTool", json.RawMessage(`{"resource":"pod","name":"a"}`)) {
+		t.Error("approval should ignore whitespace in the input")
+	}
+	if req.Approved("DeleteTool", json.RawMessage(`{"resource":"pod","name":"b"}`)) {
+		t.Error("approval applied to a different input")
+	}
+	if req.Approved("CreateTool", json.RawMessage(`{"resource":"pod","name":"a"}`)) {
+		t.Error("approval applied to a different tool")
+	}
+	var none *Request
+	if none.Approved("DeleteTool", json.RawMessage(`{}`)) {
+		t.Error("nil request approved a call")
+	}
+}
@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWebhookTimeout = 5 * time.Second
+	// webhookQueue is how many entries may wait for delivery before new ones are dropped
+	webhookQueue = 256
+)
+
+// webhook posts every entry as JSON from a background goroutine, so a slow
+// receiver never delays a tool call. The file stays the record of truth.
+type webhook struct {
+	url    string
+	client *http.Client
+	queue  chan []byte
+	done   sync.WaitGroup
+}
+
+func newWebhook(url string, timeout time.Duration) *webhook {
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	w := &webhook{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+		queue:  make(chan []byte, webhookQueue),
+	}
+	w.done.Add(1)
+	go w.run()
+	return w
+}
+
+func (w *webhook) send(data []byte) {
+	select {
+	case w.queue <- data:
+	default:
+		log.Printf("audit: webhook queue full, entry not forwarded")
+	}
+}
+
+func (w *webhook) run() {
+	defer w.done.Done()
+	for data := range w.queue {
+		if err := w.post(data); err != nil {
+			log.Printf("audit: webhook: %v", err)
+		}
+	}
+}
+
+func (w *webhook) post(data []byte) error {
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// close delivers the queued entries and stops the goroutine.
+func (w *webhook) close() {
+	close(w.queue)
+	w.done.Wait()
+}
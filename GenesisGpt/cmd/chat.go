@@ -5,14 +5,19 @@ package cmd
 
 import (
 	"bufio"
-	"encoding/json"
+	"context"
 	"fmt"
-	"regexp"
+	"os"
+	"time"
 
-	"github.com/spf13/cobra"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/agent"
 	"github.com/lexieqin/Geek/GenesisGpt/cmd/ai"
-	"github.com/lexieqin/Geek/GenesisGpt/cmd/promptTpl"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/audit"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/config"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/policy"
 	"github.com/lexieqin/Geek/GenesisGpt/cmd/tools"
+	"github.com/lexieqin/Geek/llm"
+	"github.com/spf13/cobra"
 )
 
 // chatCmd represents the chat command
@@ -25,19 +30,55 @@ and usage of using your command. For example:
 Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		createTool := tools.NewCreateTool()
-		listTool := tools.NewListTool()
-		deleteTool := tools.NewDeleteTool()
-		humanTool := tools.NewHumanTool()
-		clustersTool := tools.NewClusterTool()
-		podTool := tools.NewPodTool()
-		resourceInfoTool := tools.NewResourceInfoTool()
-		jobDebugTool := tools.NewJobDebugTool()
-		sandboxLogTool := tools.NewSandboxLogTool()
-		intelligentDebugTool := tools.NewIntelligentDebugTool()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		modeFlag, _ := cmd.Flags().GetString("mode")
+		mode, err := agent.ParseMode(modeFlag)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			return err
+		}
+		provider, err := llm.New(cfg.LLM)
+		if err != nil {
+			return err
+		}
+
+		auditLog, err := audit.Open(cfg.Audit)
+		if err != nil {
+			return err
+		}
+		defer auditLog.Close()
 
+		toolPolicy, err := loadPolicy(cmd, cfg.Policy)
+		if err != nil {
+			return err
+		}
+
+		registry := tools.NewDefaultRegistry(provider)
+		registry.SetAuditLog(auditLog)
+		registry.SetPolicy(toolPolicy)
+		if toolPolicy.ReadOnly {
+			fmt.Println("Read-only mode: tools that change the cluster are disabled.")
+		}
 		scanner := bufio.NewScanner(cmd.InOrStdin())
+		chatAgent := agent.New(provider, registry, mode)
+		chatAgent.OnEvent = printEvent
+		chatAgent.Approve = func(action *agent.Action) bool {
+			if action.Preview != "" {
+				fmt.Println(action.Preview)
+				fmt.Printf("%s. Run %s? (yes/no) ", action.Reason, action.Tool)
+			} else {
+				fmt.Printf("%s. Run %s %s? (yes/no) ", action.Reason, action.Tool, action.Input)
+			}
+			return scanner.Scan() && policy.Approved(scanner.Text())
+		}
+		messages := ai.NewChatMessages()
+		sessionID := fmt.Sprintf("cli-%d", time.Now().UnixNano())
+		user := os.Getenv("USER")
+
 		fmt.Println("Hello, I am your K8s assistant. How can I help you? (Type 'exit' to quit):")
 		for {
 			fmt.Print("> ")
@@ -47,141 +88,34 @@ to quickly create a Cobra application.`,
 			input := scanner.Text()
 			if input == "exit" {
 				fmt.Println("Goodbye!")
-				return
+				return nil
 			}
 
-			prompt := buildPrompt(createTool, listTool, deleteTool, humanTool, clustersTool, podTool, resourceInfoTool, jobDebugTool, sandboxLogTool, intelligentDebugTool, input)
-			ai.MessageStore.AddForUser(prompt)
-			i := 1
-			for {
-				first_response := ai.NormalChat(ai.MessageStore.ToMessage())
-				fmt.Printf("========Round %d Response========\n", i)
-				fmt.Println(first_response.Content)
-
-				regexPattern := regexp.MustCompile(`Final Answer:\s*(.*)`)
-				finalAnswer := regexPattern.FindStringSubmatch(first_response.Content)
-				if len(finalAnswer) > 1 {
-					fmt.Println("========Final GPT Response========")
-					fmt.Println(first_response.Content)
-					break
-				}
-
-				ai.MessageStore.AddForAssistant(first_response.Content)
-
-				regexAction := regexp.MustCompile(`Action:\s*(.*?)[\n]`)
-				regexActionInput := regexp.MustCompile(`Action Input:\s*({[\s\S]*?})`)
-
-				action := regexAction.FindStringSubmatch(first_response.Content)
-				actionInput := regexActionInput.FindStringSubmatch(first_response.Content)
-
-				if len(action) > 1 && len(actionInput) > 1 {
-					i++
-					Observation := "Observation: %s"
-					if action[1] == createTool.Name {
-						var param tools.CreateToolParam
-						_ = json.Unmarshal([]byte(actionInput[1]), &param)
-
-						output := createTool.Run(param.Prompt, param.Resource)
-						Observation = fmt.Sprintf(Observation, output)
-					} else if action[1] == listTool.Name {
-						var param tools.ListToolParam
-						_ = json.Unmarshal([]byte(actionInput[1]), &param)
-
-						output, _ := listTool.Run(param.Resource, param.Namespace, param.Name, param.Type)
-						Observation = fmt.Sprintf(Observation, output)
-					} else if action[1] == deleteTool.Name {
-						var param tools.DeleteToolParam
-						_ = json.Unmarshal([]byte(actionInput[1]), &param)
-
-						err := deleteTool.Run(param.Resource, param.Name, param.Namespace)
-						if err != nil {
-							Observation = fmt.Sprintf(Observation, "Deletion failed")
-						} else {
-							Observation = fmt.Sprintf(Observation, "Deletion successful")
-						}
-					} else if action[1] == humanTool.Name {
-						var param tools.HumanToolParam
-						_ = json.Unmarshal([]byte(actionInput[1]), &param)
-
-						output := humanTool.Run(param.Prompt)
-						Observation = fmt.Sprintf(Observation, output)
-					} else if action[1] == clustersTool.Name {
-						output, _ := clustersTool.Run()
-						Observation = fmt.Sprintf(Observation, output)
-					} else if action[1] == podTool.Name {
-						var param tools.PodToolParam
-						_ = json.Unmarshal([]byte(actionInput[1]), &param)
-
-						output, err := podTool.Run(param)
-						if err != nil {
-							Observation = fmt.Sprintf(Observation, "Error: "+err.Error())
-						} else {
-							Observation = fmt.Sprintf(Observation, output)
-						}
-					} else if action[1] == resourceInfoTool.Name {
-						var param tools.ResourceInfoToolParam
-						_ = json.Unmarshal([]byte(actionInput[1]), &param)
-
-						output, err := resourceInfoTool.Run(param)
-						if err != nil {
-							Observation = fmt.Sprintf(Observation, "Error: "+err.Error())
-						} else {
-							Observation = fmt.Sprintf(Observation, output)
-						}
-					} else if action[1] == jobDebugTool.Name() {
-						output, err := jobDebugTool.Run(actionInput[1])
-						if err != nil {
-							Observation = fmt.Sprintf(Observation, "Error: "+err.Error())
-						} else {
-							Observation = fmt.Sprintf(Observation, output)
-						}
-					} else if action[1] == sandboxLogTool.Name() {
-						output, err := sandboxLogTool.Run(actionInput[1])
-						if err != nil {
-							Observation = fmt.Sprintf(Observation, "Error: "+err.Error())
-						} else {
-							Observation = fmt.Sprintf(Observation, output)
-						}
-					} else if action[1] == intelligentDebugTool.Name() {
-						output, err := intelligentDebugTool.Run(actionInput[1])
-						if err != nil {
-							Observation = fmt.Sprintf(Observation, "Error: "+err.Error())
-						} else {
-							Observation = fmt.Sprintf(Observation, output)
-						}
-					}
-
-					prompt = first_response.Content + Observation
-					fmt.Printf("========Round %d Prompt========\n", i)
-					fmt.Println(prompt)
-					ai.MessageStore.AddForUser(prompt)
-				}
+			ctx := audit.WithRequest(context.Background(), &audit.Request{SessionID: sessionID, User: user, Query: input})
+			if _, err := chatAgent.Run(ctx, &messages, input); err != nil {
+				fmt.Println("Error:", err)
 			}
 		}
+		return nil
 	},
 }
 
-func buildPrompt(createTool *tools.CreateTool, listTool *tools.ListTool, deleteTool *tools.DeleteTool, humanTool *tools.HumanTool, clustersTool *tools.ClusterTool, podTool *tools.PodTool, resourceInfoTool *tools.ResourceInfoTool, jobDebugTool *tools.JobDebugTool, sandboxLogTool *tools.SandboxLogTool, intelligentDebugTool *tools.IntelligentDebugTool, query string) string {
-	createToolDef := "Name: " + createTool.Name + "\nDescription: " + createTool.Description + "\nArgsSchema: " + createTool.ArgsSchema + "\n"
-	listToolDef := "Name: " + listTool.Name + "\nDescription: " + listTool.Description + "\nArgsSchema: " + listTool.ArgsSchema + "\n"
-	deleteToolDef := "Name: " + deleteTool.Name + "\nDescription: " + deleteTool.Description + "\nArgsSchema: " + deleteTool.ArgsSchema + "\n"
-	humanToolDef := "Name: " + humanTool.Name + "\nDescription: " + humanTool.Description + "\nArgsSchema: " + humanTool.ArgsSchema + "\n"
-	clusterToolDef := "Name: " + clustersTool.Name + "\nDescription: " + clustersTool.Description + "\n"
-	podToolDef := "Name: " + podTool.Name + "\nDescription: " + podTool.Description + "\nArgsSchema: " + podTool.ArgsSchema + "\n"
-	resourceInfoToolDef := "Name: " + resourceInfoTool.Name + "\nDescription: " + resourceInfoTool.Description + "\nArgsSchema: " + resourceInfoTool.ArgsSchema + "\n"
-	jobDebugToolDef := "Name: " + jobDebugTool.Name() + "\nDescription: " + jobDebugTool.Description() + "\nArgsSchema: " + jobDebugTool.ArgsSchema() + "\n"
-	sandboxLogToolDef := "Name: " + sandboxLogTool.Name() + "\nDescription: " + sandboxLogTool.Description() + "\nArgsSchema: " + sandboxLogTool.ArgsSchema() + "\n"
-	intelligentDebugToolDef := "Name: " + intelligentDebugTool.Name() + "\nDescription: " + intelligentDebugTool.Description() + "\nArgsSchema: " + intelligentDebugTool.ArgsSchema() + "\n"
-
-	toolsList := make([]string, 0)
-	toolsList = append(toolsList, createToolDef, listToolDef, deleteToolDef, humanToolDef, clusterToolDef, podToolDef, resourceInfoToolDef, jobDebugToolDef, sandboxLogToolDef, intelligentDebugToolDef)
-
-	tool_names := make([]string, 0)
-	tool_names = append(tool_names, createTool.Name, listTool.Name, deleteTool.Name, humanTool.Name, clustersTool.Name, podTool.Name, resourceInfoTool.Name, jobDebugTool.Name(), sandboxLogTool.Name(), intelligentDebugTool.Name())
-
-	prompt := fmt.Sprintf(promptTpl.Template, toolsList, tool_names, "", query)
-
-	return prompt
+// printEvent prints the agent's progress to the terminal.
+func printEvent(e agent.Event) {
+	switch e.Type {
+	case agent.EventResponse:
+		fmt.Printf("========Round %d Response========\n", e.Round)
+		fmt.Println(e.Content)
+	case agent.EventAction:
+		fmt.Printf("========Round %d Action========\n", e.Round)
+		fmt.Printf("%s %s\n", e.Tool, e.Input)
+	case agent.EventObservation:
+		fmt.Printf("========Round %d Observation========\n", e.Round)
+		fmt.Println(e.Content)
+	case agent.EventFinalAnswer:
+		fmt.Println("========Final GPT Response========")
+		fmt.Println(e.Content)
+	}
 }
 
 func init() {
@@ -196,4 +130,6 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// chatCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	chatCmd.Flags().Bool("read-only", false, "Only allow tools that do not change the cluster")
+	chatCmd.Flags().String("mode", string(agent.ModeReAct), "Tool calling mode: 'react' (text Action/Action Input) or 'tools' (native function calling)")
 }
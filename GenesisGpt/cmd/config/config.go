@@ -7,14 +7,21 @@ import (
 	"strings"
 	"time"
 
+	"github.com/lexieqin/Geek/llm"
 	"gopkg.in/yaml.v2"
 )
 
 type Config struct {
-	Mode       string           `yaml:"mode"`
-	Mock       APIConfig        `yaml:"mock"`
-	Production ProductionConfig `yaml:"production"`
-	Common     CommonConfig     `yaml:"common"`
+	Mode        string           `yaml:"mode"`
+	GinToolsURL string           `yaml:"gintools_url"`
+	Mock        APIConfig        `yaml:"mock"`
+	Production  ProductionConfig `yaml:"production"`
+	Common      CommonConfig     `yaml:"common"`
+	Session     SessionConfig    `yaml:"session"`
+	Context     ContextConfig    `yaml:"context"`
+	LLM         LLMConfig        `yaml:"llm"`
+	Audit       AuditConfig      `yaml:"audit"`
+	Policy      PolicyConfig     `yaml:"policy"`
 }
 
 type APIConfig struct {
@@ -30,9 +37,9 @@ type ProductionConfig struct {
 }
 
 type AuthConfig struct {
-	JobAPI   AuthMethod `yaml:"job_api"`
-	Datadog  AuthMethod `yaml:"datadog"`
-	Sandbox  AuthMethod `yaml:"sandbox"`
+	JobAPI  AuthMethod `yaml:"job_api"`
+	Datadog AuthMethod `yaml:"datadog"`
+	Sandbox AuthMethod `yaml:"sandbox"`
 }
 
 type AuthMethod struct {
@@ -48,6 +55,50 @@ type CommonConfig struct {
 	RetryDelay time.Duration `yaml:"retry_delay"`
 }
 
+// SessionConfig selects where the server keeps conversation sessions.
+type SessionConfig struct {
+	// Backend is "memory" (default), "file" or "redis"
+	Backend  string        `yaml:"backend"`
+	TTL      time.Duration `yaml:"ttl"`
+	Dir      string        `yaml:"dir"`
+	RedisURL string        `yaml:"redis_url"`
+	// ApprovalTTL is how long a tool call waits for the user's approval
+	ApprovalTTL time.Duration `yaml:"approval_ttl"`
+}
+
+// ContextConfig bounds the conversation sent to the model. Token counts are estimates.
+type ContextConfig struct {
+	// MaxTokens is the budget for all messages, older rounds are summarized beyond it
+	MaxTokens int `yaml:"max_tokens"`
+	// ObservationMaxTokens is the largest tool output kept in the conversation
+	ObservationMaxTokens int `yaml:"observation_max_tokens"`
+	// KeepRecentMessages is how many of the latest messages are never summarized
+	KeepRecentMessages int `yaml:"keep_recent_messages"`
+	// SummarizeObservations summarizes large tool outputs with the model instead of truncating them
+	SummarizeObservations bool `yaml:"summarize_observations"`
+}
+
+// LLMConfig selects the chat model. Empty values fall back to the environment and defaults.
+type LLMConfig = llm.Config
+
+// AuditConfig controls the log of tool invocations.
+type AuditConfig struct {
+	Disabled bool `yaml:"disabled"`
+	// Path is the JSON lines file entries are appended to
+	Path string `yaml:"path"`
+	// WebhookURL, if set, receives every entry as a JSON POST
+	WebhookURL     string        `yaml:"webhook_url"`
+	WebhookTimeout time.Duration `yaml:"webhook_timeout"`
+}
+
+// PolicyConfig selects the tool permission policy.
+type PolicyConfig struct {
+	// Path is the policy file, rules missing from it keep the built-in defaults
+	Path string `yaml:"path"`
+	// ReadOnly allows only tools that do not change the cluster
+	ReadOnly bool `yaml:"read_only"`
+}
+
 var (
 	globalConfig *Config
 	configPath   = "config/config.yaml"
@@ -73,7 +124,8 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %v", err)
 	}
 
-	var config Config
+	// Fields the file leaves out keep their defaults
+	config := *getDefaultConfig()
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %v", err)
 	}
@@ -109,6 +161,24 @@ func GetAPIConfig() APIConfig {
 	return config.Mock
 }
 
+// DefaultGinToolsURL is where ginTools listens by default.
+const DefaultGinToolsURL = "http://localhost:8080"
+
+// GinToolsURL returns the ginTools base URL without a trailing slash.
+// The GINTOOLS_URL environment variable overrides the config file.
+func GinToolsURL() string {
+	url := os.Getenv("GINTOOLS_URL")
+	if url == "" {
+		if config := GetConfig(); config != nil {
+			url = config.GinToolsURL
+		}
+	}
+	if url == "" {
+		url = DefaultGinToolsURL
+	}
+	return strings.TrimRight(url, "/")
+}
+
 // GetAuthConfig returns authentication configuration (only for production)
 func GetAuthConfig() *AuthConfig {
 	config := GetConfig()
@@ -135,6 +205,10 @@ func (c *Config) replaceEnvVars() {
 			c.Production.Auth.Sandbox.Token = expandEnv(c.Production.Auth.Sandbox.Token)
 		}
 	}
+	c.Session.RedisURL = expandEnv(c.Session.RedisURL)
+	c.LLM.APIKey = expandEnv(c.LLM.APIKey)
+	c.LLM.BaseURL = expandEnv(c.LLM.BaseURL)
+	c.Audit.WebhookURL = expandEnv(c.Audit.WebhookURL)
 }
 
 func expandEnv(s string) string {
@@ -147,7 +221,8 @@ func expandEnv(s string) string {
 
 func getDefaultConfig() *Config {
 	return &Config{
-		Mode: "mock",
+		Mode:        "mock",
+		GinToolsURL: DefaultGinToolsURL,
 		Mock: APIConfig{
 			JobAPIURL:           "http://localhost:8080/tenant/{tenant}/jobs",
 			DatadogAPIURL:       "http://localhost:8080/api/datadog/trace/{traceID}",
@@ -159,5 +234,28 @@ func getDefaultConfig() *Config {
 			RetryCount: 3,
 			RetryDelay: 2 * time.Second,
 		},
+		Session: SessionConfig{
+			Backend:     "memory",
+			TTL:         30 * time.Minute,
+			ApprovalTTL: 5 * time.Minute,
+		},
+		Context: ContextConfig{
+			MaxTokens:            24000,
+			ObservationMaxTokens: 3000,
+			KeepRecentMessages:   6,
+		},
+		LLM: LLMConfig{
+			Provider:     "openai",
+			Model:        "qwen-max",
+			Timeout:      120 * time.Second,
+			MaxRetries:   3,
+			RetryBackoff: time.Second,
+		},
+		Audit: AuditConfig{
+			Path: "audit/audit.jsonl",
+		},
+		Policy: PolicyConfig{
+			Path: "config/policy.yaml",
+		},
 	}
-}
\ No newline at end of file
+}
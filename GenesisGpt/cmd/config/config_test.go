@@ -0,0 +1,40 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigKeepsDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `
+mode: production
+llm:
+  model: gpt-4o
+context:
+  keep_recent_messages: 10
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GENESISGPT_CONFIG", path)
+	globalConfig = nil
+	t.Cleanup(func() { globalConfig = nil })
+
+	c, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Mode != "production" || c.LLM.Model != "gpt-4o" || c.Context.KeepRecentMessages != 10 {
+		t.Errorf("fields set in the file were not read: %+v", c)
+	}
+	defaults := getDefaultConfig()
+	if c.Context.MaxTokens != defaults.Context.MaxTokens || c.Context.ObservationMaxTokens != defaults.Context.ObservationMaxTokens {
+		t.Errorf("context limits = %+v, want the defaults %+v", c.Context, defaults.Context)
+	}
+	if c.LLM.Timeout != 120*time.Second || c.Session.TTL != defaults.Session.TTL || c.Audit.Path != defaults.Audit.Path {
+		t.Errorf("llm timeout %s, session ttl %s, audit path %q, want the defaults", c.LLM.Timeout, c.Session.TTL, c.Audit.Path)
+	}
+}
@@ -0,0 +1,633 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/agent"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/ai"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/audit"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/config"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/policy"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/store"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/tools"
+	"github.com/lexieqin/Geek/llm"
+	"github.com/lexieqin/Geek/llm/replay"
+)
+
+// fakeGinTools serves the ginTools mock endpoints the tools call and records every hit.
+type fakeGinTools struct {
+	mu   sync.Mutex
+	hits []string
+}
+
+func startFakeGinTools(t *testing.T) *fakeGinTools {
+	f := &fakeGinTools{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /namespaces/{ns}/pods", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"data":[{"name":"nginx-1","namespace":%q,"status":"CrashLoopBackOff"}]}`, r.PathValue("ns"))
+	})
+	mux.HandleFunc("GET /namespaces/{ns}/pods/{pod}/logs", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "panic: open /etc/nginx/nginx.conf: no such file or directory")
+	})
+	mux.HandleFunc("GET /namespaces/{ns}/logs", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":"[nginx-1/nginx] panic: open /etc/nginx/nginx.conf: no such file or directory\n","meta":{"pods":2,"lines":1}}`)
+	})
+	mux.HandleFunc("GET /namespaces/{ns}/events", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":[{"namespace":"default","type":"Warning","reason":"BackOff","message":"Back-off restarting failed container","kind":"Pod","name":"nginx-1","count":12}]}`)
+	})
+	mux.HandleFunc("GET /events", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":[{"namespace":"default","kind":"Pod","name":"web-5d9c-x2v","type":"Warning","reason":"FailedScheduling","message":"0/3 nodes are available: 3 Insufficient memory","count":4}],"meta":{"objects":[{"kind":"Deployment","namespace":"default","name":"web"}]}}`)
+	})
+	mux.HandleFunc("GET /graph", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "root: Deployment web [1/2 ready] in namespace default\nIngress web --routes--> Service web\nService web --selects--> Deployment web\nDeployment web --mounts--> ConfigMap web-config [missing]\n")
+	})
+	mux.HandleFunc("GET /analyze", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "2 critical, 0 warning, 0 info findings\nCRITICAL Pod default/nginx-1 container nginx CrashLoopBackOff: back-off restarting after 12 restarts, last terminated with Error (exit code 1)\nCRITICAL Service default/web NoEndpoints: 1 pods match selector app=web but none is ready\n")
+	})
+	mux.HandleFunc("POST /apply", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("dryRun") == "true" {
+			fmt.Fprint(w, `{"data":{"dryRun":true,"results":[{"kind":"Pod","name":"nginx","namespace":"default","action":"created","dryRun":{"dryRun":true,"object":{"kind":"Pod"}}}],"failed":0}}`)
+			return
+		}
+		fmt.Fprint(w, `{"data":{"results":[{"kind":"Pod","name":"nginx","namespace":"default","action":"created"}],"failed":0}}`)
+	})
+	mux.HandleFunc("PATCH /{resource}", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("dryRun") == "true" {
+			fmt.Fprint(w, `{"data":{"dryRun":true,"object":{"kind":"Deployment"},"diff":"--- live\n+++ dry-run\n@@ -1 +1 @@\n-  replicas: 1\n+  replicas: 3\n"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"data":{"kind":"Deployment","metadata":{"name":"web","resourceVersion":"42"}}}`)
+	})
+	mux.HandleFunc("GET /{resource}/object", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":{"kind":"Deployment","metadata":{"name":"web","namespace":"default","resourceVersion":"41"},"spec":{"replicas":1}}}`)
+	})
+	mux.HandleFunc("PUT /{resource}", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("dryRun") == "true" {
+			fmt.Fprint(w, `{"data":{"dryRun":true,"object":{"kind":"Deployment"},"diff":"--- live\n+++ dry-run\n@@ -1 +1 @@\n-  replicas: 1\n+  replicas: 2\n"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"data":{"name":"web","namespace":"default","resourceVersion":"42"}}`)
+	})
+	mux.HandleFunc("DELETE /{resource}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":"deleted"}`)
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.hits = append(f.hits, r.Method+" "+r.URL.RequestURI())
+		f.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	t.Setenv("GINTOOLS_URL", srv.URL)
+	return f
+}
+
+// Hits returns the requests received so far, sorted because parallel tool calls race.
+func (f *fakeGinTools) Hits() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	hits := append([]string(nil), f.hits...)
+	sort.Strings(hits)
+	return hits
+}
+
+// e2eRun is what one pass over a test case observed.
+type e2eRun struct {
+	// toolCalls is the sequence of tools the model called, read back from the session
+	toolCalls []string
+	hits      []string
+	responses []string
+	tokens    int
+	keys      []string
+	// audit lists the audit log entries as "tool confirmation status"
+	audit []string
+}
+
+// e2eApprove and e2eDeny in a query list decide on the pending action instead of
+// sending a query.
+const (
+	e2eApprove = "<approve>"
+	e2eDeny    = "<deny>"
+)
+
+// e2eSetup configures the server under test.
+type e2eSetup struct {
+	mode     agent.Mode
+	stream   bool
+	readOnly bool
+	// rules replace the default rules of the tools they name
+	rules map[string]policy.Rule
+}
+
+// runE2E answers the queries in one session, talking to the model through a replay
+// server on dir. With a nil upstream the server only replays recorded fixtures.
+func runE2E(t *testing.T, setup e2eSetup, queries []string, dir string, upstream llm.Provider) e2eRun {
+	t.Helper()
+	ginTools := startFakeGinTools(t)
+
+	replayServer, err := replay.NewServer(dir, upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	llmServer := httptest.NewServer(replayServer)
+	defer llmServer.Close()
+
+	provider, err := llm.NewOpenAI(config.LLMConfig{APIKey: "test", BaseURL: llmServer.URL + "/v1", MaxRetries: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditPath := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog, err := audit.Open(config.AuditConfig{Path: auditPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := tools.NewDefaultRegistry(provider)
+	registry.SetAuditLog(auditLog)
+	toolPolicy := policy.Default()
+	toolPolicy.ReadOnly = setup.readOnly
+	for name, rule := range setup.rules {
+		toolPolicy.Tools[name] = rule
+	}
+	registry.SetPolicy(toolPolicy)
+	srv := newQueryServer(provider, registry, setup.mode, store.NewMemoryStore(time.Hour))
+
+	var run e2eRun
+	var onEvent func(agent.Event)
+	if setup.stream {
+		onEvent = func(e agent.Event) {
+			if e.Type == agent.EventToken {
+				run.tokens++
+			}
+		}
+	}
+
+	sessionID := ""
+	var pending *store.PendingAction
+	for _, query := range queries {
+		var reply queryReply
+		switch query {
+		case e2eApprove, e2eDeny:
+			if pending == nil {
+				t.Fatalf("%s without a pending action", query)
+			}
+			var err error
+			reply, _, err = srv.confirmAction(context.Background(), sessionID, pending.Token, query == e2eApprove, false, onEvent)
+			if err != nil {
+				t.Fatal(err)
+			}
+		default:
+			reply = srv.processQueryWithSession(context.Background(), query, sessionID, false, onEvent)
+		}
+		sessionID, pending = reply.SessionID, reply.PendingAction
+		run.responses = append(run.responses, reply.Response)
+	}
+
+	session, err := srv.sessions.Get(context.Background(), sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run.toolCalls = toolCallSequence(session.MessageStore)
+	run.hits = ginTools.Hits()
+	run.keys = replayServer.Keys()
+
+	auditLog.Close()
+	entries, err := audit.Read(auditPath, audit.Filter{SessionID: sessionID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		run.audit = append(run.audit, fmt.Sprintf("%s %s %d", e.Tool, defaultString(e.Confirmation, "-"), e.Status))
+	}
+	return run
+}
+
+// toolCallSequence lists the tools called by the assistant messages, in order.
+func toolCallSequence(messages ai.ChatMessages) []string {
+	var names []string
+	for _, m := range messages {
+		if m.Msg.Role != ai.RoleAssistant {
+			continue
+		}
+		for _, call := range m.Msg.ToolCalls {
+			names = append(names, call.Function.Name)
+		}
+		if action, _, ok := agent.ParseAction(m.Msg.Content); ok {
+			names = append(names, action)
+		}
+	}
+	return names
+}
+
+func reactStep(thought, action, input string) llm.Step {
+	return llm.Reply(fmt.Sprintf("Thought: %s\nAction: %s\nAction Input: %s\n", thought, action, input))
+}
+
+func TestAgentEndToEnd(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   e2eSetup
+		queries []string
+		script  []llm.Step
+
+		wantToolCalls []string
+		wantHits      []string
+		// wantResponse must be contained in the reply to the last query
+		wantResponse string
+		// wantPrompt, if set, must be contained in the reply to the first query
+		wantPrompt string
+		// wantAudit, if set, is the expected audit log as "tool confirmation status"
+		wantAudit []string
+	}{
+		{
+			name:    "react lists pods",
+			setup:   e2eSetup{mode: agent.ModeReAct},
+			queries: []string{"Which pods are failing in default?"},
+			script: []llm.Step{
+				reactStep("I need the pods in default", "ListTool", `{"resource":"pod","namespace":"default"}`),
+				llm.Reply("Thought: nginx-1 is crash looping\nFinal Answer: nginx-1 is in CrashLoopBackOff"),
+			},
+			wantToolCalls: []string{"ListTool"},
+			wantHits:      []string{"GET /namespaces/default/pods?limit=50"},
+			wantResponse:  "nginx-1 is in CrashLoopBackOff",
+		},
+		{
+			name:    "list by name gets one object",
+			setup:   e2eSetup{mode: agent.ModeTools},
+			queries: []string{"How many replicas does deployment web have?"},
+			script: []llm.Step{
+				llm.CallTools(llm.ToolCall("call_1", "ListTool", `{"resource":"deployment","name":"web"}`)),
+				llm.Reply("deployment web has 1 replica"),
+			},
+			wantToolCalls: []string{"ListTool"},
+			wantHits:      []string{"GET /deployment/object?name=web&ns=%2A"},
+			wantResponse:  "1 replica",
+		},
+		{
+			name:    "tools mode narrows a list",
+			setup:   e2eSetup{mode: agent.ModeTools},
+			queries: []string{"Which pods of app web restart the most?"},
+			script: []llm.Step{
+				llm.CallTools(llm.ToolCall("call_1", "ListTool", `{"resource":"pod","namespace":"default","labelSelector":"app=web","sortBy":"-restartCount","limit":5,"fields":["metadata.name","status.phase"]}`)),
+				llm.Reply("nginx-1 restarts the most"),
+			},
+			wantToolCalls: []string{"ListTool"},
+			wantHits:      []string{"GET /namespaces/default/pods?fields=metadata.name%2Cstatus.phase&labelSelector=app%3Dweb&limit=5&sortBy=-restartCount"},
+			wantResponse:  "nginx-1 restarts the most",
+		},
+		{
+			name:    "list without a namespace covers all namespaces",
+			setup:   e2eSetup{mode: agent.ModeTools},
+			queries: []string{"Which pods are crashing anywhere?"},
+			script: []llm.Step{
+				llm.CallTools(llm.ToolCall("call_1", "ListTool", `{"resource":"pod","fieldSelector":"status.phase!=Running"}`)),
+				llm.Reply("nginx-1 is crashing"),
+			},
+			wantToolCalls: []string{"ListTool"},
+			wantHits:      []string{"GET /namespaces/*/pods?fieldSelector=status.phase%21%3DRunning&limit=50"},
+			wantResponse:  "nginx-1 is crashing",
+		},
+		{
+			name:    "tools mode triages a namespace in one round",
+			setup:   e2eSetup{mode: agent.ModeTools},
+			queries: []string{"What's broken in namespace default?"},
+			script: []llm.Step{
+				llm.CallTools(
+					llm.ToolCall("call_1", "PodTool", `{"operation":"namespace-events","namespace":"default"}`),
+					llm.ToolCall("call_2", "PodTool", `{"operation":"namespace-logs","namespace":"default","labelSelector":"app=web"}`),
+				),
+				llm.Reply("nginx-1 crash loops because nginx.conf is missing"),
+			},
+			wantToolCalls: []string{"PodTool", "PodTool"},
+			wantHits:      []string{"GET /namespaces/default/events", "GET /namespaces/default/logs?labelSelector=app%3Dweb"},
+			wantResponse:  "nginx.conf is missing",
+		},
+		{
+			name:    "events follow the owner chain",
+			setup:   e2eSetup{mode: agent.ModeTools},
+			queries: []string{"Why does deployment web not roll out?"},
+			script: []llm.Step{
+				llm.CallTools(llm.ToolCall("call_1", "EventTool", `{"kind":"deployment","name":"web","namespace":"default","ownerChain":true}`)),
+				llm.Reply("Its new pods cannot be scheduled: the nodes lack memory"),
+			},
+			wantToolCalls: []string{"EventTool"},
+			wantHits:      []string{"GET /events?kind=deployment&name=web&namespace=default&ownerChain=true"},
+			wantResponse:  "lack memory",
+		},
+		{
+			name:    "topology shows what routes to a deployment",
+			setup:   e2eSetup{mode: agent.ModeTools},
+			queries: []string{"What routes traffic to deployment web?"},
+			script: []llm.Step{
+				llm.CallTools(llm.ToolCall("call_1", "TopologyTool", `{"kind":"deployment","name":"web","namespace":"default"}`)),
+				llm.Reply("Ingress web routes to Service web, which selects deployment web"),
+			},
+			wantToolCalls: []string{"TopologyTool"},
+			wantHits:      []string{"GET /graph?format=text&kind=deployment&name=web&namespace=default"},
+			wantResponse:  "Ingress web routes to Service web",
+		},
+		{
+			name:    "scan runs before reasoning",
+			setup:   e2eSetup{mode: agent.ModeTools},
+			queries: []string{"What is broken in namespace default?"},
+			script: []llm.Step{
+				llm.CallTools(llm.ToolCall("call_1", "ScanTool", `{"namespace":"default"}`)),
+				llm.Reply("nginx-1 crash loops, so Service web has no endpoints"),
+			},
+			wantToolCalls: []string{"ScanTool"},
+			wantHits:      []string{"GET /analyze?format=text&namespace=default"},
+			wantResponse:  "Service web has no endpoints",
+		},
+		{
+			name:    "react deletes after approval",
+			setup:   e2eSetup{mode: agent.ModeReAct},
+			queries: []string{"Delete pod nginx-1 in default", e2eApprove},
+			script: []llm.Step{
+				reactStep("The user asked to delete nginx-1", "DeleteTool", `{"resource":"pod","name":"nginx-1","namespace":"default"}`),
+				llm.Reply("Thought: done\nFinal Answer: pod nginx-1 was deleted"),
+			},
+			wantToolCalls: []string{"DeleteTool"},
+			wantHits:      []string{"DELETE /pod?ns=default&name=nginx-1"},
+			wantResponse:  "pod nginx-1 was deleted",
+			wantAudit:     []string{"DeleteTool pending 0", "DeleteTool approved 200"},
+		},
+		{
+			name:    "human answer does not approve a delete",
+			setup:   e2eSetup{mode: agent.ModeReAct},
+			queries: []string{"Clean up the failing pod", "yes", e2eApprove},
+			script: []llm.Step{
+				reactStep("I should check which pod is meant", "HumanTool", `{"prompt":"Delete pod nginx-1 in default?"}`),
+				reactStep("The user agreed", "DeleteTool", `{"resource":"pod","name":"nginx-1","namespace":"default"}`),
+				llm.Reply("Thought: done\nFinal Answer: pod nginx-1 was deleted"),
+			},
+			wantToolCalls: []string{"HumanTool", "DeleteTool"},
+			wantHits:      []string{"DELETE /pod?ns=default&name=nginx-1"},
+			wantResponse:  "pod nginx-1 was deleted",
+			wantAudit:     []string{"HumanTool pending 0", "HumanTool yes 0", "DeleteTool pending 0", "DeleteTool approved 200"},
+		},
+		{
+			name:    "react streams",
+			setup:   e2eSetup{mode: agent.ModeReAct, stream: true},
+			queries: []string{"Which pods are failing in default?"},
+			script: []llm.Step{
+				reactStep("I need the pods in default", "ListTool", `{"resource":"pod","namespace":"default"}`),
+				llm.Reply("Thought: nginx-1 is crash looping\nFinal Answer: nginx-1 is in CrashLoopBackOff"),
+			},
+			wantToolCalls: []string{"ListTool"},
+			wantHits:      []string{"GET /namespaces/default/pods?limit=50"},
+			wantResponse:  "nginx-1 is in CrashLoopBackOff",
+		},
+		{
+			name:    "tools mode runs parallel calls",
+			setup:   e2eSetup{mode: agent.ModeTools},
+			queries: []string{"Why is nginx-1 failing?"},
+			script: []llm.Step{
+				llm.CallTools(
+					llm.ToolCall("call_1", "ListTool", `{"resource":"pod","namespace":"default"}`),
+					llm.ToolCall("call_2", "PodTool", `{"namespace":"default","podName":"nginx-1","operation":"logs"}`),
+				),
+				llm.Reply("nginx-1 cannot find /etc/nginx/nginx.conf"),
+			},
+			wantToolCalls: []string{"ListTool", "PodTool"},
+			wantHits:      []string{"GET /namespaces/default/pods/nginx-1/logs", "GET /namespaces/default/pods?limit=50"},
+			wantResponse:  "cannot find /etc/nginx/nginx.conf",
+		},
+		{
+			name:    "tools mode streams approval",
+			setup:   e2eSetup{mode: agent.ModeTools, stream: true},
+			queries: []string{"Delete pod nginx-1 in default", e2eApprove},
+			script: []llm.Step{
+				llm.CallTools(llm.ToolCall("call_1", "DeleteTool", `{"resource":"pod","name":"nginx-1","namespace":"default"}`)),
+				llm.Reply("pod nginx-1 was deleted"),
+			},
+			wantToolCalls: []string{"DeleteTool"},
+			wantHits:      []string{"DELETE /pod?ns=default&name=nginx-1"},
+			wantResponse:  "pod nginx-1 was deleted",
+			wantAudit:     []string{"DeleteTool pending 0", "DeleteTool approved 200"},
+		},
+		{
+			name:    "create is previewed before approval",
+			setup:   e2eSetup{mode: agent.ModeTools},
+			queries: []string{"Create an nginx pod in default", e2eApprove},
+			script: []llm.Step{
+				llm.CallTools(llm.ToolCall("call_1", "CreateTool", `{"prompt":"Create an nginx pod in default","resource":"pod"}`)),
+				// The yaml generated for the preview is the yaml created after approval
+				llm.Reply("apiVersion: v1\nkind: Pod\nmetadata:\n  name: nginx\n  namespace: default\nspec:\n  containers:\n  - name: nginx\n    image: nginx"),
+				llm.Reply("pod nginx was created"),
+			},
+			wantToolCalls: []string{"CreateTool"},
+			wantHits:      []string{"POST /apply?fieldManager=genesisgpt", "POST /apply?fieldManager=genesisgpt&dryRun=true"},
+			wantResponse:  "pod nginx was created",
+			wantPrompt:    "- Pod nginx in namespace default: would be created",
+			wantAudit:     []string{"CreateTool pending 0", "CreateTool approved 200"},
+		},
+		{
+			name:    "create outside the allowed namespaces is refused after the preview",
+			setup:   e2eSetup{mode: agent.ModeTools, rules: map[string]policy.Rule{"CreateTool": {Access: policy.Confirm, Namespaces: []string{"default"}}}},
+			queries: []string{"Create an nginx pod in kube-system"},
+			script: []llm.Step{
+				llm.CallTools(llm.ToolCall("call_1", "CreateTool", `{"prompt":"Create an nginx pod in kube-system","resource":"pod"}`)),
+				llm.Reply("apiVersion: v1\nkind: Pod\nmetadata:\n  name: nginx\n  namespace: kube-system\nspec:\n  containers:\n  - name: nginx\n    image: nginx"),
+				llm.Reply("I may only create resources in namespace default"),
+			},
+			wantToolCalls: []string{"CreateTool"},
+			wantHits:      []string{"POST /apply?fieldManager=genesisgpt&dryRun=true"},
+			wantResponse:  "only create resources in namespace default",
+			wantAudit:     []string{"CreateTool - 0"},
+		},
+		{
+			name: "create without confirmation applies the generated yaml",
+			setup: e2eSetup{mode: agent.ModeTools, rules: map[string]policy.Rule{
+				"CreateTool":    {Access: policy.Write},
+				policy.AllTools: {ForbiddenNamespaces: []string{"kube-system"}},
+			}},
+			queries: []string{"Create an nginx pod in default"},
+			script: []llm.Step{
+				llm.CallTools(llm.ToolCall("call_1", "CreateTool", `{"prompt":"Create an nginx pod in default","resource":"pod"}`)),
+				llm.Reply("apiVersion: v1\nkind: Pod\nmetadata:\n  name: nginx\n  namespace: default\nspec:\n  containers:\n  - name: nginx\n    image: nginx"),
+				llm.Reply("pod nginx was created"),
+			},
+			wantToolCalls: []string{"CreateTool"},
+			wantHits:      []string{"POST /apply?fieldManager=genesisgpt", "POST /apply?fieldManager=genesisgpt&dryRun=true"},
+			wantResponse:  "pod nginx was created",
+			wantAudit:     []string{"CreateTool - 200"},
+		},
+		{
+			// The model asks for a pod, the generated yaml holds a forbidden secret
+			name: "create without confirmation checks the generated yaml",
+			setup: e2eSetup{mode: agent.ModeTools, rules: map[string]policy.Rule{
+				"CreateTool":    {Access: policy.Write},
+				policy.AllTools: {ForbiddenKinds: []string{"secret"}, ForbiddenNamespaces: []string{"kube-system"}},
+			}},
+			queries: []string{"Create an nginx pod in default"},
+			script: []llm.Step{
+				llm.CallTools(llm.ToolCall("call_1", "CreateTool", `{"prompt":"Create an nginx pod in default","resource":"pod"}`)),
+				llm.Reply("apiVersion: v1\nkind: Secret\nmetadata:\n  name: token\n  namespace: kube-system\nstringData:\n  token: abc"),
+				llm.Reply("I may not create that secret"),
+			},
+			wantToolCalls: []string{"CreateTool"},
+			wantHits:      []string{"POST /apply?fieldManager=genesisgpt&dryRun=true"},
+			wantResponse:  "may not create that secret",
+			wantAudit:     []string{"CreateTool - 0"},
+		},
+		{
+			name:    "patch is previewed before approval",
+			setup:   e2eSetup{mode: agent.ModeTools},
+			queries: []string{"Scale deployment web in default to 3 replicas", e2eApprove},
+			script: []llm.Step{
+				llm.CallTools(llm.ToolCall("call_1", "PatchTool", `{"resource":"deployment","name":"web","namespace":"default","patch":{"spec":{"replicas":3}}}`)),
+				llm.Reply("web now runs 3 replicas"),
+			},
+			wantToolCalls: []string{"PatchTool"},
+			wantHits: []string{
+				"PATCH /deployment?dryRun=true&fieldManager=genesisgpt&name=web&ns=default&patchType=strategic",
+				"PATCH /deployment?fieldManager=genesisgpt&name=web&ns=default&patchType=strategic",
+			},
+			wantResponse: "web now runs 3 replicas",
+			wantPrompt:   "+  replicas: 3",
+			wantAudit:    []string{"PatchTool pending 0", "PatchTool approved 200"},
+		},
+		{
+			name:    "update is written against the live object",
+			setup:   e2eSetup{mode: agent.ModeTools},
+			queries: []string{"Run two replicas of web in default", e2eApprove},
+			script: []llm.Step{
+				llm.CallTools(llm.ToolCall("call_1", "UpdateTool", `{"resource":"deployment","name":"web","namespace":"default","instruction":"run two replicas"}`)),
+				// The changes written for the preview are the changes stored after approval
+				llm.Reply("spec:\n  replicas: 2"),
+				llm.Reply("web now runs 2 replicas"),
+			},
+			wantToolCalls: []string{"UpdateTool"},
+			wantHits: []string{
+				"GET /deployment/object?name=web&ns=default",
+				"PUT /deployment?dryRun=true&name=web&ns=default&resourceVersion=41",
+				"PUT /deployment?name=web&ns=default&resourceVersion=41",
+			},
+			wantResponse: "web now runs 2 replicas",
+			wantPrompt:   "+  replicas: 2",
+			wantAudit:    []string{"UpdateTool pending 0", "UpdateTool approved 200"},
+		},
+		{
+			name:    "denied delete is not run",
+			setup:   e2eSetup{mode: agent.ModeTools},
+			queries: []string{"Delete pod nginx-1 in default", e2eDeny},
+			script: []llm.Step{
+				llm.CallTools(llm.ToolCall("call_1", "DeleteTool", `{"resource":"pod","name":"nginx-1","namespace":"default"}`)),
+				llm.Reply("I left nginx-1 in place"),
+			},
+			wantToolCalls: []string{"DeleteTool"},
+			wantResponse:  "left nginx-1 in place",
+			wantAudit:     []string{"DeleteTool pending 0", "DeleteTool denied 0"},
+		},
+		{
+			name:    "new query drops the pending action",
+			setup:   e2eSetup{mode: agent.ModeTools},
+			queries: []string{"Delete pod nginx-1 in default", "Which pods are failing in default?"},
+			script: []llm.Step{
+				llm.CallTools(llm.ToolCall("call_1", "DeleteTool", `{"resource":"pod","name":"nginx-1","namespace":"default"}`)),
+				llm.CallTools(llm.ToolCall("call_2", "ListTool", `{"resource":"pod","namespace":"default"}`)),
+				llm.Reply("nginx-1 is in CrashLoopBackOff"),
+			},
+			wantToolCalls: []string{"DeleteTool", "ListTool"},
+			wantHits:      []string{"GET /namespaces/default/pods?limit=50"},
+			wantResponse:  "CrashLoopBackOff",
+			wantAudit:     []string{"DeleteTool pending 0", "DeleteTool denied 0", "ListTool - 200"},
+		},
+		{
+			name:    "new query leaves the pending question unanswered",
+			setup:   e2eSetup{mode: agent.ModeTools},
+			queries: []string{"Clean up the failing pod", "Which pods are failing in default?", "yes"},
+			script: []llm.Step{
+				llm.CallTools(llm.ToolCall("call_1", "HumanTool", `{"prompt":"Delete pod nginx-1 in default?"}`)),
+				llm.CallTools(llm.ToolCall("call_2", "ListTool", `{"resource":"pod","namespace":"default"}`)),
+				llm.Reply("nginx-1 is in CrashLoopBackOff"),
+				llm.Reply("Yes what? Tell me what to do with nginx-1"),
+			},
+			wantToolCalls: []string{"HumanTool", "ListTool"},
+			wantHits:      []string{"GET /namespaces/default/pods?limit=50"},
+			wantResponse:  "Tell me what to do",
+			wantAudit:     []string{"HumanTool pending 0", "HumanTool - 0", "ListTool - 200"},
+		},
+		{
+			name:    "read-only mode refuses deletes",
+			setup:   e2eSetup{mode: agent.ModeReAct, readOnly: true},
+			queries: []string{"Delete pod nginx-1 in default", "yes"},
+			script: []llm.Step{
+				reactStep("Deleting needs confirmation", "HumanTool", `{"prompt":"Delete pod nginx-1 in default?"}`),
+				reactStep("The user confirmed", "DeleteTool", `{"resource":"pod","name":"nginx-1","namespace":"default"}`),
+				llm.Reply("Thought: the policy forbids it\nFinal Answer: deleting is not permitted in read-only mode"),
+			},
+			wantToolCalls: []string{"HumanTool", "DeleteTool"},
+			wantResponse:  "not permitted",
+			wantAudit:     []string{"HumanTool pending 0", "HumanTool yes 0", "DeleteTool - 0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// HumanTool only asks for confirmation through the session in server mode
+			t.Setenv("GENESISGPT_SERVER_MODE", "true")
+			dir := t.TempDir()
+
+			check := func(pass string, run e2eRun) {
+				if !reflect.DeepEqual(run.toolCalls, tt.wantToolCalls) {
+					t.Errorf("%s: tool calls = %v, want %v", pass, run.toolCalls, tt.wantToolCalls)
+				}
+				if !reflect.DeepEqual(run.hits, tt.wantHits) {
+					t.Errorf("%s: ginTools requests = %v, want %v", pass, run.hits, tt.wantHits)
+				}
+				if last := run.responses[len(run.responses)-1]; !strings.Contains(last, tt.wantResponse) {
+					t.Errorf("%s: response = %q, want it to contain %q", pass, last, tt.wantResponse)
+				}
+				if !strings.Contains(run.responses[0], tt.wantPrompt) {
+					t.Errorf("%s: first response = %q, want it to contain %q", pass, run.responses[0], tt.wantPrompt)
+				}
+				if tt.wantAudit != nil && !reflect.DeepEqual(run.audit, tt.wantAudit) {
+					t.Errorf("%s: audit log = %v, want %v", pass, run.audit, tt.wantAudit)
+				}
+				if tt.setup.stream && run.tokens == 0 {
+					t.Errorf("%s: no tokens were streamed", pass)
+				}
+			}
+
+			upstream := llm.NewScripted(tt.script...)
+			recorded := runE2E(t, tt.setup, tt.queries, dir, upstream)
+			check("record", recorded)
+			if n := upstream.Remaining(); n != 0 {
+				t.Errorf("record: %d scripted responses left unused", n)
+			}
+
+			// Replaying the fixtures must drive the agent through the same steps
+			replayed := runE2E(t, tt.setup, tt.queries, dir, nil)
+			check("replay", replayed)
+			if !reflect.DeepEqual(replayed.keys, recorded.keys) {
+				t.Errorf("replay asked for conversations %v, recorded %v", replayed.keys, recorded.keys)
+			}
+		})
+	}
+}
+
+// TestAgentEndToEndReplayMiss checks that a conversation without a fixture fails
+// instead of reaching a model.
+func TestAgentEndToEndReplayMiss(t *testing.T) {
+	run := runE2E(t, e2eSetup{mode: agent.ModeTools}, []string{"Why is nginx-1 failing?"}, t.TempDir(), nil)
+
+	if len(run.toolCalls) != 0 || len(run.hits) != 0 {
+		t.Errorf("tools ran without a recorded response: %v, %v", run.toolCalls, run.hits)
+	}
+	if !strings.Contains(run.responses[0], "record mode") {
+		t.Errorf("response = %q, want the missing fixture error", run.responses[0])
+	}
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/config"
+	"github.com/lexieqin/Geek/llm"
+	"github.com/lexieqin/Geek/llm/replay"
+	"github.com/spf13/cobra"
+)
+
+var llmReplayCmd = &cobra.Command{
+	Use:   "llm-replay",
+	Short: "Serve recorded LLM responses over the OpenAI API",
+	Long: `Serve recorded LLM responses over the OpenAI chat completions API, keyed by a hash of the conversation.
+
+With --record every request is forwarded to the configured LLM and its answer is saved
+to the fixture directory, so a real session can be replayed later without a model.
+Point OPENAI_BASE_URL (or llm.base_url) at http://localhost:<port>/v1 to use it.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, _ := cmd.Flags().GetString("fixtures")
+		record, _ := cmd.Flags().GetBool("record")
+		port, _ := cmd.Flags().GetString("port")
+
+		var upstream llm.Provider
+		if record {
+			cfg, err := config.LoadConfig()
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("upstream") {
+				cfg.LLM.BaseURL, _ = cmd.Flags().GetString("upstream")
+			}
+			if upstream, err = llm.New(cfg.LLM); err != nil {
+				return err
+			}
+		}
+
+		srv, err := replay.NewServer(dir, upstream)
+		if err != nil {
+			return err
+		}
+
+		mode := "replay"
+		if record {
+			mode = "record"
+		}
+		fmt.Printf("LLM replay server listening on port %s (mode: %s, fixtures: %s)\n", port, mode, dir)
+		return http.ListenAndServe(":"+port, srv)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(llmReplayCmd)
+
+	llmReplayCmd.Flags().String("fixtures", "testdata/llm", "Directory holding the recorded responses")
+	llmReplayCmd.Flags().Bool("record", false, "Forward requests to the configured LLM and save its answers")
+	llmReplayCmd.Flags().String("port", "8091", "Port to listen on")
+	llmReplayCmd.Flags().String("upstream", "", "Base URL of the LLM to record from (default from config)")
+}
@@ -0,0 +1,541 @@
+// Package policy decides which tool calls the agent may make. Rules are declared per
+// tool in a YAML file and checked before a tool runs, so they hold whatever the model
+// was told in its prompt.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Access classifies what a tool may do.
+type Access string
+
+const (
+	// ReadOnly tools only read state; they are the only tools allowed in read-only mode.
+	ReadOnly Access = "read-only"
+	// Write tools change state and run without confirmation.
+	Write Access = "write"
+	// Confirm tools change state and run only after the user approved the exact call.
+	Confirm Access = "confirm"
+	// Forbidden tools never run.
+	Forbidden Access = "forbidden"
+)
+
+// AllTools is the rule name whose restrictions apply to every tool.
+const AllTools = "*"
+
+// Rule restricts one tool. Empty lists do not restrict.
+type Rule struct {
+	Access Access `yaml:"access"`
+	// Deletes marks tools whose calls delete the named resource. It describes the tool,
+	// so it is set by Default and cannot be changed in the policy file.
+	Deletes bool `yaml:"-"`
+	// Namespaces and Kinds are allow-lists; namespaces may use shell patterns like "team-*"
+	Namespaces          []string `yaml:"namespaces"`
+	Kinds               []string `yaml:"kinds"`
+	ForbiddenNamespaces []string `yaml:"forbidden_namespaces"`
+	ForbiddenKinds      []string `yaml:"forbidden_kinds"`
+}
+
+// Policy is the set of rules the registry enforces.
+type Policy struct {
+	// ReadOnly denies every tool that is not read-only
+	ReadOnly bool
+	// DefaultAccess applies to tools without a rule
+	DefaultAccess Access
+	// BlockClusterScopedDeletes denies deletes of cluster-scoped kinds or without a namespace
+	BlockClusterScopedDeletes bool
+	Tools                     map[string]Rule
+	// Scope tells which kinds are cluster-scoped. Without it only deletes without a
+	// namespace are blocked.
+	Scope ScopeFunc
+}
+
+// ScopeFunc reports whether kind, in any spelling kubectl accepts, is cluster-scoped.
+type ScopeFunc func(kind string) (clusterScoped bool, err error)
+
+// policyFile is the YAML form of a Policy; unset fields keep the defaults.
+type policyFile struct {
+	ReadOnly                  bool            `yaml:"read_only"`
+	DefaultAccess             Access          `yaml:"default_access"`
+	BlockClusterScopedDeletes *bool           `yaml:"block_cluster_scoped_deletes"`
+	Tools                     map[string]Rule `yaml:"tools"`
+}
+
+// Default returns the built-in policy: inspection tools are read-only, creating,
+// changing and deleting require confirmation, cluster-scoped deletes are blocked.
+func Default() *Policy {
+	return &Policy{
+		DefaultAccess:             Confirm,
+		BlockClusterScopedDeletes: true,
+		Tools: map[string]Rule{
+			"CreateTool":           {Access: Confirm},
+			"DeleteTool":           {Access: Confirm, Deletes: true},
+			"PatchTool":            {Access: Confirm},
+			"UpdateTool":           {Access: Confirm},
+			"ListTool":             {Access: ReadOnly},
+			"PodTool":              {Access: ReadOnly},
+			"EventTool":            {Access: ReadOnly},
+			"TopologyTool":         {Access: ReadOnly},
+			"ScanTool":             {Access: ReadOnly},
+			"ClusterTool":          {Access: ReadOnly},
+			"ResourceInfoTool":     {Access: ReadOnly},
+			"HumanTool":            {Access: ReadOnly},
+			"JobDebugTool":         {Access: ReadOnly},
+			"SandboxLogTool":       {Access: ReadOnly},
+			"IntelligentDebugTool": {Access: ReadOnly},
+		},
+	}
+}
+
+// Load reads the policy file at path on top of Default. The fields a rule in the file sets
+// replace those of the default rule of the same tool. A missing file yields the default
+// policy.
+func Load(path string) (*Policy, error) {
+	p := Default()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %v", err)
+	}
+
+	var file policyFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file %s: %v", path, err)
+	}
+	if err := file.validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %v", path, err)
+	}
+
+	p.ReadOnly = file.ReadOnly
+	if file.DefaultAccess != "" {
+		p.DefaultAccess = file.DefaultAccess
+	}
+	if file.BlockClusterScopedDeletes != nil {
+		p.BlockClusterScopedDeletes = *file.BlockClusterScopedDeletes
+	}
+	for name, rule := range file.Tools {
+		p.Tools[name] = p.Tools[name].merge(rule)
+	}
+	return p, nil
+}
+
+// merge returns r with the fields override sets replaced.
+func (r Rule) merge(override Rule) Rule {
+	if override.Access != "" {
+		r.Access = override.Access
+	}
+	if override.Namespaces != nil {
+		r.Namespaces = override.Namespaces
+	}
+	if override.Kinds != nil {
+		r.Kinds = override.Kinds
+	}
+	if override.ForbiddenNamespaces != nil {
+		r.ForbiddenNamespaces = override.ForbiddenNamespaces
+	}
+	if override.ForbiddenKinds != nil {
+		r.ForbiddenKinds = override.ForbiddenKinds
+	}
+	return r
+}
+
+func (p *policyFile) validate() error {
+	valid := func(a Access) bool {
+		switch a {
+		case "", ReadOnly, Write, Confirm, Forbidden:
+			return true
+		}
+		return false
+	}
+
+	if !valid(p.DefaultAccess) {
+		return fmt.Errorf("default_access: unknown access %q", p.DefaultAccess)
+	}
+	for name, rule := range p.Tools {
+		if !valid(rule.Access) {
+			return fmt.Errorf("tools.%s.access: unknown access %q, use read-only, write, confirm or forbidden", name, rule.Access)
+		}
+		for _, pattern := range append(rule.Namespaces, rule.ForbiddenNamespaces...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("tools.%s: invalid namespace pattern %q", name, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// Effect is the outcome of a check.
+type Effect int
+
+const (
+	Allow Effect = iota
+	// NeedsConfirmation means the call may run once the user has approved it
+	NeedsConfirmation
+	Deny
+)
+
+// Decision is the result of checking one tool call.
+type Decision struct {
+	Effect Effect
+	Reason string
+}
+
+// Allowed reports whether the call may run.
+func (d Decision) Allowed() bool {
+	return d.Effect == Allow
+}
+
+// Message is the observation returned to the model instead of running the tool.
+func (d Decision) Message(tool string) string {
+	if d.Effect == NeedsConfirmation {
+		return fmt.Sprintf("Not executed: %s. The user has to approve this exact %s call.", d.Reason, tool)
+	}
+	return fmt.Sprintf("Denied by policy: %s. Do not retry this action; tell the user it is not permitted.", d.Reason)
+}
+
+// Access returns the access class of tool.
+func (p *Policy) Access(tool string) Access {
+	if rule, ok := p.Tools[tool]; ok && rule.Access != "" {
+		return rule.Access
+	}
+	if p.DefaultAccess != "" {
+		return p.DefaultAccess
+	}
+	return Confirm
+}
+
+// Hidden reports whether tool can never run under p, so it need not be offered to the model.
+func (p *Policy) Hidden(tool string) bool {
+	if p == nil {
+		return false
+	}
+	access := p.Access(tool)
+	return access == Forbidden || (p.ReadOnly && access != ReadOnly)
+}
+
+// Params tells which of the arguments the rules look at a tool takes, whether or not a
+// call passes them.
+type Params struct {
+	Namespace bool
+	Kind      bool
+	// Manifest marks tools whose namespaces and kinds are those of the objects in the
+	// yaml their preview pins, such as generated yaml. Calls without it are not checked
+	// until they are previewed.
+	Manifest bool
+}
+
+// Check decides whether tool may run with input. confirmed tells whether the user has
+// approved this exact call. A nil Policy allows everything. Check only knows the
+// arguments input passes; CheckCall is also told the ones tool takes.
+func (p *Policy) Check(tool string, input json.RawMessage, confirmed bool) Decision {
+	return p.CheckCall(tool, Params{}, input, confirmed)
+}
+
+// CheckCall is Check for a tool taking params. A call that leaves out a namespace, or
+// asks for all namespaces, is denied by rules restricting namespaces, and one that
+// leaves out the kind by rules allowing kinds. Calls carrying a manifest in a yaml
+// argument are checked object by object. The user is asked to confirm before those
+// checks, so a tool can fill in what is missing in the input its preview pins. A call
+// of a Manifest tool is denied until its preview pinned the manifest.
+func (p *Policy) CheckCall(tool string, params Params, input json.RawMessage, confirmed bool) Decision {
+	if p == nil {
+		return Decision{Effect: Allow}
+	}
+
+	access := p.Access(tool)
+	if access == Forbidden {
+		return deny("%s is forbidden", tool)
+	}
+	if p.ReadOnly && access != ReadOnly {
+		return deny("%s changes the cluster and GenesisGpt is running in read-only mode", tool)
+	}
+
+	if params.Manifest && !HasManifest(input) {
+		if access == Confirm && !confirmed {
+			return Decision{Effect: NeedsConfirmation, Reason: tool + " requires the user's confirmation"}
+		}
+		return deny("the %s call has to be previewed, its manifest is checked before it runs", tool)
+	}
+
+	targets, err := parseArgs(input, params)
+	if err != nil {
+		return deny("the manifest of the %s call cannot be read: %v", tool, err)
+	}
+	rule := p.Tools[tool]
+	rules := []Rule{p.Tools[AllTools], rule}
+	for _, r := range rules {
+		for _, args := range targets {
+			if d := r.check(tool, args); !d.Allowed() {
+				return d
+			}
+		}
+	}
+
+	if rule.Deletes && p.BlockClusterScopedDeletes {
+		for _, args := range targets {
+			if allNamespaces(args.namespace) {
+				return deny("deleting without a namespace is blocked")
+			}
+			if args.kind == "" || p.Scope == nil {
+				continue
+			}
+			clusterScoped, err := p.Scope(args.kind)
+			if err != nil {
+				return deny("deleting %s is blocked, whether it is cluster-scoped cannot be told: %v", args.kind, err)
+			}
+			if clusterScoped {
+				return deny("deleting the cluster-scoped kind %s is blocked", args.kind)
+			}
+		}
+	}
+
+	if access == Confirm && !confirmed {
+		return Decision{Effect: NeedsConfirmation, Reason: tool + " requires the user's confirmation"}
+	}
+
+	for _, r := range rules {
+		for _, args := range targets {
+			if d := r.checkNamed(tool, args); !d.Allowed() {
+				return d
+			}
+		}
+	}
+	return Decision{Effect: Allow}
+}
+
+// check denies the namespace and kind args names that r does not allow.
+func (r Rule) check(tool string, args toolArgs) Decision {
+	if args.hasNamespace && !allNamespaces(args.namespace) {
+		if len(r.Namespaces) > 0 && !matchAny(r.Namespaces, args.namespace) {
+			return deny("%s may only be used in namespaces %s, not %q", tool, strings.Join(r.Namespaces, ", "), args.namespace)
+		}
+		if matchAny(r.ForbiddenNamespaces, args.namespace) {
+			return deny("%s may not be used in namespace %s", tool, args.namespace)
+		}
+	}
+	if args.hasKind && args.kind != "" {
+		if len(r.Kinds) > 0 && !kindIn(r.Kinds, args.kind) {
+			return deny("%s may only be used on %s, not %q", tool, strings.Join(r.Kinds, ", "), args.kind)
+		}
+		if kindIn(r.ForbiddenKinds, args.kind) {
+			return deny("%s may not be used on %s", tool, args.kind)
+		}
+	}
+	return Decision{Effect: Allow}
+}
+
+// checkNamed denies args that leave out a namespace or kind r restricts, or that ask for
+// all namespaces.
+func (r Rule) checkNamed(tool string, args toolArgs) Decision {
+	if len(r.Namespaces) > 0 || len(r.ForbiddenNamespaces) > 0 {
+		if args.hasNamespace && allNamespaces(args.namespace) {
+			if args.object != "" {
+				return deny("%s has to name the namespace of %s, the namespaces it may be used in are restricted", tool, args.object)
+			}
+			return deny("%s has to name one namespace, the namespaces it may be used in are restricted", tool)
+		}
+	}
+	if len(r.Kinds) > 0 && args.hasKind && args.kind == "" {
+		return deny("%s has to name the kind, it may only be used on %s", tool, strings.Join(r.Kinds, ", "))
+	}
+	return Decision{Effect: Allow}
+}
+
+func deny(format string, args ...interface{}) Decision {
+	return Decision{Effect: Deny, Reason: fmt.Sprintf(format, args...)}
+}
+
+// toolArgs holds the fields of a tool input, or of one object of its manifest, the rules
+// look at. The has fields tell whether the tool takes them, so an empty value was left out.
+type toolArgs struct {
+	namespace    string
+	hasNamespace bool
+	kind         string
+	hasKind      bool
+	// object names the manifest object the args were read from, as kind/name
+	object string
+}
+
+// parseArgs reads the namespace and the resource kind from a tool input. Tools name
+// them differently, the known spellings are tried in order. An input with a yaml
+// manifest yields the args of each of its objects instead.
+func parseArgs(input json.RawMessage, params Params) ([]toolArgs, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(input, &fields); err != nil {
+		return []toolArgs{{hasNamespace: params.Namespace, hasKind: params.Kind}}, nil
+	}
+	if manifest := manifestField(fields); manifest != "" {
+		return manifestArgs(manifest)
+	}
+
+	args := toolArgs{hasNamespace: params.Namespace, hasKind: params.Kind}
+	if namespace, ok := stringField(fields, "namespace", "ns"); ok {
+		args.namespace, args.hasNamespace = namespace, true
+	}
+	if kind, ok := stringField(fields, "resource", "kind"); ok {
+		args.kind, args.hasKind = kind, true
+	}
+	return []toolArgs{args}, nil
+}
+
+// HasManifest reports whether a tool input carries a manifest in its yaml argument.
+func HasManifest(input json.RawMessage) bool {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(input, &fields); err != nil {
+		return false
+	}
+	return manifestField(fields) != ""
+}
+
+func manifestField(fields map[string]interface{}) string {
+	manifest, _ := fields["yaml"].(string)
+	return strings.TrimSpace(manifest)
+}
+
+// manifestArgs returns the args of every object of a manifest: YAML documents separated
+// by "---", JSON objects or arrays, and List kinds, as ginTools applies them.
+func manifestArgs(manifest string) ([]toolArgs, error) {
+	var objects []toolArgs
+	var collect func(v interface{})
+	collect = func(v interface{}) {
+		switch v := v.(type) {
+		case []interface{}:
+			for _, item := range v {
+				collect(item)
+			}
+		case map[interface{}]interface{}:
+			kind, _ := v["kind"].(string)
+			if items, ok := v["items"].([]interface{}); ok && strings.HasSuffix(kind, "List") {
+				collect(items)
+				return
+			}
+			metadata, _ := v["metadata"].(map[interface{}]interface{})
+			namespace, _ := metadata["namespace"].(string)
+			name, _ := metadata["name"].(string)
+			objects = append(objects, toolArgs{
+				namespace: strings.TrimSpace(namespace), hasNamespace: true,
+				kind: strings.TrimSpace(kind), hasKind: true,
+				object: strings.ToLower(kind) + "/" + name,
+			})
+		}
+	}
+
+	decoder := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		var doc interface{}
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		collect(doc)
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("no objects")
+	}
+	return objects, nil
+}
+
+// allNamespaces reports whether namespace leaves the namespace out or asks for all of them.
+func allNamespaces(namespace string) bool {
+	return namespace == "" || namespace == "*"
+}
+
+func stringField(fields map[string]interface{}, names ...string) (string, bool) {
+	for _, name := range names {
+		if v, ok := fields[name]; ok {
+			s, _ := v.(string)
+			return strings.TrimSpace(s), true
+		}
+	}
+	return "", false
+}
+
+func matchAny(patterns []string, namespace string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, namespace); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeKind maps "Pods", "pod" and "po" style spellings to the lower-case singular.
+func normalizeKind(kind string) string {
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	if short, ok := shortNames[kind]; ok {
+		return short
+	}
+	switch {
+	case strings.HasSuffix(kind, "ies"):
+		// networkpolicies
+		return strings.TrimSuffix(kind, "ies") + "y"
+	case strings.HasSuffix(kind, "sses"):
+		// ingresses, storageclasses
+		return strings.TrimSuffix(kind, "es")
+	case strings.HasSuffix(kind, "s") && !strings.HasSuffix(kind, "ss"):
+		return strings.TrimSuffix(kind, "s")
+	}
+	return kind
+}
+
+func kindIn(kinds []string, kind string) bool {
+	kind = normalizeKind(kind)
+	for _, k := range kinds {
+		if normalizeKind(k) == kind {
+			return true
+		}
+	}
+	return false
+}
+
+var shortNames = map[string]string{
+	"po": "pod", "svc": "service", "deploy": "deployment", "ds": "daemonset", "sts": "statefulset",
+	"rs": "replicaset", "cm": "configmap", "ns": "namespace", "no": "node", "pv": "persistentvolume",
+	"pvc": "persistentvolumeclaim", "sa": "serviceaccount", "ing": "ingress", "crd": "customresourcedefinition",
+	"sc": "storageclass", "cj": "cronjob",
+}
+
+// Approved reports whether a typed answer approves an action.
+func Approved(answer string) bool {
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "yes", "y":
+		return true
+	}
+	return false
+}
+
+// Summary describes the access class of each tool, for startup logs.
+func (p *Policy) Summary(tools []string) string {
+	groups := make(map[Access][]string)
+	for _, tool := range tools {
+		access := p.Access(tool)
+		if p.ReadOnly && access != ReadOnly {
+			access = Forbidden
+		}
+		groups[access] = append(groups[access], tool)
+	}
+
+	var parts []string
+	for _, access := range []Access{ReadOnly, Write, Confirm, Forbidden} {
+		if names := groups[access]; len(names) > 0 {
+			sort.Strings(names)
+			parts = append(parts, fmt.Sprintf("%s: %s", access, strings.Join(names, ", ")))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
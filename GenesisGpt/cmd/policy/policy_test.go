@@ -0,0 +1,217 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// clusterScope is the Scope of a cluster with the cluster-scoped custom resource
+// ClusterIssuer
+func clusterScope(kind string) (bool, error) {
+	switch normalizeKind(kind) {
+	case "pod", "deployment", "service":
+		return false, nil
+	case "namespace", "node", "clusterissuer":
+		return true, nil
+	}
+	return false, fmt.Errorf("the server doesn't have a resource type %q", kind)
+}
+
+func TestCheck(t *testing.T) {
+	scoped := Default()
+	scoped.Scope = clusterScope
+
+	restricted := Default()
+	restricted.Scope = clusterScope
+	restricted.Tools[AllTools] = Rule{ForbiddenKinds: []string{"secret"}, ForbiddenNamespaces: []string{"kube-system"}}
+	restricted.Tools["DeleteTool"] = Rule{Access: Confirm, Deletes: true, Namespaces: []string{"default", "team-*"}, Kinds: []string{"pod", "deployment"}}
+
+	readOnly := Default()
+	readOnly.ReadOnly = true
+
+	tests := []struct {
+		name      string
+		policy    *Policy
+		tool      string
+		input     string
+		confirmed bool
+		want      Effect
+	}{
+		{"nil policy allows", nil, "DeleteTool", `{}`, false, Allow},
+		{"read-only tool", Default(), "ListTool", `{"resource":"pods","namespace":"default"}`, false, Allow},
+		{"delete needs confirmation", scoped, "DeleteTool", `{"resource":"pod","name":"a","namespace":"default"}`, false, NeedsConfirmation},
+		{"confirmed delete", scoped, "DeleteTool", `{"resource":"pod","name":"a","namespace":"default"}`, true, Allow},
+		{"unknown tool needs confirmation", Default(), "ScaleTool", `{}`, false, NeedsConfirmation},
+		{"cluster-scoped delete", scoped, "DeleteTool", `{"resource":"namespaces","name":"prod","namespace":""}`, true, Deny},
+		{"cluster-scoped kind with namespace", scoped, "DeleteTool", `{"resource":"node","name":"n1","namespace":"default"}`, true, Deny},
+		{"cluster-scoped custom resource", scoped, "DeleteTool", `{"resource":"clusterissuers","name":"letsencrypt","namespace":"default"}`, true, Deny},
+		{"kind of unknown scope", scoped, "DeleteTool", `{"resource":"widgets","name":"a","namespace":"default"}`, true, Deny},
+		{"scope not looked up", Default(), "DeleteTool", `{"resource":"node","name":"n1","namespace":"default"}`, true, Allow},
+		{"delete without namespace", scoped, "DeleteTool", `{"resource":"pod","name":"a"}`, true, Deny},
+		{"read-only mode denies writes", readOnly, "DeleteTool", `{"resource":"pod","name":"a","namespace":"default"}`, true, Deny},
+		{"read-only mode allows reads", readOnly, "PodTool", `{"namespace":"default","podName":"a","operation":"logs"}`, false, Allow},
+		{"namespace allow-list", restricted, "DeleteTool", `{"resource":"pod","name":"a","namespace":"team-a"}`, true, Allow},
+		{"namespace outside allow-list", restricted, "DeleteTool", `{"resource":"pod","name":"a","namespace":"prod"}`, true, Deny},
+		{"kind allow-list matches plural", restricted, "DeleteTool", `{"resource":"Deployments","name":"a","namespace":"default"}`, true, Allow},
+		{"kind outside allow-list", restricted, "DeleteTool", `{"resource":"service","name":"a","namespace":"default"}`, true, Deny},
+		{"forbidden kind for all tools", restricted, "ListTool", `{"resource":"secrets","namespace":"default"}`, false, Deny},
+		{"forbidden namespace for all tools", restricted, "PodTool", `{"namespace":"kube-system","podName":"a"}`, false, Deny},
+		{"tools without namespace pass", restricted, "HumanTool", `{"prompt":"ok?"}`, false, Allow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.Check(tt.tool, json.RawMessage(tt.input), tt.confirmed)
+			if got.Effect != tt.want {
+				t.Errorf("Check() = %+v, want effect %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckCall(t *testing.T) {
+	restricted := Default()
+	restricted.Tools[AllTools] = Rule{ForbiddenNamespaces: []string{"kube-system"}}
+	restricted.Tools["CreateTool"] = Rule{Access: Confirm, Namespaces: []string{"team-*"}, Kinds: []string{"deployment", "service"}}
+
+	written := Default()
+	written.Tools[AllTools] = Rule{ForbiddenNamespaces: []string{"kube-system"}, ForbiddenKinds: []string{"secret"}}
+	written.Tools["CreateTool"] = Rule{Access: Write}
+
+	namespaced := Params{Namespace: true}
+	named := Params{Namespace: true, Kind: true}
+	create := Params{Manifest: true}
+	manifest := func(yaml string) string {
+		input, _ := json.Marshal(map[string]string{"prompt": "create web", "yaml": yaml})
+		return string(input)
+	}
+
+	tests := []struct {
+		name      string
+		policy    *Policy
+		tool      string
+		params    Params
+		input     string
+		confirmed bool
+		want      Effect
+	}{
+		{"named namespace", restricted, "ListTool", namespaced, `{"resource":"pods","ns":"default"}`, false, Allow},
+		{"missing namespace", restricted, "ListTool", namespaced, `{"resource":"pods"}`, false, Deny},
+		{"empty namespace", restricted, "ListTool", namespaced, `{"resource":"pods","ns":""}`, false, Deny},
+		{"all namespaces", restricted, "ListTool", namespaced, `{"resource":"pods","ns":"*"}`, false, Deny},
+		{"tool without namespace", restricted, "ClusterTool", Params{}, `{}`, false, Allow},
+		{"unpreviewed create asks first", restricted, "CreateTool", create, `{"prompt":"create web","resource":"deployment"}`, false, NeedsConfirmation},
+		{"approved create without yaml", restricted, "CreateTool", create, `{"prompt":"create web","resource":"deployment"}`, true, Deny},
+		{"manifest in allowed namespaces", restricted, "CreateTool", create, manifest("kind: Deployment\nmetadata:\n  name: web\n  namespace: team-a\n---\nkind: Service\nmetadata:\n  name: web\n  namespace: team-b\n"), true, Allow},
+		{"manifest object outside namespaces", restricted, "CreateTool", create, manifest("kind: Deployment\nmetadata:\n  name: web\n  namespace: team-a\n---\nkind: Service\nmetadata:\n  name: web\n  namespace: prod\n"), false, Deny},
+		{"manifest object without namespace", restricted, "CreateTool", create, manifest("kind: Deployment\nmetadata:\n  name: web\n"), true, Deny},
+		{"manifest object of other kind", restricted, "CreateTool", create, manifest(`{"kind":"List","items":[{"kind":"Secret","metadata":{"name":"s","namespace":"team-a"}}]}`), false, Deny},
+		{"unreadable manifest", restricted, "CreateTool", create, manifest("kind: [\n"), true, Deny},
+		{"unpreviewed write create", written, "CreateTool", create, `{"prompt":"create web","resource":"pod"}`, false, Deny},
+		{"write create of manifest", written, "CreateTool", create, manifest("kind: Pod\nmetadata:\n  name: web\n  namespace: default\n"), false, Allow},
+		{"write create of forbidden kind", written, "CreateTool", create, manifest("kind: Secret\nmetadata:\n  name: token\n  namespace: default\n"), false, Deny},
+		{"write create in forbidden namespace", written, "CreateTool", create, manifest("kind: Pod\nmetadata:\n  name: web\n  namespace: kube-system\n"), false, Deny},
+		{"delete in all namespaces", restricted, "DeleteTool", named, `{"resource":"pod","name":"a","namespace":"*"}`, true, Deny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.CheckCall(tt.tool, tt.params, json.RawMessage(tt.input), tt.confirmed)
+			if got.Effect != tt.want {
+				t.Errorf("CheckCall() = %+v, want effect %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	data := `
+block_cluster_scoped_deletes: false
+tools:
+  CreateTool:
+    access: forbidden
+  DeleteTool:
+    access: write
+    namespaces: [default]
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.BlockClusterScopedDeletes {
+		t.Error("block_cluster_scoped_deletes: false was ignored")
+	}
+	if !p.Hidden("CreateTool") || p.Hidden("DeleteTool") {
+		t.Error("CreateTool should be hidden and DeleteTool offered")
+	}
+	if got := p.Access("ListTool"); got != ReadOnly {
+		t.Errorf("ListTool access = %s, want the default %s", got, ReadOnly)
+	}
+	if d := p.Check("DeleteTool", json.RawMessage(`{"resource":"pod","namespace":"default"}`), false); !d.Allowed() {
+		t.Errorf("write access should not need confirmation: %+v", d)
+	}
+}
+
+func TestLoadMergesRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	data := `
+tools:
+  DeleteTool:
+    namespaces: [default]
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Scope = clusterScope
+	if got := p.Access("DeleteTool"); got != Confirm {
+		t.Errorf("DeleteTool access = %s, want the default %s", got, Confirm)
+	}
+	for _, input := range []string{
+		`{"resource":"node","name":"n1","namespace":"default"}`,
+		`{"resource":"pod","name":"a"}`,
+		`{"resource":"pod","name":"a","namespace":"prod"}`,
+	} {
+		if d := p.Check("DeleteTool", json.RawMessage(input), true); d.Effect != Deny {
+			t.Errorf("Check(%s) = %+v, want it denied", input, d)
+		}
+	}
+	if d := p.Check("DeleteTool", json.RawMessage(`{"resource":"pod","name":"a","namespace":"default"}`), true); !d.Allowed() {
+		t.Errorf("Check() = %+v, want a delete in default allowed", d)
+	}
+}
+
+func TestLoadRejectsInvalidFiles(t *testing.T) {
+	for name, data := range map[string]string{
+		"unknown access": "tools:\n  DeleteTool:\n    access: maybe\n",
+		"unknown field":  "tools:\n  DeleteTool:\n    namespace: [default]\n",
+		"bad pattern":    "tools:\n  DeleteTool:\n    namespaces: [\"[\"]\n",
+		"deletes":        "tools:\n  PatchTool:\n    deletes: false\n",
+	} {
+		path := filepath.Join(t.TempDir(), "policy.yaml")
+		os.WriteFile(path, []byte(data), 0o600)
+		if _, err := Load(path); err == nil {
+			t.Errorf("%s: Load() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestLoadMissingFileUsesDefaults(t *testing.T) {
+	p, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Access("DeleteTool") != Confirm || !p.BlockClusterScopedDeletes {
+		t.Errorf("got %+v, want the default policy", p)
+	}
+}
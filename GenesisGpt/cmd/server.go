@@ -1,370 +1,410 @@
 package cmd
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
-	"regexp"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/spf13/cobra"
-	"github.com/lexieqin/Geek/GenesisGpt/cmd/ai"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/agent"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/audit"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/config"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/policy"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/store"
 	"github.com/lexieqin/Geek/GenesisGpt/cmd/tools"
+	"github.com/lexieqin/Geek/llm"
+	"github.com/spf13/cobra"
 )
 
-// Session management
-var (
-	sessions = make(map[string]*Session)
-	sessionsMutex sync.RWMutex
-)
-
-type Session struct {
-	ID                    string
-	MessageStore          ai.ChatMessages
-	LastAccessed          time.Time
-	PendingConfirmation   bool
-	ConfirmationPrompt    string
+// queryServer holds what the HTTP handlers share.
+type queryServer struct {
+	provider llm.Provider
+	registry *tools.Registry
+	mode     agent.Mode
+	sessions store.SessionStore
+	// locks makes requests on the same session run one at a time
+	locks *store.Locks
+	// approvalTTL is how long a pending action can be approved
+	approvalTTL time.Duration
 }
 
+// janitorInterval is how often expired sessions are removed from the store.
+const janitorInterval = time.Minute
+
 var serverCmd = &cobra.Command{
 	Use:   "server",
 	Short: "Run GenesisGpt as an HTTP server",
 	Long:  `Run GenesisGpt as an HTTP server that accepts queries via POST requests`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		modeFlag, _ := cmd.Flags().GetString("mode")
+		mode, err := agent.ParseMode(modeFlag)
+		if err != nil {
+			return err
+		}
+
 		// Set server mode
 		os.Setenv("GENESISGPT_SERVER_MODE", "true")
-		
-		// Initialize tools
-		createTool := tools.NewCreateTool()
-		listTool := tools.NewListTool()
-		deleteTool := tools.NewDeleteTool()
-		humanTool := tools.NewHumanTool()
-		clustersTool := tools.NewClusterTool()
-		podTool := tools.NewPodTool()
-		resourceInfoTool := tools.NewResourceInfoTool()
-		jobDebugTool := tools.NewJobDebugTool()
-		sandboxLogTool := tools.NewSandboxLogTool()
-		intelligentDebugTool := tools.NewIntelligentDebugTool()
-
-		http.HandleFunc("/query", func(w http.ResponseWriter, r *http.Request) {
-			if r.Method != "POST" {
-				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-				return
-			}
 
-			var request struct {
-				Query               string `json:"query"`
-				ShowThinkingProcess bool   `json:"showThinkingProcess"`
-				SessionID           string `json:"sessionId"`
-			}
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			return err
+		}
+		provider, err := llm.New(cfg.LLM)
+		if err != nil {
+			return err
+		}
 
-			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
+		sessionStore, err := newSessionStore(cmd, cfg.Session)
+		if err != nil {
+			return err
+		}
+		defer sessionStore.Close()
 
-			// Process the query
-			fmt.Printf("Received query: %s (session: %s, show thinking: %v)\n", request.Query, request.SessionID, request.ShowThinkingProcess)
-			response, sessionID := processQueryWithSession(request.Query, request.SessionID, request.ShowThinkingProcess, 
-				createTool, listTool, deleteTool, humanTool, clustersTool, podTool, resourceInfoTool, 
-				jobDebugTool, sandboxLogTool, intelligentDebugTool)
-			fmt.Printf("Sending response: %s\n", response)
-
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(map[string]string{
-				"response": response,
-				"sessionId": sessionID,
-			})
-		})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go store.RunJanitor(ctx, sessionStore, janitorInterval)
+
+		auditLog, err := audit.Open(cfg.Audit)
+		if err != nil {
+			return err
+		}
+		defer auditLog.Close()
+		if auditLog != nil {
+			fmt.Printf("Audit log: %s\n", auditLog.Path())
+		}
+
+		toolPolicy, err := loadPolicy(cmd, cfg.Policy)
+		if err != nil {
+			return err
+		}
+
+		// Initialize tools
+		registry := tools.NewDefaultRegistry(provider)
+		registry.SetAuditLog(auditLog)
+		registry.SetPolicy(toolPolicy)
+		fmt.Printf("Tool policy: %s\n", toolPolicy.Summary(registry.AllNames()))
+		srv := newQueryServer(provider, registry, mode, sessionStore)
+		if cfg.Session.ApprovalTTL > 0 {
+			srv.approvalTTL = cfg.Session.ApprovalTTL
+		}
+
+		http.HandleFunc("/query", srv.handleQuery)
+		http.HandleFunc("/query/stream", srv.handleQueryStream)
+		srv.registerSessionRoutes(http.DefaultServeMux)
 
 		port := os.Getenv("PORT")
 		if port == "" {
 			port = "8090"
 		}
 
-		fmt.Printf("GenesisGpt server listening on port %s\n", port)
-		http.ListenAndServe(":"+port, nil)
+		fmt.Printf("GenesisGpt server listening on port %s (mode: %s)\n", port, mode)
+		return http.ListenAndServe(":"+port, nil)
 	},
 }
 
+func newQueryServer(provider llm.Provider, registry *tools.Registry, mode agent.Mode, sessions store.SessionStore) *queryServer {
+	return &queryServer{
+		provider: provider,
+		registry: registry,
+		mode:     mode,
+		sessions: sessions,
+		locks:    store.NewLocks(sharedLocker(sessions)),
+
+		approvalTTL: store.DefaultApprovalTTL,
+	}
+}
+
+// sharedLocker returns the lock of a store shared between replicas, nil for other stores.
+func sharedLocker(sessions store.SessionStore) store.Locker {
+	locker, _ := sessions.(store.Locker)
+	return locker
+}
+
+// newSessionStore creates the session store from the config file, overridden by the command flags.
+func newSessionStore(cmd *cobra.Command, cfg config.SessionConfig) (store.SessionStore, error) {
+	if cmd.Flags().Changed("session-backend") {
+		cfg.Backend, _ = cmd.Flags().GetString("session-backend")
+	}
+	if cmd.Flags().Changed("session-ttl") {
+		cfg.TTL, _ = cmd.Flags().GetDuration("session-ttl")
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = store.DefaultTTL
+	}
+
+	sessionStore, err := store.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Session store: %s (ttl: %s)\n", defaultString(cfg.Backend, "memory"), cfg.TTL)
+	return sessionStore, nil
+}
+
+// loadPolicy loads the tool policy file; --read-only or the config switch it to read-only mode.
+// The scope of kinds is looked up in the cluster through ginTools.
+func loadPolicy(cmd *cobra.Command, cfg config.PolicyConfig) (*policy.Policy, error) {
+	p, err := policy.Load(defaultString(cfg.Path, "config/policy.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	p.Scope = tools.ClusterScoped
+	if readOnly, _ := cmd.Flags().GetBool("read-only"); readOnly || cfg.ReadOnly {
+		p.ReadOnly = true
+	}
+	return p, nil
+}
+
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func (srv *queryServer) handleQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Query               string `json:"query"`
+		ShowThinkingProcess bool   `json:"showThinkingProcess"`
+		SessionID           string `json:"sessionId"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Process the query
+	fmt.Printf("Received query: %s (session: %s, show thinking: %v)\n", request.Query, request.SessionID, request.ShowThinkingProcess)
+	ctx := audit.WithUser(r.Context(), requestUser(r))
+	reply := srv.processQueryWithSession(ctx, request.Query, request.SessionID, request.ShowThinkingProcess, nil)
+	fmt.Printf("Sending response: %s\n", reply.Response)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reply)
+}
+
 func generateSessionID() string {
-	return fmt.Sprintf("session-%d", time.Now().UnixNano())
+	// The random suffix keeps IDs unique when requests arrive in the same nanosecond
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("session-%d-%s", time.Now().UnixNano(), hex.EncodeToString(suffix))
 }
 
-func getOrCreateSession(sessionID string) *Session {
-	sessionsMutex.Lock()
-	defer sessionsMutex.Unlock()
-	
-	// Clean up old sessions (older than 30 minutes)
-	for id, session := range sessions {
-		if time.Since(session.LastAccessed) > 30*time.Minute {
-			delete(sessions, id)
-		}
+// withSession runs fn on the session while holding its lock, then saves it back to the
+// store. A new session is started when sessionID is empty, unknown or expired.
+func (srv *queryServer) withSession(ctx context.Context, sessionID string, fn func(session *store.Session)) (string, error) {
+	if sessionID == "" {
+		sessionID = generateSessionID()
 	}
-	
-	// Get or create session
-	if sessionID != "" {
-		if session, exists := sessions[sessionID]; exists {
-			session.LastAccessed = time.Now()
-			return session
-		}
+
+	// Requests on the same session queue here
+	release, err := srv.locks.Acquire(ctx, sessionID)
+	if err != nil {
+		return sessionID, err
 	}
-	
-	// Create new session
-	newID := sessionID
-	if newID == "" {
-		newID = generateSessionID()
+	defer release()
+
+	session, err := srv.sessions.Get(ctx, sessionID)
+	if errors.Is(err, store.ErrNotFound) {
+		session = store.NewSession(sessionID)
+	} else if err != nil {
+		return sessionID, fmt.Errorf("failed to load session: %v", err)
 	}
-	
-	messageStore := make(ai.ChatMessages, 0)
-	messageStore.Clear() // Initialize with system prompt
-	
-	session := &Session{
-		ID:           newID,
-		MessageStore: messageStore,
-		LastAccessed: time.Now(),
+
+	fn(session)
+
+	session.LastAccessed = time.Now()
+	// The request context may already be cancelled, the session must be saved regardless
+	if err := srv.sessions.Save(context.WithoutCancel(ctx), session); err != nil {
+		return sessionID, fmt.Errorf("failed to save session: %v", err)
 	}
-	sessions[newID] = session
-	return session
+	return sessionID, nil
+}
+
+// queryReply is the answer to a query or to a confirmation.
+type queryReply struct {
+	Response  string `json:"response"`
+	SessionID string `json:"sessionId"`
+	// PendingAction is set when a tool call waits for the user's approval
+	PendingAction *store.PendingAction `json:"pendingAction,omitempty"`
 }
 
-func processQueryWithSession(query, sessionID string, showThinkingProcess bool, 
-	createTool *tools.CreateTool, listTool *tools.ListTool, deleteTool *tools.DeleteTool, 
-	humanTool *tools.HumanTool, clustersTool *tools.ClusterTool, podTool *tools.PodTool, 
-	resourceInfoTool *tools.ResourceInfoTool, jobDebugTool *tools.JobDebugTool,
-	sandboxLogTool *tools.SandboxLogTool, intelligentDebugTool *tools.IntelligentDebugTool) (string, string) {
-	
-	// Get or create session
-	session := getOrCreateSession(sessionID)
-	
-	// Check if this is a response to a pending confirmation
-	if session.PendingConfirmation && (strings.ToLower(strings.TrimSpace(query)) == "yes" || strings.ToLower(strings.TrimSpace(query)) == "no") {
-		// Add the human confirmation response to the conversation
-		session.MessageStore.AddForUser(fmt.Sprintf("Observation: %s", strings.TrimSpace(query)))
-		session.PendingConfirmation = false
-		session.ConfirmationPrompt = ""
-		
-		// Continue processing from where we left off
-		response := processQueryWithSessionObj("", showThinkingProcess, session, createTool, listTool, 
-			deleteTool, humanTool, clustersTool, podTool, resourceInfoTool, jobDebugTool, 
-			sandboxLogTool, intelligentDebugTool)
-		
-		return response, session.ID
+// processQueryWithSession answers query within the session. When onEvent is set the
+// model output is streamed and every agent step is forwarded to it.
+func (srv *queryServer) processQueryWithSession(ctx context.Context, query, sessionID string, showThinkingProcess bool,
+	onEvent func(agent.Event)) queryReply {
+	var reply queryReply
+	sessionID, err := srv.withSession(ctx, sessionID, func(session *store.Session) {
+		req := &audit.Request{SessionID: session.ID, User: audit.UserFrom(ctx), Query: query}
+		ctx := audit.WithRequest(ctx, req)
+
+		// Check if this is a response to a pending confirmation
+		if session.PendingConfirmation && (strings.ToLower(strings.TrimSpace(query)) == "yes" || strings.ToLower(strings.TrimSpace(query)) == "no") {
+			answer := strings.ToLower(strings.TrimSpace(query))
+			pending := &agent.Confirmation{Prompt: session.ConfirmationPrompt, ToolCallID: session.PendingToolCallID}
+			if session.PendingQuery != "" {
+				req.Query = session.PendingQuery
+			}
+			srv.recordConfirmation(ctx, session.ConfirmationPrompt, answer)
+			session.PendingConfirmation = false
+			session.ConfirmationPrompt = ""
+			session.PendingToolCallID = ""
+			session.PendingQuery = ""
+
+			// Continue processing from where we left off
+			reply.Response = srv.processQueryWithSessionObj(session, showThinkingProcess, onEvent, func(a *agent.Agent) (*agent.Result, error) {
+				return a.Resume(ctx, &session.MessageStore, pending, strings.TrimSpace(query))
+			})
+		} else {
+			// A new question instead of an answer or a decision leaves the pending question
+			// unanswered and the pending action unapproved
+			srv.dropPendingConfirmation(session, audit.UserFrom(ctx))
+			srv.dropPendingAction(session, audit.UserFrom(ctx))
+
+			// Process query with session's message store
+			reply.Response = srv.processQueryWithSessionObj(session, showThinkingProcess, onEvent, func(a *agent.Agent) (*agent.Result, error) {
+				return a.Run(ctx, &session.MessageStore, query)
+			})
+		}
+
+		if session.PendingConfirmation || session.PendingAction != nil {
+			session.PendingQuery = req.Query
+		}
+		reply.PendingAction = session.PendingAction
+	})
+	reply.SessionID = sessionID
+	if err != nil {
+		fmt.Printf("Session %s: %v\n", sessionID, err)
+		if reply.Response == "" {
+			reply.Response = "Error: " + err.Error()
+		}
 	}
-	
-	// Process query with session's message store
-	response := processQueryWithSessionObj(query, showThinkingProcess, session, createTool, listTool, 
-		deleteTool, humanTool, clustersTool, podTool, resourceInfoTool, jobDebugTool, 
-		sandboxLogTool, intelligentDebugTool)
-	
-	return response, session.ID
+	return reply
+}
+
+// recordConfirmation logs the human's answer to a HumanTool question. The answer does
+// not approve any tool call, those are approved through their pending action.
+func (srv *queryServer) recordConfirmation(ctx context.Context, prompt, answer string) {
+	input, _ := json.Marshal(tools.HumanToolParam{Prompt: prompt})
+	srv.registry.Record(ctx, "HumanTool", input, answer, "")
 }
 
-func processQueryWithSessionObj(query string, showThinkingProcess bool, session *Session,
-	createTool *tools.CreateTool, listTool *tools.ListTool, 
-	deleteTool *tools.DeleteTool, humanTool *tools.HumanTool, clustersTool *tools.ClusterTool, 
-	podTool *tools.PodTool, resourceInfoTool *tools.ResourceInfoTool, jobDebugTool *tools.JobDebugTool,
-	sandboxLogTool *tools.SandboxLogTool, intelligentDebugTool *tools.IntelligentDebugTool) string {
-	
-	// Build prompt
-	if query != "" {
-		prompt := buildServerPrompt(createTool, listTool, deleteTool, humanTool, clustersTool, 
-			podTool, resourceInfoTool, jobDebugTool, sandboxLogTool, intelligentDebugTool, query)
-		
-		// Use the session's messageStore to maintain context
-		session.MessageStore.AddForUser(prompt)
+// requestUser identifies the client of r for the audit log. A proxy in front of the
+// server may pass the authenticated user, otherwise the remote address is used.
+func requestUser(r *http.Request) string {
+	if user := r.Header.Get("X-Forwarded-User"); user != "" {
+		return user
 	}
-	
+	return r.RemoteAddr
+}
+
+// processQueryWithSessionObj runs the agent on the session and renders the reply,
+// including every round when showThinkingProcess is set.
+func (srv *queryServer) processQueryWithSessionObj(session *store.Session, showThinkingProcess bool,
+	onEvent func(agent.Event), run func(a *agent.Agent) (*agent.Result, error)) string {
 	var fullConversation strings.Builder
-	
-	// Process with AI
-	maxRounds := 10
-	for i := 1; i <= maxRounds; i++ {
-		fmt.Printf("Round %d - Calling AI...\n", i)
-		response := ai.NormalChat(session.MessageStore.ToMessage())
-		fmt.Printf("AI Response: %s\n", response.Content)
-		
-		// Add to full conversation if showing thinking process
-		if showThinkingProcess {
-			fullConversation.WriteString(fmt.Sprintf("**Round %d:**\n", i))
-			fullConversation.WriteString(response.Content)
-			fullConversation.WriteString("\n\n")
+
+	queryAgent := agent.New(srv.provider, srv.registry, srv.mode)
+	queryAgent.Stream = onEvent != nil
+	queryAgent.OnEvent = func(e agent.Event) {
+		if onEvent != nil {
+			onEvent(e)
 		}
-		
-		// Check for final answer (capture everything after "Final Answer:")
-		if strings.Contains(response.Content, "Final Answer:") {
-			parts := strings.SplitN(response.Content, "Final Answer:", 2)
-			if len(parts) == 2 {
-				finalAnswer := strings.TrimSpace(parts[1])
-				if showThinkingProcess {
-					fullConversation.WriteString("---\n\n**Final Answer:**\n")
-					fullConversation.WriteString(finalAnswer)
-					return fullConversation.String()
-				}
-				return finalAnswer
+
+		switch e.Type {
+		case agent.EventResponse:
+			fmt.Printf("Round %d - AI Response: %s\n", e.Round, e.Content)
+			if showThinkingProcess {
+				fullConversation.WriteString(fmt.Sprintf("**Round %d:**\n", e.Round))
+				fullConversation.WriteString(e.Content)
+				fullConversation.WriteString("\n\n")
 			}
-		}
-		
-		session.MessageStore.AddForAssistant(response.Content)
-		
-		// Extract and execute action
-		actionRe := regexp.MustCompile(`Action:\s*(.*?)[\n]`)
-		actionInputRe := regexp.MustCompile(`Action Input:\s*({[\s\S]*?})`)
-		
-		action := actionRe.FindStringSubmatch(response.Content)
-		actionInput := actionInputRe.FindStringSubmatch(response.Content)
-		
-		if len(action) > 1 && len(actionInput) > 1 {
-			observation := executeAction(action[1], actionInput[1], createTool, listTool, 
-				deleteTool, humanTool, clustersTool, podTool, resourceInfoTool, 
-				jobDebugTool, sandboxLogTool, intelligentDebugTool)
-			
-			// Check if human confirmation is required
-			if strings.Contains(observation, "[HUMAN_CONFIRMATION_REQUIRED]") {
-				// Extract the confirmation prompt
-				confirmationRe := regexp.MustCompile(`\[HUMAN_CONFIRMATION_REQUIRED\]: (.+)`)
-				matches := confirmationRe.FindStringSubmatch(observation)
-				if len(matches) > 1 {
-					confirmPrompt := matches[1]
-					session.PendingConfirmation = true
-					session.ConfirmationPrompt = confirmPrompt
-					
-					if showThinkingProcess {
-						fullConversation.WriteString("\n")
-						fullConversation.WriteString(observation)
-						fullConversation.WriteString("\n\n---\n\n**Human Confirmation Required:**\n")
-						fullConversation.WriteString(confirmPrompt)
-						fullConversation.WriteString("\n\nPlease respond with 'yes' or 'no' to continue.")
-						return fullConversation.String()
-					}
-					return fmt.Sprintf("**Confirmation Required:** %s\n\nPlease respond with 'yes' or 'no' to continue.", confirmPrompt)
-				}
+		case agent.EventAction:
+			if showThinkingProcess && srv.mode == agent.ModeTools {
+				fullConversation.WriteString(fmt.Sprintf("Action: %s\nAction Input: %s\n", e.Tool, e.Input))
 			}
-			
+		case agent.EventObservation:
 			if showThinkingProcess {
 				fullConversation.WriteString("\n")
-				fullConversation.WriteString(observation)
+				fullConversation.WriteString(e.Content)
 				fullConversation.WriteString("\n\n")
 			}
-			
-			prompt := response.Content + observation
-			session.MessageStore.AddForUser(prompt)
-		} else {
-			// No valid action, return current response
-			if showThinkingProcess {
-				return fullConversation.String() + "\n\n**Note:** Process ended without a clear final answer."
-			}
-			return response.Content
 		}
 	}
-	
-	return "I couldn't complete the task within the allowed steps. Please try a simpler query."
-}
 
-
-func executeAction(actionName, actionInput string, createTool *tools.CreateTool, listTool *tools.ListTool,
-	deleteTool *tools.DeleteTool, humanTool *tools.HumanTool, clustersTool *tools.ClusterTool,
-	podTool *tools.PodTool, resourceInfoTool *tools.ResourceInfoTool, jobDebugTool *tools.JobDebugTool,
-	sandboxLogTool *tools.SandboxLogTool, intelligentDebugTool *tools.IntelligentDebugTool) string {
-	
-	observation := "Observation: "
-	
-	switch actionName {
-	case createTool.Name:
-		var param tools.CreateToolParam
-		json.Unmarshal([]byte(actionInput), &param)
-		output := createTool.Run(param.Prompt, param.Resource)
-		observation += output
-		
-	case listTool.Name:
-		var param tools.ListToolParam
-		json.Unmarshal([]byte(actionInput), &param)
-		output, _ := listTool.Run(param.Resource, param.Namespace, param.Name, param.Type)
-		observation += output
-		
-	case deleteTool.Name:
-		var param tools.DeleteToolParam
-		json.Unmarshal([]byte(actionInput), &param)
-		err := deleteTool.Run(param.Resource, param.Name, param.Namespace)
-		if err != nil {
-			observation += "Deletion failed: " + err.Error()
-		} else {
-			observation += "Deletion successful"
-		}
-		
-	case humanTool.Name:
-		var param tools.HumanToolParam
-		json.Unmarshal([]byte(actionInput), &param)
-		output := humanTool.Run(param.Prompt)
-		observation += output
-		
-	case clustersTool.Name:
-		output, _ := clustersTool.Run()
-		observation += output
-		
-	case podTool.Name:
-		var param tools.PodToolParam
-		json.Unmarshal([]byte(actionInput), &param)
-		output, err := podTool.Run(param)
-		if err != nil {
-			observation += "Error: " + err.Error()
-		} else {
-			observation += output
+	result, err := run(queryAgent)
+	if err != nil {
+		if errors.Is(err, agent.ErrMaxRounds) {
+			return "I couldn't complete the task within the allowed steps. Please try a simpler query."
 		}
-		
-	case resourceInfoTool.Name:
-		var param tools.ResourceInfoToolParam
-		json.Unmarshal([]byte(actionInput), &param)
-		output, err := resourceInfoTool.Run(param)
-		if err != nil {
-			observation += "Error: " + err.Error()
-		} else {
-			observation += output
+		return "Error: " + err.Error()
+	}
+
+	// A tool call waits for the user's approval
+	if result.Action != nil {
+		session.PendingAction = srv.newPendingAction(result.Action)
+		prompt := fmt.Sprintf("**Approval Required:** %s %s\n\n%s. Approve or deny this action to continue.",
+			result.Action.Tool, result.Action.Input, result.Action.Reason)
+		if result.Action.Preview != "" {
+			// The preview shows the input in readable form
+			prompt = fmt.Sprintf("**Approval Required:** %s\n\n%s\n\n%s. Approve or deny this action to continue.",
+				result.Action.Tool, result.Action.Preview, result.Action.Reason)
 		}
-		
-	case jobDebugTool.Name():
-		output, err := jobDebugTool.Run(actionInput)
-		if err != nil {
-			observation += "Error: " + err.Error()
-		} else {
-			observation += output
+		if showThinkingProcess {
+			fullConversation.WriteString("\n---\n\n")
+			fullConversation.WriteString(prompt)
+			return fullConversation.String()
 		}
-		
-	case sandboxLogTool.Name():
-		output, err := sandboxLogTool.Run(actionInput)
-		if err != nil {
-			observation += "Error: " + err.Error()
-		} else {
-			observation += output
+		return prompt
+	}
+
+	// Check if human confirmation is required
+	if result.Confirmation != nil {
+		session.PendingConfirmation = true
+		session.ConfirmationPrompt = result.Confirmation.Prompt
+		session.PendingToolCallID = result.Confirmation.ToolCallID
+
+		if showThinkingProcess {
+			fullConversation.WriteString("\n---\n\n**Human Confirmation Required:**\n")
+			fullConversation.WriteString(result.Confirmation.Prompt)
+			fullConversation.WriteString("\n\nPlease respond with 'yes' or 'no' to continue.")
+			return fullConversation.String()
 		}
-		
-	case intelligentDebugTool.Name():
-		output, err := intelligentDebugTool.Run(actionInput)
-		if err != nil {
-			observation += "Error: " + err.Error()
-		} else {
-			observation += output
+		return fmt.Sprintf("**Confirmation Required:** %s\n\nPlease respond with 'yes' or 'no' to continue.", result.Confirmation.Prompt)
+	}
+
+	if !result.Complete {
+		// No valid action, return current response
+		if showThinkingProcess {
+			return fullConversation.String() + "\n\n**Note:** Process ended without a clear final answer."
 		}
-		
-	default:
-		observation += fmt.Sprintf("Unknown action: %s", actionName)
+		return result.FinalAnswer
 	}
-	
-	return observation
-}
 
-func buildServerPrompt(createTool *tools.CreateTool, listTool *tools.ListTool, deleteTool *tools.DeleteTool, 
-	humanTool *tools.HumanTool, clustersTool *tools.ClusterTool, podTool *tools.PodTool, 
-	resourceInfoTool *tools.ResourceInfoTool, jobDebugTool *tools.JobDebugTool, 
-	sandboxLogTool *tools.SandboxLogTool, intelligentDebugTool *tools.IntelligentDebugTool, query string) string {
-	// For now, use the same logic as chat - we could refactor this into a shared package
-	return buildPrompt(createTool, listTool, deleteTool, humanTool, clustersTool, 
-		podTool, resourceInfoTool, jobDebugTool, sandboxLogTool, intelligentDebugTool, query)
+	if showThinkingProcess {
+		fullConversation.WriteString("---\n\n**Final Answer:**\n")
+		fullConversation.WriteString(result.FinalAnswer)
+		return fullConversation.String()
+	}
+	return result.FinalAnswer
 }
 
 func init() {
 	rootCmd.AddCommand(serverCmd)
-}
\ No newline at end of file
+
+	serverCmd.Flags().String("mode", string(agent.ModeReAct), "Tool calling mode: 'react' (text Action/Action Input) or 'tools' (native function calling)")
+	serverCmd.Flags().String("session-backend", "", "Session store: 'memory', 'file' or 'redis' (default from config, else memory)")
+	serverCmd.Flags().Duration("session-ttl", store.DefaultTTL, "How long an idle session is kept")
+	serverCmd.Flags().Bool("read-only", false, "Only allow tools that do not change the cluster")
+}
@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/agent"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/audit"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/store"
+)
+
+// sessionSummary is the list view of a session, without its messages.
+type sessionSummary struct {
+	ID                  string    `json:"id"`
+	CreatedAt           time.Time `json:"createdAt"`
+	LastAccessed        time.Time `json:"lastAccessed"`
+	MessageCount        int       `json:"messageCount"`
+	PendingConfirmation bool      `json:"pendingConfirmation"`
+	ConfirmationPrompt  string    `json:"confirmationPrompt,omitempty"`
+	// PendingAction is the tool call waiting for approval
+	PendingAction *store.PendingAction `json:"pendingAction,omitempty"`
+}
+
+func summarize(s *store.Session) sessionSummary {
+	return sessionSummary{
+		ID:                  s.ID,
+		CreatedAt:           s.CreatedAt,
+		LastAccessed:        s.LastAccessed,
+		MessageCount:        len(s.MessageStore),
+		PendingConfirmation: s.PendingConfirmation,
+		ConfirmationPrompt:  s.ConfirmationPrompt,
+		PendingAction:       s.PendingAction,
+	}
+}
+
+// registerSessionRoutes adds the session management endpoints:
+//
+//	GET    /sessions             list sessions
+//	GET    /sessions/{id}        fetch one session with its messages
+//	GET    /sessions/{id}/export download a session as JSON, or as Markdown with ?format=markdown
+//	DELETE /sessions/{id}        delete a session
+//	POST   /sessions/{id}/confirm approve or deny the pending action with its token
+func (srv *queryServer) registerSessionRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /sessions", srv.handleListSessions)
+	mux.HandleFunc("GET /sessions/{id}", srv.handleGetSession)
+	mux.HandleFunc("GET /sessions/{id}/export", srv.handleExportSession)
+	mux.HandleFunc("DELETE /sessions/{id}", srv.handleDeleteSession)
+	mux.HandleFunc("POST /sessions/{id}/confirm", srv.handleConfirm)
+}
+
+func (srv *queryServer) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := srv.sessions.List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	summaries := make([]sessionSummary, 0, len(sessions))
+	for _, s := range sessions {
+		summaries = append(summaries, summarize(s))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"sessions": summaries})
+}
+
+func (srv *queryServer) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	session, ok := srv.loadSession(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, session)
+}
+
+func (srv *queryServer) handleExportSession(w http.ResponseWriter, r *http.Request) {
+	session, ok := srv.loadSession(w, r)
+	if !ok {
+		return
+	}
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", session.ID+".json"))
+		writeJSON(w, http.StatusOK, session)
+	case "markdown", "md":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", session.ID+".md"))
+		w.Write([]byte(renderSessionMarkdown(session)))
+	default:
+		http.Error(w, fmt.Sprintf("unsupported format %q: must be json or markdown", format), http.StatusBadRequest)
+	}
+}
+
+func (srv *queryServer) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	if err := srv.sessions.Delete(r.Context(), r.PathValue("id")); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadSession fetches the session named in the path, writing the error response if it fails.
+func (srv *queryServer) loadSession(w http.ResponseWriter, r *http.Request) (*store.Session, bool) {
+	session, err := srv.sessions.Get(r.Context(), r.PathValue("id"))
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return session, true
+}
+
+// renderSessionMarkdown renders the conversation as a readable transcript.
+func renderSessionMarkdown(s *store.Session) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Session %s\n\n", s.ID)
+	fmt.Fprintf(&b, "- Created: %s\n", s.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Last accessed: %s\n", s.LastAccessed.Format(time.RFC3339))
+	if s.PendingConfirmation {
+		fmt.Fprintf(&b, "- Pending confirmation: %s\n", s.ConfirmationPrompt)
+	}
+	if s.PendingAction != nil {
+		fmt.Fprintf(&b, "- Pending action: %s `%s`, expires %s\n", s.PendingAction.Tool, s.PendingAction.Input, s.PendingAction.ExpiresAt.Format(time.RFC3339))
+	}
+
+	for _, m := range s.MessageStore {
+		fmt.Fprintf(&b, "\n## %s\n\n", m.Msg.Role)
+		if m.Msg.Content != "" {
+			b.WriteString(m.Msg.Content)
+			b.WriteString("\n")
+		}
+		for _, call := range m.Msg.ToolCalls {
+			fmt.Fprintf(&b, "\n- Tool call `%s`: `%s`\n", call.Function.Name, call.Function.Arguments)
+		}
+	}
+	return b.String()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// newPendingAction records action as waiting for approval with a fresh token.
+func (srv *queryServer) newPendingAction(action *agent.Action) *store.PendingAction {
+	token := make([]byte, 16)
+	rand.Read(token)
+
+	now := time.Now()
+	return &store.PendingAction{
+		Token:      hex.EncodeToString(token),
+		Tool:       action.Tool,
+		Input:      action.Input,
+		ToolCallID: action.ToolCallID,
+		Reason:     action.Reason,
+		Preview:    action.Preview,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(srv.approvalTTL),
+	}
+}
+
+func agentAction(pending *store.PendingAction) *agent.Action {
+	return &agent.Action{Tool: pending.Tool, Input: pending.Input, ToolCallID: pending.ToolCallID, Reason: pending.Reason, Preview: pending.Preview}
+}
+
+// dropPendingAction tells the model that the session's pending action did not run,
+// because it expired or the user moved on without deciding.
+func (srv *queryServer) dropPendingAction(session *store.Session, user string) {
+	pending := session.PendingAction
+	if pending == nil {
+		return
+	}
+	session.PendingAction = nil
+
+	confirmation, output := audit.ConfirmationDenied, "Not executed: the user did not approve this action."
+	if pending.Expired(time.Now()) {
+		confirmation, output = audit.ConfirmationExpired, "Not executed: the approval expired."
+	}
+	ctx := audit.WithRequest(context.Background(), &audit.Request{SessionID: session.ID, User: user, Query: session.PendingQuery})
+	session.PendingQuery = ""
+
+	srv.registry.Record(ctx, pending.Tool, pending.Input, confirmation, output)
+	agent.New(srv.provider, srv.registry, srv.mode).AnswerAction(ctx, &session.MessageStore, agentAction(pending), output)
+}
+
+// UnansweredOutput is what the model is told about a HumanTool question the user did not
+// answer before asking something else.
+const UnansweredOutput = "The user did not answer the question and asked something else instead."
+
+// dropPendingConfirmation answers the session's pending HumanTool question, which the
+// user moved on from, so the conversation does not end in a tool call without a reply.
+func (srv *queryServer) dropPendingConfirmation(session *store.Session, user string) {
+	if !session.PendingConfirmation {
+		return
+	}
+	pending := &agent.Action{Tool: "HumanTool", ToolCallID: session.PendingToolCallID}
+	ctx := audit.WithRequest(context.Background(), &audit.Request{SessionID: session.ID, User: user, Query: session.PendingQuery})
+	srv.recordConfirmation(ctx, session.ConfirmationPrompt, "")
+
+	session.PendingConfirmation = false
+	session.ConfirmationPrompt = ""
+	session.PendingToolCallID = ""
+	session.PendingQuery = ""
+	agent.New(srv.provider, srv.registry, srv.mode).AnswerAction(ctx, &session.MessageStore, pending, UnansweredOutput)
+}
+
+func (srv *queryServer) handleConfirm(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Token               string `json:"token"`
+		Approve             bool   `json:"approve"`
+		ShowThinkingProcess bool   `json:"showThinkingProcess"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.Token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+	if _, ok := srv.loadSession(w, r); !ok {
+		return
+	}
+
+	ctx := audit.WithUser(r.Context(), requestUser(r))
+	reply, status, err := srv.confirmAction(ctx, r.PathValue("id"), request.Token, request.Approve, request.ShowThinkingProcess, nil)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	writeJSON(w, http.StatusOK, reply)
+}
+
+// confirmAction applies the user's decision on the session's pending action and
+// continues the conversation. Only the action the token was issued for can be decided.
+func (srv *queryServer) confirmAction(ctx context.Context, sessionID, token string, approve, showThinkingProcess bool,
+	onEvent func(agent.Event)) (queryReply, int, error) {
+	reply := queryReply{SessionID: sessionID}
+	status, failure := http.StatusOK, error(nil)
+
+	_, err := srv.withSession(ctx, sessionID, func(session *store.Session) {
+		pending := session.PendingAction
+		switch {
+		case pending == nil:
+			status, failure = http.StatusConflict, errors.New("no action is waiting for approval")
+			return
+		case subtle.ConstantTimeCompare([]byte(token), []byte(pending.Token)) != 1:
+			status, failure = http.StatusForbidden, errors.New("token does not match the pending action")
+			return
+		case pending.Expired(time.Now()):
+			srv.dropPendingAction(session, audit.UserFrom(ctx))
+			status, failure = http.StatusGone, errors.New("approval expired, ask again to retry the action")
+			return
+		}
+
+		req := &audit.Request{SessionID: session.ID, User: audit.UserFrom(ctx), Query: session.PendingQuery}
+		ctx := audit.WithRequest(ctx, req)
+		session.PendingAction = nil
+		session.PendingQuery = ""
+
+		reply.Response = srv.processQueryWithSessionObj(session, showThinkingProcess, onEvent, func(a *agent.Agent) (*agent.Result, error) {
+			return a.ResumeAction(ctx, &session.MessageStore, agentAction(pending), approve)
+		})
+		if session.PendingConfirmation || session.PendingAction != nil {
+			session.PendingQuery = req.Query
+		}
+		reply.PendingAction = session.PendingAction
+	})
+	if err != nil {
+		return reply, http.StatusInternalServerError, err
+	}
+	return reply, status, failure
+}
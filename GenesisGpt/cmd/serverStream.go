@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/agent"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/audit"
+)
+
+// sseWriter writes Server-Sent Events and flushes after each one.
+type sseWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func newSSEWriter(w http.ResponseWriter) (*sseWriter, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, false
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Disable response buffering in nginx-style proxies
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &sseWriter{w: w, flusher: flusher}, true
+}
+
+// Send writes one event with data encoded as JSON.
+func (s *sseWriter) Send(event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// sseEventNames maps agent steps to the event names sent to the client.
+var sseEventNames = map[agent.EventType]string{
+	agent.EventToken:        "token",
+	agent.EventThought:      "thought",
+	agent.EventAction:       "action",
+	agent.EventObservation:  "observation",
+	agent.EventConfirmation: "confirmation_required",
+	agent.EventApproval:     "approval_required",
+	agent.EventFinalAnswer:  "final_answer",
+}
+
+// handleQueryStream serves /query/stream. It accepts the same JSON body as /query via POST,
+// or query/sessionId/showThinkingProcess URL parameters via GET for EventSource clients,
+// and emits one event per agent step followed by a final "done" event.
+func (srv *queryServer) handleQueryStream(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Query               string `json:"query"`
+		ShowThinkingProcess bool   `json:"showThinkingProcess"`
+		SessionID           string `json:"sessionId"`
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case http.MethodGet:
+		request.Query = r.URL.Query().Get("query")
+		request.SessionID = r.URL.Query().Get("sessionId")
+		request.ShowThinkingProcess, _ = strconv.ParseBool(r.URL.Query().Get("showThinkingProcess"))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sse, ok := newSSEWriter(w)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("Received streaming query: %s (session: %s)\n", request.Query, request.SessionID)
+	ctx := audit.WithUser(r.Context(), requestUser(r))
+	reply := srv.processQueryWithSession(ctx, request.Query, request.SessionID, request.ShowThinkingProcess,
+		func(e agent.Event) {
+			if name, ok := sseEventNames[e.Type]; ok {
+				sse.Send(name, e)
+			}
+		})
+
+	sse.Send("done", reply)
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/agent"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/ai"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/store"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/tools"
+	"github.com/lexieqin/Geek/llm"
+)
+
+func newTestServer() *queryServer {
+	return newQueryServer(llm.NewScripted(), tools.NewRegistry(), agent.ModeReAct, store.NewMemoryStore(time.Hour))
+}
+
+// TestWithSessionSerializesRequests checks that concurrent requests on one session
+// do not interleave their messages.
+func TestWithSessionSerializesRequests(t *testing.T) {
+	srv := newTestServer()
+	ctx := context.Background()
+
+	const requests = 10
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := srv.withSession(ctx, "shared", func(session *store.Session) {
+				session.MessageStore.AddForUser(fmt.Sprintf("query %d", i))
+				// Give other requests a chance to interleave
+				time.Sleep(time.Millisecond)
+				session.MessageStore.AddForAssistant(fmt.Sprintf("answer %d", i))
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	session, err := srv.sessions.Get(ctx, "shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// System prompt, then one query/answer pair per request
+	messages := session.MessageStore[1:]
+	if len(messages) != 2*requests {
+		t.Fatalf("got %d messages, want %d", len(messages), 2*requests)
+	}
+	for i := 0; i < len(messages); i += 2 {
+		query, answer := messages[i].Msg, messages[i+1].Msg
+		var n int
+		fmt.Sscanf(query.Content, "query %d", &n)
+		if query.Role != ai.RoleUser || answer.Role != ai.RoleAssistant || answer.Content != fmt.Sprintf("answer %d", n) {
+			t.Errorf("messages %d-%d interleaved: %q, %q", i, i+1, query.Content, answer.Content)
+		}
+	}
+	if n := srv.locks.Len(); n != 0 {
+		t.Errorf("%d session locks left after all requests", n)
+	}
+}
+
+// TestWithSessionIsolatesSessions checks that sessions without an ID get their own state.
+func TestWithSessionIsolatesSessions(t *testing.T) {
+	srv := newTestServer()
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	ids := make(map[string]bool)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id, err := srv.withSession(ctx, "", func(session *store.Session) {
+				session.MessageStore.AddForUser(fmt.Sprintf("query %d", i))
+			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			ids[id] = true
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	if len(ids) != 20 {
+		t.Fatalf("got %d distinct session IDs, want 20", len(ids))
+	}
+	for id := range ids {
+		session, err := srv.sessions.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(session.MessageStore) != 2 {
+			t.Errorf("session %s has %d messages, want 2", id, len(session.MessageStore))
+		}
+	}
+}
+
+// TestConfirmRejectsStaleApprovals checks that only the pending action's own token,
+// before it expires, can decide on it.
+func TestConfirmRejectsStaleApprovals(t *testing.T) {
+	srv := newTestServer()
+	mux := http.NewServeMux()
+	srv.registerSessionRoutes(mux)
+
+	now := time.Now()
+	save := func(id string, pending *store.PendingAction) {
+		session := store.NewSession(id)
+		session.PendingAction = pending
+		if err := srv.sessions.Save(context.Background(), session); err != nil {
+			t.Fatal(err)
+		}
+	}
+	save("idle", nil)
+	save("waiting", &store.PendingAction{Token: "abc", Tool: "DeleteTool", Input: json.RawMessage(`{}`), ExpiresAt: now.Add(time.Minute)})
+	save("expired", &store.PendingAction{Token: "abc", Tool: "DeleteTool", Input: json.RawMessage(`{}`), ExpiresAt: now.Add(-time.Minute)})
+
+	tests := []struct {
+		name    string
+		session string
+		token   string
+		want    int
+	}{
+		{"missing session", "unknown", "abc", http.StatusNotFound},
+		{"nothing pending", "idle", "abc", http.StatusConflict},
+		{"wrong token", "waiting", "abd", http.StatusForbidden},
+		{"missing token", "waiting", "", http.StatusBadRequest},
+		{"expired", "expired", "abc", http.StatusGone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"token":%q,"approve":true}`, tt.token)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/sessions/"+tt.session+"/confirm", strings.NewReader(body)))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+
+	// A refused token leaves the action waiting, an expired one is answered and cleared
+	waiting, _ := srv.sessions.Get(context.Background(), "waiting")
+	if waiting.PendingAction == nil {
+		t.Error("wrong token cleared the pending action")
+	}
+	expired, _ := srv.sessions.Get(context.Background(), "expired")
+	if expired.PendingAction != nil {
+		t.Error("expired action is still pending")
+	}
+	if last := expired.MessageStore[len(expired.MessageStore)-1].Msg.Content; !strings.Contains(last, "approval expired") {
+		t.Errorf("model was told %q, want the expiry", last)
+	}
+}
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileStore keeps one JSON file per session in a directory, so sessions survive
+// restarts and can be shared by replicas mounting the same volume. On Unix it locks
+// sessions with flock for all replicas, see Lock.
+type FileStore struct {
+	mu  sync.Mutex
+	dir string
+	ttl time.Duration
+}
+
+// NewFileStore creates a FileStore in dir, creating the directory if needed.
+func NewFileStore(dir string, ttl time.Duration) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %v", err)
+	}
+	return &FileStore{dir: dir, ttl: ttl}, nil
+}
+
+// path maps a session ID to its file. IDs come from clients, so they are encoded
+// rather than used as file names directly.
+func (f *FileStore) path(id string) string {
+	return filepath.Join(f.dir, base64.RawURLEncoding.EncodeToString([]byte(id))+".json")
+}
+
+func (f *FileStore) Get(ctx context.Context, id string) (*Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, err := f.read(f.path(id))
+	if err != nil {
+		return nil, err
+	}
+	// Expired sessions are removed by Sweep
+	if expired(s, f.ttl) {
+		return nil, ErrNotFound
+	}
+	return s, nil
+}
+
+func (f *FileStore) Save(ctx context.Context, s *Session) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Write to a temporary file first so readers never see a partial session
+	tmp, err := os.CreateTemp(f.dir, ".session-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path(s.ID))
+}
+
+func (f *FileStore) Delete(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := os.Remove(f.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (f *FileStore) List(ctx context.Context) ([]*Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ret := make([]*Session, 0)
+	err := f.each(func(path string, s *Session) {
+		if !expired(s, f.ttl) {
+			ret = append(ret, s)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortSessions(ret)
+	return ret, nil
+}
+
+func (f *FileStore) Sweep(ctx context.Context) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	err := f.each(func(path string, s *Session) {
+		if expired(s, f.ttl) && os.Remove(path) == nil {
+			n++
+		}
+	})
+	return n, err
+}
+
+// each calls fn for every readable session file. The caller must hold f.mu.
+func (f *FileStore) each(fn func(path string, s *Session)) error {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(f.dir, entry.Name())
+		s, err := f.read(path)
+		if err != nil {
+			// Skip files removed or rewritten by another replica meanwhile
+			continue
+		}
+		fn(path, s)
+	}
+	return nil
+}
+
+func (f *FileStore) Close() error {
+	return nil
+}
+
+func (f *FileStore) read(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to decode session file %s: %v", path, err)
+	}
+	return &s, nil
+}
@@ -0,0 +1,48 @@
+//go:build unix
+
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// fileLockStripes is the number of lock files the sessions are spread over. Sessions
+// sharing a lock file wait for each other, which is rare, while the files stay bounded
+// and never have to be removed.
+const fileLockStripes = 256
+
+// Lock takes an flock on the lock file of the session, retrying while another process,
+// or another session sharing the file, holds it.
+func (f *FileStore) Lock(ctx context.Context, id string) (func(), error) {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	// The leading dot keeps the lock files out of List and Sweep
+	path := filepath.Join(f.dir, fmt.Sprintf(".lock-%03d", h.Sum32()%fileLockStripes))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session lock: %v", err)
+	}
+
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock session: %v", err)
+		}
+		if err := waitRetry(ctx); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	// Closing the file releases the lock
+	return func() { file.Close() }, nil
+}
@@ -0,0 +1,40 @@
+package store
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Sweeper is implemented by stores that have to remove expired sessions themselves.
+// RedisStore does not need it, Redis expires the keys.
+type Sweeper interface {
+	// Sweep deletes every expired session and returns how many were removed.
+	Sweep(ctx context.Context) (int, error)
+}
+
+// RunJanitor sweeps s every interval until ctx is done. It returns at once if s
+// is not a Sweeper.
+func RunJanitor(ctx context.Context, s SessionStore, interval time.Duration) {
+	sweeper, ok := s.(Sweeper)
+	if !ok {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := sweeper.Sweep(ctx)
+			if err != nil {
+				log.Printf("session janitor: %v", err)
+			} else if n > 0 {
+				log.Printf("session janitor: removed %d expired sessions", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// lockRetryInterval is how often a session locked by another process is tried again.
+const lockRetryInterval = 50 * time.Millisecond
+
+// Locker is implemented by stores shared between processes. Their lock holds a session
+// for every process using the store.
+type Locker interface {
+	// Lock waits until the session is free or ctx is done. The returned func releases it.
+	Lock(ctx context.Context, id string) (func(), error)
+}
+
+// Locks serializes requests on the same session ID, so one session runs one request at
+// a time while different sessions proceed in parallel. Requests of this process queue in
+// memory; the one at the front also takes the lock of a shared store, so requests on
+// other replicas using the store wait too.
+type Locks struct {
+	mu    sync.Mutex
+	locks map[string]*sessionLock
+	// shared locks sessions across processes, nil if the store is not shared
+	shared Locker
+}
+
+type sessionLock struct {
+	// ch holds a token while the session is in use
+	ch chan struct{}
+	// refs counts the holder and the waiters, the entry is dropped when it reaches zero
+	refs int
+}
+
+// NewLocks creates an empty Locks. shared, if not nil, locks sessions across processes.
+func NewLocks(shared Locker) *Locks {
+	return &Locks{locks: make(map[string]*sessionLock), shared: shared}
+}
+
+// Acquire waits until the session is free or ctx is done. The returned func releases it.
+func (l *Locks) Acquire(ctx context.Context, id string) (func(), error) {
+	l.mu.Lock()
+	lock, ok := l.locks[id]
+	if !ok {
+		lock = &sessionLock{ch: make(chan struct{}, 1)}
+		l.locks[id] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	select {
+	case lock.ch <- struct{}{}:
+	case <-ctx.Done():
+		l.drop(id, lock)
+		return nil, ctx.Err()
+	}
+
+	unlockShared := func() {}
+	if l.shared != nil {
+		unlock, err := l.shared.Lock(ctx, id)
+		if err != nil {
+			<-lock.ch
+			l.drop(id, lock)
+			return nil, err
+		}
+		unlockShared = unlock
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			unlockShared()
+			<-lock.ch
+			l.drop(id, lock)
+		})
+	}, nil
+}
+
+// Len returns the number of sessions currently held or waited for.
+func (l *Locks) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.locks)
+}
+
+func (l *Locks) drop(id string, lock *sessionLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lock.refs--
+	if lock.refs == 0 {
+		delete(l.locks, id)
+	}
+}
+
+// waitRetry waits lockRetryInterval, or until ctx is done.
+func waitRetry(ctx context.Context) error {
+	t := time.NewTimer(lockRetryInterval)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// newLockToken returns a random token identifying the holder of a lock.
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
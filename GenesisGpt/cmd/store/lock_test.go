@@ -0,0 +1,209 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLocksSerializeSameSession(t *testing.T) {
+	locks := NewLocks(nil)
+
+	var active, maxActive int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := locks.Acquire(context.Background(), "s1")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer release()
+
+			n := atomic.AddInt32(&active, 1)
+			for {
+				m := atomic.LoadInt32(&maxActive)
+				if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&active, -1)
+		}()
+	}
+	wg.Wait()
+
+	if maxActive != 1 {
+		t.Errorf("%d requests held the same session at once, want 1", maxActive)
+	}
+	if n := locks.Len(); n != 0 {
+		t.Errorf("Len() = %d after all releases, want 0", n)
+	}
+}
+
+func TestLocksDifferentSessionsRunInParallel(t *testing.T) {
+	locks := NewLocks(nil)
+
+	release, err := locks.Acquire(context.Background(), "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	other, err := locks.Acquire(ctx, "s2")
+	if err != nil {
+		t.Fatalf("Acquire(s2) while s1 is held: %v", err)
+	}
+	other()
+}
+
+func TestLocksAcquireCancelled(t *testing.T) {
+	locks := NewLocks(nil)
+
+	release, err := locks.Acquire(context.Background(), "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := locks.Acquire(ctx, "s1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire() on a held session error = %v, want DeadlineExceeded", err)
+	}
+
+	release()
+	// Releasing twice must not free the session for someone else
+	release()
+	if n := locks.Len(); n != 0 {
+		t.Errorf("Len() = %d, want 0", n)
+	}
+}
+
+func TestLocksAcrossReplicas(t *testing.T) {
+	dir := t.TempDir()
+	redisAddr := startFakeRedis(t, "")
+
+	// Each backend returns the lockers of two replicas sharing the store
+	backends := map[string]func(t *testing.T) (Locker, Locker){
+		"file": func(t *testing.T) (Locker, Locker) {
+			a, err := NewFileStore(dir, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := NewFileStore(dir, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return a, b
+		},
+		"redis": func(t *testing.T) (Locker, Locker) {
+			a, err := NewRedisStore(redisAddr, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := NewRedisStore(redisAddr, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { a.Close(); b.Close() })
+			return a, b
+		},
+	}
+
+	for name, replicas := range backends {
+		t.Run(name, func(t *testing.T) {
+			a, b := replicas(t)
+			replicaA, replicaB := NewLocks(a), NewLocks(b)
+
+			release, err := replicaA.Acquire(context.Background(), "s1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			if _, err := replicaB.Acquire(ctx, "s1"); !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("Acquire() on a session held by another replica error = %v, want DeadlineExceeded", err)
+			}
+			if n := replicaB.Len(); n != 0 {
+				t.Errorf("Len() = %d after a failed Acquire(), want 0", n)
+			}
+
+			release()
+			ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			other, err := replicaB.Acquire(ctx, "s1")
+			if err != nil {
+				t.Fatalf("Acquire() after the other replica released the session: %v", err)
+			}
+			other()
+		})
+
+		t.Run(name+" serializes", func(t *testing.T) {
+			a, b := replicas(t)
+			replicaLocks := []*Locks{NewLocks(a), NewLocks(b)}
+
+			var active, maxActive int32
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func(locks *Locks) {
+					defer wg.Done()
+					release, err := locks.Acquire(context.Background(), "s2")
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					defer release()
+
+					n := atomic.AddInt32(&active, 1)
+					for {
+						m := atomic.LoadInt32(&maxActive)
+						if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+							break
+						}
+					}
+					time.Sleep(time.Millisecond)
+					atomic.AddInt32(&active, -1)
+				}(replicaLocks[i%2])
+			}
+			wg.Wait()
+
+			if maxActive != 1 {
+				t.Errorf("%d requests of two replicas held the same session at once, want 1", maxActive)
+			}
+		})
+	}
+}
+
+func TestRedisUnlockKeepsOtherHoldersLock(t *testing.T) {
+	s, err := NewRedisStore(startFakeRedis(t, ""), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	release, err := s.Lock(context.Background(), "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The lease ran out and another replica took the lock
+	if _, err := s.do(context.Background(), "SET", redisLockPrefix+"s1", "other-token"); err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	reply, err := s.do(context.Background(), "GET", redisLockPrefix+"s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(reply.([]byte)) != "other-token" {
+		t.Errorf("releasing an expired lock removed the next holder's lock, left %q", reply)
+	}
+}
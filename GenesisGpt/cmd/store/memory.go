@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps sessions in process memory. Sessions are lost on restart.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	ttl      time.Duration
+	sessions map[string]*Session
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		ttl:      ttl,
+		sessions: make(map[string]*Session),
+	}
+}
+
+func (m *MemoryStore) Get(ctx context.Context, id string) (*Session, error) {
+	m.mu.RLock()
+	s, ok := m.sessions[id]
+	m.mu.RUnlock()
+
+	// Expired sessions are removed by Sweep
+	if !ok || expired(s, m.ttl) {
+		return nil, ErrNotFound
+	}
+	return s.Clone(), nil
+}
+
+func (m *MemoryStore) Save(ctx context.Context, s *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sessions[s.ID] = s.Clone()
+	return nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *MemoryStore) List(ctx context.Context) ([]*Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ret := make([]*Session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		if !expired(s, m.ttl) {
+			ret = append(ret, s.Clone())
+		}
+	}
+	sortSessions(ret)
+	return ret, nil
+}
+
+func (m *MemoryStore) Sweep(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := 0
+	for id, s := range m.sessions {
+		if expired(s, m.ttl) {
+			delete(m.sessions, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// sortSessions orders sessions by most recent access first.
+func sortSessions(sessions []*Session) {
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastAccessed.After(sessions[j].LastAccessed)
+	})
+}
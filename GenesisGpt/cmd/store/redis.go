@@ -0,0 +1,367 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisKeyPrefix namespaces the session keys in a shared Redis.
+const redisKeyPrefix = "genesisgpt:session:"
+
+// redisLockPrefix namespaces the session locks. The keys do not match redisKeyPrefix, so
+// List skips them.
+const redisLockPrefix = "genesisgpt:lock:"
+
+// redisLockLease is how long a lock outlives a holder that stopped renewing it, e.g. a
+// replica that crashed. Holders renew it every third of the lease.
+const redisLockLease = 30 * time.Second
+
+// redisUnlockScript and redisRenewScript only touch a lock still held with the token
+// ARGV[1], so a holder whose lease ran out cannot release or extend the next holder's lock.
+const (
+	redisUnlockScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`
+	redisRenewScript  = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) end return 0`
+)
+
+// RedisStore keeps sessions in Redis (or any server speaking the Redis protocol),
+// letting several server replicas share them. Expiry is delegated to Redis via PX.
+type RedisStore struct {
+	mu       sync.Mutex
+	addr     string
+	password string
+	db       int
+	ttl      time.Duration
+
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// NewRedisStore creates a RedisStore for rawURL, either redis://[:password@]host:port[/db]
+// or a plain host:port. The connection is opened on first use.
+func NewRedisStore(rawURL string, ttl time.Duration) (*RedisStore, error) {
+	r := &RedisStore{ttl: ttl}
+
+	if !strings.Contains(rawURL, "://") {
+		r.addr = rawURL
+		return r, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %v", err)
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("invalid redis url: unsupported scheme %q", u.Scheme)
+	}
+	r.addr = u.Host
+	if !strings.Contains(r.addr, ":") {
+		r.addr += ":6379"
+	}
+	if u.User != nil {
+		r.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if r.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid redis database %q", db)
+		}
+	}
+	return r, nil
+}
+
+func (r *RedisStore) Get(ctx context.Context, id string) (*Session, error) {
+	reply, err := r.do(ctx, "GET", redisKeyPrefix+id)
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, ErrNotFound
+	}
+
+	s, err := decodeSession(reply)
+	if err != nil {
+		return nil, err
+	}
+	if expired(s, r.ttl) {
+		return nil, ErrNotFound
+	}
+	return s, nil
+}
+
+func (r *RedisStore) Save(ctx context.Context, s *Session) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = r.do(ctx, "SET", redisKeyPrefix+s.ID, string(data), "PX", strconv.FormatInt(r.ttl.Milliseconds(), 10))
+	return err
+}
+
+func (r *RedisStore) Delete(ctx context.Context, id string) error {
+	_, err := r.do(ctx, "DEL", redisKeyPrefix+id)
+	return err
+}
+
+func (r *RedisStore) List(ctx context.Context) ([]*Session, error) {
+	var keys []string
+	cursor := "0"
+	for {
+		reply, err := r.do(ctx, "SCAN", cursor, "MATCH", redisKeyPrefix+"*", "COUNT", "100")
+		if err != nil {
+			return nil, err
+		}
+		page, ok := reply.([]interface{})
+		if !ok || len(page) != 2 {
+			return nil, fmt.Errorf("unexpected SCAN reply: %v", reply)
+		}
+		next, _ := page[0].([]byte)
+		batch, _ := page[1].([]interface{})
+		for _, key := range batch {
+			if b, ok := key.([]byte); ok {
+				keys = append(keys, string(b))
+			}
+		}
+
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			break
+		}
+	}
+
+	ret := make([]*Session, 0, len(keys))
+	if len(keys) == 0 {
+		return ret, nil
+	}
+
+	reply, err := r.do(ctx, append([]string{"MGET"}, keys...)...)
+	if err != nil {
+		return nil, err
+	}
+	values, _ := reply.([]interface{})
+	for _, value := range values {
+		// Keys that expired between SCAN and MGET come back as nil
+		if value == nil {
+			continue
+		}
+		s, err := decodeSession(value)
+		if err != nil {
+			return nil, err
+		}
+		if !expired(s, r.ttl) {
+			ret = append(ret, s)
+		}
+	}
+	sortSessions(ret)
+	return ret, nil
+}
+
+// Lock takes the session's lock key with SET NX, retrying while another holder has it.
+// The lock is renewed until it is released.
+func (r *RedisStore) Lock(ctx context.Context, id string) (func(), error) {
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+	key := redisLockPrefix + id
+	lease := strconv.FormatInt(redisLockLease.Milliseconds(), 10)
+	for {
+		reply, err := r.do(ctx, "SET", key, token, "NX", "PX", lease)
+		if err != nil {
+			if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+				// The command ran into the deadline of ctx, which is done at once
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("failed to lock session: %v", err)
+		}
+		if reply != nil {
+			break
+		}
+		if err := waitRetry(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(redisLockLease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				r.do(context.Background(), "EVAL", redisRenewScript, "1", key, token, lease)
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+		// A lock that cannot be released now expires after the lease
+		r.do(context.Background(), "EVAL", redisUnlockScript, "1", key, token)
+	}, nil
+}
+
+func (r *RedisStore) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.reset()
+}
+
+// reset drops the current connection. The caller must hold r.mu.
+func (r *RedisStore) reset() error {
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn, r.rd = nil, nil
+	return err
+}
+
+func decodeSession(reply interface{}) (*Session, error) {
+	data, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected session value: %v", reply)
+	}
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %v", err)
+	}
+	return &s, nil
+}
+
+// redisError is an error reply sent by the server.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// do sends one command and returns its reply: nil, int64, []byte or []interface{}.
+// Commands are serialized over a single connection, which is reopened after I/O errors.
+func (r *RedisStore) do(ctx context.Context, args ...string) (interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		if err := r.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := r.roundTrip(ctx, args)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		// The connection state is unknown, start over next time
+		r.reset()
+	}
+	return reply, err
+}
+
+func (r *RedisStore) connect(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to redis at %s: %v", r.addr, err)
+	}
+	r.conn, r.rd = conn, bufio.NewReader(conn)
+
+	if r.password != "" {
+		if _, err := r.roundTrip(ctx, []string{"AUTH", r.password}); err != nil {
+			r.reset()
+			return err
+		}
+	}
+	if r.db != 0 {
+		if _, err := r.roundTrip(ctx, []string{"SELECT", strconv.Itoa(r.db)}); err != nil {
+			r.reset()
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RedisStore) roundTrip(ctx context.Context, args []string) (interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(10 * time.Second)
+	}
+	r.conn.SetDeadline(deadline)
+
+	var cmd strings.Builder
+	fmt.Fprintf(&cmd, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&cmd, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(r.conn, cmd.String()); err != nil {
+		return nil, err
+	}
+	return readReply(r.rd)
+}
+
+// readReply parses one RESP value.
+func readReply(rd *bufio.Reader) (interface{}, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return []byte(line[1:]), nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			if values[i], err = readReply(rd); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
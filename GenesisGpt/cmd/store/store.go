@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/ai"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/config"
+)
+
+// DefaultTTL is how long a session is kept after its last access.
+const DefaultTTL = 30 * time.Minute
+
+// DefaultApprovalTTL is how long a pending action can be approved.
+const DefaultApprovalTTL = 5 * time.Minute
+
+// ErrNotFound is returned when a session does not exist or has expired.
+var ErrNotFound = errors.New("session not found")
+
+// Session is the conversation state of one server client.
+type Session struct {
+	ID                  string          `json:"id"`
+	MessageStore        ai.ChatMessages `json:"messages"`
+	CreatedAt           time.Time       `json:"createdAt"`
+	LastAccessed        time.Time       `json:"lastAccessed"`
+	PendingConfirmation bool            `json:"pendingConfirmation"`
+	ConfirmationPrompt  string          `json:"confirmationPrompt,omitempty"`
+	// PendingToolCallID is the HumanTool call awaiting an answer in tools mode
+	PendingToolCallID string `json:"pendingToolCallId,omitempty"`
+	// PendingQuery is the question that led to the confirmation, for the audit log
+	PendingQuery string `json:"pendingQuery,omitempty"`
+	// PendingAction is the tool call waiting for the user's approval
+	PendingAction *PendingAction `json:"pendingAction,omitempty"`
+}
+
+// PendingAction is a tool call that runs only when the user approves it with Token
+// before ExpiresAt. Approving runs exactly Tool with Input, nothing else.
+type PendingAction struct {
+	Token string          `json:"token"`
+	Tool  string          `json:"tool"`
+	Input json.RawMessage `json:"input"`
+	// ToolCallID is the call to answer in tools mode
+	ToolCallID string    `json:"toolCallId,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	Preview    string    `json:"preview,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// Expired reports whether the approval window has closed at now.
+func (a *PendingAction) Expired(now time.Time) bool {
+	return !now.Before(a.ExpiresAt)
+}
+
+// NewSession creates a session whose message store holds only the system prompt.
+func NewSession(id string) *Session {
+	now := time.Now()
+	return &Session{
+		ID:           id,
+		MessageStore: ai.NewChatMessages(),
+		CreatedAt:    now,
+		LastAccessed: now,
+	}
+}
+
+// Clone returns a copy of s that shares no message slice with it.
+func (s *Session) Clone() *Session {
+	c := *s
+	c.MessageStore = append(ai.ChatMessages(nil), s.MessageStore...)
+	return &c
+}
+
+// SessionStore persists sessions. Implementations must be safe for concurrent use.
+type SessionStore interface {
+	// Get returns the session with the given ID, or ErrNotFound if it is missing or expired.
+	Get(ctx context.Context, id string) (*Session, error)
+	// Save creates or replaces the session.
+	Save(ctx context.Context, s *Session) error
+	// Delete removes the session. Deleting a missing session is not an error.
+	Delete(ctx context.Context, id string) error
+	// List returns all sessions that have not expired.
+	List(ctx context.Context) ([]*Session, error)
+	// Close releases the resources held by the store.
+	Close() error
+}
+
+// New creates the SessionStore selected by cfg.
+func New(cfg config.SessionConfig) (SessionStore, error) {
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	switch strings.ToLower(cfg.Backend) {
+	case "", "memory":
+		return NewMemoryStore(ttl), nil
+	case "file":
+		dir := cfg.Dir
+		if dir == "" {
+			dir = "sessions"
+		}
+		return NewFileStore(dir, ttl)
+	case "redis":
+		addr := cfg.RedisURL
+		if addr == "" {
+			addr = "redis://localhost:6379/0"
+		}
+		return NewRedisStore(addr, ttl)
+	default:
+		return nil, fmt.Errorf("unknown session backend %q: must be memory, file or redis", cfg.Backend)
+	}
+}
+
+// expired reports whether s was last accessed more than ttl ago.
+func expired(s *Session, ttl time.Duration) bool {
+	return time.Since(s.LastAccessed) > ttl
+}
@@ -0,0 +1,312 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStores(t *testing.T) {
+	backends := map[string]func(t *testing.T, ttl time.Duration) SessionStore{
+		"memory": func(t *testing.T, ttl time.Duration) SessionStore {
+			return NewMemoryStore(ttl)
+		},
+		"file": func(t *testing.T, ttl time.Duration) SessionStore {
+			s, err := NewFileStore(t.TempDir(), ttl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		},
+		"redis": func(t *testing.T, ttl time.Duration) SessionStore {
+			s, err := NewRedisStore("redis://:secret@"+startFakeRedis(t, "secret")+"/2", ttl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { s.Close() })
+			return s
+		},
+	}
+
+	for name, newStore := range backends {
+		t.Run(name, func(t *testing.T) {
+			t.Run("RoundTrip", func(t *testing.T) {
+				testRoundTrip(t, newStore(t, time.Hour))
+			})
+			t.Run("Expiry", func(t *testing.T) {
+				testExpiry(t, newStore(t, time.Hour))
+			})
+			t.Run("Concurrent", func(t *testing.T) {
+				testConcurrent(t, newStore(t, time.Hour))
+			})
+		})
+	}
+}
+
+func testRoundTrip(t *testing.T, s SessionStore) {
+	ctx := context.Background()
+
+	if _, err := s.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+
+	// IDs come from clients and must not be trusted as file names
+	session := NewSession("../weird id/1")
+	session.MessageStore.AddForUser("list pods")
+	session.PendingConfirmation = true
+	session.ConfirmationPrompt = "Delete pod nginx?"
+	session.PendingToolCallID = "call_1"
+	if err := s.Save(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(ctx, NewSession("other")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Get(ctx, session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != session.ID || !got.PendingConfirmation || got.ConfirmationPrompt != session.ConfirmationPrompt ||
+		got.PendingToolCallID != session.PendingToolCallID {
+		t.Errorf("Get() = %+v, want %+v", got, session)
+	}
+	if len(got.MessageStore) != 2 || got.MessageStore[1].Msg.Content != "list pods" {
+		t.Errorf("Get() messages = %d, want system prompt and user query", len(got.MessageStore))
+	}
+
+	list, err := s.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Errorf("List() returned %d sessions, want 2", len(list))
+	}
+
+	if err := s.Delete(ctx, session.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, session.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, session.ID); err != nil {
+		t.Errorf("Delete() of a missing session error = %v", err)
+	}
+}
+
+func testExpiry(t *testing.T, s SessionStore) {
+	ctx := context.Background()
+
+	stale := NewSession("stale")
+	stale.LastAccessed = time.Now().Add(-2 * time.Hour)
+	if err := s.Save(ctx, stale); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(ctx, NewSession("fresh")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Get(ctx, "stale"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(stale) error = %v, want ErrNotFound", err)
+	}
+	list, err := s.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != "fresh" {
+		t.Errorf("List() = %d sessions, want only the fresh one", len(list))
+	}
+}
+
+// testConcurrent hammers the store from many goroutines; run with -race.
+func testConcurrent(t *testing.T, s SessionStore) {
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("session-%d", i%3)
+			for j := 0; j < 20; j++ {
+				session := NewSession(id)
+				session.MessageStore.AddForUser(fmt.Sprintf("query %d", j))
+				if err := s.Save(ctx, session); err != nil {
+					t.Error(err)
+					return
+				}
+				if got, err := s.Get(ctx, id); err == nil {
+					// Callers own what Get returns
+					got.MessageStore.AddForAssistant("answer")
+				}
+				if _, err := s.List(ctx); err != nil {
+					t.Error(err)
+					return
+				}
+				if sweeper, ok := s.(Sweeper); ok {
+					sweeper.Sweep(ctx)
+				}
+				if j%5 == 0 {
+					s.Delete(ctx, id)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestJanitor(t *testing.T) {
+	s := NewMemoryStore(time.Hour)
+	stale := NewSession("stale")
+	stale.LastAccessed = time.Now().Add(-2 * time.Hour)
+	s.Save(context.Background(), stale)
+	s.Save(context.Background(), NewSession("fresh"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunJanitor(ctx, s, time.Millisecond)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.RLock()
+		n := len(s.sessions)
+		s.mu.RUnlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor left %d sessions, want 1", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	<-done
+}
+
+// startFakeRedis serves the subset of the Redis protocol RedisStore uses.
+func startFakeRedis(t *testing.T, password string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	var mu sync.Mutex
+	data := make(map[string]string)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				rd := bufio.NewReader(conn)
+				authed := password == ""
+				for {
+					reply, err := readReply(rd)
+					if err != nil {
+						return
+					}
+					var args []string
+					for _, arg := range reply.([]interface{}) {
+						args = append(args, string(arg.([]byte)))
+					}
+
+					mu.Lock()
+					out := fakeRedisCommand(data, args, password, &authed)
+					mu.Unlock()
+					conn.Write([]byte(out))
+				}
+			}(conn)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func fakeRedisCommand(data map[string]string, args []string, password string, authed *bool) string {
+	bulk := func(s string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s) }
+
+	cmd := strings.ToUpper(args[0])
+	if cmd == "AUTH" {
+		if args[1] != password {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		*authed = true
+		return "+OK\r\n"
+	}
+	if !*authed {
+		return "-NOAUTH Authentication required.\r\n"
+	}
+
+	switch cmd {
+	case "SELECT":
+		return "+OK\r\n"
+	case "SET":
+		if _, ok := data[args[1]]; ok && len(args) > 3 && strings.EqualFold(args[3], "NX") {
+			return "$-1\r\n"
+		}
+		data[args[1]] = args[2]
+		return "+OK\r\n"
+	case "EVAL":
+		// Only the lock scripts of RedisStore are supported
+		key, token := args[3], args[4]
+		if data[key] != token {
+			return ":0\r\n"
+		}
+		switch args[1] {
+		case redisUnlockScript:
+			delete(data, key)
+		case redisRenewScript:
+		default:
+			return "-ERR unknown script\r\n"
+		}
+		return ":1\r\n"
+	case "GET":
+		v, ok := data[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulk(v)
+	case "DEL":
+		_, ok := data[args[1]]
+		delete(data, args[1])
+		if ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "SCAN":
+		// Everything is returned in one page, only "prefix*" patterns are supported
+		var keys []string
+		for k := range data {
+			if strings.HasPrefix(k, strings.TrimSuffix(args[3], "*")) {
+				keys = append(keys, bulk(k))
+			}
+		}
+		return "*2\r\n" + bulk("0") + "*" + strconv.Itoa(len(keys)) + "\r\n" + strings.Join(keys, "")
+	case "MGET":
+		out := "*" + strconv.Itoa(len(args)-1) + "\r\n"
+		for _, k := range args[1:] {
+			if v, ok := data[k]; ok {
+				out += bulk(v)
+			} else {
+				out += "$-1\r\n"
+			}
+		}
+		return out
+	default:
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
@@ -1,29 +1,39 @@
 package tools
 
 import (
+	"context"
+	"encoding/json"
+
 	"github.com/lexieqin/Geek/GenesisGpt/cmd/utils"
 )
 
 // ClusterTool represents a tool for listing k8s cluster commands.
-type ClusterTool struct {
-	Name        string
-	Description string
-}
+type ClusterTool struct{}
 
 // NewClusterTool creates a new ClusterTool instance.
 func NewClusterTool() *ClusterTool {
-	return &ClusterTool{
-		Name:        "ClusterTool",
-		Description: "Used to list cluster information",
-	}
+	return &ClusterTool{}
+}
+
+func (l *ClusterTool) Name() string {
+	return "ClusterTool"
+}
+
+func (l *ClusterTool) Description() string {
+	return "Used to list cluster information"
+}
+
+// ArgsSchema is empty because ClusterTool takes no input.
+func (l *ClusterTool) ArgsSchema() string {
+	return ""
 }
 
 // Run executes the command and returns the output.
-func (l *ClusterTool) Run() (string, error) {
+func (l *ClusterTool) Run(ctx context.Context, _ json.RawMessage) (string, error) {
 
 	url := "http://localhost:8081/clusters"
 
-	s, err := utils.GetHTTP(url)
+	s, err := utils.GetHTTPContext(ctx, url)
 
 	return s, err
 }
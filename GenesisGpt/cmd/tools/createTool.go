@@ -1,72 +1,154 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
-	"github.com/sashabaranov/go-openai"
-	"github.com/lexieqin/Geek/GenesisGpt/cmd/ai"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/policy"
 	"github.com/lexieqin/Geek/GenesisGpt/cmd/promptTpl"
 	"github.com/lexieqin/Geek/GenesisGpt/cmd/utils"
+	"github.com/lexieqin/Geek/llm"
+	"github.com/sashabaranov/go-openai"
 )
 
 type CreateToolParam struct {
 	Prompt   string `json:"prompt"`
 	Resource string `json:"resource"`
-}
-
-// Define struct to parse JSON response
-type response struct {
-	Data string `json:"data"`
+	// Yaml is set by Preview; a call with Yaml applies it instead of generating new yaml
+	Yaml string `json:"yaml,omitempty"`
 }
 
 // CreateTool represents a tool for creating k8s resources.
 type CreateTool struct {
-	Name        string
-	Description string
-	ArgsSchema  string
+	// Provider generates the yaml from the user's prompt
+	Provider llm.Provider
 }
 
 // NewCreateTool creates a new CreateTool instance.
-func NewCreateTool() *CreateTool {
-	return &CreateTool{
-		Name:        "CreateTool",
-		Description: "Used to create specified Kubernetes resources in a given namespace, such as creating pods, services, etc.",
-		ArgsSchema:  `{"type":"object","properties":{"prompt":{"type":"string", "description": "Place the user's resource creation prompt here exactly as provided, without any modifications"},"resource":{"type":"string", "description": "Specified k8s resource type, e.g. pod, service, etc."}}}`,
-	}
+func NewCreateTool(provider llm.Provider) *CreateTool {
+	return &CreateTool{Provider: provider}
+}
+
+func (c *CreateTool) Name() string {
+	return "CreateTool"
+}
+
+func (c *CreateTool) Description() string {
+	return "Used to create specified Kubernetes resources in a given namespace, such as creating pods, services, etc. The user sees the generated yaml and a dry-run result before anything is created."
+}
+
+func (c *CreateTool) ArgsSchema() string {
+	return `{"type":"object","properties":{"prompt":{"type":"string", "description": "Place the user's resource creation prompt here exactly as provided, without any modifications"},"resource":{"type":"string", "description": "Specified k8s resource type, e.g. pod, service, etc."}}}`
+}
+
+// PolicyParams tells the policy that the namespaces and kinds of a CreateTool call are
+// those of the objects in the yaml its preview pins.
+func (c *CreateTool) PolicyParams() policy.Params {
+	return policy.Params{Manifest: true}
 }
 
 // Run executes the command and returns the output.
-func (c *CreateTool) Run(prompt string, resource string) string {
-	// Let the large model generate yaml
+func (c *CreateTool) Run(ctx context.Context, input json.RawMessage) (string, error) {
+	var param CreateToolParam
+	if err := json.Unmarshal(input, &param); err != nil {
+		return "", fmt.Errorf("invalid input: %v", err)
+	}
+
+	if param.Yaml != "" {
+		return c.Apply(ctx, param.Yaml)
+	}
+	return c.Create(ctx, param.Prompt)
+}
+
+// Preview generates the yaml and validates it with a ginTools dry run. The yaml is
+// pinned in the returned input, so the approved call creates exactly what was shown.
+func (c *CreateTool) Preview(ctx context.Context, input json.RawMessage) (string, json.RawMessage, error) {
+	var param CreateToolParam
+	if err := json.Unmarshal(input, &param); err != nil {
+		return "", nil, fmt.Errorf("invalid input: %v", err)
+	}
+
+	if param.Yaml == "" {
+		yaml, err := c.Generate(ctx, param.Prompt)
+		if err != nil {
+			return "", nil, err
+		}
+		param.Yaml = yaml
+	}
+
+	s, err := utils.PostHTTPContext(ctx, applyURL(true), yamlBody(param.Yaml))
+	if err != nil {
+		return "", nil, err
+	}
+	var result manifestResult
+	if err := parseData(s, &result); err != nil {
+		return "", nil, fmt.Errorf("the API server rejected the generated yaml: %v\n%s\n%s", err, result.describe(), param.Yaml)
+	}
+
+	pinned, err := json.Marshal(param)
+	if err != nil {
+		return "", nil, err
+	}
+	preview := fmt.Sprintf("Generated yaml:\n%s\n\nDry run:\n%s", strings.TrimSpace(param.Yaml), result.describe())
+	return preview, pinned, nil
+}
+
+// Create lets the model generate yaml for prompt and posts it to ginTools.
+func (c *CreateTool) Create(ctx context.Context, prompt string) (string, error) {
+	yaml, err := c.Generate(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	return c.Apply(ctx, yaml)
+}
+
+// Generate lets the large model write the yaml for prompt.
+func (c *CreateTool) Generate(ctx context.Context, prompt string) (string, error) {
 	messages := make([]openai.ChatCompletionMessage, 2)
 
 	messages[0] = openai.ChatCompletionMessage{Role: "system", Content: promptTpl.SystemPrompt}
 	messages[1] = openai.ChatCompletionMessage{Role: "user", Content: prompt}
 
-	rsp := ai.NormalChat(messages)
+	rsp, err := c.Provider.Chat(ctx, llm.Request{Messages: messages})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate yaml: %v", err)
+	}
 	fmt.Println("-----------------------")
 	fmt.Println(rsp.Content)
+	return rsp.Content, nil
+}
 
-	// Create JSON object {"yaml":"xxx"}
-	body := map[string]string{"yaml": rsp.Content}
-	jsonBody, err := json.Marshal(body)
+// Apply applies every object of yaml through ginTools with server-side apply, so running
+// the same creation twice leaves the objects unchanged.
+func (c *CreateTool) Apply(ctx context.Context, yaml string) (string, error) {
+	s, err := utils.PostHTTPContext(ctx, applyURL(false), yamlBody(yaml))
 	if err != nil {
-		return err.Error()
+		return err.Error(), nil
 	}
 
-	url := "http://localhost:8080/" + resource
-	s, err := utils.PostHTTP(url, jsonBody)
-	if err != nil {
-		return err.Error()
+	var result manifestResult
+	if err := parseData(s, &result); err != nil {
+		return fmt.Sprintf("%v\n%s", err, result.describe()), nil
 	}
+	return result.describe(), nil
+}
 
-	var response response
-	// Parse JSON response
-	err = json.Unmarshal([]byte(s), &response)
-	if err != nil {
-		return err.Error()
+// applyURL is the ginTools server-side apply endpoint, owning fields as GenesisGpt.
+func applyURL(dryRun bool) string {
+	url := ginToolsURL() + "/apply?fieldManager=" + fieldManager
+	if dryRun {
+		url += "&dryRun=true"
 	}
+	return url
+}
+
+// fieldManager owns the fields GenesisGpt applies.
+const fieldManager = "genesisgpt"
 
-	return response.Data
+// yamlBody is the JSON body {"yaml":"xxx"} ginTools expects for writes.
+func yamlBody(yaml string) []byte {
+	body, _ := json.Marshal(map[string]string{"yaml": yaml})
+	return body
 }
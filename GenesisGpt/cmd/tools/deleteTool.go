@@ -1,6 +1,9 @@
 package tools
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"strings"
 
 	"github.com/lexieqin/Geek/GenesisGpt/cmd/utils"
@@ -13,28 +16,45 @@ type DeleteToolParam struct {
 }
 
 // DeleteTool represents a tool for deleting k8s resources.
-type DeleteTool struct {
-	Name        string
-	Description string
-	ArgsSchema  string
-}
+type DeleteTool struct{}
 
 // NewDeleteTool creates a new DeleteTool instance.
 func NewDeleteTool() *DeleteTool {
-	return &DeleteTool{
-		Name:        "DeleteTool",
-		Description: "Used to delete specified Kubernetes resources in a given namespace, such as deleting pods, services, etc.",
-		ArgsSchema:  `{"type":"object","properties":{"resource":{"type":"string", "description": "Specified k8s resource type, e.g. pod, service, etc."}, "name":{"type":"string", "description": "Name of the specified k8s resource instance"}, "namespace":{"type":"string", "description": "Namespace where the specified k8s resource is located"}}`,
-	}
+	return &DeleteTool{}
+}
+
+func (d *DeleteTool) Name() string {
+	return "DeleteTool"
+}
+
+func (d *DeleteTool) Description() string {
+	return "Used to delete specified Kubernetes resources in a given namespace, such as deleting pods, services, etc."
+}
+
+func (d *DeleteTool) ArgsSchema() string {
+	return `{"type":"object","properties":{"resource":{"type":"string", "description": "Specified k8s resource type, e.g. pod, service, etc."}, "name":{"type":"string", "description": "Name of the specified k8s resource instance"}, "namespace":{"type":"string", "description": "Namespace where the specified k8s resource is located"}}}`
 }
 
 // Run executes the command and returns the output.
-func (d *DeleteTool) Run(resource, name, ns string) error {
+func (d *DeleteTool) Run(ctx context.Context, input json.RawMessage) (string, error) {
+	var param DeleteToolParam
+	if err := json.Unmarshal(input, &param); err != nil {
+		return "", fmt.Errorf("invalid input: %v", err)
+	}
+
+	if err := d.Delete(ctx, param.Resource, param.Name, param.Namespace); err != nil {
+		return "Deletion failed: " + err.Error(), nil
+	}
+	return "Deletion successful", nil
+}
+
+// Delete removes the named resource through ginTools.
+func (d *DeleteTool) Delete(ctx context.Context, resource, name, ns string) error {
 	resource = strings.ToLower(resource)
 
-	url := "http://localhost:8080/" + resource + "?ns=" + ns + "&name=" + name
+	url := ginToolsURL() + "/" + resource + "?ns=" + ns + "&name=" + name
 
-	_, err := utils.DeleteHTTP(url)
+	_, err := utils.DeleteHTTPContext(ctx, url)
 
 	return err
 }
@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/utils"
+)
+
+// dryRunResult is what ginTools reports for an object written with dryRun=true.
+type dryRunResult struct {
+	// Object is the object as the API server would store it
+	Object map[string]interface{} `json:"object"`
+	YAML   string                 `json:"yaml"`
+	// Diff compares the live object with Object, empty for new objects
+	Diff string `json:"diff"`
+}
+
+// objectResult is the ginTools outcome for one object of a manifest.
+type objectResult struct {
+	Kind      string        `json:"kind"`
+	Name      string        `json:"name"`
+	Namespace string        `json:"namespace"`
+	Action    string        `json:"action"`
+	Error     string        `json:"error"`
+	DryRun    *dryRunResult `json:"dryRun"`
+}
+
+// manifestResult is the ginTools answer to a create or apply, one result per object.
+type manifestResult struct {
+	DryRun  bool           `json:"dryRun"`
+	Results []objectResult `json:"results"`
+	Failed  int            `json:"failed"`
+}
+
+// parseData decodes the data of a ginTools response into v. A response carrying an error
+// returns it as a *utils.APIError, after decoding any data sent along with it.
+func parseData(body string, v interface{}) error {
+	var rsp struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(body), &rsp); err != nil {
+		return fmt.Errorf("invalid ginTools response: %v", err)
+	}
+	if len(rsp.Data) > 0 {
+		if err := json.Unmarshal(rsp.Data, v); err != nil {
+			return fmt.Errorf("invalid ginTools response: %v", err)
+		}
+	}
+	if err := utils.ErrorFromBody(0, []byte(body)); err != nil {
+		return err
+	}
+	if len(rsp.Data) == 0 {
+		return errors.New("empty ginTools response")
+	}
+	return nil
+}
+
+// describe names the object, e.g. "Deployment nginx in namespace default".
+func (o *objectResult) describe() string {
+	if o.Namespace == "" {
+		return fmt.Sprintf("%s %s", o.Kind, o.Name)
+	}
+	return fmt.Sprintf("%s %s in namespace %s", o.Kind, o.Name, o.Namespace)
+}
+
+// describe lists the outcome of every object, with the changes of a dry run.
+func (m *manifestResult) describe() string {
+	var b strings.Builder
+	for _, o := range m.Results {
+		action := o.Action
+		if m.DryRun && action != "unchanged" {
+			action = "would be " + action
+		}
+		if o.Error != "" {
+			action = "failed: " + o.Error
+		}
+		fmt.Fprintf(&b, "- %s: %s\n", o.describe(), action)
+		if o.DryRun != nil && o.DryRun.Diff != "" {
+			b.WriteString(o.DryRun.Diff)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
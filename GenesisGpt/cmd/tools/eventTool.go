@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	neturl "net/url"
+	"strconv"
+
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/utils"
+)
+
+type EventToolParam struct {
+	Kind       string `json:"kind,omitempty"`
+	Name       string `json:"name,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	Type       string `json:"type,omitempty"`
+	Since      string `json:"since,omitempty"`
+	OwnerChain bool   `json:"ownerChain,omitempty"`
+	Limit      int    `json:"limit,omitempty"`
+}
+
+// EventTool reads the events of any kind of object, optionally together with the
+// events of the objects it owns.
+type EventTool struct{}
+
+// NewEventTool creates a new EventTool instance.
+func NewEventTool() *EventTool {
+	return &EventTool{}
+}
+
+func (e *EventTool) Name() string {
+	return "EventTool"
+}
+
+func (e *EventTool) Description() string {
+	return "Used to read the Kubernetes events of any kind of object, e.g. a Deployment that does not roll out, a Service, a PVC or a Node, as one timeline ordered by time. With ownerChain the events of the objects it owns are merged in, e.g. a Deployment's ReplicaSets and Pods."
+}
+
+func (e *EventTool) ArgsSchema() string {
+	return `{"type":"object","properties":{"kind":{"type":"string", "description": "Resource or kind of the object, e.g. deployment, deploy, statefulset, pvc or node"}, "name":{"type":"string", "description": "Name of the object; without it the events of all objects of the kind are returned"}, "namespace":{"type":"string", "description": "Namespace of the object. Omit it to search all namespaces"}, "type":{"type":"string", "description": "Optional: Event type, Warning or Normal"}, "since":{"type":"string", "description": "Optional: Only events seen within this duration, e.g. 30m or 2h"}, "ownerChain":{"type":"boolean", "description": "Optional: Also return the events of the objects the named object owns, e.g. the ReplicaSets and Pods of a Deployment. Needs kind and name"}, "limit":{"type":"integer", "description": "Optional: Number of most recent events, 200 by default"}}}`
+}
+
+// Run executes the command and returns the output.
+func (e *EventTool) Run(ctx context.Context, input json.RawMessage) (string, error) {
+	var param EventToolParam
+	if err := json.Unmarshal(input, &param); err != nil {
+		return "", fmt.Errorf("invalid input: %v", err)
+	}
+
+	query := neturl.Values{}
+	if param.Kind != "" {
+		query.Set("kind", param.Kind)
+	}
+	if param.Name != "" {
+		query.Set("name", param.Name)
+	}
+	if param.Namespace != "" {
+		query.Set("namespace", param.Namespace)
+	}
+	if param.Type != "" {
+		query.Set("type", param.Type)
+	}
+	if param.Since != "" {
+		query.Set("since", param.Since)
+	}
+	if param.OwnerChain {
+		query.Set("ownerChain", "true")
+	}
+	if param.Limit > 0 {
+		query.Set("limit", strconv.Itoa(param.Limit))
+	}
+
+	url := ginToolsURL() + "/events"
+	if len(query) > 0 {
+		url += "?" + query.Encode()
+	}
+	return utils.GetHTTPContext(ctx, url)
+}
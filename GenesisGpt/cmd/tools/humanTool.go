@@ -1,6 +1,8 @@
 package tools
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 )
@@ -11,32 +13,48 @@ type HumanToolParam struct {
 
 // HumanTool represents a tool for human interaction.
 type HumanTool struct {
-	Name        string
-	Description string
-	ArgsSchema  string
-	ServerMode  bool
+	ServerMode bool
 }
 
 // NewHumanTool creates a new HumanTool instance.
 func NewHumanTool() *HumanTool {
 	// Check if running in server mode
 	serverMode := os.Getenv("GENESISGPT_SERVER_MODE") == "true"
-	
+
 	return &HumanTool{
-		Name:        "HumanTool",
-		Description: "When you need to perform irreversible dangerous operations, such as deletion actions, use this tool to request human confirmation first",
-		ArgsSchema:  `{"type":"object","properties":{"prompt":{"type":"string", "description": "Content for which you need human assistance", "example": "Please confirm if you want to delete the foo-app pod in the default namespace"}}}`,
-		ServerMode:  serverMode,
+		ServerMode: serverMode,
 	}
 }
 
+func (d *HumanTool) Name() string {
+	return "HumanTool"
+}
+
+func (d *HumanTool) Description() string {
+	return "Ask the user a question when the request is ambiguous or information is missing. Do not use it to confirm changes: tools that change the cluster ask the user to approve the exact call themselves"
+}
+
+func (d *HumanTool) ArgsSchema() string {
+	return `{"type":"object","properties":{"prompt":{"type":"string", "description": "Content for which you need human assistance", "example": "Which namespace is the foo-app pod in?"}}}`
+}
+
 // Run executes the command and returns the output.
-func (d *HumanTool) Run(prompt string) string {
+func (d *HumanTool) Run(ctx context.Context, input json.RawMessage) (string, error) {
+	var param HumanToolParam
+	if err := json.Unmarshal(input, &param); err != nil {
+		return "", fmt.Errorf("invalid input: %v", err)
+	}
+
+	return d.Ask(param.Prompt), nil
+}
+
+// Ask requests confirmation for prompt and returns the human's answer.
+func (d *HumanTool) Ask(prompt string) string {
 	if d.ServerMode {
 		// In server mode, return a special response that the UI can handle
 		return fmt.Sprintf("[HUMAN_CONFIRMATION_REQUIRED]: %s", prompt)
 	}
-	
+
 	// In CLI mode, use the original behavior
 	fmt.Print(prompt, " ")
 	var input string
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -51,7 +52,7 @@ func (t *IntelligentDebugTool) ArgsSchema() string {
 	}`
 }
 
-func (t *IntelligentDebugTool) Run(input string) (string, error) {
+func (t *IntelligentDebugTool) Run(ctx context.Context, input json.RawMessage) (string, error) {
 	var args struct {
 		JobID      string `json:"jobId"`
 		Tenant     string `json:"tenant"`
@@ -59,7 +60,7 @@ func (t *IntelligentDebugTool) Run(input string) (string, error) {
 		DebugLevel string `json:"debugLevel"`
 	}
 
-	if err := json.Unmarshal([]byte(input), &args); err != nil {
+	if err := json.Unmarshal(input, &args); err != nil {
 		return "", fmt.Errorf("invalid input: %v", err)
 	}
 
@@ -78,7 +79,7 @@ func (t *IntelligentDebugTool) Run(input string) (string, error) {
 	result.WriteString(fmt.Sprintf("=== Debugging Job: %s (Tenant: %s) ===\n\n", args.JobID, args.Tenant))
 
 	// Step 1: Get job details
-	jobDetails, err := t.getJobDetails(args.Tenant, args.Namespace, args.JobID)
+	jobDetails, err := t.getJobDetails(ctx, args.Tenant, args.Namespace, args.JobID)
 	if err != nil {
 		return "", fmt.Errorf("failed to get job details: %v", err)
 	}
@@ -108,7 +109,7 @@ func (t *IntelligentDebugTool) Run(input string) (string, error) {
 
 			// Here we would fetch actual traces via Datadog API
 			// For now, we'll simulate it
-			traceErrors := t.fetchDatadogTraces(traceID)
+			traceErrors := t.fetchDatadogTraces(ctx, traceID)
 			if traceErrors != "" {
 				result.WriteString("Errors from traces:\n")
 				result.WriteString(traceErrors)
@@ -139,7 +140,7 @@ func (t *IntelligentDebugTool) Run(input string) (string, error) {
 			result.WriteString("- containers.log\n\n")
 
 			// Analyze containers.log
-			errors := t.analyzeLogFile(sandboxPath, "containers.log")
+			errors := t.analyzeLogFile(ctx, sandboxPath, "containers.log")
 			if errors != "" {
 				result.WriteString("Critical errors found (showing first 3):\n")
 				errorLines := strings.Split(errors, "\n")
@@ -156,7 +157,7 @@ func (t *IntelligentDebugTool) Run(input string) (string, error) {
 			}
 
 			// Use smart analysis for deeper insights
-			smartAnalysis := t.getSmartLogAnalysis(sandboxPath)
+			smartAnalysis := t.getSmartLogAnalysis(ctx, sandboxPath)
 			if smartAnalysis != "" {
 				result.WriteString("\nSmart Log Analysis Summary:\n")
 				result.WriteString(smartAnalysis)
@@ -172,19 +173,19 @@ func (t *IntelligentDebugTool) Run(input string) (string, error) {
 	result.WriteString("=== Debug Summary ===\n")
 	result.WriteString(t.generateDebugSummary(jobDetails, args.DebugLevel))
 	result.WriteString("\n")
-	
+
 	// Format the complete debug report
 	debugReport := result.String()
-	
+
 	// Return with clear structure
 	return fmt.Sprintf("Debug Report for Job %s:\n\n%s", args.JobID, debugReport), nil
 }
 
-func (t *IntelligentDebugTool) getJobDetails(tenant, namespace, jobID string) (map[string]interface{}, error) {
+func (t *IntelligentDebugTool) getJobDetails(ctx context.Context, tenant, namespace, jobID string) (map[string]interface{}, error) {
 	// Use the new static data endpoint for demo/test purposes
 	// The trace=true flag provides additional debugging information
-	url := fmt.Sprintf("http://localhost:8080/tenant/%s/jobs?requuid=%s&trace=true", tenant, jobID)
-	resp, err := utils.GetHTTP(url)
+	url := fmt.Sprintf(ginToolsURL()+"/tenant/%s/jobs?requuid=%s&trace=true", tenant, jobID)
+	resp, err := utils.GetHTTPContext(ctx, url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get job details: %v", err)
 	}
@@ -263,10 +264,10 @@ func (t *IntelligentDebugTool) extractSandboxPath(jobDetails map[string]interfac
 	return "/csi-data-dir/7d1f4a89-b6ec-44e4-b047-d34d6d3f9704" // Default for demo
 }
 
-func (t *IntelligentDebugTool) fetchDatadogTraces(traceID string) string {
+func (t *IntelligentDebugTool) fetchDatadogTraces(ctx context.Context, traceID string) string {
 	// Call our static datadog trace endpoint
-	url := fmt.Sprintf("http://localhost:8080/api/datadog/trace/%s", traceID)
-	resp, err := utils.GetHTTP(url)
+	url := fmt.Sprintf(ginToolsURL()+"/api/datadog/trace/%s", traceID)
+	resp, err := utils.GetHTTPContext(ctx, url)
 	if err != nil {
 		return fmt.Sprintf("Failed to fetch traces: %v", err)
 	}
@@ -278,7 +279,7 @@ func (t *IntelligentDebugTool) fetchDatadogTraces(traceID string) string {
 
 	// Extract errors from trace data
 	var errorSpans []map[string]interface{}
-	
+
 	// Navigate to the actual spans location: data.attributes.spans
 	var spans []interface{}
 	if data, ok := traceData["data"].(map[string]interface{}); ok {
@@ -288,27 +289,27 @@ func (t *IntelligentDebugTool) fetchDatadogTraces(traceID string) string {
 			}
 		}
 	}
-	
+
 	// If not found in nested structure, try top level (for backward compatibility)
 	if len(spans) == 0 {
 		if spansData, ok := traceData["spans"].([]interface{}); ok {
 			spans = spansData
 		}
 	}
-	
+
 	if len(spans) > 0 {
 		for _, span := range spans {
 			if spanMap, ok := span.(map[string]interface{}); ok {
 				hasError := false
 				errorDetails := ""
-				
+
 				// Check meta fields for error information
 				if meta, ok := spanMap["meta"].(map[string]interface{}); ok {
 					// Check for OpenTelemetry error status
 					if otelStatus, ok := meta["otel.status_code"].(string); ok && otelStatus == "ERROR" {
 						hasError = true
 					}
-					
+
 					// Extract error details from meta
 					if hasError {
 						if errMsg, ok := meta["error.message"].(string); ok {
@@ -316,7 +317,7 @@ func (t *IntelligentDebugTool) fetchDatadogTraces(traceID string) string {
 						} else if errMsg, ok := meta["err.msg"].(string); ok {
 							errorDetails = errMsg
 						}
-						
+
 						// Add error type and category if available
 						if errType, ok := meta["err.type"].(string); ok {
 							errorDetails = fmt.Sprintf("[%s] %s", errType, errorDetails)
@@ -326,18 +327,18 @@ func (t *IntelligentDebugTool) fetchDatadogTraces(traceID string) string {
 						}
 					}
 				}
-				
+
 				// Also check numeric error field
 				if errorFlag, ok := spanMap["error"].(float64); ok && errorFlag == 1 {
 					hasError = true
 				}
-				
+
 				if hasError {
 					errorSpan := map[string]interface{}{
-						"service": spanMap["service"],
-						"operation": spanMap["name"],  // Using "name" field from actual trace
-						"resource": spanMap["resource"],
-						"error": errorDetails,
+						"service":   spanMap["service"],
+						"operation": spanMap["name"], // Using "name" field from actual trace
+						"resource":  spanMap["resource"],
+						"error":     errorDetails,
 					}
 					errorSpans = append(errorSpans, errorSpan)
 				}
@@ -348,7 +349,7 @@ func (t *IntelligentDebugTool) fetchDatadogTraces(traceID string) string {
 	if len(errorSpans) > 0 {
 		var result strings.Builder
 		result.WriteString(fmt.Sprintf("Found %d error spans in trace:\n\n", len(errorSpans)))
-		
+
 		// Find root cause - usually ppregistrator or the first error
 		var rootCause map[string]interface{}
 		for _, span := range errorSpans {
@@ -360,7 +361,7 @@ func (t *IntelligentDebugTool) fetchDatadogTraces(traceID string) string {
 		if rootCause == nil && len(errorSpans) > 0 {
 			rootCause = errorSpans[0]
 		}
-		
+
 		// Show root cause prominently
 		if rootCause != nil {
 			result.WriteString("Root Cause:\n")
@@ -375,13 +376,13 @@ func (t *IntelligentDebugTool) fetchDatadogTraces(traceID string) string {
 			}
 			result.WriteString("\n")
 		}
-		
+
 		// Show error propagation chain (unique services only)
 		if len(errorSpans) > 1 {
 			result.WriteString("Error Propagation Chain:\n  ")
 			seenServices := make(map[string]bool)
 			var chain []string
-			
+
 			// Start from the root cause
 			for _, span := range errorSpans {
 				if service, ok := span["service"].(string); ok {
@@ -394,20 +395,20 @@ func (t *IntelligentDebugTool) fetchDatadogTraces(traceID string) string {
 			result.WriteString(strings.Join(chain, " → "))
 			result.WriteString("\n")
 		}
-		
+
 		return result.String()
 	}
 	return "No error spans found in Datadog traces (all spans have OK status)"
 }
 
-func (t *IntelligentDebugTool) analyzeLogFile(sandboxPath, logFile string) string {
+func (t *IntelligentDebugTool) analyzeLogFile(ctx context.Context, sandboxPath, logFile string) string {
 	// For the demo, directly use the sandbox log endpoint
-	url := fmt.Sprintf("http://localhost:8080/api/sandbox/logs?path=%s&file=%s", sandboxPath, logFile)
-	resp, err := utils.GetHTTP(url)
+	url := fmt.Sprintf(ginToolsURL()+"/api/sandbox/logs?path=%s&file=%s", sandboxPath, logFile)
+	resp, err := utils.GetHTTPContext(ctx, url)
 	if err != nil {
 		return ""
 	}
-	
+
 	// Simple error extraction
 	var errors []string
 	lines := strings.Split(resp, "\n")
@@ -425,10 +426,10 @@ func (t *IntelligentDebugTool) analyzeLogFile(sandboxPath, logFile string) strin
 	return strings.Join(errors, "\n")
 }
 
-func (t *IntelligentDebugTool) getSmartLogAnalysis(sandboxPath string) string {
+func (t *IntelligentDebugTool) getSmartLogAnalysis(ctx context.Context, sandboxPath string) string {
 	// Use the smart log endpoint for comprehensive analysis
-	url := fmt.Sprintf("http://localhost:8080/api/sandbox/logs/smart?path=%s", sandboxPath)
-	resp, err := utils.GetHTTP(url)
+	url := fmt.Sprintf(ginToolsURL()+"/api/sandbox/logs/smart?path=%s", sandboxPath)
+	resp, err := utils.GetHTTPContext(ctx, url)
 	if err != nil {
 		return ""
 	}
@@ -439,7 +440,7 @@ func (t *IntelligentDebugTool) getSmartLogAnalysis(sandboxPath string) string {
 	}
 
 	var summary strings.Builder
-	
+
 	// Extract summary information
 	if summaryData, ok := logAnalysis["summary"].(map[string]interface{}); ok {
 		if counts, ok := summaryData["counts"].(map[string]interface{}); ok {
@@ -453,7 +454,7 @@ func (t *IntelligentDebugTool) getSmartLogAnalysis(sandboxPath string) string {
 				summary.WriteString(fmt.Sprintf("Warnings: %d\n", int(warnings)))
 			}
 		}
-		
+
 		if errorCategories, ok := summaryData["error_categories"].(map[string]interface{}); ok {
 			summary.WriteString("\nError Categories:\n")
 			for category, count := range errorCategories {
@@ -463,7 +464,7 @@ func (t *IntelligentDebugTool) getSmartLogAnalysis(sandboxPath string) string {
 			}
 		}
 	}
-	
+
 	return summary.String()
 }
 
@@ -497,4 +498,4 @@ func getStringFromMap(m map[string]interface{}, key string) string {
 		}
 	}
 	return ""
-}
\ No newline at end of file
+}
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -52,7 +53,7 @@ func (t *JobDebugTool) ArgsSchema() string {
 	}`
 }
 
-func (t *JobDebugTool) Run(input string) (string, error) {
+func (t *JobDebugTool) Run(ctx context.Context, input json.RawMessage) (string, error) {
 	var args struct {
 		UUID      string `json:"uuid"`
 		Name      string `json:"name"`
@@ -60,7 +61,7 @@ func (t *JobDebugTool) Run(input string) (string, error) {
 		DebugType string `json:"debug_type"`
 	}
 
-	if err := json.Unmarshal([]byte(input), &args); err != nil {
+	if err := json.Unmarshal(input, &args); err != nil {
 		return "", fmt.Errorf("invalid input: %v", err)
 	}
 
@@ -71,7 +72,7 @@ func (t *JobDebugTool) Run(input string) (string, error) {
 
 	// If UUID is provided, first find the job
 	if args.UUID != "" {
-		job, err := t.findJobByUUID(args.UUID, args.Namespace)
+		job, err := t.findJobByUUID(ctx, args.UUID, args.Namespace)
 		if err != nil {
 			return "", err
 		}
@@ -103,27 +104,27 @@ func (t *JobDebugTool) Run(input string) (string, error) {
 	// Get debug information based on type
 	switch args.DebugType {
 	case "full":
-		return t.getFullDebugInfo(args.Namespace, args.Name)
+		return t.getFullDebugInfo(ctx, args.Namespace, args.Name)
 	case "traces":
-		return t.getTraces(args.Namespace, args.Name)
+		return t.getTraces(ctx, args.Namespace, args.Name)
 	case "errors":
-		return t.getErrors(args.Namespace, args.Name)
+		return t.getErrors(ctx, args.Namespace, args.Name)
 	case "logs":
-		return t.getSandboxLogs(args.Namespace, args.Name)
+		return t.getSandboxLogs(ctx, args.Namespace, args.Name)
 	case "pods":
-		return t.getJobPods(args.Namespace, args.Name)
+		return t.getJobPods(ctx, args.Namespace, args.Name)
 	default:
 		return "", fmt.Errorf("invalid debug_type: %s", args.DebugType)
 	}
 }
 
-func (t *JobDebugTool) findJobByUUID(uuid, namespace string) (interface{}, error) {
-	url := fmt.Sprintf("http://localhost:8080/jobs/uuid/%s", uuid)
+func (t *JobDebugTool) findJobByUUID(ctx context.Context, uuid, namespace string) (interface{}, error) {
+	url := fmt.Sprintf(ginToolsURL()+"/jobs/uuid/%s", uuid)
 	if namespace != "" {
 		url += fmt.Sprintf("?namespace=%s", namespace)
 	}
 
-	resp, err := utils.GetHTTP(url)
+	resp, err := utils.GetHTTPContext(ctx, url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find job by UUID: %v", err)
 	}
@@ -136,10 +137,10 @@ func (t *JobDebugTool) findJobByUUID(uuid, namespace string) (interface{}, error
 	return job, nil
 }
 
-func (t *JobDebugTool) getFullDebugInfo(namespace, name string) (string, error) {
-	url := fmt.Sprintf("http://localhost:8080/jobs/%s/%s/debug", namespace, name)
-	
-	resp, err := utils.GetHTTP(url)
+func (t *JobDebugTool) getFullDebugInfo(ctx context.Context, namespace, name string) (string, error) {
+	url := fmt.Sprintf(ginToolsURL()+"/jobs/%s/%s/debug", namespace, name)
+
+	resp, err := utils.GetHTTPContext(ctx, url)
 	if err != nil {
 		return "", fmt.Errorf("failed to get job debug info: %v", err)
 	}
@@ -153,10 +154,10 @@ func (t *JobDebugTool) getFullDebugInfo(namespace, name string) (string, error)
 	return t.formatDebugInfo(debugInfo), nil
 }
 
-func (t *JobDebugTool) getTraces(namespace, name string) (string, error) {
-	url := fmt.Sprintf("http://localhost:8080/jobs/%s/%s/traces", namespace, name)
-	
-	resp, err := utils.GetHTTP(url)
+func (t *JobDebugTool) getTraces(ctx context.Context, namespace, name string) (string, error) {
+	url := fmt.Sprintf(ginToolsURL()+"/jobs/%s/%s/traces", namespace, name)
+
+	resp, err := utils.GetHTTPContext(ctx, url)
 	if err != nil {
 		return "", fmt.Errorf("failed to get job traces: %v", err)
 	}
@@ -164,10 +165,10 @@ func (t *JobDebugTool) getTraces(namespace, name string) (string, error) {
 	return resp, nil
 }
 
-func (t *JobDebugTool) getErrors(namespace, name string) (string, error) {
-	url := fmt.Sprintf("http://localhost:8080/jobs/%s/%s/errors", namespace, name)
-	
-	resp, err := utils.GetHTTP(url)
+func (t *JobDebugTool) getErrors(ctx context.Context, namespace, name string) (string, error) {
+	url := fmt.Sprintf(ginToolsURL()+"/jobs/%s/%s/errors", namespace, name)
+
+	resp, err := utils.GetHTTPContext(ctx, url)
 	if err != nil {
 		return "", fmt.Errorf("failed to get job errors: %v", err)
 	}
@@ -175,10 +176,10 @@ func (t *JobDebugTool) getErrors(namespace, name string) (string, error) {
 	return resp, nil
 }
 
-func (t *JobDebugTool) getSandboxLogs(namespace, name string) (string, error) {
-	url := fmt.Sprintf("http://localhost:8080/jobs/%s/%s/sandbox", namespace, name)
-	
-	resp, err := utils.GetHTTP(url)
+func (t *JobDebugTool) getSandboxLogs(ctx context.Context, namespace, name string) (string, error) {
+	url := fmt.Sprintf(ginToolsURL()+"/jobs/%s/%s/sandbox", namespace, name)
+
+	resp, err := utils.GetHTTPContext(ctx, url)
 	if err != nil {
 		return "", fmt.Errorf("failed to get sandbox logs: %v", err)
 	}
@@ -186,10 +187,10 @@ func (t *JobDebugTool) getSandboxLogs(namespace, name string) (string, error) {
 	return resp, nil
 }
 
-func (t *JobDebugTool) getJobPods(namespace, name string) (string, error) {
-	url := fmt.Sprintf("http://localhost:8080/jobs/%s/%s/pods", namespace, name)
-	
-	resp, err := utils.GetHTTP(url)
+func (t *JobDebugTool) getJobPods(ctx context.Context, namespace, name string) (string, error) {
+	url := fmt.Sprintf(ginToolsURL()+"/jobs/%s/%s/pods", namespace, name)
+
+	resp, err := utils.GetHTTPContext(ctx, url)
 	if err != nil {
 		return "", fmt.Errorf("failed to get job pods: %v", err)
 	}
@@ -286,7 +287,7 @@ func (t *JobDebugTool) formatDebugInfo(debugInfo map[string]interface{}) string
 		result.WriteString("=== Associated Pods ===\n")
 		for _, pod := range pods {
 			if p, ok := pod.(map[string]interface{}); ok {
-				result.WriteString(fmt.Sprintf("  - %s (Status: %s, Node: %s)\n", 
+				result.WriteString(fmt.Sprintf("  - %s (Status: %s, Node: %s)\n",
 					p["name"], p["status"], p["node"]))
 			}
 		}
@@ -296,14 +297,14 @@ func (t *JobDebugTool) formatDebugInfo(debugInfo map[string]interface{}) string
 }
 
 // readSandboxLog reads a specific sandbox log file
-func (t *JobDebugTool) readSandboxLog(sandboxPath, logFile string, startLine, numLines int) (string, error) {
-	url := fmt.Sprintf("http://localhost:8080/sandbox/read?path=%s&file=%s&start=%d&lines=%d", 
+func (t *JobDebugTool) readSandboxLog(ctx context.Context, sandboxPath, logFile string, startLine, numLines int) (string, error) {
+	url := fmt.Sprintf(ginToolsURL()+"/sandbox/read?path=%s&file=%s&start=%d&lines=%d",
 		sandboxPath, logFile, startLine, numLines)
-	
-	resp, err := utils.GetHTTP(url)
+
+	resp, err := utils.GetHTTPContext(ctx, url)
 	if err != nil {
 		return "", fmt.Errorf("failed to read sandbox log: %v", err)
 	}
 
 	return resp, nil
-}
\ No newline at end of file
+}
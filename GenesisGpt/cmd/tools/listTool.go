@@ -1,64 +1,109 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	neturl "net/url"
+	"strconv"
 	"strings"
 
 	"github.com/lexieqin/Geek/GenesisGpt/cmd/utils"
 )
 
 type ListToolParam struct {
-	Resource  string `json:"resource"`
-	Namespace string `json:"namespace"`
-	Name      string `json:"name,omitempty"`
-	Type      string `json:"type,omitempty"`
+	Resource      string `json:"resource"`
+	Namespace     string `json:"namespace"`
+	Name          string `json:"name,omitempty"`
+	Type          string `json:"type,omitempty"`
+	LabelSelector string `json:"labelSelector,omitempty"`
+	FieldSelector string `json:"fieldSelector,omitempty"`
+	// Limit caps the items returned, DefaultListLimit if 0
+	Limit    int64    `json:"limit,omitempty"`
+	Continue string   `json:"continue,omitempty"`
+	SortBy   string   `json:"sortBy,omitempty"`
+	Fields   []string `json:"fields,omitempty"`
 }
 
+// allNamespaces is the namespace by which ginTools routes select every namespace.
+const allNamespaces = "*"
+
+// DefaultListLimit keeps a list of a large namespace from flooding the conversation.
+const DefaultListLimit = 50
+
 type APIResponse struct {
 	Data interface{} `json:"data"`
 	Meta interface{} `json:"meta,omitempty"`
 }
 
 // ListTool represents a tool for listing k8s resource commands.
-type ListTool struct {
-	Name        string
-	Description string
-	ArgsSchema  string
-}
+type ListTool struct{}
 
 // NewListTool creates a new ListTool instance.
 func NewListTool() *ListTool {
-	return &ListTool{
-		Name:        "ListTool",
-		Description: "Used to list and get details of Kubernetes resources. Can list all resources of a type in a namespace, get specific resource details, or filter resources by type.",
-		ArgsSchema:  `{"type":"object","properties":{"resource":{"type":"string", "description": "Specified k8s resource type, e.g. pod, service, etc."}, "namespace":{"type":"string", "description": "Specified k8s namespace"}, "name":{"type":"string", "description": "Optional: Name of specific resource to get details for"}, "type":{"type":"string", "description": "Optional: Filter resources by type"}}}`,
-	}
+	return &ListTool{}
+}
+
+func (l *ListTool) Name() string {
+	return "ListTool"
+}
+
+func (l *ListTool) Description() string {
+	return "Used to list and get details of Kubernetes resources. Can list resources of a type in a namespace or across all namespaces, list cluster-scoped resources such as nodes, get specific resource details, or filter resources by type, labels or fields. Returns 50 items by default; use selectors, sortBy and fields to keep lists of busy namespaces short."
+}
+
+func (l *ListTool) ArgsSchema() string {
+	return `{"type":"object","properties":{"resource":{"type":"string", "description": "Specified k8s resource type, e.g. pod, service, etc."}, "namespace":{"type":"string", "description": "Specified k8s namespace. Omit it or use * to list across all namespaces; every item carries its namespace. Cluster-scoped resources such as node, persistentvolume, namespace and clusterrole ignore it"}, "name":{"type":"string", "description": "Optional: Name of specific resource to get details for"}, "type":{"type":"string", "description": "Optional: Filter resources by type"}, "labelSelector":{"type":"string", "description": "Optional: Label selector, e.g. app=web,tier!=cache"}, "fieldSelector":{"type":"string", "description": "Optional: Field selector on any field, e.g. status.phase!=Running or spec.nodeName=node-1"}, "limit":{"type":"integer", "description": "Optional: Maximum number of items, 50 by default"}, "continue":{"type":"string", "description": "Optional: Token from the previous call to get the next page"}, "sortBy":{"type":"string", "enum":["creationTimestamp","-creationTimestamp","name","-name","restartCount","-restartCount"], "description": "Optional: Order of the items, a leading - reverses it, e.g. -restartCount for the most restarted pods first"}, "fields":{"type":"array", "items":{"type":"string"}, "description": "Optional: Only return these fields of each item, e.g. [\"metadata.name\", \"status.phase\"]"}}}`
 }
 
 // Run executes the command and returns the output.
-func (l *ListTool) Run(resource string, ns string, name string, resourceType string) (string, error) {
-	resource = strings.ToLower(resource)
+func (l *ListTool) Run(ctx context.Context, input json.RawMessage) (string, error) {
+	var param ListToolParam
+	if err := json.Unmarshal(input, &param); err != nil {
+		return "", fmt.Errorf("invalid input: %v", err)
+	}
+
+	return l.List(ctx, param)
+}
+
+// List queries ginTools for the requested resources.
+func (l *ListTool) List(ctx context.Context, param ListToolParam) (string, error) {
+	resource := strings.ToLower(param.Resource)
+	ns, name, resourceType := param.Namespace, param.Name, param.Type
+	if ns == "" {
+		ns = allNamespaces
+	}
 	var url string
 
 	if name != "" {
-		// Get specific resource details
-		url = fmt.Sprintf("http://localhost:8080/%s?ns=%s&name=%s", resource, ns, name)
+		// Get specific resource details, one object however many share the namespace
+		query := neturl.Values{}
+		query.Set("ns", ns)
+		query.Set("name", name)
+		url = fmt.Sprintf(ginToolsURL()+"/%s/object?%s", resource, query.Encode())
 	} else if resourceType != "" {
 		// Filter resources by type
-		url = fmt.Sprintf("http://localhost:8080/get/resource?resource=%s&type=%s", resource, resourceType)
+		url = fmt.Sprintf(ginToolsURL()+"/get/resource?resource=%s&namespace=%s&type=%s", resource, ns, resourceType)
 	} else {
 		// List all resources
 		if resource == "pod" || resource == "pods" {
 			// Use the namespace-specific endpoint for pods
-			url = fmt.Sprintf("http://localhost:8080/namespaces/%s/pods", ns)
+			url = fmt.Sprintf(ginToolsURL()+"/namespaces/%s/pods", ns)
 		} else {
 			// Use the generic resource endpoint for other resources
-			url = fmt.Sprintf("http://localhost:8080/%s?ns=%s", resource, ns)
+			url = fmt.Sprintf(ginToolsURL()+"/%s?ns=%s", resource, ns)
+		}
+	}
+
+	if name == "" {
+		separator := "?"
+		if strings.Contains(url, "?") {
+			separator = "&"
 		}
+		url += separator + listQuery(param)
 	}
 
-	response, err := utils.GetHTTP(url)
+	response, err := utils.GetHTTPContext(ctx, url)
 	if err != nil {
 		return "", fmt.Errorf("failed to get resource: %v", err)
 	}
@@ -75,5 +120,39 @@ func (l *ListTool) Run(resource string, ns string, name string, resourceType str
 		return "", fmt.Errorf("failed to format response data: %v", err)
 	}
 
-	return string(data), nil
+	output := string(data)
+	var meta struct {
+		Total    int    `json:"total"`
+		Continue string `json:"continue"`
+	}
+	if raw, err := json.Marshal(apiResp.Meta); err == nil && json.Unmarshal(raw, &meta) == nil && meta.Continue != "" {
+		output += fmt.Sprintf("\n\nShowing part of %d items. Call ListTool again with continue=%q for the next page, or narrow the list with a selector.", meta.Total, meta.Continue)
+	}
+	return output, nil
+}
+
+// listQuery encodes the selectors, order and page of param for a ginTools list route.
+func listQuery(param ListToolParam) string {
+	query := neturl.Values{}
+	if param.LabelSelector != "" {
+		query.Set("labelSelector", param.LabelSelector)
+	}
+	if param.FieldSelector != "" {
+		query.Set("fieldSelector", param.FieldSelector)
+	}
+	limit := param.Limit
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	query.Set("limit", strconv.FormatInt(limit, 10))
+	if param.Continue != "" {
+		query.Set("continue", param.Continue)
+	}
+	if param.SortBy != "" {
+		query.Set("sortBy", param.SortBy)
+	}
+	if len(param.Fields) > 0 {
+		query.Set("fields", strings.Join(param.Fields, ","))
+	}
+	return query.Encode()
 }
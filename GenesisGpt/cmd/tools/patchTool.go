@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/utils"
+)
+
+type PatchToolParam struct {
+	Resource  string `json:"resource"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// PatchType is strategic, merge, json or apply; strategic if empty
+	PatchType string          `json:"patchType"`
+	Patch     json.RawMessage `json:"patch"`
+}
+
+// PatchTool changes fields of an existing k8s resource in place.
+type PatchTool struct{}
+
+// NewPatchTool creates a new PatchTool instance.
+func NewPatchTool() *PatchTool {
+	return &PatchTool{}
+}
+
+func (p *PatchTool) Name() string {
+	return "PatchTool"
+}
+
+func (p *PatchTool) Description() string {
+	return "Used to change fields of an existing Kubernetes resource in place, such as scaling a deployment, setting a container image or adding labels, without deleting and recreating it. The patch holds only the fields to change. The user sees the resulting diff before anything is changed."
+}
+
+func (p *PatchTool) ArgsSchema() string {
+	return `{"type":"object","properties":{"resource":{"type":"string", "description": "Specified k8s resource type, e.g. deployment, service, etc."}, "name":{"type":"string", "description": "Name of the specified k8s resource instance"}, "namespace":{"type":"string", "description": "Namespace where the specified k8s resource is located"}, "patchType":{"type":"string", "enum":["strategic","merge","json","apply"], "description": "strategic (default) merges lists such as containers by name and works for built-in kinds; merge replaces lists and also works for custom resources; json takes RFC 6902 operations; apply is server-side apply of a partial object"}, "patch":{"description": "The patch. For strategic and merge a partial object holding only the changed fields, e.g. {\"spec\":{\"replicas\":3}} or {\"spec\":{\"template\":{\"spec\":{\"containers\":[{\"name\":\"nginx\",\"image\":\"nginx:1.27\"}]}}}}. For json a list of operations, e.g. [{\"op\":\"replace\",\"path\":\"/spec/replicas\",\"value\":3}]"}},"required":["resource","name","patch"]}`
+}
+
+// Run executes the command and returns the output.
+func (p *PatchTool) Run(ctx context.Context, input json.RawMessage) (string, error) {
+	param, err := parsePatchParam(input)
+	if err != nil {
+		return "", err
+	}
+
+	var patched map[string]interface{}
+	if err := p.Patch(ctx, param, false, &patched); err != nil {
+		return "Patch failed: " + err.Error(), nil
+	}
+	return fmt.Sprintf("Patch successful, %s %s is now at resourceVersion %s", param.Resource, param.Name, resourceVersion(patched)), nil
+}
+
+// Preview patches with a ginTools dry run and shows the diff against the live object.
+func (p *PatchTool) Preview(ctx context.Context, input json.RawMessage) (string, json.RawMessage, error) {
+	param, err := parsePatchParam(input)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var result dryRunResult
+	if err := p.Patch(ctx, param, true, &result); err != nil {
+		return "", nil, fmt.Errorf("the API server rejected the patch: %v", err)
+	}
+	if result.Diff == "" {
+		return "", nil, fmt.Errorf("the patch would not change %s %s", param.Resource, param.Name)
+	}
+	return fmt.Sprintf("Dry run of a %s patch on %s %s in namespace %s:\n%s", param.PatchType, param.Resource, param.Name, param.Namespace, result.Diff), input, nil
+}
+
+// Patch sends the patch to ginTools and decodes the response data into v.
+func (p *PatchTool) Patch(ctx context.Context, param *PatchToolParam, dryRun bool, v interface{}) error {
+	query := url.Values{}
+	query.Set("ns", param.Namespace)
+	query.Set("name", param.Name)
+	query.Set("patchType", param.PatchType)
+	query.Set("fieldManager", fieldManager)
+	if dryRun {
+		query.Set("dryRun", "true")
+	}
+
+	body, err := json.Marshal(map[string]json.RawMessage{"patch": param.Patch})
+	if err != nil {
+		return err
+	}
+	s, err := utils.PatchHTTPContext(ctx, ginToolsURL()+"/"+param.Resource+"?"+query.Encode(), body)
+	if err != nil {
+		return err
+	}
+	return parseData(s, v)
+}
+
+// parsePatchParam decodes the tool input and fills in the defaults.
+func parsePatchParam(input json.RawMessage) (*PatchToolParam, error) {
+	var param PatchToolParam
+	if err := json.Unmarshal(input, &param); err != nil {
+		return nil, fmt.Errorf("invalid input: %v", err)
+	}
+	if param.Resource == "" || param.Name == "" || len(param.Patch) == 0 {
+		return nil, fmt.Errorf("invalid input: resource, name and patch are required")
+	}
+	param.Resource = strings.ToLower(param.Resource)
+	if param.Namespace == "" {
+		param.Namespace = "default"
+	}
+	if param.PatchType == "" {
+		param.PatchType = "strategic"
+	}
+	return &param, nil
+}
+
+// resourceVersion reads metadata.resourceVersion of an object decoded from JSON.
+func resourceVersion(obj map[string]interface{}) string {
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	version, _ := metadata["resourceVersion"].(string)
+	return version
+}
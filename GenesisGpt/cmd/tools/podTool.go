@@ -1,62 +1,123 @@
 package tools
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	neturl "net/url"
+	"strconv"
 
 	"github.com/lexieqin/Geek/GenesisGpt/cmd/utils"
 )
 
 type PodToolParam struct {
-	Namespace string `json:"namespace"`
-	PodName   string `json:"podName"`
-	Container string `json:"container,omitempty"`
-	Tail      int    `json:"tail,omitempty"`
-	EventType string `json:"eventType,omitempty"`
-	Operation string `json:"operation"` // "logs" or "events"
+	Namespace     string `json:"namespace"`
+	PodName       string `json:"podName"`
+	Container     string `json:"container,omitempty"`
+	AllContainers bool   `json:"allContainers,omitempty"`
+	Previous      bool   `json:"previous,omitempty"`
+	Tail          int    `json:"tail,omitempty"`
+	SinceSeconds  int64  `json:"sinceSeconds,omitempty"`
+	Grep          string `json:"grep,omitempty"`
+	LimitBytes    int64  `json:"limitBytes,omitempty"`
+	LabelSelector string `json:"labelSelector,omitempty"`
+	Lines         int    `json:"lines,omitempty"`
+	EventType     string `json:"eventType,omitempty"`
+	// Operation is "logs" or "events" of a pod, or "namespace-logs" or "namespace-events"
+	// of all pods of the namespace
+	Operation string `json:"operation"`
 }
 
 // PodTool represents a tool for pod-specific operations.
-type PodTool struct {
-	Name        string
-	Description string
-	ArgsSchema  string
-}
+type PodTool struct{}
 
 // NewPodTool creates a new PodTool instance.
 func NewPodTool() *PodTool {
-	return &PodTool{
-		Name:        "PodTool",
-		Description: "Used for pod-specific operations like getting logs and events. Can retrieve pod logs with optional container and line count, and get pod events with optional event type filtering.",
-		ArgsSchema:  `{"type":"object","properties":{"namespace":{"type":"string", "description": "Namespace where the pod is located"}, "podName":{"type":"string", "description": "Name of the pod"}, "container":{"type":"string", "description": "Optional: Specific container name for logs"}, "tail":{"type":"integer", "description": "Optional: Number of log lines to retrieve"}, "eventType":{"type":"string", "description": "Optional: Filter events by type (e.g., Warning)"}, "operation":{"type":"string", "description": "Operation to perform: 'logs' or 'events'"}}}`,
-	}
+	return &PodTool{}
+}
+
+func (p *PodTool) Name() string {
+	return "PodTool"
+}
+
+func (p *PodTool) Description() string {
+	return "Used for pod-specific operations like getting logs and events. Can retrieve pod logs of one or all containers, of the previous crashed instance, since a number of seconds or filtered by a regular expression, and get pod events with optional event type filtering. To triage a whole namespace in one call, namespace-events returns the recent Warning events of all its objects and namespace-logs the most recent error-looking log lines of all its pods."
+}
+
+func (p *PodTool) ArgsSchema() string {
+	return `{"type":"object","properties":{"namespace":{"type":"string", "description": "Namespace where the pod is located. Omit it to find the pod by name in all namespaces"}, "podName":{"type":"string", "description": "Name of the pod, not needed for the namespace operations"}, "container":{"type":"string", "description": "Optional: Specific container name for logs"}, "allContainers":{"type":"boolean", "description": "Optional: Read the logs of all containers, init containers included, interleaved by time"}, "previous":{"type":"boolean", "description": "Optional: Read the logs of the previous terminated instance, e.g. of a container in CrashLoopBackOff"}, "tail":{"type":"integer", "description": "Optional: Number of log lines to retrieve per container, 100 by default"}, "sinceSeconds":{"type":"integer", "description": "Optional: Only lines of the last seconds"}, "grep":{"type":"string", "description": "Optional: Regular expression the log lines must match, e.g. (?i)error|panic. namespace-logs matches error-looking lines by default"}, "limitBytes":{"type":"integer", "description": "Optional: Maximum bytes of log text, the most recent lines are kept; 64KiB by default"}, "labelSelector":{"type":"string", "description": "Optional: For namespace-logs, the label selector of the pods to read, e.g. app=web"}, "lines":{"type":"integer", "description": "Optional: For namespace-logs, the number of most recent lines across all pods, 100 by default"}, "eventType":{"type":"string", "description": "Optional: Filter events by type (e.g., Warning). namespace-events defaults to Warning, 'all' returns every type"}, "operation":{"type":"string", "description": "Operation to perform: 'logs' or 'events' of a pod, or 'namespace-logs' or 'namespace-events' of the whole namespace"}}}`
 }
 
 // Run executes the command and returns the output.
-func (p *PodTool) Run(param PodToolParam) (string, error) {
+func (p *PodTool) Run(ctx context.Context, input json.RawMessage) (string, error) {
+	var param PodToolParam
+	if err := json.Unmarshal(input, &param); err != nil {
+		return "", fmt.Errorf("invalid input: %v", err)
+	}
+
+	if param.Namespace == "" {
+		param.Namespace = allNamespaces
+	}
+
 	var url string
 
 	if param.Operation == "logs" {
-		url = fmt.Sprintf("http://localhost:8080/namespaces/%s/pods/%s/logs", param.Namespace, param.PodName)
-		if param.Container != "" {
-			url += "?container=" + param.Container
-		}
-		if param.Tail > 0 {
-			if param.Container != "" {
-				url += "&"
-			} else {
-				url += "?"
-			}
-			url += fmt.Sprintf("tail=%d", param.Tail)
+		url = fmt.Sprintf(ginToolsURL()+"/namespaces/%s/pods/%s/logs", param.Namespace, param.PodName)
+		if query := logQuery(param); query != "" {
+			url += "?" + query
 		}
 	} else if param.Operation == "events" {
-		url = fmt.Sprintf("http://localhost:8080/namespaces/%s/pods/%s/events", param.Namespace, param.PodName)
+		url = fmt.Sprintf(ginToolsURL()+"/namespaces/%s/pods/%s/events", param.Namespace, param.PodName)
 		if param.EventType != "" {
 			url += "?type=" + param.EventType
 		}
+	} else if param.Operation == "namespace-logs" {
+		url = fmt.Sprintf(ginToolsURL()+"/namespaces/%s/logs", param.Namespace)
+		if query := logQuery(param); query != "" {
+			url += "?" + query
+		}
+	} else if param.Operation == "namespace-events" {
+		url = fmt.Sprintf(ginToolsURL()+"/namespaces/%s/events", param.Namespace)
+		if param.EventType != "" {
+			url += "?type=" + neturl.QueryEscape(param.EventType)
+		}
 	} else {
 		return "", fmt.Errorf("invalid operation: %s", param.Operation)
 	}
 
-	s, err := utils.GetHTTP(url)
+	s, err := utils.GetHTTPContext(ctx, url)
 	return s, err
 }
+
+// logQuery encodes the log options of param for the ginTools pod and namespace logs routes.
+func logQuery(param PodToolParam) string {
+	query := neturl.Values{}
+	if param.Container != "" {
+		query.Set("container", param.Container)
+	}
+	if param.AllContainers {
+		query.Set("allContainers", "true")
+	}
+	if param.Previous {
+		query.Set("previous", "true")
+	}
+	if param.Tail > 0 {
+		query.Set("tail", strconv.Itoa(param.Tail))
+	}
+	if param.SinceSeconds > 0 {
+		query.Set("sinceSeconds", strconv.FormatInt(param.SinceSeconds, 10))
+	}
+	if param.Grep != "" {
+		query.Set("grep", param.Grep)
+	}
+	if param.LimitBytes > 0 {
+		query.Set("limitBytes", strconv.FormatInt(param.LimitBytes, 10))
+	}
+	if param.LabelSelector != "" {
+		query.Set("labelSelector", param.LabelSelector)
+	}
+	if param.Lines > 0 {
+		query.Set("lines", strconv.Itoa(param.Lines))
+	}
+	return query.Encode()
+}
@@ -1,44 +1,76 @@
 package tools
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/url"
 
 	"github.com/lexieqin/Geek/GenesisGpt/cmd/utils"
 )
 
 type ResourceInfoToolParam struct {
 	Resource string `json:"resource"`
-	InfoType string `json:"infoType"` // "gvr" or "list"
+	InfoType string `json:"infoType"` // "gvr", "list" or "api-resources"
 }
 
 // ResourceInfoTool represents a tool for getting resource type information.
-type ResourceInfoTool struct {
-	Name        string
-	Description string
-	ArgsSchema  string
-}
+type ResourceInfoTool struct{}
 
 // NewResourceInfoTool creates a new ResourceInfoTool instance.
 func NewResourceInfoTool() *ResourceInfoTool {
-	return &ResourceInfoTool{
-		Name:        "ResourceInfoTool",
-		Description: "Used to get information about Kubernetes resource types. Can retrieve GVR (GroupVersionResource) information or list available resources of a specific type.",
-		ArgsSchema:  `{"type":"object","properties":{"resource":{"type":"string", "description": "Resource type to get information for"}, "infoType":{"type":"string", "description": "Type of information to retrieve: 'gvr' for GroupVersionResource info or 'list' for resource list"}}}`,
-	}
+	return &ResourceInfoTool{}
+}
+
+func (r *ResourceInfoTool) Name() string {
+	return "ResourceInfoTool"
+}
+
+func (r *ResourceInfoTool) Description() string {
+	return "Used to get information about Kubernetes resource types. Can retrieve GVR (GroupVersionResource) information, list available resources of a specific type, or list every resource type the cluster serves, including custom resources, with their short names and verbs."
+}
+
+func (r *ResourceInfoTool) ArgsSchema() string {
+	return `{"type":"object","properties":{"resource":{"type":"string", "description": "Resource type to get information for"}, "infoType":{"type":"string", "description": "Type of information to retrieve: 'gvr' for GroupVersionResource info, 'list' for resource list or 'api-resources' for all resource types of the cluster (resource is not needed)"}}}`
 }
 
 // Run executes the command and returns the output.
-func (r *ResourceInfoTool) Run(param ResourceInfoToolParam) (string, error) {
-	var url string
+func (r *ResourceInfoTool) Run(ctx context.Context, input json.RawMessage) (string, error) {
+	var param ResourceInfoToolParam
+	if err := json.Unmarshal(input, &param); err != nil {
+		return "", fmt.Errorf("invalid input: %v", err)
+	}
+
+	var endpoint string
 
 	if param.InfoType == "gvr" {
-		url = "http://localhost:8080/get/gvr?resource=" + param.Resource
+		endpoint = ginToolsURL() + "/get/gvr?resource=" + param.Resource
 	} else if param.InfoType == "list" {
-		url = "http://localhost:8080/get/resource?resource=" + param.Resource
+		endpoint = ginToolsURL() + "/get/resource?resource=" + param.Resource
+	} else if param.InfoType == "api-resources" {
+		endpoint = ginToolsURL() + "/api-resources"
 	} else {
 		return "", fmt.Errorf("invalid info type: %s", param.InfoType)
 	}
 
-	s, err := utils.GetHTTP(url)
+	s, err := utils.GetHTTPContext(ctx, endpoint)
 	return s, err
 }
+
+// ClusterScoped asks ginTools whether kind is cluster-scoped, as the REST mapper of the
+// cluster has it, so the policy also knows the scope of custom resources.
+func ClusterScoped(kind string) (bool, error) {
+	s, err := utils.GetHTTP(ginToolsURL() + "/get/gvr?resource=" + url.QueryEscape(kind))
+	if err != nil {
+		return false, err
+	}
+	var resp struct {
+		Data struct {
+			Namespaced bool `json:"namespaced"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(s), &resp); err != nil {
+		return false, fmt.Errorf("invalid response: %v", err)
+	}
+	return !resp.Data.Namespaced, nil
+}
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -62,7 +63,7 @@ func (t *SandboxLogTool) ArgsSchema() string {
 	}`
 }
 
-func (t *SandboxLogTool) Run(input string) (string, error) {
+func (t *SandboxLogTool) Run(ctx context.Context, input json.RawMessage) (string, error) {
 	var args struct {
 		SandboxPath   string `json:"sandboxPath"`
 		Action        string `json:"action"`
@@ -72,7 +73,7 @@ func (t *SandboxLogTool) Run(input string) (string, error) {
 		SearchPattern string `json:"searchPattern"`
 	}
 
-	if err := json.Unmarshal([]byte(input), &args); err != nil {
+	if err := json.Unmarshal(input, &args); err != nil {
 		return "", fmt.Errorf("invalid input: %v", err)
 	}
 
@@ -89,25 +90,25 @@ func (t *SandboxLogTool) Run(input string) (string, error) {
 
 	switch args.Action {
 	case "read":
-		return t.readLogFile(args.SandboxPath, args.LogFile, args.StartLine, args.NumLines)
+		return t.readLogFile(ctx, args.SandboxPath, args.LogFile, args.StartLine, args.NumLines)
 	case "analyze":
-		return t.analyzeAllLogs(args.SandboxPath)
+		return t.analyzeAllLogs(ctx, args.SandboxPath)
 	case "search":
 		if args.SearchPattern == "" {
 			return "", fmt.Errorf("searchPattern is required for search action")
 		}
-		return t.searchInLog(args.SandboxPath, args.LogFile, args.SearchPattern)
+		return t.searchInLog(ctx, args.SandboxPath, args.LogFile, args.SearchPattern)
 	default:
 		return "", fmt.Errorf("invalid action: %s", args.Action)
 	}
 }
 
-func (t *SandboxLogTool) readLogFile(sandboxPath, logFile string, startLine, numLines int) (string, error) {
+func (t *SandboxLogTool) readLogFile(ctx context.Context, sandboxPath, logFile string, startLine, numLines int) (string, error) {
 	encodedPath := url.QueryEscape(sandboxPath)
-	url := fmt.Sprintf("http://localhost:8080/sandbox/read?path=%s&file=%s&start=%d&lines=%d",
+	url := fmt.Sprintf(ginToolsURL()+"/sandbox/read?path=%s&file=%s&start=%d&lines=%d",
 		encodedPath, logFile, startLine, numLines)
 
-	resp, err := utils.GetHTTP(url)
+	resp, err := utils.GetHTTPContext(ctx, url)
 	if err != nil {
 		return "", fmt.Errorf("failed to read log file: %v", err)
 	}
@@ -119,28 +120,28 @@ func (t *SandboxLogTool) readLogFile(sandboxPath, logFile string, startLine, num
 	}
 
 	if content, ok := result["content"].(string); ok {
-		return fmt.Sprintf("=== Content of %s (lines %d-%d) ===\n%s", 
+		return fmt.Sprintf("=== Content of %s (lines %d-%d) ===\n%s",
 			logFile, startLine, startLine+numLines, content), nil
 	}
 
 	return resp, nil
 }
 
-func (t *SandboxLogTool) analyzeAllLogs(sandboxPath string) (string, error) {
+func (t *SandboxLogTool) analyzeAllLogs(ctx context.Context, sandboxPath string) (string, error) {
 	var result strings.Builder
 	result.WriteString("=== Analyzing Sandbox Logs ===\n\n")
 
 	// Analyze each log file
 	logFiles := []string{"std.out", "std.err", "decout", "decerr"}
 	errorSummary := make(map[string][]string)
-	
+
 	for _, logFile := range logFiles {
 		// Read first 500 lines to look for errors
 		encodedPath := url.QueryEscape(sandboxPath)
-		url := fmt.Sprintf("http://localhost:8080/sandbox/read?path=%s&file=%s&start=0&lines=500",
+		url := fmt.Sprintf(ginToolsURL()+"/sandbox/read?path=%s&file=%s&start=0&lines=500",
 			encodedPath, logFile)
-		
-		resp, err := utils.GetHTTP(url)
+
+		resp, err := utils.GetHTTPContext(ctx, url)
 		if err != nil {
 			continue // Skip if file doesn't exist
 		}
@@ -155,7 +156,7 @@ func (t *SandboxLogTool) analyzeAllLogs(sandboxPath string) (string, error) {
 			for _, line := range lines {
 				lowerLine := strings.ToLower(line)
 				if strings.Contains(lowerLine, "error") || strings.Contains(lowerLine, "exception") ||
-				   strings.Contains(lowerLine, "failed") || strings.Contains(lowerLine, "fatal") {
+					strings.Contains(lowerLine, "failed") || strings.Contains(lowerLine, "fatal") {
 					if errorSummary[logFile] == nil {
 						errorSummary[logFile] = []string{}
 					}
@@ -192,13 +193,13 @@ func (t *SandboxLogTool) analyzeAllLogs(sandboxPath string) (string, error) {
 	return result.String(), nil
 }
 
-func (t *SandboxLogTool) searchInLog(sandboxPath, logFile, pattern string) (string, error) {
+func (t *SandboxLogTool) searchInLog(ctx context.Context, sandboxPath, logFile, pattern string) (string, error) {
 	// Read the entire file (up to 10000 lines)
 	encodedPath := url.QueryEscape(sandboxPath)
-	url := fmt.Sprintf("http://localhost:8080/sandbox/read?path=%s&file=%s&start=0&lines=10000",
+	url := fmt.Sprintf(ginToolsURL()+"/sandbox/read?path=%s&file=%s&start=0&lines=10000",
 		encodedPath, logFile)
 
-	resp, err := utils.GetHTTP(url)
+	resp, err := utils.GetHTTPContext(ctx, url)
 	if err != nil {
 		return "", fmt.Errorf("failed to read log file: %v", err)
 	}
@@ -232,4 +233,4 @@ func (t *SandboxLogTool) searchInLog(sandboxPath, logFile, pattern string) (stri
 	}
 
 	return result.String(), nil
-}
\ No newline at end of file
+}
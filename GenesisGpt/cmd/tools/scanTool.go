@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	neturl "net/url"
+	"strings"
+
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/utils"
+)
+
+type ScanToolParam struct {
+	Namespace string   `json:"namespace,omitempty"`
+	Analyzers []string `json:"analyzers,omitempty"`
+	Severity  string   `json:"severity,omitempty"`
+}
+
+// ScanTool runs the rule-based health checks of ginTools over a namespace and returns
+// what they found, as a first pass before reasoning about the cluster.
+type ScanTool struct{}
+
+// NewScanTool creates a new ScanTool instance.
+func NewScanTool() *ScanTool {
+	return &ScanTool{}
+}
+
+func (s *ScanTool) Name() string {
+	return "ScanTool"
+}
+
+func (s *ScanTool) Description() string {
+	return "Used first when asked what is broken or unhealthy in a namespace or the cluster. Scans the objects with deterministic rules and returns the problems found, the most severe first: pods in CrashLoopBackOff or ImagePullBackOff, unschedulable pods, OOMKilled containers, failing probes, Services with no endpoints, PVCs stuck Pending, Deployments with unavailable replicas and failed Jobs."
+}
+
+func (s *ScanTool) ArgsSchema() string {
+	return `{"type":"object","properties":{"namespace":{"type":"string", "description": "Namespace to scan. Omit it to scan all namespaces"}, "analyzers":{"type":"array", "items":{"type":"string"}, "description": "Optional: Only run these analyzers: crashloop, image-pull, unschedulable, oom-killed, probes, service-endpoints, pvc-pending, deployment-replicas, failed-jobs"}, "severity":{"type":"string", "description": "Optional: Only findings at least this severe: critical, warning or info"}}}`
+}
+
+// Run executes the command and returns the output.
+func (s *ScanTool) Run(ctx context.Context, input json.RawMessage) (string, error) {
+	var param ScanToolParam
+	if err := json.Unmarshal(input, &param); err != nil {
+		return "", fmt.Errorf("invalid input: %v", err)
+	}
+
+	query := neturl.Values{}
+	if param.Namespace != "" {
+		query.Set("namespace", param.Namespace)
+	}
+	if len(param.Analyzers) > 0 {
+		query.Set("analyzers", strings.Join(param.Analyzers, ","))
+	}
+	if param.Severity != "" {
+		query.Set("severity", param.Severity)
+	}
+	query.Set("format", "text")
+	return utils.GetHTTPContext(ctx, ginToolsURL()+"/analyze?"+query.Encode())
+}
@@ -0,0 +1,395 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/audit"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/config"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/policy"
+	"github.com/lexieqin/Geek/llm"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Tool is implemented by every capability the agent can invoke.
+type Tool interface {
+	// Name is the identifier the model uses in "Action:".
+	Name() string
+	// Description tells the model when to use the tool.
+	Description() string
+	// ArgsSchema is the JSON schema of the Action Input, empty if the tool takes no input.
+	ArgsSchema() string
+	// Run executes the tool with the raw JSON Action Input and returns the observation.
+	Run(ctx context.Context, input json.RawMessage) (string, error)
+}
+
+// Previewer is implemented by tools that can show what a call would change before the
+// user approves it.
+type Previewer interface {
+	// Preview describes the effect of running the tool with input. It returns the input
+	// to run once approved, which may pin details such as generated YAML.
+	Preview(ctx context.Context, input json.RawMessage) (preview string, pinned json.RawMessage, err error)
+}
+
+// PolicyDescriber is implemented by tools taking arguments the policy looks at that are
+// not properties of their ArgsSchema, e.g. namespaces given in a manifest.
+type PolicyDescriber interface {
+	// PolicyParams tells which of the arguments the policy rules look at the tool takes.
+	PolicyParams() policy.Params
+}
+
+// Registry holds the tools available to the agent, in registration order.
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool
+	order []string
+	// audit records every invocation, nil disables it
+	audit *audit.Log
+	// policy is checked before every invocation, nil allows everything
+	policy *policy.Policy
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{tools: make(map[string]Tool)}
+}
+
+// NewDefaultRegistry creates a Registry with all built-in GenesisGpt tools.
+// provider is used by the tools that ask the model themselves.
+func NewDefaultRegistry(provider llm.Provider) *Registry {
+	r := NewRegistry()
+	r.Register(NewCreateTool(provider))
+	r.Register(NewListTool())
+	r.Register(NewDeleteTool())
+	r.Register(NewPatchTool())
+	r.Register(NewUpdateTool(provider))
+	r.Register(NewHumanTool())
+	r.Register(NewClusterTool())
+	r.Register(NewPodTool())
+	r.Register(NewEventTool())
+	r.Register(NewTopologyTool())
+	r.Register(NewScanTool())
+	r.Register(NewResourceInfoTool())
+	r.Register(NewJobDebugTool())
+	r.Register(NewSandboxLogTool())
+	r.Register(NewIntelligentDebugTool())
+	return r
+}
+
+// Register adds a tool, replacing any tool previously registered under the same name.
+func (r *Registry) Register(t Tool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.tools[t.Name()]; !exists {
+		r.order = append(r.order, t.Name())
+	}
+	r.tools[t.Name()] = t
+}
+
+// SetAuditLog makes the registry record every tool invocation in l.
+func (r *Registry) SetAuditLog(l *audit.Log) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.audit = l
+}
+
+// SetPolicy makes the registry check every tool call against p. Tools p never allows
+// are no longer offered to the model.
+func (r *Registry) SetPolicy(p *policy.Policy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.policy = p
+}
+
+// AuditLog returns the log set by SetAuditLog, or nil.
+func (r *Registry) AuditLog() *audit.Log {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.audit
+}
+
+// Get returns the tool registered under name.
+func (r *Registry) Get(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.tools[name]
+	return t, ok
+}
+
+// Tools returns all registered tools in registration order.
+func (r *Registry) Tools() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ret := make([]Tool, 0, len(r.order))
+	for _, name := range r.order {
+		ret = append(ret, r.tools[name])
+	}
+	return ret
+}
+
+// AllNames returns the names of all registered tools in registration order.
+func (r *Registry) AllNames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]string(nil), r.order...)
+}
+
+// Names returns the names of the tools offered to the model in registration order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0)
+	for _, t := range r.offered() {
+		names = append(names, t.Name())
+	}
+	return names
+}
+
+// offered returns the registered tools the policy may allow.
+func (r *Registry) offered() []Tool {
+	r.mu.RLock()
+	p := r.policy
+	r.mu.RUnlock()
+
+	ret := make([]Tool, 0)
+	for _, t := range r.Tools() {
+		if !p.Hidden(t.Name()) {
+			ret = append(ret, t)
+		}
+	}
+	return ret
+}
+
+// Definitions renders every offered tool as a Name/Description/ArgsSchema block for the ReAct prompt.
+func (r *Registry) Definitions() []string {
+	defs := make([]string, 0)
+	for _, t := range r.offered() {
+		def := "Name: " + t.Name() + "\nDescription: " + t.Description() + "\n"
+		if schema := t.ArgsSchema(); schema != "" {
+			def += "ArgsSchema: " + schema + "\n"
+		}
+		defs = append(defs, def)
+	}
+	return defs
+}
+
+// emptyArgsSchema is advertised for tools that take no input.
+const emptyArgsSchema = `{"type":"object","properties":{}}`
+
+// OpenAITools converts every offered tool into a native function-calling definition.
+func (r *Registry) OpenAITools() []openai.Tool {
+	ret := make([]openai.Tool, 0)
+	for _, t := range r.offered() {
+		schema := t.ArgsSchema()
+		if schema == "" {
+			schema = emptyArgsSchema
+		}
+		ret = append(ret, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        t.Name(),
+				Description: t.Description(),
+				Parameters:  json.RawMessage(schema),
+			},
+		})
+	}
+	return ret
+}
+
+// Check decides whether the named tool may run with input now. A call needing
+// confirmation is allowed once the audit.Request in ctx approved exactly this input.
+func (r *Registry) Check(ctx context.Context, name string, input json.RawMessage) policy.Decision {
+	name = strings.TrimSpace(name)
+	if len(input) == 0 {
+		input = json.RawMessage("{}")
+	}
+
+	return r.check(name, input, audit.RequestFrom(ctx).Approved(name, input))
+}
+
+// CheckApproved decides whether the named tool may run with input once the user approved
+// it, so a call the policy would refuse anyway is not put to the user.
+func (r *Registry) CheckApproved(name string, input json.RawMessage) policy.Decision {
+	return r.check(strings.TrimSpace(name), input, true)
+}
+
+func (r *Registry) check(name string, input json.RawMessage, confirmed bool) policy.Decision {
+	r.mu.RLock()
+	p := r.policy
+	r.mu.RUnlock()
+
+	var params policy.Params
+	if t, ok := r.Get(name); ok {
+		params = policyParams(t)
+	}
+	return p.CheckCall(name, params, input, confirmed)
+}
+
+// policyParams returns the arguments the policy looks at that a tool takes: those it
+// describes as a PolicyDescriber, or else the properties of its ArgsSchema.
+func policyParams(t Tool) policy.Params {
+	if d, ok := t.(PolicyDescriber); ok {
+		return d.PolicyParams()
+	}
+
+	var schema struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	json.Unmarshal([]byte(t.ArgsSchema()), &schema)
+
+	has := func(names ...string) bool {
+		for _, name := range names {
+			if _, ok := schema.Properties[name]; ok {
+				return true
+			}
+		}
+		return false
+	}
+	return policy.Params{
+		Namespace: has("namespace", "ns"),
+		Kind:      has("resource", "kind"),
+	}
+}
+
+// Preview asks the named tool what input would do, if the tool can tell. Tools that
+// cannot preview return an empty preview and input unchanged.
+func (r *Registry) Preview(ctx context.Context, name string, input json.RawMessage) (string, json.RawMessage, error) {
+	t, ok := r.Get(strings.TrimSpace(name))
+	if !ok {
+		return "", input, nil
+	}
+	p, ok := t.(Previewer)
+	if !ok {
+		return "", input, nil
+	}
+	return p.Preview(ctx, input)
+}
+
+// pin previews a call of a tool whose policy arguments are only known from the manifest
+// its preview pins, and returns the pinned input, so the policy checks what will run.
+// Inputs already carrying a manifest, and calls the policy never allows, are returned as is.
+func (r *Registry) pin(ctx context.Context, t Tool, input json.RawMessage) (json.RawMessage, error) {
+	r.mu.RLock()
+	p := r.policy
+	r.mu.RUnlock()
+
+	if !policyParams(t).Manifest || policy.HasManifest(input) || p.Hidden(t.Name()) {
+		return input, nil
+	}
+	previewer, ok := t.(Previewer)
+	if !ok {
+		return input, nil
+	}
+	_, pinned, err := previewer.Preview(ctx, input)
+	if err != nil {
+		return input, err
+	}
+	return pinned, nil
+}
+
+// Invoke runs the named tool and returns its output, or the error text if it failed.
+// The call is checked against the policy first, once previewed if the policy looks at
+// the manifest its preview pins; a refused call returns the reason instead. Every call is recorded in the audit log, attributed to the audit.Request in ctx.
+func (r *Registry) Invoke(ctx context.Context, name string, input json.RawMessage) string {
+	name = strings.TrimSpace(name)
+	t, ok := r.Get(name)
+	if !ok {
+		return fmt.Sprintf("Unknown action: %s", name)
+	}
+
+	if len(input) == 0 {
+		input = json.RawMessage("{}")
+	}
+
+	req := audit.RequestFrom(ctx)
+	input, err := r.pin(ctx, t, input)
+	if err != nil {
+		output := fmt.Sprintf("Not executed: the preview failed: %v", err)
+		r.record(req, name, input, "", output, err, 0, time.Now(), 0)
+		return output
+	}
+	if decision := r.Check(ctx, name, input); !decision.Allowed() {
+		output := decision.Message(name)
+		r.record(req, name, input, "", output, fmt.Errorf("policy: %s", decision.Reason), 0, time.Now(), 0)
+		return output
+	}
+
+	callCtx, status := audit.StartCall(ctx)
+	start := time.Now()
+	output, err := t.Run(callCtx, input)
+	duration := time.Since(start)
+
+	confirmation := ""
+	if req.Approved(name, input) {
+		confirmation = audit.ConfirmationApproved
+	} else if _, isHuman := t.(*HumanTool); isHuman && err == nil {
+		confirmation = strings.TrimSpace(output)
+		if strings.HasPrefix(output, "[HUMAN_CONFIRMATION_REQUIRED]") {
+			confirmation = audit.ConfirmationPending
+		}
+	}
+	r.record(req, name, input, confirmation, output, err, status(), start, duration)
+
+	if err != nil {
+		return "Error: " + err.Error()
+	}
+	return output
+}
+
+// Record adds an entry for a tool call that was decided without running, such as an
+// action the user denied.
+func (r *Registry) Record(ctx context.Context, name string, input json.RawMessage, confirmation, output string) {
+	r.record(audit.RequestFrom(ctx), name, input, confirmation, output, nil, 0, time.Now(), 0)
+}
+
+func (r *Registry) record(req *audit.Request, name string, input json.RawMessage, confirmation, output string,
+	err error, status int, start time.Time, duration time.Duration) {
+	r.mu.RLock()
+	auditLog := r.audit
+	r.mu.RUnlock()
+	if auditLog == nil {
+		return
+	}
+
+	entry := audit.Entry{
+		Time:         start,
+		Tool:         name,
+		Input:        input,
+		Confirmation: confirmation,
+		Status:       status,
+		Output:       output,
+		DurationMs:   duration.Milliseconds(),
+	}
+	if !json.Valid(input) {
+		// Keep what the model sent even when it is not JSON
+		entry.Input, _ = json.Marshal(string(input))
+	}
+	if req != nil {
+		entry.SessionID = req.SessionID
+		entry.User = req.User
+		entry.Query = req.Query
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	auditLog.Record(entry)
+}
+
+// Execute runs the named tool and formats the result as an "Observation: " line.
+func (r *Registry) Execute(ctx context.Context, name string, input json.RawMessage) string {
+	return "Observation: " + r.Invoke(ctx, name, input)
+}
+
+// ginToolsURL is the base URL of the ginTools API used by the Kubernetes tools.
+func ginToolsURL() string {
+	return config.GinToolsURL()
+}
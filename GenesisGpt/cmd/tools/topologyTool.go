@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	neturl "net/url"
+	"strconv"
+
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/utils"
+)
+
+type TopologyToolParam struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Depth     int    `json:"depth,omitempty"`
+}
+
+// TopologyTool shows how an object relates to the objects around it.
+type TopologyTool struct{}
+
+// NewTopologyTool creates a new TopologyTool instance.
+func NewTopologyTool() *TopologyTool {
+	return &TopologyTool{}
+}
+
+func (t *TopologyTool) Name() string {
+	return "TopologyTool"
+}
+
+func (t *TopologyTool) Description() string {
+	return "Used to see how a Kubernetes object relates to others: what owns a pod, which ReplicaSets and Pods a Deployment owns, which Services and Ingresses route to it, which ConfigMaps, Secrets and PVCs its pods mount or use, and which autoscaler scales it. Referenced objects that do not exist are marked [missing]."
+}
+
+func (t *TopologyTool) ArgsSchema() string {
+	return `{"type":"object","properties":{"kind":{"type":"string", "description": "Resource or kind of the object, e.g. pod, deployment, statefulset, service, ingress, configmap or pvc"}, "name":{"type":"string", "description": "Name of the object"}, "namespace":{"type":"string", "description": "Namespace of the object. Omit it to find the object by name in all namespaces"}, "depth":{"type":"integer", "description": "Optional: How many relations to follow, 2 by default, at most 5"}},"required":["kind","name"]}`
+}
+
+// Run executes the command and returns the output.
+func (t *TopologyTool) Run(ctx context.Context, input json.RawMessage) (string, error) {
+	var param TopologyToolParam
+	if err := json.Unmarshal(input, &param); err != nil {
+		return "", fmt.Errorf("invalid input: %v", err)
+	}
+	if param.Kind == "" || param.Name == "" {
+		return "", fmt.Errorf("kind and name are required")
+	}
+
+	query := neturl.Values{}
+	query.Set("kind", param.Kind)
+	query.Set("name", param.Name)
+	if param.Namespace != "" {
+		query.Set("namespace", param.Namespace)
+	}
+	if param.Depth > 0 {
+		query.Set("depth", strconv.Itoa(param.Depth))
+	}
+	query.Set("format", "text")
+	return utils.GetHTTPContext(ctx, ginToolsURL()+"/graph?"+query.Encode())
+}
@@ -0,0 +1,167 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/promptTpl"
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/utils"
+	"github.com/lexieqin/Geek/llm"
+	"github.com/sashabaranov/go-openai"
+)
+
+type UpdateToolParam struct {
+	Resource    string `json:"resource"`
+	Name        string `json:"name"`
+	Namespace   string `json:"namespace"`
+	Instruction string `json:"instruction"`
+	// Yaml holds the changes written for the object at ResourceVersion. Both are set by
+	// Preview; a call with Yaml updates with it instead of writing new changes.
+	Yaml            string `json:"yaml,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+// UpdateTool edits a live k8s object following a natural-language instruction.
+type UpdateTool struct {
+	// Provider writes the changes from the instruction
+	Provider llm.Provider
+}
+
+// NewUpdateTool creates a new UpdateTool instance.
+func NewUpdateTool(provider llm.Provider) *UpdateTool {
+	return &UpdateTool{Provider: provider}
+}
+
+func (u *UpdateTool) Name() string {
+	return "UpdateTool"
+}
+
+func (u *UpdateTool) Description() string {
+	return "Used to edit an existing Kubernetes resource following an instruction, such as changing resource limits, environment variables or probes. The changes are written against the live object and the user sees the resulting diff before anything is changed. Fails instead of overwriting if the object changes in the meantime."
+}
+
+func (u *UpdateTool) ArgsSchema() string {
+	return `{"type":"object","properties":{"resource":{"type":"string", "description": "Specified k8s resource type, e.g. deployment, configmap, etc."}, "name":{"type":"string", "description": "Name of the specified k8s resource instance"}, "namespace":{"type":"string", "description": "Namespace where the specified k8s resource is located"}, "instruction":{"type":"string", "description": "What to change, e.g. set the memory limit of container app to 512Mi"}},"required":["resource","name","instruction"]}`
+}
+
+// Run executes the command and returns the output.
+func (u *UpdateTool) Run(ctx context.Context, input json.RawMessage) (string, error) {
+	param, err := parseUpdateParam(input)
+	if err != nil {
+		return "", err
+	}
+	if param.Yaml == "" {
+		if err := u.Write(ctx, param); err != nil {
+			return "Update failed: " + err.Error(), nil
+		}
+	}
+
+	var updated struct {
+		ResourceVersion string `json:"resourceVersion"`
+	}
+	if err := u.Update(ctx, param, false, &updated); err != nil {
+		return "Update failed: " + err.Error(), nil
+	}
+	return fmt.Sprintf("Update successful, %s %s is now at resourceVersion %s", param.Resource, param.Name, updated.ResourceVersion), nil
+}
+
+// Preview writes the changes against the live object and validates them with a ginTools
+// dry run. The changes and the object's resourceVersion are pinned in the returned input,
+// so the approved call makes exactly the change shown, or fails if the object changed.
+func (u *UpdateTool) Preview(ctx context.Context, input json.RawMessage) (string, json.RawMessage, error) {
+	param, err := parseUpdateParam(input)
+	if err != nil {
+		return "", nil, err
+	}
+	if param.Yaml == "" {
+		if err := u.Write(ctx, param); err != nil {
+			return "", nil, err
+		}
+	}
+
+	var result dryRunResult
+	if err := u.Update(ctx, param, true, &result); err != nil {
+		return "", nil, fmt.Errorf("the API server rejected the changes: %v\n%s", err, param.Yaml)
+	}
+	if result.Diff == "" {
+		return "", nil, fmt.Errorf("the changes would not modify %s %s:\n%s", param.Resource, param.Name, param.Yaml)
+	}
+
+	pinned, err := json.Marshal(param)
+	if err != nil {
+		return "", nil, err
+	}
+	preview := fmt.Sprintf("Changes to %s %s in namespace %s:\n%s\n\nDry run:\n%s", param.Resource, param.Name, param.Namespace, strings.TrimSpace(param.Yaml), result.Diff)
+	return preview, pinned, nil
+}
+
+// Write fetches the live object and lets the large model write the changes the
+// instruction asks for, setting param.Yaml and param.ResourceVersion.
+func (u *UpdateTool) Write(ctx context.Context, param *UpdateToolParam) error {
+	query := url.Values{}
+	query.Set("ns", param.Namespace)
+	query.Set("name", param.Name)
+	s, err := utils.GetHTTPContext(ctx, ginToolsURL()+"/"+param.Resource+"/object?"+query.Encode())
+	if err != nil {
+		return err
+	}
+	var live map[string]interface{}
+	if err := parseData(s, &live); err != nil {
+		return fmt.Errorf("failed to get %s %s: %v", param.Resource, param.Name, err)
+	}
+	liveJSON, err := json.MarshalIndent(live, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	messages := []openai.ChatCompletionMessage{
+		{Role: "system", Content: promptTpl.UpdatePrompt},
+		{Role: "user", Content: fmt.Sprintf("Object:\n%s\n\nInstruction: %s", liveJSON, param.Instruction)},
+	}
+	rsp, err := u.Provider.Chat(ctx, llm.Request{Messages: messages})
+	if err != nil {
+		return fmt.Errorf("failed to write the changes: %v", err)
+	}
+
+	param.Yaml = rsp.Content
+	param.ResourceVersion = resourceVersion(live)
+	return nil
+}
+
+// Update sends the changes to ginTools and decodes the response data into v.
+func (u *UpdateTool) Update(ctx context.Context, param *UpdateToolParam, dryRun bool, v interface{}) error {
+	query := url.Values{}
+	query.Set("ns", param.Namespace)
+	query.Set("name", param.Name)
+	if param.ResourceVersion != "" {
+		query.Set("resourceVersion", param.ResourceVersion)
+	}
+	if dryRun {
+		query.Set("dryRun", "true")
+	}
+
+	s, err := utils.PutHTTPContext(ctx, ginToolsURL()+"/"+param.Resource+"?"+query.Encode(), yamlBody(param.Yaml))
+	if err != nil {
+		return err
+	}
+	return parseData(s, v)
+}
+
+// parseUpdateParam decodes the tool input and fills in the defaults.
+func parseUpdateParam(input json.RawMessage) (*UpdateToolParam, error) {
+	var param UpdateToolParam
+	if err := json.Unmarshal(input, &param); err != nil {
+		return nil, fmt.Errorf("invalid input: %v", err)
+	}
+	if param.Resource == "" || param.Name == "" || (param.Instruction == "" && param.Yaml == "") {
+		return nil, fmt.Errorf("invalid input: resource, name and instruction are required")
+	}
+	param.Resource = strings.ToLower(param.Resource)
+	if param.Namespace == "" {
+		param.Namespace = "default"
+	}
+	return &param, nil
+}
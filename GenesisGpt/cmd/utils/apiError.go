@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIError is a request ginTools answered with an error. Its message ends with a hint on
+// what to do about it, so a model reading a tool's output can react.
+type APIError struct {
+	// Code is the HTTP status code
+	Code int `json:"code"`
+	// Reason is the Kubernetes StatusReason, e.g. NotFound, Forbidden or Conflict
+	Reason    string `json:"reason"`
+	Message   string `json:"message"`
+	RequestID string `json:"requestId"`
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message
+	if e.Reason != "" {
+		msg = fmt.Sprintf("%s (HTTP %d): %s", e.Reason, e.Code, e.Message)
+	}
+	if hint := e.Hint(); hint != "" {
+		msg += ". " + hint
+	}
+	return msg
+}
+
+// Hint suggests the next step for the error's reason, empty if there is nothing to suggest.
+func (e *APIError) Hint() string {
+	switch e.Reason {
+	case "NotFound":
+		return "Check the name, the resource type and the namespace; the object may live in another namespace, so list the resources across all namespaces to find it"
+	case "AlreadyExists":
+		return "An object of that name already exists; update or patch it instead of creating it"
+	case "Conflict":
+		return "The object was changed in the meantime; read it again and retry against the current version"
+	case "Forbidden", "Unauthorized":
+		return "ginTools is not permitted to do this; retrying will not help, the permissions of its service account must be extended"
+	case "Invalid":
+		return "The API server rejected the fields named in the message; correct them and retry"
+	case "BadRequest":
+		return "The request parameters are malformed; correct them and retry"
+	case "Timeout", "ServerTimeout", "TooManyRequests", "ServiceUnavailable":
+		return "The cluster is busy or unreachable; retry later"
+	}
+	return ""
+}
+
+// ErrorFromBody returns the error a ginTools response reports, nil if status is a success
+// and the body carries no error. It reads the error envelope of ginTools, and plain error
+// strings and bodies of other servers. status may be 0 if it is not known.
+func ErrorFromBody(status int, body []byte) error {
+	var rsp struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(body, &rsp) != nil || len(rsp.Error) == 0 || string(rsp.Error) == "null" {
+		if status < http.StatusBadRequest {
+			return nil
+		}
+		return newAPIError(status, strings.TrimSpace(string(body)))
+	}
+
+	apiErr := &APIError{}
+	if err := json.Unmarshal(rsp.Error, apiErr); err != nil {
+		var message string
+		if json.Unmarshal(rsp.Error, &message) != nil {
+			message = string(rsp.Error)
+		}
+		return newAPIError(status, message)
+	}
+	if apiErr.Code == 0 {
+		apiErr.Code = status
+	}
+	if apiErr.Reason == "" {
+		apiErr.Reason = reasonForCode(apiErr.Code)
+	}
+	return apiErr
+}
+
+// newAPIError makes an APIError of a status and a message that came without a reason
+func newAPIError(status int, message string) *APIError {
+	return &APIError{Code: status, Reason: reasonForCode(status), Message: message}
+}
+
+// reasonForCode returns the Kubernetes StatusReason of an HTTP error status, empty for
+// statuses that are no error
+func reasonForCode(code int) string {
+	switch code {
+	case http.StatusBadRequest:
+		return "BadRequest"
+	case http.StatusUnauthorized:
+		return "Unauthorized"
+	case http.StatusForbidden:
+		return "Forbidden"
+	case http.StatusNotFound:
+		return "NotFound"
+	case http.StatusConflict:
+		return "Conflict"
+	case http.StatusUnprocessableEntity:
+		return "Invalid"
+	case http.StatusTooManyRequests:
+		return "TooManyRequests"
+	case http.StatusServiceUnavailable:
+		return "ServiceUnavailable"
+	case http.StatusGatewayTimeout:
+		return "Timeout"
+	}
+	if code >= http.StatusInternalServerError {
+		return "InternalError"
+	}
+	return ""
+}
+
+// statusError returns the error of a response whose status is not a success
+func statusError(status int, body []byte) error {
+	if err := ErrorFromBody(status, body); err != nil {
+		return err
+	}
+	return newAPIError(status, strings.TrimSpace(string(body)))
+}
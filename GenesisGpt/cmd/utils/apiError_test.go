@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorFromBody(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		// want is the error message, empty for no error
+		want string
+	}{
+		{"data", 200, `{"data":[]}`, ""},
+		{"envelope", 404, `{"error":{"code":404,"reason":"NotFound","message":"pods \"web\" not found","requestId":"ab12"}}`,
+			`NotFound (HTTP 404): pods "web" not found. Check the name`},
+		{"envelope without status", 0, `{"error":{"code":409,"reason":"Conflict","message":"the object has been modified"}}`,
+			"Conflict (HTTP 409): the object has been modified. The object was changed"},
+		{"envelope without reason", 403, `{"error":{"code":403,"message":"denied"}}`, "Forbidden (HTTP 403): denied. ginTools is not permitted"},
+		{"string error", 400, `{"error":"name parameter is required"}`, "BadRequest (HTTP 400): name parameter is required"},
+		{"string error without status", 0, `{"data":{"failed":1},"error":"Apply failed for 1 of 2 objects"}`, "Apply failed for 1 of 2 objects"},
+		{"plain body", 502, "bad gateway\n", "InternalError (HTTP 502): bad gateway"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ErrorFromBody(tt.status, []byte(tt.body))
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("ErrorFromBody() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Fatalf("ErrorFromBody() = %v, want prefix %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientSurfacesAPIErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":{"code":404,"reason":"NotFound","message":"deployments.apps \"web\" not found","requestId":"ab12"}}`)
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient()
+	for _, call := range []func() error{
+		func() error { _, err := client.GetContext(context.Background(), srv.URL, nil); return err },
+		func() error { _, err := client.DeleteContext(context.Background(), srv.URL, nil); return err },
+	} {
+		var apiErr *APIError
+		if err := call(); !errors.As(err, &apiErr) {
+			t.Fatalf("error = %v, want an *APIError", err)
+		}
+		if apiErr.Reason != "NotFound" || apiErr.RequestID != "ab12" {
+			t.Errorf("error = %+v, want reason NotFound and request ID ab12", apiErr)
+		}
+	}
+}
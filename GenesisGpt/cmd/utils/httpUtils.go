@@ -2,10 +2,11 @@ package utils
 
 import (
 	"bytes"
-	"fmt"
+	"context"
 	"io/ioutil"
 	"net/http"
 
+	"github.com/lexieqin/Geek/GenesisGpt/cmd/audit"
 	"github.com/lexieqin/Geek/GenesisGpt/cmd/config"
 )
 
@@ -33,7 +34,12 @@ func NewHTTPClient() *DefaultHTTPClient {
 
 // Get performs HTTP GET request with optional headers
 func (c *DefaultHTTPClient) Get(url string, headers map[string]string) (string, error) {
-	req, err := http.NewRequest("GET", url, nil)
+	return c.GetContext(context.Background(), url, headers)
+}
+
+// GetContext performs HTTP GET request bound to ctx
+func (c *DefaultHTTPClient) GetContext(ctx context.Context, url string, headers map[string]string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return "", err
 	}
@@ -48,10 +54,11 @@ func (c *DefaultHTTPClient) Get(url string, headers map[string]string) (string,
 		return "", err
 	}
 	defer resp.Body.Close()
+	audit.SetStatus(ctx, resp.StatusCode)
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := ioutil.ReadAll(resp.Body)
-		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+		return "", statusError(resp.StatusCode, body)
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
@@ -64,7 +71,27 @@ func (c *DefaultHTTPClient) Get(url string, headers map[string]string) (string,
 
 // Post performs HTTP POST request with optional headers
 func (c *DefaultHTTPClient) Post(url string, body []byte, headers map[string]string) (string, error) {
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	return c.PostContext(context.Background(), url, body, headers)
+}
+
+// PostContext performs HTTP POST request bound to ctx
+func (c *DefaultHTTPClient) PostContext(ctx context.Context, url string, body []byte, headers map[string]string) (string, error) {
+	return c.sendContext(ctx, "POST", url, body, headers)
+}
+
+// PatchContext performs HTTP PATCH request bound to ctx
+func (c *DefaultHTTPClient) PatchContext(ctx context.Context, url string, body []byte, headers map[string]string) (string, error) {
+	return c.sendContext(ctx, "PATCH", url, body, headers)
+}
+
+// PutContext performs HTTP PUT request bound to ctx
+func (c *DefaultHTTPClient) PutContext(ctx context.Context, url string, body []byte, headers map[string]string) (string, error) {
+	return c.sendContext(ctx, "PUT", url, body, headers)
+}
+
+// sendContext sends a JSON body with method and returns the response body whatever its status
+func (c *DefaultHTTPClient) sendContext(ctx context.Context, method string, url string, body []byte, headers map[string]string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(body))
 	if err != nil {
 		return "", err
 	}
@@ -82,6 +109,7 @@ func (c *DefaultHTTPClient) Post(url string, body []byte, headers map[string]str
 		return "", err
 	}
 	defer resp.Body.Close()
+	audit.SetStatus(ctx, resp.StatusCode)
 
 	respBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -93,7 +121,13 @@ func (c *DefaultHTTPClient) Post(url string, body []byte, headers map[string]str
 
 // Delete performs HTTP DELETE request with optional headers
 func (c *DefaultHTTPClient) Delete(url string, headers map[string]string) (string, error) {
-	req, err := http.NewRequest("DELETE", url, nil)
+	return c.DeleteContext(context.Background(), url, headers)
+}
+
+// DeleteContext performs HTTP DELETE request bound to ctx. A failed delete returns the
+// error the response reports.
+func (c *DefaultHTTPClient) DeleteContext(ctx context.Context, url string, headers map[string]string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
 	if err != nil {
 		return "", err
 	}
@@ -108,11 +142,15 @@ func (c *DefaultHTTPClient) Delete(url string, headers map[string]string) (strin
 		return "", err
 	}
 	defer resp.Body.Close()
+	audit.SetStatus(ctx, resp.StatusCode)
 
 	respBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return "", err
 	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return string(respBody), statusError(resp.StatusCode, respBody)
+	}
 
 	return string(respBody), nil
 }
@@ -138,6 +176,36 @@ func DeleteHTTP(url string) (string, error) {
 	return client.Delete(url, nil)
 }
 
+// GetHTTPContext executes a GET HTTP request that is cancelled together with ctx.
+func GetHTTPContext(ctx context.Context, url string) (string, error) {
+	client := NewHTTPClient()
+	return client.GetContext(ctx, url, nil)
+}
+
+// PostHTTPContext executes a POST HTTP request that is cancelled together with ctx.
+func PostHTTPContext(ctx context.Context, url string, body []byte) (string, error) {
+	client := NewHTTPClient()
+	return client.PostContext(ctx, url, body, nil)
+}
+
+// PatchHTTPContext executes a PATCH HTTP request that is cancelled together with ctx.
+func PatchHTTPContext(ctx context.Context, url string, body []byte) (string, error) {
+	client := NewHTTPClient()
+	return client.PatchContext(ctx, url, body, nil)
+}
+
+// PutHTTPContext executes a PUT HTTP request that is cancelled together with ctx.
+func PutHTTPContext(ctx context.Context, url string, body []byte) (string, error) {
+	client := NewHTTPClient()
+	return client.PutContext(ctx, url, body, nil)
+}
+
+// DeleteHTTPContext executes a DELETE HTTP request that is cancelled together with ctx.
+func DeleteHTTPContext(ctx context.Context, url string) (string, error) {
+	client := NewHTTPClient()
+	return client.DeleteContext(ctx, url, nil)
+}
+
 // GetHTTPWithAuth performs HTTP GET with authentication based on config
 func GetHTTPWithAuth(url string, authType string) (string, error) {
 	client := NewHTTPClient()
@@ -183,4 +251,4 @@ func addDatadogHeaders(headers map[string]string, auth config.AuthMethod) {
 	if auth.AppKey != "" {
 		headers["DD-APPLICATION-KEY"] = auth.AppKey
 	}
-}
\ No newline at end of file
+}
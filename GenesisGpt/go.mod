@@ -5,12 +5,15 @@ go 1.22.0
 toolchain go1.22.9
 
 require (
+	github.com/lexieqin/Geek/llm v0.0.0
 	github.com/sashabaranov/go-openai v1.35.6
 	github.com/spf13/cobra v1.8.1
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
+
+replace github.com/lexieqin/Geek/llm => ../llm
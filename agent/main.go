@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"regexp"
 	"strconv"
 
@@ -27,7 +29,10 @@ func main() {
 	ai.MessageStore.AddForUser(prompt)
 	i := 1
 	for {
-		first_response := ai.NormalChat(ai.MessageStore.ToMessage())
+		first_response, err := ai.NormalChat(context.Background(), ai.MessageStore.ToMessage())
+		if err != nil {
+			log.Fatalln("chat err: ", err)
+		}
 		fmt.Printf("========第%d轮回答========\n", i)
 		fmt.Println(first_response)
 		regexPattern := regexp.MustCompile(`Final Answer:\s*(.*)`)
@@ -2,8 +2,10 @@ package ai
 
 import (
 	"context"
+	"sync"
+
+	"github.com/lexieqin/Geek/llm"
 	openai "github.com/sashabaranov/go-openai"
-	"log"
 )
 
 var MessageStore ChatMessages
@@ -14,45 +16,49 @@ func init() {
 
 }
 
-func NewOpenAiClient() *openai.Client {
-	token := "sk-07d4040e83824cea8df0da757f10844f"
-	dashscope_url := "https://dashscope.aliyuncs.com/compatible-mode/v1"
-	config := openai.DefaultConfig(token)
-	config.BaseURL = dashscope_url
+var (
+	providerMu sync.Mutex
+	provider   llm.Provider
+)
 
-	return openai.NewClientWithConfig(config)
+// SetProvider replaces the model NormalChat and Chat talk to, e.g. with a scripted one in tests
+func SetProvider(p llm.Provider) {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	provider = p
 }
 
-// chat对话
-func NormalChat(message []openai.ChatCompletionMessage) openai.ChatCompletionMessage {
-	c := NewOpenAiClient()
-	rsp, err := c.CreateChatCompletion(context.TODO(), openai.ChatCompletionRequest{
-		Model:    "qwen-max",
-		Messages: message,
-	})
-	if err != nil {
-		log.Println(err)
-		return openai.ChatCompletionMessage{}
+// Provider returns the model NormalChat and Chat talk to, by default an OpenAI-compatible
+// client configured from the environment
+func Provider() (llm.Provider, error) {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	if provider == nil {
+		cfg, err := llm.ConfigFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		p, err := llm.NewOpenAI(cfg)
+		if err != nil {
+			return nil, err
+		}
+		provider = p
 	}
-
-	return rsp.Choices[0].Message
+	return provider, nil
 }
 
 // chat对话
-func Chat(message []openai.ChatCompletionMessage, tools []openai.Tool) openai.ChatCompletionMessage {
-	c := NewOpenAiClient()
-	rsp, err := c.CreateChatCompletion(context.TODO(), openai.ChatCompletionRequest{
-		Model:      "qwen-plus",
-		Messages:   message,
-		Tools:      tools,
-		ToolChoice: "auto",
-	})
+func NormalChat(ctx context.Context, message []openai.ChatCompletionMessage) (openai.ChatCompletionMessage, error) {
+	return Chat(ctx, message, nil)
+}
+
+// 带tools的chat对话
+func Chat(ctx context.Context, message []openai.ChatCompletionMessage, tools []openai.Tool) (openai.ChatCompletionMessage, error) {
+	p, err := Provider()
 	if err != nil {
-		log.Println(err)
-		return openai.ChatCompletionMessage{}
+		return openai.ChatCompletionMessage{}, err
 	}
-
-	return rsp.Choices[0].Message
+	return p.Chat(ctx, llm.Request{Messages: message, Tools: tools})
 }
 
 // 定义chat模型
@@ -2,4 +2,9 @@ module github.com/xingyunyang01/Geek/function-calling
 
 go 1.19
 
-require github.com/sashabaranov/go-openai v1.27.1
+require (
+	github.com/lexieqin/Geek/llm v0.0.0
+	github.com/sashabaranov/go-openai v1.35.6
+)
+
+replace github.com/lexieqin/Geek/llm => ../llm
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"strconv"
 
 	"github.com/xingyunyang01/Geek/function-calling/ai"
 	"github.com/xingyunyang01/Geek/function-calling/tools"
@@ -18,7 +20,11 @@ func main() {
 	prompt := "1+2-3+4-5+6=? Just give me a number result"
 	ai.MessageStore.AddFor(ai.RoleUser, prompt, nil)
 
-	response := ai.ToolsChat(ai.MessageStore.ToMessage(), toolsList)
+	ctx := context.Background()
+	response, err := ai.ToolsChat(ctx, ai.MessageStore.ToMessage(), toolsList)
+	if err != nil {
+		log.Fatalln("chat err: ", err)
+	}
 	toolCalls := response.ToolCalls
 
 	for {
@@ -41,9 +47,12 @@ func main() {
 
 			fmt.Println("函数计算结果: ", result)
 			ai.MessageStore.AddFor(ai.RoleAssistant, response.Content, toolCalls)
-			ai.MessageStore.AddForTool(string(result), toolCalls[0].Function.Name, toolCalls[0].ID)
+			ai.MessageStore.AddForTool(strconv.Itoa(result), toolCalls[0].Function.Name, toolCalls[0].ID)
 
-			response = ai.ToolsChat(ai.MessageStore.ToMessage(), toolsList)
+			response, err = ai.ToolsChat(ctx, ai.MessageStore.ToMessage(), toolsList)
+			if err != nil {
+				log.Fatalln("chat err: ", err)
+			}
 			toolCalls = response.ToolCalls
 
 		} else {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 )
 
@@ -62,6 +63,79 @@ func main() {
 		io.Copy(w, resp.Body)
 	})
 
+	// Proxy streaming API requests to GenesisGpt, flushing every chunk as it arrives
+	http.HandleFunc("/api/query/stream", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		// Forward to GenesisGpt, cancelling the upstream call if the browser goes away
+		req, err := http.NewRequestWithContext(r.Context(), "POST", genesisgptURL+"/query/stream", r.Body)
+		if err != nil {
+			http.Error(w, "Failed to build request", http.StatusInternalServerError)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "text/event-stream")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "GenesisGpt service unavailable: " + err.Error(),
+			})
+			return
+		}
+		defer resp.Body.Close()
+
+		w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(resp.StatusCode)
+		flusher.Flush()
+
+		// Copy the stream unbuffered
+		buf := make([]byte, 4096)
+		for {
+			n, err := resp.Body.Read(buf)
+			if n > 0 {
+				if _, werr := w.Write(buf[:n]); werr != nil {
+					return
+				}
+				flusher.Flush()
+			}
+			if err != nil {
+				return
+			}
+		}
+	})
+
+	// Proxy approve/deny decisions on a pending action to GenesisGpt
+	http.HandleFunc("POST /api/sessions/{id}/confirm", func(w http.ResponseWriter, r *http.Request) {
+		resp, err := http.Post(genesisgptURL+"/sessions/"+url.PathEscape(r.PathValue("id"))+"/confirm", "application/json", r.Body)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "GenesisGpt service unavailable: " + err.Error(),
+			})
+			return
+		}
+		defer resp.Body.Close()
+
+		w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	})
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "3000"
@@ -2,6 +2,7 @@ package main
 
 import (
 	"os"
+	"time"
 	
 	"github.com/gin-gonic/gin"
 	"github.com/lexieqin/Geek/ginTools/pkg/config"
@@ -20,26 +21,44 @@ func main() {
 		// Use auto-detection (in-cluster first, then kubeconfig)
 		k8sconfig = config.NewK8sConfig().InitRestConfig()
 	}
-	restMapper := k8sconfig.InitRestMapper()
+	discoveryClient := k8sconfig.InitDiscoveryClient()
+	restMapper := k8sconfig.InitRestMapper(discoveryClient)
+	mapperRefresh, err := time.ParseDuration(os.Getenv("RESTMAPPER_REFRESH"))
+	if err != nil || mapperRefresh <= 0 {
+		mapperRefresh = 10 * time.Minute
+	}
+	go config.RefreshRestMapper(restMapper, mapperRefresh, make(chan struct{}))
 	dynamicClient := k8sconfig.InitDynamicClient()
-	informer := k8sconfig.InitInformer()
+	informerTTL, _ := time.ParseDuration(os.Getenv("INFORMER_TTL"))
+	informers := services.NewInformerCache(dynamicClient, informerTTL)
+	go informers.Run(make(chan struct{}))
 
 	clientSet := k8sconfig.InitClientSet()
 
-	resourceCtl := controllers.NewResourceCtl(services.NewResourceService(&restMapper, dynamicClient, informer))
+	resourceService := services.NewResourceService(&restMapper, discoveryClient, dynamicClient, informers)
+	resourceService.SetFieldManager(os.Getenv("FIELD_MANAGER"))
+	resourceCtl := controllers.NewResourceCtl(resourceService)
 	podLogCtl := controllers.NewPodLogEventCtl(services.NewPodLogEventService(clientSet))
 	jobDebugCtl := controllers.NewJobDebugController(services.NewJobDebugService(clientSet))
 	mockJobCtl := controllers.NewMockJobController()
 
 	r := gin.New()
+	r.Use(controllers.RequestID(), controllers.Recovery())
+	r.NoRoute(controllers.NoRoute())
 
 	r.GET("/:resource", resourceCtl.List())
 	r.DELETE("/:resource", resourceCtl.Delete())
 	r.POST("/:resource", resourceCtl.Create())
+	r.POST("/apply", resourceCtl.Apply())
 	r.PUT("/:resource", resourceCtl.Update())
 	r.PATCH("/:resource", resourceCtl.Patch())
 	r.GET("/:resource/status", resourceCtl.GetStatus())
+	r.GET("/:resource/object", resourceCtl.GetObject())
 	r.GET("/get/gvr", resourceCtl.GetGVR())
+	r.GET("/api-resources", resourceCtl.APIResources())
+	r.GET("/events", resourceCtl.Events())
+	r.GET("/graph", resourceCtl.Graph())
+	r.GET("/analyze", resourceCtl.Analyze())
 	r.GET("/get/resource", resourceCtl.GetResource())
 	r.POST("/get/resource", resourceCtl.GetResourceByType())
 
@@ -49,9 +68,9 @@ func main() {
 	r.GET("/namespaces/:namespace/pods/:podName/logs", podLogCtl.GetLog())
 	r.GET("/namespaces/:namespace/pods/:podName/events", podLogCtl.GetEvent())
 
-	// Handle all pod logs and events
-	r.GET("/namespaces/:namespace/pods/logs", podLogCtl.GetLog())
-	r.GET("/namespaces/:namespace/pods/events", podLogCtl.GetEvent())
+	// Handle the logs and events of a whole namespace
+	r.GET("/namespaces/:namespace/logs", podLogCtl.NamespaceLogs())
+	r.GET("/namespaces/:namespace/events", podLogCtl.NamespaceEvents())
 
 	// Job debug endpoints
 	r.GET("/jobs/:namespace/:name/debug", jobDebugCtl.GetJobDebugInfo)
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lexieqin/Geek/ginTools/pkg/services"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ErrorBody is the "error" of every failed response
+type ErrorBody struct {
+	// Code is the HTTP status code of the response
+	Code int `json:"code"`
+	// Reason is the Kubernetes StatusReason of the error, e.g. NotFound or Conflict. Errors
+	// that do not come from the API server get the reason matching Code.
+	Reason    metav1.StatusReason `json:"reason"`
+	Message   string              `json:"message"`
+	RequestID string              `json:"requestId,omitempty"`
+}
+
+// writeError responds with the error envelope of err. The status is the one the API server
+// answered with, or the one of the services' error kinds, and code for any other error.
+func writeError(c *gin.Context, code int, err error) {
+	code, reason := statusOf(err, code)
+	c.AbortWithStatusJSON(code, gin.H{"error": errorBody(c, code, reason, err.Error())})
+}
+
+// badRequest responds with a 400 error envelope for an invalid parameter or body
+func badRequest(c *gin.Context, format string, args ...interface{}) {
+	writeError(c, http.StatusBadRequest, fmt.Errorf(format, args...))
+}
+
+// errorBody builds the envelope of an error, tagged with the request ID
+func errorBody(c *gin.Context, code int, reason metav1.StatusReason, message string) ErrorBody {
+	return ErrorBody{Code: code, Reason: reason, Message: message, RequestID: c.GetString(requestIDKey)}
+}
+
+// statusOf returns the HTTP status and StatusReason of err, code if err says nothing of them
+func statusOf(err error, code int) (int, metav1.StatusReason) {
+	var status apierrors.APIStatus
+	if errors.As(err, &status) && status.Status().Code != 0 {
+		code = int(status.Status().Code)
+		if reason := apierrors.ReasonForError(err); reason != metav1.StatusReasonUnknown {
+			return code, reason
+		}
+		return code, reasonForCode(code)
+	}
+
+	switch {
+	case errors.Is(err, services.ErrUnknownResource), meta.IsNoMatchError(err):
+		code = http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidListOptions),
+		errors.Is(err, services.ErrInvalidPatch),
+		errors.Is(err, services.ErrInvalidUpdate),
+		errors.Is(err, services.ErrInvalidLogOptions),
+		errors.Is(err, services.ErrInvalidEventOptions),
+		errors.Is(err, services.ErrInvalidAnalysis):
+		code = http.StatusBadRequest
+	}
+	return code, reasonForCode(code)
+}
+
+// reasonForCode returns the StatusReason the API server uses for an HTTP status
+func reasonForCode(code int) metav1.StatusReason {
+	switch code {
+	case http.StatusBadRequest:
+		return metav1.StatusReasonBadRequest
+	case http.StatusUnauthorized:
+		return metav1.StatusReasonUnauthorized
+	case http.StatusForbidden:
+		return metav1.StatusReasonForbidden
+	case http.StatusNotFound:
+		return metav1.StatusReasonNotFound
+	case http.StatusMethodNotAllowed:
+		return metav1.StatusReasonMethodNotAllowed
+	case http.StatusConflict:
+		return metav1.StatusReasonConflict
+	case http.StatusUnprocessableEntity:
+		return metav1.StatusReasonInvalid
+	case http.StatusTooManyRequests:
+		return metav1.StatusReasonTooManyRequests
+	case http.StatusServiceUnavailable:
+		return metav1.StatusReasonServiceUnavailable
+	case http.StatusGatewayTimeout:
+		return metav1.StatusReasonTimeout
+	default:
+		return metav1.StatusReasonInternalError
+	}
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/lexieqin/Geek/ginTools/pkg/services"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestStatusOf(t *testing.T) {
+	pods := schema.GroupResource{Resource: "pods"}
+
+	tests := []struct {
+		name       string
+		err        error
+		code       int
+		wantCode   int
+		wantReason metav1.StatusReason
+	}{
+		{name: "not found", err: apierrors.NewNotFound(pods, "web"), code: 500, wantCode: 404, wantReason: metav1.StatusReasonNotFound},
+		{name: "conflict", err: apierrors.NewConflict(pods, "web", errors.New("modified")), code: 400, wantCode: 409, wantReason: metav1.StatusReasonConflict},
+		{name: "forbidden", err: apierrors.NewForbidden(pods, "web", errors.New("rbac")), code: 500, wantCode: 403, wantReason: metav1.StatusReasonForbidden},
+		{name: "invalid", err: apierrors.NewInvalid(schema.GroupKind{Kind: "Pod"}, "web", nil), code: 400, wantCode: 422, wantReason: metav1.StatusReasonInvalid},
+		{name: "wrapped API error", err: fmt.Errorf("failed to get pod: %w", apierrors.NewNotFound(pods, "web")), code: 500, wantCode: 404, wantReason: metav1.StatusReasonNotFound},
+		{
+			name:       "API error without reason",
+			err:        &apierrors.StatusError{ErrStatus: metav1.Status{Status: metav1.StatusFailure, Code: http.StatusTooManyRequests}},
+			code:       500,
+			wantCode:   429,
+			wantReason: metav1.StatusReasonTooManyRequests,
+		},
+		{
+			name:       "API error without code",
+			err:        &apierrors.StatusError{ErrStatus: metav1.Status{Status: metav1.StatusFailure, Reason: metav1.StatusReasonNotFound}},
+			code:       500,
+			wantCode:   500,
+			wantReason: metav1.StatusReasonInternalError,
+		},
+		{name: "unknown resource", err: fmt.Errorf("%w: widgets", services.ErrUnknownResource), code: 500, wantCode: 404, wantReason: metav1.StatusReasonNotFound},
+		{name: "no REST mapping", err: &meta.NoKindMatchError{GroupKind: schema.GroupKind{Kind: "Widget"}}, code: 500, wantCode: 404, wantReason: metav1.StatusReasonNotFound},
+		{name: "invalid list options", err: fmt.Errorf("%w: limit", services.ErrInvalidListOptions), code: 500, wantCode: 400, wantReason: metav1.StatusReasonBadRequest},
+		{name: "invalid patch", err: fmt.Errorf("%w: empty", services.ErrInvalidPatch), code: 500, wantCode: 400, wantReason: metav1.StatusReasonBadRequest},
+		{name: "invalid update", err: services.ErrInvalidUpdate, code: 500, wantCode: 400, wantReason: metav1.StatusReasonBadRequest},
+		{name: "invalid log options", err: services.ErrInvalidLogOptions, code: 500, wantCode: 400, wantReason: metav1.StatusReasonBadRequest},
+		{name: "invalid event options", err: services.ErrInvalidEventOptions, code: 500, wantCode: 400, wantReason: metav1.StatusReasonBadRequest},
+		{name: "invalid analysis", err: services.ErrInvalidAnalysis, code: 500, wantCode: 400, wantReason: metav1.StatusReasonBadRequest},
+		{name: "other error", err: errors.New("connection refused"), code: 500, wantCode: 500, wantReason: metav1.StatusReasonInternalError},
+		{name: "other error keeps the code", err: errors.New("name is required"), code: 400, wantCode: 400, wantReason: metav1.StatusReasonBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, reason := statusOf(tt.err, tt.code)
+			if code != tt.wantCode || reason != tt.wantReason {
+				t.Errorf("statusOf() = %d %s, want %d %s", code, reason, tt.wantCode, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestReasonForCode(t *testing.T) {
+	tests := []struct {
+		code int
+		want metav1.StatusReason
+	}{
+		{http.StatusBadRequest, metav1.StatusReasonBadRequest},
+		{http.StatusUnauthorized, metav1.StatusReasonUnauthorized},
+		{http.StatusForbidden, metav1.StatusReasonForbidden},
+		{http.StatusNotFound, metav1.StatusReasonNotFound},
+		{http.StatusMethodNotAllowed, metav1.StatusReasonMethodNotAllowed},
+		{http.StatusConflict, metav1.StatusReasonConflict},
+		{http.StatusUnprocessableEntity, metav1.StatusReasonInvalid},
+		{http.StatusTooManyRequests, metav1.StatusReasonTooManyRequests},
+		{http.StatusServiceUnavailable, metav1.StatusReasonServiceUnavailable},
+		{http.StatusGatewayTimeout, metav1.StatusReasonTimeout},
+		{http.StatusInternalServerError, metav1.StatusReasonInternalError},
+		{http.StatusTeapot, metav1.StatusReasonInternalError},
+	}
+	for _, tt := range tests {
+		if got := reasonForCode(tt.code); got != tt.want {
+			t.Errorf("reasonForCode(%d) = %s, want %s", tt.code, got, tt.want)
+		}
+	}
+}
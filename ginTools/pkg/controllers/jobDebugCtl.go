@@ -22,7 +22,7 @@ func (c *JobDebugController) GetJobDebugInfo(ctx *gin.Context) {
 
 	debugInfo, err := c.service.GetJobDebugInfo(namespace, name)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -36,7 +36,7 @@ func (c *JobDebugController) GetJobByUUID(ctx *gin.Context) {
 
 	job, err := c.service.GetJobByUUID(uuid, namespace)
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		writeError(ctx, http.StatusNotFound, err)
 		return
 	}
 
@@ -50,7 +50,7 @@ func (c *JobDebugController) GetJobTraces(ctx *gin.Context) {
 
 	traces, err := c.service.GetJobTraces(namespace, name)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -64,7 +64,7 @@ func (c *JobDebugController) GetJobErrors(ctx *gin.Context) {
 
 	errors, err := c.service.GetJobErrors(namespace, name)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -78,7 +78,7 @@ func (c *JobDebugController) GetJobSandboxLogs(ctx *gin.Context) {
 
 	logs, err := c.service.GetJobSandboxLogs(namespace, name)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -92,7 +92,7 @@ func (c *JobDebugController) GetJobPods(ctx *gin.Context) {
 
 	pods, err := c.service.GetJobPods(namespace, name)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -107,13 +107,13 @@ func (c *JobDebugController) ReadSandboxLog(ctx *gin.Context) {
 	numLines := ctx.DefaultQuery("lines", "1000")
 
 	if sandboxPath == "" || logFile == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "path and file parameters are required"})
+		badRequest(ctx, "path and file parameters are required")
 		return
 	}
 
 	content, err := c.service.ReadSandboxLogFile(sandboxPath, logFile, startLine, numLines)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
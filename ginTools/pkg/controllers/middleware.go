@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the ID of a request, taken from the client if it sends one
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the gin context key of the request ID
+const requestIDKey = "requestID"
+
+// RequestID tags every request with an ID, echoed in the response header and in errors
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// Recovery turns a panicking handler into a 500 error envelope. The stack is logged by gin.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		writeError(c, http.StatusInternalServerError, fmt.Errorf("internal error: %v", recovered))
+	})
+}
+
+// newRequestID returns a random 16 hex digit ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// NoRoute answers requests to unknown routes with a 404 error envelope
+func NoRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		writeError(c, http.StatusNotFound, fmt.Errorf("no route for %s %s", c.Request.Method, c.Request.URL.Path))
+	}
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// testRouter returns a router with the middleware of the server and a few failing routes
+func testRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultErrorWriter = io.Discard
+	r := gin.New()
+	r.Use(RequestID(), Recovery())
+	r.NoRoute(NoRoute())
+	r.GET("/ok", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"data": "ok"})
+	})
+	r.GET("/missing", func(c *gin.Context) {
+		writeError(c, http.StatusBadRequest, apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "web"))
+	})
+	r.GET("/invalid", func(c *gin.Context) {
+		badRequest(c, "limit must be positive, got %d", -1)
+	})
+	r.GET("/panic", func(c *gin.Context) {
+		panic(errors.New("nil map"))
+	})
+	return r
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		requestID  string
+		wantCode   int
+		wantReason metav1.StatusReason
+		wantMsg    string
+	}{
+		{name: "success", path: "/ok", wantCode: http.StatusOK},
+		{name: "client request ID", path: "/ok", requestID: "req-42", wantCode: http.StatusOK},
+		{name: "API error", path: "/missing", requestID: "req-42", wantCode: http.StatusNotFound, wantReason: metav1.StatusReasonNotFound, wantMsg: `pods "web" not found`},
+		{name: "bad request", path: "/invalid", wantCode: http.StatusBadRequest, wantReason: metav1.StatusReasonBadRequest, wantMsg: "limit must be positive, got -1"},
+		{name: "panic", path: "/panic", wantCode: http.StatusInternalServerError, wantReason: metav1.StatusReasonInternalError, wantMsg: "internal error: nil map"},
+		{name: "unknown route", path: "/nowhere", wantCode: http.StatusNotFound, wantReason: metav1.StatusReasonNotFound, wantMsg: "no route for GET /nowhere"},
+	}
+
+	r := testRouter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.requestID != "" {
+				req.Header.Set(RequestIDHeader, tt.requestID)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			if tt.requestID != "" && id != tt.requestID {
+				t.Errorf("%s = %q, want the client's %q", RequestIDHeader, id, tt.requestID)
+			}
+			if tt.requestID == "" && len(id) != 16 {
+				t.Errorf("%s = %q, want a generated 16 digit ID", RequestIDHeader, id)
+			}
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantReason == "" {
+				return
+			}
+
+			var body struct {
+				Error ErrorBody `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("response %s is not an error envelope: %v", w.Body, err)
+			}
+			want := ErrorBody{Code: tt.wantCode, Reason: tt.wantReason, Message: tt.wantMsg, RequestID: id}
+			if body.Error != want {
+				t.Errorf("error = %+v, want %+v", body.Error, want)
+			}
+		})
+	}
+}
+
+func TestRequestIDsDiffer(t *testing.T) {
+	r := testRouter()
+	ids := map[string]bool{}
+	for i := 0; i < 10; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ok", nil))
+		ids[w.Header().Get(RequestIDHeader)] = true
+	}
+	if len(ids) != 10 {
+		t.Errorf("10 requests got %d distinct IDs", len(ids))
+	}
+}
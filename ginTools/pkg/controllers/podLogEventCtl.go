@@ -6,13 +6,14 @@ package controllers
 import (
 	"context"
 	"fmt"
-	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lexieqin/Geek/ginTools/pkg/services"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type PodLogEventCtl struct {
@@ -23,75 +24,122 @@ func NewPodLogEventCtl(service *services.PodLogEventService) *PodLogEventCtl {
 	return &PodLogEventCtl{podLogEventService: service}
 }
 
+// GetLog returns the logs of a pod, see logOptionsParam for the parameters. The lines of
+// several containers are prefixed with their container, and with their timestamp if
+// timestamps=true. With follow=true the lines are streamed as they are written, as
+// server-sent events if the client accepts text/event-stream and as chunked plain text
+// otherwise.
 func (p *PodLogEventCtl) GetLog() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ns := c.Param("namespace")
 		podName := c.Param("podName")
-		containerName := c.DefaultQuery("container", "")
-		tailLineStr := c.DefaultQuery("tail", "100")
 
-		// Convert tailLine from string to int64
-		tailLine, err := strconv.ParseInt(tailLineStr, 10, 64)
+		// Validate required parameters
+		if podName == "" {
+			badRequest(c, "podname parameter is required")
+			return
+		}
+		follow, err := strconv.ParseBool(c.DefaultQuery("follow", "false"))
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": fmt.Sprintf("Invalid tail parameter: %v", err),
-			})
+			badRequest(c, "invalid follow parameter: %v", err)
 			return
 		}
-
-		// Validate required parameters
-		if podName == "" {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "podname parameter is required",
-			})
+		timestamps, err := strconv.ParseBool(c.DefaultQuery("timestamps", "false"))
+		if err != nil {
+			badRequest(c, "invalid timestamps parameter: %v", err)
+			return
+		}
+		opts, ok := logOptionsParam(c, follow)
+		if !ok {
+			return
+		}
+		if follow {
+			p.followLog(c, ns, podName, opts, timestamps)
 			return
 		}
 
 		// Create a context with timeout
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 		defer cancel()
 
-		// Get logs with the improved service
-		req := p.podLogEventService.GetLogs(ns, podName, tailLine, containerName)
-
-		// Stream the logs
-		rc, err := req.Stream(ctx)
+		logs, err := p.podLogEventService.GetLogs(ctx, ns, podName, opts)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": fmt.Sprintf("Failed to stream logs: %v", err),
-			})
+			writeError(c, http.StatusInternalServerError, err)
 			return
 		}
-		defer rc.Close()
-
-		// Read log data with a buffer size limit to prevent memory issues
-		logData, err := io.ReadAll(rc)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": fmt.Sprintf("Failed to read logs: %v", err),
-			})
-			return
+		var text strings.Builder
+		for _, line := range logs.Lines {
+			text.WriteString(line.Format(timestamps, len(logs.Containers) > 1))
+			text.WriteByte('\n')
 		}
 
 		// If the client accepts text/plain, return as plain text
 		if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain) == gin.MIMEPlain {
-			c.String(http.StatusOK, string(logData))
+			c.Header("X-Log-Truncated", strconv.FormatBool(logs.Truncated))
+			c.String(http.StatusOK, text.String())
 			return
 		}
 
 		// Otherwise return as JSON
 		c.JSON(http.StatusOK, gin.H{
-			"data": string(logData),
+			"data": text.String(),
 			"meta": gin.H{
-				"namespace":     ns,
-				"podName":       podName,
-				"containerName": containerName,
-				"tailLines":     tailLine,
+				"namespace":     logs.Namespace,
+				"podName":       logs.Pod,
+				"containerName": opts.Container,
+				"containers":    logs.Containers,
+				"tailLines":     opts.TailLines,
+				"lines":         len(logs.Lines),
+				"truncated":     logs.Truncated,
+				"errors":        logs.Errors,
 			},
 		})
 	}
 }
 
+// followLog streams the logs of a pod until the client goes away or the containers end
+func (p *PodLogEventCtl) followLog(c *gin.Context, ns, podName string, opts services.LogOptions, timestamps bool) {
+	stream, err := p.podLogEventService.FollowLogs(c.Request.Context(), ns, podName, opts)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	sse := c.NegotiateFormat(gin.MIMEPlain, mimeEventStream) == mimeEventStream
+	if sse {
+		c.Header("Content-Type", mimeEventStream)
+		c.Header("Cache-Control", "no-cache")
+	} else {
+		c.Header("Content-Type", gin.MIMEPlain+"; charset=utf-8")
+	}
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	prefix := len(stream.Containers) > 1
+	err = stream.Run(c.Request.Context(), func(line services.PodLogLine) error {
+		if sse {
+			c.SSEvent("log", line)
+		} else if _, err := fmt.Fprintln(c.Writer, line.Format(timestamps, prefix)); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	// The status is sent already, so a failure can only be reported in the stream
+	if err != nil {
+		if sse {
+			code, reason := statusOf(err, http.StatusInternalServerError)
+			c.SSEvent("error", errorBody(c, code, reason, err.Error()))
+		} else {
+			fmt.Fprintln(c.Writer, "error:", err)
+		}
+	}
+	if sse {
+		c.SSEvent("end", gin.H{"namespace": stream.Namespace, "podName": stream.Pod})
+	}
+	c.Writer.Flush()
+}
+
 func (p *PodLogEventCtl) GetEvent() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ns := c.Param("namespace")
@@ -100,17 +148,13 @@ func (p *PodLogEventCtl) GetEvent() gin.HandlerFunc {
 		eventType := c.DefaultQuery("type", "")
 
 		if podName == "" {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "podname parameter is required",
-			})
+			badRequest(c, "podname parameter is required")
 			return
 		}
 
 		events, err := p.podLogEventService.GetEvents(ns, podName, eventType)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": fmt.Sprintf("Failed to retrieve events: %v", err),
-			})
+			writeError(c, http.StatusInternalServerError, err)
 			return
 		}
 
@@ -125,17 +169,140 @@ func (p *PodLogEventCtl) GetEvent() gin.HandlerFunc {
 	}
 }
 
-func (p *PodLogEventCtl) ListPods() gin.HandlerFunc {
+// NamespaceLogs returns the most recent log lines of the pods of a namespace that look
+// like errors. It takes the parameters of GetLog, except follow, and labelSelector to pick
+// the pods and lines to cap the lines across all pods. grep defaults to
+// services.ErrorLogPattern, an empty grep returns every line. tail defaults to 500 lines of
+// each container.
+func (p *PodLogEventCtl) NamespaceLogs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ns := c.Param("namespace")
+
+		timestamps, err := strconv.ParseBool(c.DefaultQuery("timestamps", "false"))
+		if err != nil {
+			badRequest(c, "invalid timestamps parameter: %v", err)
+			return
+		}
+		logOpts, ok := logOptionsParam(c, false)
+		if !ok {
+			return
+		}
+		if _, ok := c.GetQuery("grep"); !ok {
+			logOpts.Grep = services.ErrorLogPattern
+		}
+		if c.Query("tail") == "" && logOpts.TailLines >= 0 {
+			logOpts.TailLines = 500
+		}
+		opts := services.NamespaceLogOptions{
+			LogOptions:    logOpts,
+			LabelSelector: c.Query("labelSelector"),
+			Lines:         100,
+		}
+		if lines := c.Query("lines"); lines != "" {
+			if opts.Lines, err = strconv.Atoi(lines); err != nil || opts.Lines <= 0 {
+				badRequest(c, "invalid lines parameter: must be a positive integer")
+				return
+			}
+		}
+
+		// Create a context with timeout
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+		defer cancel()
+
+		logs, err := p.podLogEventService.GetNamespaceLogs(ctx, ns, opts)
+		if err != nil {
+			writeError(c, http.StatusInternalServerError, err)
+			return
+		}
+		var text strings.Builder
+		for _, line := range logs.Lines {
+			text.WriteString(line.Format(timestamps, true))
+			text.WriteByte('\n')
+		}
+
+		// If the client accepts text/plain, return as plain text
+		if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain) == gin.MIMEPlain {
+			c.Header("X-Log-Truncated", strconv.FormatBool(logs.Truncated))
+			c.String(http.StatusOK, text.String())
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data": text.String(),
+			"meta": gin.H{
+				"namespace":     ns,
+				"labelSelector": opts.LabelSelector,
+				"grep":          opts.Grep,
+				"pods":          logs.Pods,
+				"skippedPods":   logs.SkippedPods,
+				"lines":         len(logs.Lines),
+				"truncated":     logs.Truncated,
+				"errors":        logs.Errors,
+			},
+		})
+	}
+}
+
+// NamespaceEvents returns the events of every object in a namespace, ordered by the time
+// they were last seen. type defaults to Warning, type=all returns every type. sinceSeconds
+// keeps the events seen since then and limit, 100 by default, the most recent ones.
+func (p *PodLogEventCtl) NamespaceEvents() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ns := c.Param("namespace")
-		podList, err := p.podLogEventService.ListPods(ns)
+		eventType := c.DefaultQuery("type", "Warning")
+		if strings.EqualFold(eventType, "all") {
+			eventType = ""
+		}
+
+		var since time.Duration
+		if s := c.Query("sinceSeconds"); s != "" {
+			seconds, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || seconds <= 0 {
+				badRequest(c, "invalid sinceSeconds parameter: must be a positive integer")
+				return
+			}
+			since = time.Duration(seconds) * time.Second
+		}
+		limit := 100
+		if l := c.Query("limit"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil || n <= 0 {
+				badRequest(c, "invalid limit parameter: must be a positive integer")
+				return
+			}
+			limit = n
+		}
+
+		timeline, err := p.podLogEventService.ListNamespaceEvents(ns, eventType, since, limit)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": err.Error(),
-			})
+			writeError(c, http.StatusInternalServerError, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data": timeline.Events,
+			"meta": gin.H{
+				"namespace": ns,
+				"type":      eventType,
+				"count":     len(timeline.Events),
+				"truncated": timeline.Truncated,
+			},
+		})
+	}
+}
+
+func (p *PodLogEventCtl) ListPods() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ns := c.Param("namespace")
+		opts, ok := listOptionsParam(c)
+		if !ok {
 			return
 		}
-		c.JSON(200, gin.H{"data": podList})
+		result, err := p.podLogEventService.ListPods(ns, opts)
+		if !writeListError(c, err) {
+			return
+		}
+		c.JSON(200, gin.H{"data": result.Items, "meta": result.Meta})
 	}
 }
 
@@ -146,11 +313,67 @@ func (p *PodLogEventCtl) GetPod() gin.HandlerFunc {
 
 		pod, err := p.podLogEventService.GetPod(ns, podName)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": err.Error(),
-			})
+			writeError(c, http.StatusInternalServerError, err)
 			return
 		}
 		c.JSON(200, gin.H{"data": pod})
 	}
 }
+
+// mimeEventStream is the content type of server-sent events
+const mimeEventStream = "text/event-stream"
+
+// logOptionsParam reads the log parameters container, allContainers, previous, tail
+// (-1 for all lines), sinceSeconds, sinceTime (RFC 3339), grep (a regular expression)
+// and limitBytes. tail defaults to 100 unless a since parameter is given. limitBytes
+// defaults to services.DefaultLogLimitBytes unless following. An invalid value is
+// answered with 400 and ok is false.
+func logOptionsParam(c *gin.Context, follow bool) (opts services.LogOptions, ok bool) {
+	opts = services.LogOptions{
+		Container: c.Query("container"),
+		Grep:      c.Query("grep"),
+		TailLines: 100,
+	}
+	if !follow {
+		opts.LimitBytes = services.DefaultLogLimitBytes
+	}
+
+	var err error
+	if opts.AllContainers, err = strconv.ParseBool(c.DefaultQuery("allContainers", "false")); err != nil {
+		badRequest(c, "invalid allContainers parameter: %v", err)
+		return opts, false
+	}
+	if opts.Previous, err = strconv.ParseBool(c.DefaultQuery("previous", "false")); err != nil {
+		badRequest(c, "invalid previous parameter: %v", err)
+		return opts, false
+	}
+	if since := c.Query("sinceSeconds"); since != "" {
+		if opts.SinceSeconds, err = strconv.ParseInt(since, 10, 64); err != nil || opts.SinceSeconds <= 0 {
+			badRequest(c, "invalid sinceSeconds parameter: must be a positive integer")
+			return opts, false
+		}
+		opts.TailLines = -1
+	}
+	if since := c.Query("sinceTime"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			badRequest(c, "invalid sinceTime parameter: %v", err)
+			return opts, false
+		}
+		opts.SinceTime = &metav1.Time{Time: t}
+		opts.TailLines = -1
+	}
+	if tail := c.Query("tail"); tail != "" {
+		if opts.TailLines, err = strconv.ParseInt(tail, 10, 64); err != nil || opts.TailLines < -1 {
+			badRequest(c, "invalid tail parameter: must be a non-negative integer or -1")
+			return opts, false
+		}
+	}
+	if limit := c.Query("limitBytes"); limit != "" {
+		if opts.LimitBytes, err = strconv.ParseInt(limit, 10, 64); err != nil || opts.LimitBytes <= 0 {
+			badRequest(c, "invalid limitBytes parameter: must be a positive integer")
+			return opts, false
+		}
+	}
+	return opts, true
+}
@@ -1,7 +1,12 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lexieqin/Geek/ginTools/pkg/services"
@@ -15,23 +20,40 @@ func NewResourceCtl(service *services.ResourceService) *ResourceCtl {
 	return &ResourceCtl{resourceService: service}
 }
 
+// List lists resources of a type in ns, or in all namespaces if ns is empty or *. See
+// listOptionsParam for the list parameters.
 func (r *ResourceCtl) List() func(c *gin.Context) {
 	return func(c *gin.Context) {
-		var resource = c.Param("resource")
-		ns := c.DefaultQuery("ns", "default")
-		resourceList, _ := r.resourceService.ListResource(resource, ns)
-		c.JSON(200, gin.H{"data": resourceList})
+		r.list(c, c.Param("resource"))
 	}
 }
 
+// list writes the page of resource selected by the ns and list option parameters
+func (r *ResourceCtl) list(c *gin.Context, resource string) {
+	ns := c.Query("ns")
+	opts, ok := listOptionsParam(c)
+	if !ok {
+		return
+	}
+	result, err := r.resourceService.ListResource(resource, ns, opts)
+	if !writeListError(c, err) {
+		return
+	}
+	c.JSON(200, gin.H{"data": result.Items, "meta": result.Meta})
+}
+
 func (r *ResourceCtl) Delete() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		var resource = c.Param("resource")
 		ns := c.DefaultQuery("ns", "default")
 		name := c.Query("name")
+		if name == "" {
+			badRequest(c, "name parameter is required")
+			return
+		}
 		err := r.resourceService.DeleteResource(resource, ns, name)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Delete failed: " + err.Error()})
+			writeError(c, 500, err)
 			return
 		} else {
 			c.JSON(200, gin.H{"data": "Delete successful"})
@@ -39,10 +61,12 @@ func (r *ResourceCtl) Delete() func(c *gin.Context) {
 	}
 }
 
+// Create creates every object of the YAML manifest in the body. The objects' own kinds
+// decide where they are created; ns is the namespace of objects that name none.
 func (r *ResourceCtl) Create() func(c *gin.Context) {
 	fmt.Println("create")
 	return func(c *gin.Context) {
-		var resource = c.Param("resource")
+		ns := c.DefaultQuery("ns", "default")
 
 		type ResouceParam struct {
 			Yaml string `json:"yaml"`
@@ -50,18 +74,70 @@ func (r *ResourceCtl) Create() func(c *gin.Context) {
 
 		var param ResouceParam
 		if err := c.ShouldBindJSON(&param); err != nil {
-			c.JSON(400, gin.H{"error": "Failed to parse request body: " + err.Error()})
+			badRequest(c, "failed to parse request body: %v", err)
 			return
 		}
 
-		err := r.resourceService.CreateResource(resource, param.Yaml)
+		dryRun, ok := dryRunParam(c)
+		if !ok {
+			return
+		}
+		result, err := r.resourceService.CreateResource(ns, param.Yaml, dryRun)
 		if err != nil {
-			c.JSON(400, gin.H{"error": "Creation failed: " + err.Error()})
+			writeError(c, 400, err)
+			return
+		}
+		writeManifestResult(c, "Creation", result)
+	}
+}
+
+// Apply applies the YAML manifest in the body with server-side apply
+func (r *ResourceCtl) Apply() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ns := c.DefaultQuery("ns", "default")
+
+		var param struct {
+			Yaml string `json:"yaml"`
+		}
+		if err := c.ShouldBindJSON(&param); err != nil {
+			badRequest(c, "failed to parse request body: %v", err)
+			return
+		}
+
+		dryRun, ok := dryRunParam(c)
+		if !ok {
+			return
+		}
+		force, err := strconv.ParseBool(c.DefaultQuery("force", "false"))
+		if err != nil {
+			badRequest(c, "invalid force parameter: %v", err)
+			return
+		}
+
+		result, err := r.resourceService.ApplyManifest(ns, param.Yaml, services.ApplyOptions{
+			FieldManager: c.Query("fieldManager"),
+			Force:        force,
+			DryRun:       dryRun,
+		})
+		if err != nil {
+			writeError(c, 400, err)
 			return
-		} else {
-			c.JSON(200, gin.H{"data": "Creation successful"})
 		}
+		writeManifestResult(c, "Apply", result)
+	}
+}
+
+// writeManifestResult answers with the per-object results, as an error if any object failed
+func writeManifestResult(c *gin.Context, operation string, result *services.ManifestResult) {
+	if result.Failed > 0 {
+		message := fmt.Sprintf("%s failed for %d of %d objects", operation, result.Failed, len(result.Results))
+		c.JSON(400, gin.H{
+			"error": errorBody(c, 400, reasonForCode(400), message),
+			"data":  result,
+		})
+		return
 	}
+	c.JSON(200, gin.H{"data": result})
 }
 
 func (r *ResourceCtl) GetGVR() func(c *gin.Context) {
@@ -70,7 +146,7 @@ func (r *ResourceCtl) GetGVR() func(c *gin.Context) {
 
 		gvr, err := r.resourceService.GetGVR(resource)
 		if err != nil {
-			c.JSON(400, gin.H{"error": "Resource error: " + err.Error()})
+			writeError(c, 400, err)
 			return
 		} else {
 			c.JSON(200, gin.H{"data": *gvr})
@@ -78,13 +154,29 @@ func (r *ResourceCtl) GetGVR() func(c *gin.Context) {
 	}
 }
 
+// APIResources lists the resource types the cluster serves, including those of CRDs
+func (r *ResourceCtl) APIResources() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := r.resourceService.ListAPIResources()
+		if err != nil {
+			writeError(c, 500, err)
+			return
+		}
+		c.JSON(200, gin.H{"data": list.Resources, "meta": gin.H{"failedGroups": list.FailedGroups}})
+	}
+}
+
 func (r *ResourceCtl) GetResource() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var resource = c.Query("resource")
+		opts, ok := listOptionsParam(c)
+		if !ok {
+			return
+		}
 
-		resourceList, err := r.resourceService.GetResource(resource)
+		resourceList, err := r.resourceService.GetResource(resource, c.Query("namespace"), opts)
 		if err != nil {
-			c.JSON(400, gin.H{"error": "Resource error: " + err.Error()})
+			writeError(c, 400, err)
 			return
 		}
 		c.JSON(200, gin.H{"data": resourceList})
@@ -95,61 +187,128 @@ func (r *ResourceCtl) GetResourceByType() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var resource = c.Query("resource")
 		var resourceType = c.Query("type")
+		opts, ok := listOptionsParam(c)
+		if !ok {
+			return
+		}
 
-		resourceList, err := r.resourceService.GetResourceByType(resource, resourceType)
+		resourceList, err := r.resourceService.GetResourceByType(resource, c.Query("namespace"), resourceType, opts)
 		if err != nil {
-			c.JSON(400, gin.H{"error": "Resource error: " + err.Error()})
+			writeError(c, 400, err)
 			return
 		}
 		c.JSON(200, gin.H{"data": resourceList})
 	}
 }
 
-// Update updates an existing resource
+// Update merges the YAML in the body into the live object named by the path. With
+// resourceVersion the update only succeeds if the object is still at that version. The
+// response holds the new resourceVersion.
 func (r *ResourceCtl) Update() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var resource = c.Param("resource")
 		ns := c.DefaultQuery("ns", "default")
 		name := c.Query("name")
 		if name == "" {
-			c.JSON(400, gin.H{"error": "name parameter is required"})
+			badRequest(c, "name parameter is required")
 			return
 		}
 		var yaml string
 		if err := c.ShouldBindJSON(&gin.H{"yaml": &yaml}); err != nil {
-			c.JSON(400, gin.H{"error": "Failed to parse request body: " + err.Error()})
+			badRequest(c, "failed to parse request body: %v", err)
+			return
+		}
+		dryRun, ok := dryRunParam(c)
+		if !ok {
 			return
 		}
-		err := r.resourceService.UpdateResource(resource, ns, name, yaml)
+		result, err := r.resourceService.UpdateResource(resource, ns, name, yaml, services.UpdateOptions{
+			ResourceVersion: c.Query("resourceVersion"),
+			DryRun:          dryRun,
+		})
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Update failed: " + err.Error()})
+			writeError(c, 500, err)
+			return
+		}
+		if dryRun {
+			c.JSON(200, gin.H{"data": result.DryRun})
 			return
 		}
-		c.JSON(200, gin.H{"data": "Update successful"})
+		c.JSON(200, gin.H{"data": gin.H{
+			"name":            result.Object.GetName(),
+			"namespace":       result.Object.GetNamespace(),
+			"resourceVersion": result.Object.GetResourceVersion(),
+		}})
 	}
 }
 
-// Patch patches a resource
+// Patch patches a resource. patchType selects json (RFC 6902, the default), merge,
+// strategic or apply; the body's patch is either a string or the patch itself. The
+// response holds the patched object.
 func (r *ResourceCtl) Patch() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var resource = c.Param("resource")
 		ns := c.DefaultQuery("ns", "default")
 		name := c.Query("name")
 		if name == "" {
-			c.JSON(400, gin.H{"error": "name parameter is required"})
+			badRequest(c, "name parameter is required")
 			return
 		}
-		var patch string
-		if err := c.ShouldBindJSON(&gin.H{"patch": &patch}); err != nil {
-			c.JSON(400, gin.H{"error": "Failed to parse request body: " + err.Error()})
+		var body struct {
+			Patch json.RawMessage `json:"patch"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			badRequest(c, "failed to parse request body: %v", err)
 			return
 		}
-		err := r.resourceService.PatchResource(resource, ns, name, patch)
+		patch := string(body.Patch)
+		if err := json.Unmarshal(body.Patch, &patch); err != nil {
+			// Not a string: the patch was sent as JSON
+			patch = string(body.Patch)
+		}
+		dryRun, ok := dryRunParam(c)
+		if !ok {
+			return
+		}
+		force, err := strconv.ParseBool(c.DefaultQuery("force", "false"))
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Patch failed: " + err.Error()})
+			badRequest(c, "invalid force parameter: %v", err)
 			return
 		}
-		c.JSON(200, gin.H{"data": "Patch successful"})
+		result, err := r.resourceService.PatchResource(resource, ns, name, patch, services.PatchOptions{
+			Type:         c.Query("patchType"),
+			FieldManager: c.Query("fieldManager"),
+			Force:        force,
+			DryRun:       dryRun,
+		})
+		if err != nil {
+			writeError(c, 500, err)
+			return
+		}
+		if dryRun {
+			c.JSON(200, gin.H{"data": result.DryRun})
+			return
+		}
+		c.JSON(200, gin.H{"data": result.Object.Object})
+	}
+}
+
+// GetObject returns the named object
+func (r *ResourceCtl) GetObject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var resource = c.Param("resource")
+		ns := c.DefaultQuery("ns", "default")
+		name := c.Query("name")
+		if name == "" {
+			badRequest(c, "name parameter is required")
+			return
+		}
+		obj, err := r.resourceService.GetObject(resource, ns, name)
+		if err != nil {
+			writeError(c, 500, err)
+			return
+		}
+		c.JSON(200, gin.H{"data": obj.Object})
 	}
 }
 
@@ -160,14 +319,202 @@ func (r *ResourceCtl) GetStatus() gin.HandlerFunc {
 		ns := c.DefaultQuery("ns", "default")
 		name := c.Query("name")
 		if name == "" {
-			c.JSON(400, gin.H{"error": "name parameter is required"})
+			badRequest(c, "name parameter is required")
 			return
 		}
 		status, err := r.resourceService.GetResourceStatus(resource, ns, name)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to get status: " + err.Error()})
+			writeError(c, 500, err)
 			return
 		}
 		c.JSON(200, gin.H{"data": status})
 	}
 }
+
+// Events returns the events of objects selected by kind and name, in namespace or in all
+// namespaces, ordered by the time they were last seen. type filters by event type, since
+// (a duration such as 30m, or seconds) keeps the recent events and limit, 200 by default,
+// the most recent ones. ownerChain=true adds the events of the objects the named object
+// owns, e.g. the ReplicaSets and Pods of a Deployment. Without kind, name and ownerChain
+// the request lists event objects like any other resource.
+func (r *ResourceCtl) Events() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Query("kind") == "" && c.Query("name") == "" && c.Query("ownerChain") == "" {
+			r.list(c, "events")
+			return
+		}
+		opts := services.EventOptions{
+			Kind:  c.Query("kind"),
+			Name:  c.Query("name"),
+			Type:  c.Query("type"),
+			Limit: 200,
+		}
+		var err error
+		if opts.OwnerChain, err = strconv.ParseBool(c.DefaultQuery("ownerChain", "false")); err != nil {
+			badRequest(c, "invalid ownerChain parameter: %v", err)
+			return
+		}
+		if since := c.Query("since"); since != "" {
+			if opts.Since, err = parseSince(since); err != nil {
+				badRequest(c, "invalid since parameter: %v", err)
+				return
+			}
+		}
+		if limit := c.Query("limit"); limit != "" {
+			if opts.Limit, err = strconv.Atoi(limit); err != nil || opts.Limit <= 0 {
+				badRequest(c, "invalid limit parameter: must be a positive integer")
+				return
+			}
+		}
+
+		timeline, err := r.resourceService.ListEvents(c.Query("namespace"), opts)
+		if err != nil {
+			writeError(c, 500, err)
+			return
+		}
+		c.JSON(200, gin.H{
+			"data": timeline.Events,
+			"meta": gin.H{
+				"objects":   timeline.Objects,
+				"count":     len(timeline.Events),
+				"truncated": timeline.Truncated,
+			},
+		})
+	}
+}
+
+// Graph returns the objects related to the object of kind and name: its owners and owned
+// objects, the Services selecting it and the Ingresses routing to those, the ConfigMaps,
+// Secrets and PVCs its pods reference and the autoscalers scaling it. depth, 2 by default,
+// is how many relations are followed. The graph is JSON nodes and edges, or a compact text
+// with format=text or if the client accepts text/plain.
+func (r *ResourceCtl) Graph() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kind := c.Query("kind")
+		name := c.Query("name")
+		if kind == "" || name == "" {
+			badRequest(c, "kind and name parameters are required")
+			return
+		}
+		depth := services.DefaultGraphDepth
+		if d := c.Query("depth"); d != "" {
+			n, err := strconv.Atoi(d)
+			if err != nil || n <= 0 || n > services.MaxGraphDepth {
+				badRequest(c, "invalid depth parameter: must be between 1 and %d", services.MaxGraphDepth)
+				return
+			}
+			depth = n
+		}
+
+		graph, err := r.resourceService.GetGraph(kind, c.Query("namespace"), name, depth)
+		if err != nil {
+			writeError(c, 500, err)
+			return
+		}
+		if c.Query("format") == "text" || c.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain) == gin.MIMEPlain {
+			c.String(200, graph.Text())
+			return
+		}
+		c.JSON(200, gin.H{"data": graph})
+	}
+}
+
+// Analyze runs the analyzers over the objects of namespace, or of all namespaces if it is
+// empty or *, and returns their findings, the most severe first. analyzers, a comma
+// separated list of names, selects the analyzers to run, all by default, and severity drops
+// the findings less severe than it. The findings are JSON, or a compact text with
+// format=text or if the client accepts text/plain.
+func (r *ResourceCtl) Analyze() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var opts services.AnalysisOptions
+		for _, name := range strings.Split(c.Query("analyzers"), ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				opts.Analyzers = append(opts.Analyzers, name)
+			}
+		}
+		if s := c.Query("severity"); s != "" {
+			severity, err := services.ParseSeverity(s)
+			if err != nil {
+				writeError(c, 400, err)
+				return
+			}
+			opts.MinSeverity = severity
+		}
+
+		report, err := r.resourceService.Analyze(c.Query("namespace"), opts)
+		if err != nil {
+			writeError(c, 500, err)
+			return
+		}
+		if c.Query("format") == "text" || c.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain) == gin.MIMEPlain {
+			c.String(200, report.Text())
+			return
+		}
+		c.JSON(200, gin.H{"data": report})
+	}
+}
+
+// parseSince reads a positive duration, either as a Go duration or as seconds
+func parseSince(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, serr := strconv.ParseInt(value, 10, 64)
+		if serr != nil {
+			return 0, err
+		}
+		d = time.Duration(seconds) * time.Second
+	}
+	if d <= 0 {
+		return 0, errors.New("must be positive")
+	}
+	return d, nil
+}
+
+// listOptionsParam reads the list parameters labelSelector, fieldSelector, limit,
+// continue, sortBy and fields (comma-separated). A malformed limit is answered with 400.
+func listOptionsParam(c *gin.Context) (services.ListOptions, bool) {
+	opts := services.ListOptions{
+		LabelSelector: c.Query("labelSelector"),
+		FieldSelector: c.Query("fieldSelector"),
+		Continue:      c.Query("continue"),
+		SortBy:        c.Query("sortBy"),
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || n < 0 {
+			badRequest(c, "invalid limit parameter: must be a non-negative integer")
+			return opts, false
+		}
+		opts.Limit = n
+	}
+	for _, field := range strings.Split(c.Query("fields"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			opts.Fields = append(opts.Fields, field)
+		}
+	}
+	return opts, true
+}
+
+// writeListError answers a failed list, with 400 for invalid list parameters. It
+// returns true if err is nil and nothing was written.
+func writeListError(c *gin.Context, err error) bool {
+	if err == nil {
+		return true
+	}
+	writeError(c, 500, err)
+	return false
+}
+
+// dryRunParam reads the dryRun query parameter, "true" or "All" as kubectl spells it.
+// An invalid value is answered with 400 and ok is false.
+func dryRunParam(c *gin.Context) (dryRun bool, ok bool) {
+	value := c.Query("dryRun")
+	switch strings.ToLower(value) {
+	case "", "false", "none":
+		return false, true
+	case "true", "all", "server":
+		return true, true
+	}
+	badRequest(c, "invalid dryRun %q: use true or All", value)
+	return false, false
+}
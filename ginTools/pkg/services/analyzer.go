@@ -0,0 +1,341 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ErrInvalidAnalysis is wrapped by the errors of analysis parameters that do not parse
+var ErrInvalidAnalysis = errors.New("invalid analysis options")
+
+// Severity ranks findings
+type Severity string
+
+const (
+	// SeverityCritical is a workload that does not run or serve
+	SeverityCritical Severity = "critical"
+	// SeverityWarning is a degraded or stuck object
+	SeverityWarning Severity = "warning"
+	// SeverityInfo is worth knowing but needs no action
+	SeverityInfo Severity = "info"
+)
+
+// rank orders severities, the most severe first
+func (s Severity) rank() int {
+	switch s {
+	case SeverityCritical:
+		return 0
+	case SeverityWarning:
+		return 1
+	}
+	return 2
+}
+
+// ParseSeverity reads a severity, failing for unknown ones
+func ParseSeverity(s string) (Severity, error) {
+	switch severity := Severity(strings.ToLower(s)); severity {
+	case SeverityCritical, SeverityWarning, SeverityInfo:
+		return severity, nil
+	}
+	return "", fmt.Errorf("%w: unknown severity %q, want critical, warning or info", ErrInvalidAnalysis, s)
+}
+
+// Finding is a problem an analyzer found
+type Finding struct {
+	// Analyzer is the name of the analyzer that found the problem
+	Analyzer string    `json:"analyzer"`
+	Severity Severity  `json:"severity"`
+	Object   ObjectRef `json:"object"`
+	// Container is the container of a pod the problem is about, if any
+	Container string `json:"container,omitempty"`
+	// Reason is a short CamelCase cause, e.g. CrashLoopBackOff
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+	// Hint suggests what to look at next
+	Hint string `json:"hint,omitempty"`
+}
+
+// Analyzer inspects the objects of a scope for one kind of problem
+type Analyzer interface {
+	// Name identifies the analyzer in findings and in the analyzers parameter
+	Name() string
+	// Analyze returns the problems found in scope
+	Analyze(scope *AnalysisScope) ([]Finding, error)
+}
+
+// AnalyzerFunc makes an Analyzer of a function
+func AnalyzerFunc(name string, analyze func(scope *AnalysisScope) ([]Finding, error)) Analyzer {
+	return analyzerFunc{name: name, analyze: analyze}
+}
+
+type analyzerFunc struct {
+	name    string
+	analyze func(scope *AnalysisScope) ([]Finding, error)
+}
+
+func (a analyzerFunc) Name() string { return a.name }
+
+func (a analyzerFunc) Analyze(scope *AnalysisScope) ([]Finding, error) { return a.analyze(scope) }
+
+// AnalysisScope gives analyzers the objects of the namespace analyzed, read from the
+// informer cache once and shared by all analyzers of an analysis
+type AnalysisScope struct {
+	// Namespace is the namespace analyzed, empty for all namespaces
+	Namespace string
+	// Now is the time of the analysis, against which ages are measured
+	Now time.Time
+
+	lister *kindLister
+	typed  map[schema.GroupKind]interface{}
+}
+
+// scopeObjects returns the objects of a kind in the scope converted to T
+func scopeObjects[T any](s *AnalysisScope, kind schema.GroupKind) ([]*T, error) {
+	if cached, ok := s.typed[kind]; ok {
+		return cached.([]*T), nil
+	}
+	objs, err := s.lister.list(kind, s.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	typed := make([]*T, 0, len(objs))
+	for _, obj := range objs {
+		t := new(T)
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, t); err != nil {
+			return nil, fmt.Errorf("failed to convert %s %s: %w", strings.ToLower(kind.Kind), obj.GetName(), err)
+		}
+		typed = append(typed, t)
+	}
+	s.typed[kind] = typed
+	return typed, nil
+}
+
+// Pods returns the pods of the scope
+func (s *AnalysisScope) Pods() ([]*v1.Pod, error) {
+	return scopeObjects[v1.Pod](s, podKind)
+}
+
+// Services returns the Services of the scope
+func (s *AnalysisScope) Services() ([]*v1.Service, error) {
+	return scopeObjects[v1.Service](s, serviceKind)
+}
+
+// EndpointSlices returns the EndpointSlices of the scope
+func (s *AnalysisScope) EndpointSlices() ([]*discoveryv1.EndpointSlice, error) {
+	return scopeObjects[discoveryv1.EndpointSlice](s, schema.GroupKind{Group: "discovery.k8s.io", Kind: "EndpointSlice"})
+}
+
+// PersistentVolumeClaims returns the PVCs of the scope
+func (s *AnalysisScope) PersistentVolumeClaims() ([]*v1.PersistentVolumeClaim, error) {
+	return scopeObjects[v1.PersistentVolumeClaim](s, pvcKind)
+}
+
+// Deployments returns the Deployments of the scope
+func (s *AnalysisScope) Deployments() ([]*appsv1.Deployment, error) {
+	return scopeObjects[appsv1.Deployment](s, schema.GroupKind{Group: "apps", Kind: "Deployment"})
+}
+
+// Jobs returns the Jobs of the scope
+func (s *AnalysisScope) Jobs() ([]*batchv1.Job, error) {
+	return scopeObjects[batchv1.Job](s, schema.GroupKind{Group: "batch", Kind: "Job"})
+}
+
+// Events returns the core events of the scope
+func (s *AnalysisScope) Events() ([]*v1.Event, error) {
+	return scopeObjects[v1.Event](s, schema.GroupKind{Kind: "Event"})
+}
+
+// LatestEvent returns the most recent event of one of the reasons about an object, nil if
+// there is none
+func (s *AnalysisScope) LatestEvent(ref ObjectRef, reasons ...string) (*v1.Event, error) {
+	events, err := s.Events()
+	if err != nil {
+		return nil, err
+	}
+	var latest *v1.Event
+	for _, event := range events {
+		involved := event.InvolvedObject
+		if involved.Kind != ref.Kind || involved.Namespace != ref.Namespace || involved.Name != ref.Name {
+			continue
+		}
+		if len(reasons) > 0 && !containsString(reasons, event.Reason) {
+			continue
+		}
+		if latest == nil || lastSeen(event).After(lastSeen(latest)) {
+			latest = event
+		}
+	}
+	return latest, nil
+}
+
+// lastSeen returns the last time a core event occurred. Its timestamps are those of the
+// deprecated fields of events.k8s.io/v1, so convertEvent reads them.
+func lastSeen(event *v1.Event) time.Time {
+	converted := eventsv1.Event{
+		ObjectMeta:               event.ObjectMeta,
+		EventTime:                event.EventTime,
+		DeprecatedFirstTimestamp: event.FirstTimestamp,
+		DeprecatedLastTimestamp:  event.LastTimestamp,
+	}
+	if event.Series != nil {
+		converted.Series = &eventsv1.EventSeries{Count: event.Series.Count, LastObservedTime: event.Series.LastObservedTime}
+	}
+	return convertEvent(&converted).LastSeen
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// AnalysisOptions selects the analyzers and findings of an analysis
+type AnalysisOptions struct {
+	// Analyzers are the names of the analyzers to run, all if empty
+	Analyzers []string
+	// MinSeverity drops the findings less severe, none if empty
+	MinSeverity Severity
+}
+
+// AnalysisReport is the result of an analysis
+type AnalysisReport struct {
+	Namespace string    `json:"namespace"`
+	Findings  []Finding `json:"findings"`
+	// Summary counts the findings by severity
+	Summary map[Severity]int `json:"summary"`
+	// Analyzers are the analyzers that ran
+	Analyzers []string `json:"analyzers"`
+	// Errors holds the analyzers that failed, when others did not
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// AddAnalyzer adds an analyzer to the built-in ones, replacing any of the same name
+func (r *ResourceService) AddAnalyzer(a Analyzer) {
+	r.analyzers = append(r.analyzers, a)
+}
+
+// Analyzers returns the analyzers Analyze runs by default
+func (r *ResourceService) Analyzers() []Analyzer {
+	byName := make(map[string]int)
+	var analyzers []Analyzer
+	for _, a := range append(DefaultAnalyzers(), r.analyzers...) {
+		if i, ok := byName[a.Name()]; ok {
+			analyzers[i] = a
+			continue
+		}
+		byName[a.Name()] = len(analyzers)
+		analyzers = append(analyzers, a)
+	}
+	return analyzers
+}
+
+// Analyze runs the analyzers selected by opts over the objects in ns, or in all namespaces
+// if ns is empty or AllNamespaces, and returns their findings, the most severe first
+func (r *ResourceService) Analyze(ns string, opts AnalysisOptions) (*AnalysisReport, error) {
+	analyzers := r.Analyzers()
+	if len(opts.Analyzers) > 0 {
+		byName := make(map[string]Analyzer, len(analyzers))
+		var names []string
+		for _, a := range analyzers {
+			byName[a.Name()] = a
+			names = append(names, a.Name())
+		}
+		analyzers = nil
+		for _, name := range opts.Analyzers {
+			a, ok := byName[name]
+			if !ok {
+				return nil, fmt.Errorf("%w: unknown analyzer %q, want one of %s", ErrInvalidAnalysis, name, strings.Join(names, ", "))
+			}
+			analyzers = append(analyzers, a)
+		}
+	}
+
+	scope := &AnalysisScope{
+		Namespace: listNamespace(ns),
+		Now:       time.Now(),
+		lister:    newKindLister(r),
+		typed:     make(map[schema.GroupKind]interface{}),
+	}
+	report := &AnalysisReport{
+		Namespace: ns,
+		Findings:  []Finding{},
+		Summary:   map[Severity]int{SeverityCritical: 0, SeverityWarning: 0, SeverityInfo: 0},
+	}
+	var lastErr error
+	for _, a := range analyzers {
+		report.Analyzers = append(report.Analyzers, a.Name())
+		findings, err := a.Analyze(scope)
+		if err != nil {
+			if report.Errors == nil {
+				report.Errors = make(map[string]string)
+			}
+			report.Errors[a.Name()] = err.Error()
+			lastErr = err
+			continue
+		}
+		for _, f := range findings {
+			if opts.MinSeverity != "" && f.Severity.rank() > opts.MinSeverity.rank() {
+				continue
+			}
+			f.Analyzer = a.Name()
+			report.Findings = append(report.Findings, f)
+			report.Summary[f.Severity]++
+		}
+	}
+	if len(analyzers) > 0 && len(report.Errors) == len(analyzers) {
+		return nil, fmt.Errorf("all analyzers failed: %w", lastErr)
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		fi, fj := report.Findings[i], report.Findings[j]
+		if fi.Severity.rank() != fj.Severity.rank() {
+			return fi.Severity.rank() < fj.Severity.rank()
+		}
+		return fi.Object.String() < fj.Object.String()
+	})
+	return report, nil
+}
+
+// Text renders the report compactly, one finding per line
+func (a *AnalysisReport) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d critical, %d warning, %d info findings\n",
+		a.Summary[SeverityCritical], a.Summary[SeverityWarning], a.Summary[SeverityInfo])
+	for _, f := range a.Findings {
+		object := f.Object.Kind + " " + f.Object.Name
+		if f.Object.Namespace != "" {
+			object = f.Object.Kind + " " + f.Object.Namespace + "/" + f.Object.Name
+		}
+		if f.Container != "" {
+			object += " container " + f.Container
+		}
+		fmt.Fprintf(&b, "%s %s %s: %s", strings.ToUpper(string(f.Severity)), object, f.Reason, f.Message)
+		if f.Hint != "" {
+			b.WriteString(" Hint: " + f.Hint)
+		}
+		b.WriteByte('\n')
+	}
+	names := make([]string, 0, len(a.Errors))
+	for name := range a.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "analyzer %s failed: %s\n", name, a.Errors[name])
+	}
+	return b.String()
+}
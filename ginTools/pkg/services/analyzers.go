@@ -0,0 +1,423 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// analysisGracePeriod is how long an object may be in a transient state, e.g. a pod not
+// ready or a PVC pending, before it is reported
+const analysisGracePeriod = time.Minute
+
+// probeEventWindow is how recent an Unhealthy event must be to report a failing probe
+const probeEventWindow = time.Hour
+
+// imagePullReasons are the waiting reasons of containers whose image cannot be pulled
+var imagePullReasons = []string{"ImagePullBackOff", "ErrImagePull", "InvalidImageName", "ErrImageNeverPull"}
+
+// DefaultAnalyzers returns the built-in analyzers
+func DefaultAnalyzers() []Analyzer {
+	return []Analyzer{
+		AnalyzerFunc("crashloop", analyzeCrashLoops),
+		AnalyzerFunc("image-pull", analyzeImagePulls),
+		AnalyzerFunc("unschedulable", analyzeUnschedulable),
+		AnalyzerFunc("oom-killed", analyzeOOMKilled),
+		AnalyzerFunc("probes", analyzeProbes),
+		AnalyzerFunc("service-endpoints", analyzeServiceEndpoints),
+		AnalyzerFunc("pvc-pending", analyzePendingClaims),
+		AnalyzerFunc("deployment-replicas", analyzeDeploymentReplicas),
+		AnalyzerFunc("failed-jobs", analyzeFailedJobs),
+	}
+}
+
+func podRef(pod *v1.Pod) ObjectRef {
+	return ObjectRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name}
+}
+
+// containerStatuses returns the statuses of the init and regular containers of a pod
+func containerStatuses(pod *v1.Pod) []v1.ContainerStatus {
+	return append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+}
+
+// lastTermination describes how a container last terminated, empty if it has not
+func lastTermination(status v1.ContainerStatus) string {
+	terminated := status.LastTerminationState.Terminated
+	if terminated == nil {
+		return ""
+	}
+	return fmt.Sprintf("last terminated with %s (exit code %d)", terminated.Reason, terminated.ExitCode)
+}
+
+func analyzeCrashLoops(scope *AnalysisScope) ([]Finding, error) {
+	pods, err := scope.Pods()
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, pod := range pods {
+		for _, status := range containerStatuses(pod) {
+			if status.State.Waiting == nil || status.State.Waiting.Reason != "CrashLoopBackOff" {
+				continue
+			}
+			message := fmt.Sprintf("back-off restarting after %d restarts", status.RestartCount)
+			if last := lastTermination(status); last != "" {
+				message += ", " + last
+			}
+			findings = append(findings, Finding{
+				Severity:  SeverityCritical,
+				Object:    podRef(pod),
+				Container: status.Name,
+				Reason:    "CrashLoopBackOff",
+				Message:   message,
+				Hint:      "Read the logs of the previous container run.",
+			})
+		}
+	}
+	return findings, nil
+}
+
+func analyzeImagePulls(scope *AnalysisScope) ([]Finding, error) {
+	pods, err := scope.Pods()
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, pod := range pods {
+		for _, status := range containerStatuses(pod) {
+			waiting := status.State.Waiting
+			if waiting == nil || !containsString(imagePullReasons, waiting.Reason) {
+				continue
+			}
+			message := fmt.Sprintf("cannot pull image %s", status.Image)
+			if waiting.Message != "" {
+				message += ": " + waiting.Message
+			}
+			findings = append(findings, Finding{
+				Severity:  SeverityCritical,
+				Object:    podRef(pod),
+				Container: status.Name,
+				Reason:    waiting.Reason,
+				Message:   message,
+				Hint:      "Check the image name and tag, the registry and the pod's imagePullSecrets.",
+			})
+		}
+	}
+	return findings, nil
+}
+
+func analyzeUnschedulable(scope *AnalysisScope) ([]Finding, error) {
+	pods, err := scope.Pods()
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, pod := range pods {
+		if pod.Status.Phase != v1.PodPending {
+			continue
+		}
+		for _, cond := range pod.Status.Conditions {
+			if cond.Type != v1.PodScheduled || cond.Status != v1.ConditionFalse || cond.Reason != v1.PodReasonUnschedulable {
+				continue
+			}
+			findings = append(findings, Finding{
+				Severity: SeverityCritical,
+				Object:   podRef(pod),
+				Reason:   "Unschedulable",
+				Message:  cond.Message,
+				Hint:     "Check the pod's resource requests, node selector, affinity and tolerations against the nodes, and its PVCs.",
+			})
+		}
+	}
+	return findings, nil
+}
+
+func analyzeOOMKilled(scope *AnalysisScope) ([]Finding, error) {
+	pods, err := scope.Pods()
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, pod := range pods {
+		limits := make(map[string]string)
+		for _, c := range append(append([]v1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...) {
+			if limit, ok := c.Resources.Limits[v1.ResourceMemory]; ok {
+				limits[c.Name] = limit.String()
+			}
+		}
+		for _, status := range containerStatuses(pod) {
+			severity, message := SeverityCritical, "was killed running out of memory"
+			if terminated := status.State.Terminated; terminated == nil || terminated.Reason != "OOMKilled" {
+				terminated = status.LastTerminationState.Terminated
+				if terminated == nil || terminated.Reason != "OOMKilled" {
+					continue
+				}
+				severity = SeverityWarning
+				message = fmt.Sprintf("was last killed running out of memory, %d restarts", status.RestartCount)
+			}
+			if limit, ok := limits[status.Name]; ok {
+				message += ", memory limit " + limit
+			} else {
+				message += ", no memory limit"
+			}
+			findings = append(findings, Finding{
+				Severity:  severity,
+				Object:    podRef(pod),
+				Container: status.Name,
+				Reason:    "OOMKilled",
+				Message:   message,
+				Hint:      "Raise the container's memory limit or find what grows its memory use.",
+			})
+		}
+	}
+	return findings, nil
+}
+
+// containerOfFieldPath returns the container an event's fieldPath, e.g. spec.containers{app},
+// is about
+func containerOfFieldPath(fieldPath string) string {
+	start, end := strings.IndexByte(fieldPath, '{'), strings.LastIndexByte(fieldPath, '}')
+	if start < 0 || end < start {
+		return ""
+	}
+	return fieldPath[start+1 : end]
+}
+
+func analyzeProbes(scope *AnalysisScope) ([]Finding, error) {
+	pods, err := scope.Pods()
+	if err != nil {
+		return nil, err
+	}
+	events, err := scope.Events()
+	if err != nil {
+		return nil, err
+	}
+	// unhealthy holds the latest Unhealthy event of each container, by namespace/pod/container
+	unhealthy := make(map[string]*v1.Event)
+	for _, event := range events {
+		if event.Reason != "Unhealthy" || event.InvolvedObject.Kind != "Pod" || scope.Now.Sub(lastSeen(event)) > probeEventWindow {
+			continue
+		}
+		key := event.InvolvedObject.Namespace + "/" + event.InvolvedObject.Name + "/" + containerOfFieldPath(event.InvolvedObject.FieldPath)
+		if latest, ok := unhealthy[key]; !ok || lastSeen(event).After(lastSeen(latest)) {
+			unhealthy[key] = event
+		}
+	}
+
+	var findings []Finding
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		probed := make(map[string]v1.Container)
+		for _, c := range pod.Spec.Containers {
+			probed[c.Name] = c
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			c := probed[status.Name]
+			event := unhealthy[pod.Namespace+"/"+pod.Name+"/"+status.Name]
+			running := status.State.Running
+			switch {
+			case running != nil && !status.Ready && c.ReadinessProbe != nil && scope.Now.Sub(running.StartedAt.Time) > analysisGracePeriod:
+				message := fmt.Sprintf("running since %s but not ready", running.StartedAt.UTC().Format(time.RFC3339))
+				if event != nil && strings.HasPrefix(event.Message, "Readiness probe") {
+					message += ": " + event.Message
+				}
+				findings = append(findings, Finding{
+					Severity:  SeverityWarning,
+					Object:    podRef(pod),
+					Container: status.Name,
+					Reason:    "ReadinessProbeFailing",
+					Message:   message,
+					Hint:      "Check the readiness probe's path, port and timeout against the container's logs.",
+				})
+			case event != nil && (strings.HasPrefix(event.Message, "Liveness probe") || strings.HasPrefix(event.Message, "Startup probe")):
+				findings = append(findings, Finding{
+					Severity:  SeverityWarning,
+					Object:    podRef(pod),
+					Container: status.Name,
+					Reason:    strings.SplitN(event.Message, " ", 2)[0] + "ProbeFailing",
+					Message:   fmt.Sprintf("%s (%d restarts)", event.Message, status.RestartCount),
+					Hint:      "A failing liveness or startup probe restarts the container; check its thresholds and the container's logs.",
+				})
+			}
+		}
+	}
+	return findings, nil
+}
+
+func analyzeServiceEndpoints(scope *AnalysisScope) ([]Finding, error) {
+	services, err := scope.Services()
+	if err != nil {
+		return nil, err
+	}
+	slices, err := scope.EndpointSlices()
+	if err != nil {
+		return nil, err
+	}
+	pods, err := scope.Pods()
+	if err != nil {
+		return nil, err
+	}
+	// ready counts the ready endpoints of each Service, by namespace/name
+	ready := make(map[string]int)
+	for _, slice := range slices {
+		service := slice.Labels[discoveryv1.LabelServiceName]
+		if service == "" {
+			continue
+		}
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready {
+				ready[slice.Namespace+"/"+service]++
+			}
+		}
+	}
+
+	var findings []Finding
+	for _, svc := range services {
+		if svc.Spec.Type == v1.ServiceTypeExternalName || len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		if ready[svc.Namespace+"/"+svc.Name] > 0 {
+			continue
+		}
+		selector := labels.SelectorFromSet(svc.Spec.Selector)
+		matched := 0
+		for _, pod := range pods {
+			if pod.Namespace == svc.Namespace && selector.Matches(labels.Set(pod.Labels)) {
+				matched++
+			}
+		}
+		message := fmt.Sprintf("no pods match selector %s", selector)
+		hint := "Check the Service's selector against the labels of the pods it should route to."
+		if matched > 0 {
+			message = fmt.Sprintf("%d pods match selector %s but none is ready", matched, selector)
+			hint = "Find why the selected pods are not ready."
+		}
+		findings = append(findings, Finding{
+			Severity: SeverityCritical,
+			Object:   ObjectRef{Kind: "Service", Namespace: svc.Namespace, Name: svc.Name},
+			Reason:   "NoEndpoints",
+			Message:  message,
+			Hint:     hint,
+		})
+	}
+	return findings, nil
+}
+
+func analyzePendingClaims(scope *AnalysisScope) ([]Finding, error) {
+	claims, err := scope.PersistentVolumeClaims()
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, claim := range claims {
+		if claim.Status.Phase != v1.ClaimPending || scope.Now.Sub(claim.CreationTimestamp.Time) < analysisGracePeriod {
+			continue
+		}
+		ref := ObjectRef{Kind: "PersistentVolumeClaim", Namespace: claim.Namespace, Name: claim.Name}
+		storageClass := "the default storage class"
+		if claim.Spec.StorageClassName != nil {
+			storageClass = "storage class " + *claim.Spec.StorageClassName
+		}
+		message := fmt.Sprintf("pending since %s with %s", claim.CreationTimestamp.UTC().Format(time.RFC3339), storageClass)
+		event, err := scope.LatestEvent(ref, "ProvisioningFailed", "FailedBinding", "ExternalProvisioning", "WaitForFirstConsumer", "WaitForPodScheduled")
+		if err != nil {
+			return nil, err
+		}
+		if event != nil {
+			message += ": " + event.Message
+		}
+		findings = append(findings, Finding{
+			Severity: SeverityWarning,
+			Object:   ref,
+			Reason:   "ClaimPending",
+			Message:  message,
+			Hint:     "Check that the storage class exists and its provisioner runs, or that a matching PersistentVolume is available.",
+		})
+	}
+	return findings, nil
+}
+
+func analyzeDeploymentReplicas(scope *AnalysisScope) ([]Finding, error) {
+	deployments, err := scope.Deployments()
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, deploy := range deployments {
+		desired := int32(1)
+		if deploy.Spec.Replicas != nil {
+			desired = *deploy.Spec.Replicas
+		}
+		if desired == 0 {
+			continue
+		}
+		ref := ObjectRef{Kind: "Deployment", Namespace: deploy.Namespace, Name: deploy.Name}
+		available := deploy.Status.AvailableReplicas
+		for _, cond := range deploy.Status.Conditions {
+			if cond.Type == appsv1.DeploymentProgressing && cond.Reason == "ProgressDeadlineExceeded" {
+				findings = append(findings, Finding{
+					Severity: SeverityCritical,
+					Object:   ref,
+					Reason:   "ProgressDeadlineExceeded",
+					Message:  cond.Message,
+					Hint:     "The rollout is stuck; check the pods of the newest ReplicaSet.",
+				})
+			}
+		}
+		if available >= desired {
+			continue
+		}
+		finding := Finding{
+			Severity: SeverityWarning,
+			Object:   ref,
+			Reason:   "UnavailableReplicas",
+			Message:  fmt.Sprintf("%d of %d replicas available", available, desired),
+			Hint:     "Check the Deployment's pods for the ones that are not ready.",
+		}
+		if available == 0 {
+			finding.Severity = SeverityCritical
+			finding.Reason = "NoAvailableReplicas"
+		}
+		findings = append(findings, finding)
+	}
+	return findings, nil
+}
+
+func analyzeFailedJobs(scope *AnalysisScope) ([]Finding, error) {
+	jobs, err := scope.Jobs()
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, job := range jobs {
+		for _, cond := range job.Status.Conditions {
+			if cond.Type != batchv1.JobFailed || cond.Status != v1.ConditionTrue {
+				continue
+			}
+			reason := cond.Reason
+			if reason == "" {
+				reason = "JobFailed"
+			}
+			message := fmt.Sprintf("failed with %d failed pods", job.Status.Failed)
+			if cond.Message != "" {
+				message += ": " + cond.Message
+			}
+			findings = append(findings, Finding{
+				Severity: SeverityCritical,
+				Object:   ObjectRef{Kind: "Job", Namespace: job.Namespace, Name: job.Name},
+				Reason:   reason,
+				Message:  message,
+				Hint:     "Read the logs of the Job's failed pods.",
+			})
+		}
+	}
+	return findings, nil
+}
@@ -0,0 +1,627 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestAnalyzers(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		analyze  func(scope *AnalysisScope) ([]Finding, error)
+		fixtures string
+		// want are the findings as "severity reason object[ container]: message"
+		want []string
+	}{
+		{
+			name:    "crashloop",
+			analyze: analyzeCrashLoops,
+			fixtures: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-1
+  namespace: shop
+status:
+  phase: Running
+  containerStatuses:
+  - name: app
+    restartCount: 5
+    state:
+      waiting:
+        reason: CrashLoopBackOff
+    lastState:
+      terminated:
+        reason: Error
+        exitCode: 1
+  - name: proxy
+    state:
+      running:
+        startedAt: "2024-05-01T11:00:00Z"
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: api-1
+  namespace: shop
+status:
+  phase: Pending
+  initContainerStatuses:
+  - name: migrate
+    restartCount: 2
+    state:
+      waiting:
+        reason: CrashLoopBackOff
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-1
+  namespace: other
+status:
+  containerStatuses:
+  - name: app
+    state:
+      waiting:
+        reason: CrashLoopBackOff
+`,
+			want: []string{
+				"critical CrashLoopBackOff Pod/shop/web-1 app: back-off restarting after 5 restarts, last terminated with Error (exit code 1)",
+				"critical CrashLoopBackOff Pod/shop/api-1 migrate: back-off restarting after 2 restarts",
+			},
+		},
+		{
+			name:    "image-pull",
+			analyze: analyzeImagePulls,
+			fixtures: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-1
+  namespace: shop
+status:
+  phase: Pending
+  containerStatuses:
+  - name: app
+    image: shop/web:nope
+    state:
+      waiting:
+        reason: ImagePullBackOff
+        message: Back-off pulling image "shop/web:nope"
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-2
+  namespace: shop
+status:
+  phase: Pending
+  containerStatuses:
+  - name: app
+    image: shop/web:1.0
+    state:
+      waiting:
+        reason: ContainerCreating
+`,
+			want: []string{
+				`critical ImagePullBackOff Pod/shop/web-1 app: cannot pull image shop/web:nope: Back-off pulling image "shop/web:nope"`,
+			},
+		},
+		{
+			name:    "unschedulable",
+			analyze: analyzeUnschedulable,
+			fixtures: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: big-1
+  namespace: shop
+status:
+  phase: Pending
+  conditions:
+  - type: PodScheduled
+    status: "False"
+    reason: Unschedulable
+    message: "0/3 nodes are available: 3 Insufficient memory."
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-1
+  namespace: shop
+status:
+  phase: Pending
+  conditions:
+  - type: PodScheduled
+    status: "True"
+`,
+			want: []string{
+				"critical Unschedulable Pod/shop/big-1: 0/3 nodes are available: 3 Insufficient memory.",
+			},
+		},
+		{
+			name:    "oom-killed",
+			analyze: analyzeOOMKilled,
+			fixtures: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-1
+  namespace: shop
+spec:
+  containers:
+  - name: app
+    resources:
+      limits:
+        memory: 128Mi
+status:
+  containerStatuses:
+  - name: app
+    state:
+      terminated:
+        reason: OOMKilled
+        exitCode: 137
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-2
+  namespace: shop
+spec:
+  containers:
+  - name: app
+  - name: proxy
+status:
+  containerStatuses:
+  - name: app
+    restartCount: 3
+    state:
+      running:
+        startedAt: "2024-05-01T11:59:00Z"
+    lastState:
+      terminated:
+        reason: OOMKilled
+        exitCode: 137
+  - name: proxy
+    lastState:
+      terminated:
+        reason: Error
+        exitCode: 1
+`,
+			want: []string{
+				"critical OOMKilled Pod/shop/web-1 app: was killed running out of memory, memory limit 128Mi",
+				"warning OOMKilled Pod/shop/web-2 app: was last killed running out of memory, 3 restarts, no memory limit",
+			},
+		},
+		{
+			name:    "probes",
+			analyze: analyzeProbes,
+			fixtures: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-1
+  namespace: shop
+spec:
+  containers:
+  - name: app
+    readinessProbe:
+      httpGet:
+        path: /ready
+        port: 8080
+status:
+  containerStatuses:
+  - name: app
+    ready: false
+    state:
+      running:
+        startedAt: "2024-05-01T11:50:00Z"
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-2
+  namespace: shop
+spec:
+  containers:
+  - name: app
+    readinessProbe:
+      httpGet:
+        path: /ready
+        port: 8080
+status:
+  containerStatuses:
+  - name: app
+    ready: false
+    state:
+      running:
+        startedAt: "2024-05-01T11:59:30Z"
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: api-1
+  namespace: shop
+spec:
+  containers:
+  - name: app
+    livenessProbe:
+      tcpSocket:
+        port: 9090
+status:
+  containerStatuses:
+  - name: app
+    ready: true
+    restartCount: 4
+    state:
+      running:
+        startedAt: "2024-05-01T11:58:00Z"
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: api-2
+  namespace: shop
+spec:
+  containers:
+  - name: app
+    livenessProbe:
+      tcpSocket:
+        port: 9090
+status:
+  containerStatuses:
+  - name: app
+    ready: true
+    restartCount: 1
+    state:
+      running:
+        startedAt: "2024-05-01T09:00:00Z"
+---
+apiVersion: v1
+kind: Event
+metadata:
+  name: web-1.1
+  namespace: shop
+involvedObject:
+  kind: Pod
+  namespace: shop
+  name: web-1
+  fieldPath: spec.containers{app}
+reason: Unhealthy
+message: "Readiness probe failed: HTTP probe failed with statuscode: 503"
+lastTimestamp: "2024-05-01T11:59:00Z"
+---
+apiVersion: v1
+kind: Event
+metadata:
+  name: api-1.1
+  namespace: shop
+involvedObject:
+  kind: Pod
+  namespace: shop
+  name: api-1
+  fieldPath: spec.containers{app}
+reason: Unhealthy
+message: "Liveness probe failed: dial tcp 10.0.0.7:9090: connect: connection refused"
+lastTimestamp: "2024-05-01T11:40:00Z"
+---
+apiVersion: v1
+kind: Event
+metadata:
+  name: api-1.2
+  namespace: shop
+involvedObject:
+  kind: Pod
+  namespace: shop
+  name: api-1
+  fieldPath: spec.containers{app}
+reason: Unhealthy
+message: "Liveness probe failed: dial tcp 10.0.0.7:9090: i/o timeout"
+lastTimestamp: "2024-05-01T11:57:00Z"
+---
+apiVersion: v1
+kind: Event
+metadata:
+  name: api-2.1
+  namespace: shop
+involvedObject:
+  kind: Pod
+  namespace: shop
+  name: api-2
+  fieldPath: spec.containers{app}
+reason: Unhealthy
+message: "Liveness probe failed: dial tcp 10.0.0.8:9090: i/o timeout"
+lastTimestamp: "2024-05-01T09:30:00Z"
+`,
+			want: []string{
+				"warning ReadinessProbeFailing Pod/shop/web-1 app: running since 2024-05-01T11:50:00Z but not ready: Readiness probe failed: HTTP probe failed with statuscode: 503",
+				"warning LivenessProbeFailing Pod/shop/api-1 app: Liveness probe failed: dial tcp 10.0.0.7:9090: i/o timeout (4 restarts)",
+			},
+		},
+		{
+			name:    "service-endpoints",
+			analyze: analyzeServiceEndpoints,
+			fixtures: `
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: shop
+spec:
+  selector:
+    app: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: api
+  namespace: shop
+spec:
+  selector:
+    app: api
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: legacy
+  namespace: shop
+spec:
+  selector:
+    app: legacy
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: external
+  namespace: shop
+spec:
+  type: ExternalName
+  externalName: db.example.com
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: manual
+  namespace: shop
+spec:
+  ports:
+  - port: 5432
+---
+apiVersion: discovery.k8s.io/v1
+kind: EndpointSlice
+metadata:
+  name: web-abc
+  namespace: shop
+  labels:
+    kubernetes.io/service-name: web
+addressType: IPv4
+endpoints:
+- addresses: ["10.0.0.5"]
+  conditions:
+    ready: true
+---
+apiVersion: discovery.k8s.io/v1
+kind: EndpointSlice
+metadata:
+  name: api-abc
+  namespace: shop
+  labels:
+    kubernetes.io/service-name: api
+addressType: IPv4
+endpoints:
+- addresses: ["10.0.0.7"]
+  conditions:
+    ready: false
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: api-1
+  namespace: shop
+  labels:
+    app: api
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: api-2
+  namespace: shop
+  labels:
+    app: api
+`,
+			want: []string{
+				"critical NoEndpoints Service/shop/api: 2 pods match selector app=api but none is ready",
+				"critical NoEndpoints Service/shop/legacy: no pods match selector app=legacy",
+			},
+		},
+		{
+			name:    "pvc-pending",
+			analyze: analyzePendingClaims,
+			fixtures: `
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+  namespace: shop
+  creationTimestamp: "2024-05-01T11:50:00Z"
+spec:
+  storageClassName: fast
+status:
+  phase: Pending
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: cache
+  namespace: shop
+  creationTimestamp: "2024-05-01T11:00:00Z"
+status:
+  phase: Pending
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: scratch
+  namespace: shop
+  creationTimestamp: "2024-05-01T11:59:30Z"
+status:
+  phase: Pending
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: logs
+  namespace: shop
+  creationTimestamp: "2024-05-01T11:00:00Z"
+status:
+  phase: Bound
+---
+apiVersion: v1
+kind: Event
+metadata:
+  name: data.1
+  namespace: shop
+involvedObject:
+  kind: PersistentVolumeClaim
+  namespace: shop
+  name: data
+reason: ProvisioningFailed
+message: storageclass.storage.k8s.io "fast" not found
+lastTimestamp: "2024-05-01T11:59:00Z"
+`,
+			want: []string{
+				`warning ClaimPending PersistentVolumeClaim/shop/data: pending since 2024-05-01T11:50:00Z with storage class fast: storageclass.storage.k8s.io "fast" not found`,
+				"warning ClaimPending PersistentVolumeClaim/shop/cache: pending since 2024-05-01T11:00:00Z with the default storage class",
+			},
+		},
+		{
+			name:    "deployment-replicas",
+			analyze: analyzeDeploymentReplicas,
+			fixtures: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: shop
+spec:
+  replicas: 3
+status:
+  availableReplicas: 2
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+  namespace: shop
+spec:
+  replicas: 2
+status:
+  conditions:
+  - type: Progressing
+    status: "False"
+    reason: ProgressDeadlineExceeded
+    message: ReplicaSet "api-5d4f" has timed out progressing.
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: worker
+  namespace: shop
+spec:
+  replicas: 0
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: cron
+  namespace: shop
+status:
+  availableReplicas: 1
+`,
+			want: []string{
+				"warning UnavailableReplicas Deployment/shop/web: 2 of 3 replicas available",
+				`critical ProgressDeadlineExceeded Deployment/shop/api: ReplicaSet "api-5d4f" has timed out progressing.`,
+				"critical NoAvailableReplicas Deployment/shop/api: 0 of 2 replicas available",
+			},
+		},
+		{
+			name:    "failed-jobs",
+			analyze: analyzeFailedJobs,
+			fixtures: `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  namespace: shop
+status:
+  failed: 6
+  conditions:
+  - type: Failed
+    status: "True"
+    reason: BackoffLimitExceeded
+    message: Job has reached the specified backoff limit
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: report
+  namespace: shop
+status:
+  failed: 1
+  conditions:
+  - type: Failed
+    status: "True"
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: backup
+  namespace: shop
+status:
+  succeeded: 1
+  conditions:
+  - type: Complete
+    status: "True"
+`,
+			want: []string{
+				"critical BackoffLimitExceeded Job/shop/migrate: failed with 6 failed pods: Job has reached the specified backoff limit",
+				"critical JobFailed Job/shop/report: failed with 1 failed pods",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope := &AnalysisScope{
+				Namespace: "shop",
+				Now:       now,
+				lister:    fixtureLister("shop", fixtureObjects(t, tt.fixtures)),
+				typed:     make(map[schema.GroupKind]interface{}),
+			}
+			findings, err := tt.analyze(scope)
+			if err != nil {
+				t.Fatalf("%s error = %v", tt.name, err)
+			}
+			var got []string
+			for _, f := range findings {
+				object := f.Object.String()
+				if f.Container != "" {
+					object += " " + f.Container
+				}
+				got = append(got, string(f.Severity)+" "+f.Reason+" "+object+": "+f.Message)
+				if f.Hint == "" {
+					t.Errorf("%s finding %s has no hint", tt.name, object)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s findings =\n%s\nwant\n%s", tt.name, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
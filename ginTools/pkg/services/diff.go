@@ -0,0 +1,172 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// DryRunResult is what a dry-run create, update or patch would store
+type DryRunResult struct {
+	DryRun bool `json:"dryRun"`
+	// Object is the object as the API server would persist it
+	Object map[string]interface{} `json:"object"`
+	// YAML is Object rendered without server-managed bookkeeping fields
+	YAML string `json:"yaml"`
+	// Diff compares the live object with Object, empty for creates or when nothing changes
+	Diff string `json:"diff,omitempty"`
+}
+
+// newDryRunResult describes the dry-run result obj, diffed against live unless it is nil
+func newDryRunResult(live, obj *unstructured.Unstructured) (*DryRunResult, error) {
+	after, err := diffYAML(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DryRunResult{DryRun: true, Object: obj.Object, YAML: after}
+	if live != nil {
+		before, err := diffYAML(live)
+		if err != nil {
+			return nil, err
+		}
+		result.Diff = unifiedDiff(before, after, "live", "dry-run")
+	}
+	return result, nil
+}
+
+// diffYAML renders obj as YAML without the fields that change on every write
+func diffYAML(obj *unstructured.Unstructured) (string, error) {
+	obj = obj.DeepCopy()
+	unstructured.RemoveNestedField(obj.Object, "metadata", "managedFields")
+	unstructured.RemoveNestedField(obj.Object, "metadata", "resourceVersion")
+	unstructured.RemoveNestedField(obj.Object, "metadata", "generation")
+
+	data, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return "", fmt.Errorf("failed to render object: %w", err)
+	}
+	return string(data), nil
+}
+
+// diffLine is one line of a diff: ' ' kept, '-' removed from a, '+' added from b
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// unifiedDiff returns the unified diff between a and b, or "" when they are equal
+func unifiedDiff(a, b, aName, bName string) string {
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	// aLine and bLine are the 1-based numbers of lines[i] in a and b
+	aLine, bLine := 1, 1
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			i, aLine, bLine = i+1, aLine+1, bLine+1
+			continue
+		}
+
+		// A hunk starts diffContext lines before the change
+		start := i
+		for start > 0 && i-start < diffContext && lines[start-1].op == ' ' {
+			start--
+		}
+		aStart, bStart := aLine-(i-start), bLine-(i-start)
+
+		// and ends once more than 2*diffContext unchanged lines follow a change
+		end, unchanged := i, 0
+		for ; end < len(lines) && unchanged <= 2*diffContext; end++ {
+			if lines[end].op == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+		if unchanged > diffContext {
+			end -= unchanged - diffContext
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+		}
+		aCount, bCount := 0, 0
+		for _, l := range lines[start:end] {
+			if l.op != '+' {
+				aCount++
+			}
+			if l.op != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+		for _, l := range lines[start:end] {
+			fmt.Fprintf(&out, "%c%s\n", l.op, l.text)
+		}
+
+		for _, l := range lines[i:end] {
+			if l.op != '+' {
+				aLine++
+			}
+			if l.op != '-' {
+				bLine++
+			}
+		}
+		i = end
+	}
+	return out.String()
+}
+
+// diffLines aligns a and b along their longest common subsequence
+func diffLines(a, b []string) []diffLine {
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	lines := make([]diffLine, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i, j = i+1, j+1
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, diffLine{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, diffLine{'+', b[j]})
+	}
+	return lines
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	eventsv1 "k8s.io/api/events/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ErrInvalidEventOptions is wrapped by the errors of event queries that cannot be answered
+var ErrInvalidEventOptions = errors.New("invalid event options")
+
+// eventsGVR is the resource events are read from
+var eventsGVR = schema.GroupVersionResource{Group: "events.k8s.io", Version: "v1", Resource: "events"}
+
+// maxOwnedObjects caps the objects an owner chain is followed to
+const maxOwnedObjects = 500
+
+// ownedKinds lists the kinds of the objects the controllers of each kind create and own
+var ownedKinds = map[schema.GroupKind][]schema.GroupKind{
+	{Group: "apps", Kind: "Deployment"}:  {{Group: "apps", Kind: "ReplicaSet"}},
+	{Group: "apps", Kind: "ReplicaSet"}:  {{Kind: "Pod"}},
+	{Group: "apps", Kind: "StatefulSet"}: {{Kind: "Pod"}},
+	{Group: "apps", Kind: "DaemonSet"}:   {{Kind: "Pod"}},
+	{Group: "batch", Kind: "CronJob"}:    {{Group: "batch", Kind: "Job"}},
+	{Group: "batch", Kind: "Job"}:        {{Kind: "Pod"}},
+	{Kind: "ReplicationController"}:      {{Kind: "Pod"}},
+}
+
+// ObjectRef identifies an object; Namespace is empty for cluster-scoped objects
+type ObjectRef struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+func (o ObjectRef) String() string {
+	if o.Namespace == "" {
+		return o.Kind + "/" + o.Name
+	}
+	return o.Kind + "/" + o.Namespace + "/" + o.Name
+}
+
+// Event is an event about an object, read from events.k8s.io/v1
+type Event struct {
+	Namespace string `json:"namespace"`
+	// Kind and Name identify the object the event is about
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+	Action  string `json:"action,omitempty"`
+	// Source is the controller that reported the event
+	Source string `json:"source,omitempty"`
+	// Count is how often the event occurred between FirstSeen and LastSeen
+	Count     int32     `json:"count"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// EventOptions selects events
+type EventOptions struct {
+	// Kind selects the events of objects of a resource or kind, in any form kubectl accepts
+	Kind string
+	// Name selects the events of the objects of a name; with Kind, of that one object
+	Name string
+	// Type is the event type, e.g. Warning, all types if empty
+	Type string
+	// Since, if positive, keeps the events last seen within this duration
+	Since time.Duration
+	// OwnerChain adds the events of the objects the named object owns, and of the objects
+	// those own, e.g. the ReplicaSets and Pods of a Deployment. It needs Kind and Name.
+	OwnerChain bool
+	// Limit, if positive, keeps the most recent events
+	Limit int
+}
+
+// EventTimeline is the events of one or more objects, ordered by the time they were last seen
+type EventTimeline struct {
+	// Objects are the objects whose events were selected by name, the owner chain included
+	Objects   []ObjectRef `json:"objects,omitempty"`
+	Events    []Event     `json:"events"`
+	Truncated bool        `json:"truncated"`
+}
+
+// ListEvents returns the events selected by opts in ns, or in all namespaces if ns is empty
+// or AllNamespaces. A named object is looked up in all namespaces when none is given.
+func (r *ResourceService) ListEvents(ns string, opts EventOptions) (*EventTimeline, error) {
+	if opts.OwnerChain && (opts.Kind == "" || opts.Name == "") {
+		return nil, fmt.Errorf("%w: ownerChain needs a kind and a name", ErrInvalidEventOptions)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	timeline := &EventTimeline{}
+	eventNamespace := listNamespace(ns)
+	match := func(ref ObjectRef) bool { return opts.Name == "" || ref.Name == opts.Name }
+	if opts.Kind != "" {
+		mapping, err := r.mappingFor(opts.Kind, r.restMapper)
+		if err != nil {
+			return nil, err
+		}
+		kind := mapping.GroupVersionKind.Kind
+		match = func(ref ObjectRef) bool { return ref.Kind == kind }
+		if opts.Name != "" {
+			objects, err := r.namedObjects(ctx, mapping, ns, opts.Name, opts.OwnerChain)
+			if err != nil {
+				return nil, err
+			}
+			selected := make(map[ObjectRef]bool, len(objects))
+			for _, obj := range objects {
+				selected[obj] = true
+			}
+			match = func(ref ObjectRef) bool { return selected[ref] }
+			timeline.Objects = objects
+			// The events of cluster-scoped objects may be kept in any namespace
+			eventNamespace = objects[0].Namespace
+		}
+	}
+
+	var listOpts metav1.ListOptions
+	if opts.Type != "" {
+		listOpts.FieldSelector = fields.OneTermEqualSelector("type", opts.Type).String()
+	}
+	list, err := r.client.Resource(eventsGVR).Namespace(eventNamespace).List(ctx, listOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+	var events []Event
+	for _, item := range list.Items {
+		var event eventsv1.Event
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &event); err != nil {
+			return nil, fmt.Errorf("failed to convert event %s: %w", item.GetName(), err)
+		}
+		ref := ObjectRef{Kind: event.Regarding.Kind, Namespace: event.Regarding.Namespace, Name: event.Regarding.Name}
+		if match(ref) {
+			events = append(events, convertEvent(&event))
+		}
+	}
+	timeline.Events, timeline.Truncated = recentEvents(events, opts.Since, opts.Limit)
+	return timeline, nil
+}
+
+// namedObjects returns the named object of mapping and, with ownerChain, the objects it
+// owns directly or indirectly. The named object comes first.
+func (r *ResourceService) namedObjects(ctx context.Context, mapping *meta.RESTMapping, ns string, name string, ownerChain bool) ([]ObjectRef, error) {
+	kind := mapping.GroupVersionKind.Kind
+	ns, err := r.objectNamespace(mapping, ns, name)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := []ObjectRef{{Kind: kind, Namespace: ns, Name: name}}
+	if !ownerChain {
+		return objects, nil
+	}
+
+	root, err := r.client.Resource(mapping.Resource).Namespace(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s %s: %w", strings.ToLower(kind), name, err)
+	}
+	owned, err := r.ownedObjects(root, mapping.GroupVersionKind.GroupKind())
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range owned {
+		objects = append(objects, obj.ref)
+	}
+	return objects, nil
+}
+
+// ownedObject is an object found by ownedObjects
+type ownedObject struct {
+	ref ObjectRef
+	// obj is shared with the informer cache and must not be modified
+	obj *unstructured.Unstructured
+	// owner is the UID of the object owning obj
+	owner types.UID
+}
+
+// ownedObjects follows the owner references from the objects of ownedKinds back to root,
+// returning the objects root owns directly or indirectly, closest first. Kinds the cluster
+// does not serve are skipped.
+func (r *ResourceService) ownedObjects(root *unstructured.Unstructured, rootKind schema.GroupKind) ([]ownedObject, error) {
+	type owner struct {
+		uid       types.UID
+		namespace string
+		kind      schema.GroupKind
+	}
+	var owned []ownedObject
+	queue := []owner{{root.GetUID(), root.GetNamespace(), rootKind}}
+	for len(queue) > 0 && len(owned) < maxOwnedObjects {
+		parent := queue[0]
+		queue = queue[1:]
+		for _, childKind := range ownedKinds[parent.kind] {
+			mapping, err := (*r.restMapper).RESTMapping(childKind)
+			if meta.IsNoMatchError(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			children, err := r.listObjects(mapping, parent.namespace, labels.Everything())
+			if err != nil {
+				return nil, fmt.Errorf("failed to list %s: %w", mapping.Resource.Resource, err)
+			}
+			sort.Slice(children, func(i, j int) bool { return children[i].GetName() < children[j].GetName() })
+			for _, child := range children {
+				if !ownedBy(child, parent.uid) {
+					continue
+				}
+				owned = append(owned, ownedObject{
+					ref:   ObjectRef{Kind: childKind.Kind, Namespace: child.GetNamespace(), Name: child.GetName()},
+					obj:   child,
+					owner: parent.uid,
+				})
+				queue = append(queue, owner{child.GetUID(), child.GetNamespace(), childKind})
+			}
+		}
+	}
+	if len(owned) > maxOwnedObjects {
+		owned = owned[:maxOwnedObjects]
+	}
+	return owned, nil
+}
+
+// ownedBy reports whether obj has an owner reference to the object with uid
+func ownedBy(obj *unstructured.Unstructured, uid types.UID) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == uid {
+			return true
+		}
+	}
+	return false
+}
+
+// recentEvents keeps the events last seen within since, if positive, orders them by the time
+// they were last seen and keeps the limit most recent ones, if limit is positive. truncated
+// reports whether older events were dropped for limit.
+func recentEvents(events []Event, since time.Duration, limit int) (recent []Event, truncated bool) {
+	recent = make([]Event, 0, len(events))
+	for _, e := range events {
+		if since > 0 && time.Since(e.LastSeen) > since {
+			continue
+		}
+		recent = append(recent, e)
+	}
+	sort.SliceStable(recent, func(i, j int) bool { return recent[i].LastSeen.Before(recent[j].LastSeen) })
+	if limit > 0 && len(recent) > limit {
+		recent = recent[len(recent)-limit:]
+		truncated = true
+	}
+	return recent, truncated
+}
+
+// convertEvent flattens an event, reading the deprecated core fields of events recorded
+// through the core API
+func convertEvent(event *eventsv1.Event) Event {
+	e := Event{
+		Namespace: event.Namespace,
+		Kind:      event.Regarding.Kind,
+		Name:      event.Regarding.Name,
+		Type:      event.Type,
+		Reason:    event.Reason,
+		Message:   event.Note,
+		Action:    event.Action,
+		Source:    event.ReportingController,
+		Count:     event.DeprecatedCount,
+	}
+	if e.Source == "" {
+		e.Source = event.DeprecatedSource.Component
+	}
+
+	e.FirstSeen = event.CreationTimestamp.Time
+	switch {
+	case !event.DeprecatedFirstTimestamp.IsZero():
+		e.FirstSeen = event.DeprecatedFirstTimestamp.Time
+	case !event.EventTime.IsZero():
+		e.FirstSeen = event.EventTime.Time
+	}
+	e.LastSeen = e.FirstSeen
+	switch {
+	case event.Series != nil && !event.Series.LastObservedTime.IsZero():
+		e.LastSeen = event.Series.LastObservedTime.Time
+		e.Count = event.Series.Count
+	case !event.DeprecatedLastTimestamp.IsZero():
+		e.LastSeen = event.DeprecatedLastTimestamp.Time
+	}
+	if e.Count < 1 {
+		e.Count = 1
+	}
+	return e
+}
@@ -0,0 +1,395 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+// fakeKinds are the kinds a fake ResourceService serves, with the scope of each
+var fakeKinds = map[schema.GroupVersionKind]meta.RESTScope{
+	{Version: "v1", Kind: "Pod"}:                            meta.RESTScopeNamespace,
+	{Version: "v1", Kind: "Node"}:                           meta.RESTScopeRoot,
+	{Group: "apps", Version: "v1", Kind: "Deployment"}:      meta.RESTScopeNamespace,
+	{Group: "apps", Version: "v1", Kind: "ReplicaSet"}:      meta.RESTScopeNamespace,
+	{Group: "events.k8s.io", Version: "v1", Kind: "Event"}:  meta.RESTScopeNamespace,
+	{Group: "example.com", Version: "v1", Kind: "Database"}: meta.RESTScopeNamespace,
+}
+
+// fakeResourceService returns a ResourceService serving fakeKinds from a fake dynamic
+// client holding objs. Its informers are stopped when the test ends.
+func fakeResourceService(t *testing.T, objs ...*unstructured.Unstructured) (*ResourceService, *dynamicfake.FakeDynamicClient) {
+	t.Helper()
+	var versions []schema.GroupVersion
+	for gvk := range fakeKinds {
+		versions = append(versions, gvk.GroupVersion())
+	}
+	mapper := meta.NewDefaultRESTMapper(versions)
+	listKinds := make(map[schema.GroupVersionResource]string)
+	for gvk, scope := range fakeKinds {
+		mapper.Add(gvk, scope)
+		plural, _ := meta.UnsafeGuessKindToResource(gvk)
+		listKinds[plural] = gvk.Kind + "List"
+	}
+	var restMapper meta.RESTMapper = mapper
+
+	runtimeObjs := make([]runtime.Object, 0, len(objs))
+	for _, obj := range objs {
+		runtimeObjs = append(runtimeObjs, obj)
+	}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, runtimeObjs...)
+	informers := NewInformerCache(client, time.Minute)
+	t.Cleanup(func() { informers.evict(func(*informerEntry) bool { return true }) })
+	return NewResourceService(&restMapper, nil, client, informers), client
+}
+
+// testEvent builds an events.k8s.io/v1 event about regarding, last seen at lastSeen
+func testEvent(t *testing.T, name string, regarding ObjectRef, eventType, reason string, lastSeen time.Time) *unstructured.Unstructured {
+	t.Helper()
+	ns := regarding.Namespace
+	if ns == "" {
+		ns = "default"
+	}
+	event := &eventsv1.Event{
+		TypeMeta:                metav1.TypeMeta{APIVersion: "events.k8s.io/v1", Kind: "Event"},
+		ObjectMeta:              metav1.ObjectMeta{Namespace: ns, Name: name},
+		Regarding:               v1.ObjectReference{Kind: regarding.Kind, Namespace: regarding.Namespace, Name: regarding.Name},
+		Type:                    eventType,
+		Reason:                  reason,
+		Note:                    reason + " " + regarding.Name,
+		DeprecatedLastTimestamp: metav1.NewTime(lastSeen),
+	}
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &unstructured.Unstructured{Object: data}
+}
+
+func TestListEvents(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	objs := fixtureObjects(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {namespace: default, name: web, uid: deploy-web}
+---
+apiVersion: apps/v1
+kind: ReplicaSet
+metadata:
+  namespace: default
+  name: web-1
+  uid: rs-web-1
+  ownerReferences: [{apiVersion: apps/v1, kind: Deployment, name: web, uid: deploy-web}]
+---
+apiVersion: apps/v1
+kind: ReplicaSet
+metadata: {namespace: default, name: api-1, uid: rs-api-1}
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  namespace: default
+  name: web-1-a
+  uid: pod-web-1-a
+  ownerReferences: [{apiVersion: apps/v1, kind: ReplicaSet, name: web-1, uid: rs-web-1}]
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  namespace: default
+  name: api-1-a
+  uid: pod-api-1-a
+  ownerReferences: [{apiVersion: apps/v1, kind: ReplicaSet, name: api-1, uid: rs-api-1}]
+---
+apiVersion: v1
+kind: Node
+metadata: {name: node-1, uid: node-1}
+`)
+	deploy := ObjectRef{Kind: "Deployment", Namespace: "default", Name: "web"}
+	rs := ObjectRef{Kind: "ReplicaSet", Namespace: "default", Name: "web-1"}
+	pod := ObjectRef{Kind: "Pod", Namespace: "default", Name: "web-1-a"}
+	otherPod := ObjectRef{Kind: "Pod", Namespace: "default", Name: "api-1-a"}
+	node := ObjectRef{Kind: "Node", Name: "node-1"}
+	objs = append(objs,
+		testEvent(t, "pod-scheduling", pod, "Warning", "FailedScheduling", now.Add(-time.Minute)),
+		testEvent(t, "rs-create", rs, "Normal", "SuccessfulCreate", now.Add(-3*time.Minute)),
+		testEvent(t, "deploy-scale", deploy, "Normal", "ScalingReplicaSet", now.Add(-4*time.Minute)),
+		testEvent(t, "deploy-old", deploy, "Normal", "ScalingReplicaSet", now.Add(-2*time.Hour)),
+		testEvent(t, "other-pod", otherPod, "Warning", "BackOff", now.Add(-2*time.Minute)),
+		testEvent(t, "node-pressure", node, "Warning", "MemoryPressure", now.Add(-5*time.Minute)),
+	)
+	r, _ := fakeResourceService(t, objs...)
+
+	tests := []struct {
+		name        string
+		ns          string
+		opts        EventOptions
+		wantObjects []ObjectRef
+		// wantEvents are the reasons and object names of the events, oldest first
+		wantEvents    []string
+		wantTruncated bool
+		wantErr       error
+	}{
+		{
+			name:        "one object",
+			ns:          "default",
+			opts:        EventOptions{Kind: "deployment", Name: "web"},
+			wantObjects: []ObjectRef{deploy},
+			wantEvents:  []string{"ScalingReplicaSet web", "ScalingReplicaSet web"},
+		},
+		{
+			name:        "owner chain",
+			ns:          "default",
+			opts:        EventOptions{Kind: "deployments", Name: "web", OwnerChain: true},
+			wantObjects: []ObjectRef{deploy, rs, pod},
+			wantEvents:  []string{"ScalingReplicaSet web", "ScalingReplicaSet web", "SuccessfulCreate web-1", "FailedScheduling web-1-a"},
+		},
+		{
+			name:        "named object found in all namespaces",
+			opts:        EventOptions{Kind: "deployment", Name: "web", OwnerChain: true, Since: time.Hour},
+			wantObjects: []ObjectRef{deploy, rs, pod},
+			wantEvents:  []string{"ScalingReplicaSet web", "SuccessfulCreate web-1", "FailedScheduling web-1-a"},
+		},
+		{
+			name:       "every object of a kind",
+			ns:         "default",
+			opts:       EventOptions{Kind: "pod"},
+			wantEvents: []string{"BackOff api-1-a", "FailedScheduling web-1-a"},
+		},
+		{
+			name:       "every object of a name",
+			ns:         AllNamespaces,
+			opts:       EventOptions{Name: "web-1"},
+			wantEvents: []string{"SuccessfulCreate web-1"},
+		},
+		{
+			// The events of cluster-scoped objects are read from every namespace
+			name:        "cluster-scoped object",
+			ns:          "shop",
+			opts:        EventOptions{Kind: "node", Name: "node-1"},
+			wantObjects: []ObjectRef{node},
+			wantEvents:  []string{"MemoryPressure node-1"},
+		},
+		{
+			name:          "limit keeps the most recent",
+			ns:            "default",
+			opts:          EventOptions{Kind: "deployment", Name: "web", OwnerChain: true, Limit: 2},
+			wantObjects:   []ObjectRef{deploy, rs, pod},
+			wantEvents:    []string{"SuccessfulCreate web-1", "FailedScheduling web-1-a"},
+			wantTruncated: true,
+		},
+		{
+			name:       "since",
+			ns:         "default",
+			opts:       EventOptions{Since: 150 * time.Second},
+			wantEvents: []string{"BackOff api-1-a", "FailedScheduling web-1-a"},
+		},
+		{
+			name:    "owner chain without a name",
+			opts:    EventOptions{Kind: "deploy", OwnerChain: true},
+			wantErr: ErrInvalidEventOptions,
+		},
+		{
+			name:    "unknown kind",
+			opts:    EventOptions{Kind: "widgets", Name: "web"},
+			wantErr: ErrUnknownResource,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeline, err := r.ListEvents(tt.ns, tt.opts)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ListEvents() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ListEvents() error = %v", err)
+			}
+			if !reflect.DeepEqual(timeline.Objects, tt.wantObjects) {
+				t.Errorf("ListEvents() objects = %v, want %v", timeline.Objects, tt.wantObjects)
+			}
+			events := []string{}
+			for _, e := range timeline.Events {
+				events = append(events, e.Message)
+			}
+			if !reflect.DeepEqual(events, tt.wantEvents) {
+				t.Errorf("ListEvents() events = %v, want %v", events, tt.wantEvents)
+			}
+			if timeline.Truncated != tt.wantTruncated {
+				t.Errorf("ListEvents() truncated = %v, want %v", timeline.Truncated, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestOwnedObjects(t *testing.T) {
+	objs := fixtureObjects(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {namespace: default, name: web, uid: deploy-web}
+---
+apiVersion: apps/v1
+kind: ReplicaSet
+metadata:
+  namespace: default
+  name: web-2
+  uid: rs-web-2
+  ownerReferences: [{apiVersion: apps/v1, kind: Deployment, name: web, uid: deploy-web}]
+---
+apiVersion: apps/v1
+kind: ReplicaSet
+metadata:
+  namespace: default
+  name: web-1
+  uid: rs-web-1
+  ownerReferences: [{apiVersion: apps/v1, kind: Deployment, name: web, uid: deploy-web}]
+---
+apiVersion: apps/v1
+kind: ReplicaSet
+metadata:
+  # Owned by a deployment of the same name in another namespace
+  namespace: shop
+  name: web-3
+  uid: rs-web-3
+  ownerReferences: [{apiVersion: apps/v1, kind: Deployment, name: web, uid: deploy-web-shop}]
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  namespace: default
+  name: web-2-b
+  uid: pod-web-2-b
+  ownerReferences: [{apiVersion: apps/v1, kind: ReplicaSet, name: web-2, uid: rs-web-2}]
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  namespace: default
+  name: web-1-a
+  uid: pod-web-1-a
+  ownerReferences: [{apiVersion: apps/v1, kind: ReplicaSet, name: web-1, uid: rs-web-1}]
+---
+apiVersion: v1
+kind: Pod
+metadata: {namespace: default, name: standalone, uid: pod-standalone}
+`)
+	r, _ := fakeResourceService(t, objs...)
+
+	owned, err := r.ownedObjects(objs[0], schema.GroupKind{Group: "apps", Kind: "Deployment"})
+	if err != nil {
+		t.Fatalf("ownedObjects() error = %v", err)
+	}
+	// Closest first, by name within each level
+	want := []string{
+		"ReplicaSet/default/web-1 <- deploy-web",
+		"ReplicaSet/default/web-2 <- deploy-web",
+		"Pod/default/web-1-a <- rs-web-1",
+		"Pod/default/web-2-b <- rs-web-2",
+	}
+	got := []string{}
+	for _, obj := range owned {
+		got = append(got, fmt.Sprintf("%s <- %s", obj.ref, obj.owner))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ownedObjects() = %v, want %v", got, want)
+	}
+
+	// Pods own nothing
+	owned, err = r.ownedObjects(objs[len(objs)-1], podKind)
+	if err != nil || len(owned) != 0 {
+		t.Errorf("ownedObjects(pod) = %v, %v, want nothing", owned, err)
+	}
+}
+
+func TestConvertEvent(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	first := created.Add(time.Minute)
+	last := created.Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		event eventsv1.Event
+		want  Event
+	}{
+		{
+			name: "recorded through the core API",
+			event: eventsv1.Event{
+				ObjectMeta:               metav1.ObjectMeta{Namespace: "default", CreationTimestamp: metav1.NewTime(created)},
+				Regarding:                v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "web"},
+				Type:                     "Warning",
+				Reason:                   "BackOff",
+				Note:                     "Back-off restarting failed container",
+				DeprecatedSource:         v1.EventSource{Component: "kubelet"},
+				DeprecatedFirstTimestamp: metav1.NewTime(first),
+				DeprecatedLastTimestamp:  metav1.NewTime(last),
+				DeprecatedCount:          12,
+			},
+			want: Event{
+				Namespace: "default", Kind: "Pod", Name: "web", Type: "Warning", Reason: "BackOff",
+				Message: "Back-off restarting failed container", Source: "kubelet",
+				Count: 12, FirstSeen: first, LastSeen: last,
+			},
+		},
+		{
+			name: "series",
+			event: eventsv1.Event{
+				ObjectMeta:          metav1.ObjectMeta{Namespace: "default", CreationTimestamp: metav1.NewTime(created)},
+				Regarding:           v1.ObjectReference{Kind: "Node", Name: "node-1"},
+				Type:                "Warning",
+				Reason:              "MemoryPressure",
+				Action:              "Evict",
+				ReportingController: "kubelet",
+				DeprecatedSource:    v1.EventSource{Component: "ignored"},
+				EventTime:           metav1.NewMicroTime(first),
+				Series:              &eventsv1.EventSeries{Count: 7, LastObservedTime: metav1.NewMicroTime(last)},
+				DeprecatedCount:     2,
+			},
+			want: Event{
+				Namespace: "default", Kind: "Node", Name: "node-1", Type: "Warning", Reason: "MemoryPressure",
+				Action: "Evict", Source: "kubelet", Count: 7, FirstSeen: first, LastSeen: last,
+			},
+		},
+		{
+			name: "event time only",
+			event: eventsv1.Event{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", CreationTimestamp: metav1.NewTime(created)},
+				EventTime:  metav1.NewMicroTime(first),
+			},
+			want: Event{Namespace: "default", Count: 1, FirstSeen: first, LastSeen: first},
+		},
+		{
+			name: "creation timestamp only",
+			event: eventsv1.Event{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", CreationTimestamp: metav1.NewTime(created)},
+			},
+			want: Event{Namespace: "default", Count: 1, FirstSeen: created, LastSeen: created},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := convertEvent(&tt.event)
+			if !got.FirstSeen.Equal(tt.want.FirstSeen) || !got.LastSeen.Equal(tt.want.LastSeen) {
+				t.Errorf("convertEvent() seen %v to %v, want %v to %v", got.FirstSeen, got.LastSeen, tt.want.FirstSeen, tt.want.LastSeen)
+			}
+			got.FirstSeen, got.LastSeen = tt.want.FirstSeen, tt.want.LastSeen
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("convertEvent() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
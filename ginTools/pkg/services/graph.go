@@ -0,0 +1,625 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// DefaultGraphDepth is how many relations away from the root object a graph reaches unless
+// a depth is given, MaxGraphDepth the most it may reach
+const (
+	DefaultGraphDepth = 2
+	MaxGraphDepth     = 5
+)
+
+// maxGraphNodes caps the objects of a graph
+const maxGraphNodes = 200
+
+// The edge types of a graph, read as "From <type> To"
+const (
+	EdgeOwns    = "owns"
+	EdgeSelects = "selects"
+	EdgeRoutes  = "routes"
+	EdgeMounts  = "mounts"
+	EdgeUses    = "uses"
+	EdgeScales  = "scales"
+	EdgeClaims  = "claims"
+)
+
+var (
+	podKind         = schema.GroupKind{Kind: "Pod"}
+	serviceKind     = schema.GroupKind{Kind: "Service"}
+	configMapKind   = schema.GroupKind{Kind: "ConfigMap"}
+	secretKind      = schema.GroupKind{Kind: "Secret"}
+	pvcKind         = schema.GroupKind{Kind: "PersistentVolumeClaim"}
+	ingressKind     = schema.GroupKind{Group: "networking.k8s.io", Kind: "Ingress"}
+	hpaKind         = schema.GroupKind{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"}
+	statefulSetKind = schema.GroupKind{Group: "apps", Kind: "StatefulSet"}
+)
+
+// podSpecKinds are the kinds that hold a pod spec, directly or in a template
+var podSpecKinds = []schema.GroupKind{
+	podKind,
+	{Group: "apps", Kind: "Deployment"},
+	{Group: "apps", Kind: "ReplicaSet"},
+	statefulSetKind,
+	{Group: "apps", Kind: "DaemonSet"},
+	{Group: "batch", Kind: "Job"},
+	{Group: "batch", Kind: "CronJob"},
+}
+
+// GraphNode is an object of a graph
+type GraphNode struct {
+	ID string `json:"id"`
+	ObjectRef
+	// Status summarizes the state of pods, workloads, claims and autoscalers
+	Status string `json:"status,omitempty"`
+	// Missing is set for objects that are referenced but do not exist
+	Missing bool `json:"missing,omitempty"`
+}
+
+// GraphEdge is a relation between two objects of a graph, by their IDs
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Type string `json:"type"`
+}
+
+// Graph is the objects related to a root object and their relations
+type Graph struct {
+	Root  string      `json:"root"`
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+	// Truncated is set when objects were left out to stay within maxGraphNodes
+	Truncated bool `json:"truncated"`
+}
+
+// GetGraph builds the graph of the objects related to the named object, up to depth
+// relations away: owners and owned objects, Services selecting pods and workloads, Ingress
+// backends, the ConfigMaps, Secrets and PVCs pod specs reference, and autoscaler targets.
+// If ns is empty or AllNamespaces the object is found by name.
+func (r *ResourceService) GetGraph(resourceOrKindArg string, ns string, name string, depth int) (*Graph, error) {
+	if depth <= 0 {
+		depth = DefaultGraphDepth
+	}
+	if depth > MaxGraphDepth {
+		depth = MaxGraphDepth
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	mapping, err := r.mappingFor(resourceOrKindArg, r.restMapper)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := r.namedObjects(ctx, mapping, ns, name, false)
+	if err != nil {
+		return nil, err
+	}
+	root, err := r.client.Resource(mapping.Resource).Namespace(objects[0].Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s %s: %w", strings.ToLower(mapping.GroupVersionKind.Kind), name, err)
+	}
+
+	return buildGraph(newKindLister(r), objects[0], mapping.GroupVersionKind.GroupKind(), root, depth)
+}
+
+// buildGraph follows the relations of root, an object of kind, up to depth relations away,
+// reading the related objects from lister
+func buildGraph(lister *kindLister, rootRef ObjectRef, kind schema.GroupKind, root *unstructured.Unstructured, depth int) (*Graph, error) {
+	b := &graphBuilder{
+		kindLister: lister,
+		depth:      depth,
+		graph:      &Graph{},
+		nodes:      make(map[string]bool),
+		edges:      make(map[GraphEdge]bool),
+	}
+	b.graph.Root = rootRef.String()
+	b.addNode(rootRef, kind, root, 0)
+	for len(b.queue) > 0 {
+		item := b.queue[0]
+		b.queue = b.queue[1:]
+		if err := b.expand(item); err != nil {
+			return nil, err
+		}
+	}
+	return b.graph, nil
+}
+
+// graphItem is an object of a graph whose relations are still to be followed
+type graphItem struct {
+	ref   ObjectRef
+	kind  schema.GroupKind
+	obj   *unstructured.Unstructured
+	depth int
+}
+
+// graphBuilder collects the objects of a graph breadth first
+type graphBuilder struct {
+	*kindLister
+	depth int
+	graph *Graph
+	nodes map[string]bool
+	edges map[GraphEdge]bool
+	queue []graphItem
+}
+
+// addNode adds an object, nil if it is missing, and queues it to follow its relations. It
+// returns false if the graph is full.
+func (b *graphBuilder) addNode(ref ObjectRef, kind schema.GroupKind, obj *unstructured.Unstructured, depth int) bool {
+	id := ref.String()
+	if b.nodes[id] {
+		return true
+	}
+	if len(b.nodes) >= maxGraphNodes {
+		b.graph.Truncated = true
+		return false
+	}
+	b.nodes[id] = true
+	node := GraphNode{ID: id, ObjectRef: ref, Missing: obj == nil}
+	if obj != nil {
+		node.Status = objectStatus(obj, kind)
+		if depth < b.depth {
+			b.queue = append(b.queue, graphItem{ref: ref, kind: kind, obj: obj, depth: depth})
+		}
+	}
+	b.graph.Nodes = append(b.graph.Nodes, node)
+	return true
+}
+
+// link relates item to another object, adding that object one level further away. from
+// tells whether item is the From end of the edge.
+func (b *graphBuilder) link(item graphItem, from bool, edgeType string, kind schema.GroupKind, namespace, name string, obj *unstructured.Unstructured) {
+	if b.clusterScoped[kind] {
+		namespace = ""
+	}
+	ref := ObjectRef{Kind: kind.Kind, Namespace: namespace, Name: name}
+	if !b.addNode(ref, kind, obj, item.depth+1) {
+		return
+	}
+	edge := GraphEdge{From: item.ref.String(), To: ref.String(), Type: edgeType}
+	if !from {
+		edge.From, edge.To = edge.To, edge.From
+	}
+	if !b.edges[edge] {
+		b.edges[edge] = true
+		b.graph.Edges = append(b.graph.Edges, edge)
+	}
+}
+
+// expand follows the relations of an object
+func (b *graphBuilder) expand(item graphItem) error {
+	obj, ns := item.obj, item.ref.Namespace
+
+	// Owners up, owned objects down
+	for _, owner := range obj.GetOwnerReferences() {
+		gv, _ := schema.ParseGroupVersion(owner.APIVersion)
+		kind := schema.GroupKind{Group: gv.Group, Kind: owner.Kind}
+		target, err := b.find(kind, ns, owner.Name)
+		if err != nil {
+			return err
+		}
+		b.link(item, false, EdgeOwns, kind, ns, owner.Name, target)
+	}
+	for _, kind := range ownedKinds[item.kind] {
+		objs, err := b.list(kind, ns)
+		if err != nil {
+			return err
+		}
+		for _, child := range objs {
+			if ownedBy(child, obj.GetUID()) {
+				b.link(item, true, EdgeOwns, kind, ns, child.GetName(), child)
+			}
+		}
+	}
+
+	if spec := podSpec(obj, item.kind); spec != nil {
+		if err := b.expandPodSpec(item, spec); err != nil {
+			return err
+		}
+	}
+
+	switch item.kind {
+	case serviceKind:
+		return b.expandService(item)
+	case ingressKind:
+		for _, name := range ingressBackends(obj) {
+			target, err := b.find(serviceKind, ns, name)
+			if err != nil {
+				return err
+			}
+			b.link(item, true, EdgeRoutes, serviceKind, ns, name, target)
+		}
+	case hpaKind:
+		apiVersion, _, _ := unstructured.NestedString(obj.Object, "spec", "scaleTargetRef", "apiVersion")
+		kindName, _, _ := unstructured.NestedString(obj.Object, "spec", "scaleTargetRef", "kind")
+		name, _, _ := unstructured.NestedString(obj.Object, "spec", "scaleTargetRef", "name")
+		if kindName != "" && name != "" {
+			gv, _ := schema.ParseGroupVersion(apiVersion)
+			kind := schema.GroupKind{Group: gv.Group, Kind: kindName}
+			target, err := b.find(kind, ns, name)
+			if err != nil {
+				return err
+			}
+			b.link(item, true, EdgeScales, kind, ns, name, target)
+		}
+	case configMapKind, secretKind, pvcKind:
+		return b.expandReferenced(item)
+	}
+	return nil
+}
+
+// expandPodSpec follows the references of a pod spec, the Services selecting the pods and
+// the autoscalers scaling the workload
+func (b *graphBuilder) expandPodSpec(item graphItem, spec map[string]interface{}) error {
+	ns := item.ref.Namespace
+	for _, ref := range podSpecRefs(spec) {
+		target, err := b.find(ref.kind, ns, ref.name)
+		if err != nil {
+			return err
+		}
+		b.link(item, true, ref.edge, ref.kind, ns, ref.name, target)
+	}
+	if item.kind == statefulSetKind {
+		if err := b.expandClaimTemplates(item); err != nil {
+			return err
+		}
+	}
+
+	// Owned workloads, such as the ReplicaSets of a Deployment, are reached through their owner
+	if item.kind != podKind && metav1.GetControllerOf(item.obj) != nil {
+		return nil
+	}
+	podLabels := labels.Set(podTemplateLabels(item.obj, item.kind))
+	services, err := b.list(serviceKind, ns)
+	if err != nil {
+		return err
+	}
+	for _, svc := range services {
+		if selector := serviceSelector(svc); selector != nil && selector.Matches(podLabels) {
+			b.link(item, false, EdgeSelects, serviceKind, ns, svc.GetName(), svc)
+		}
+	}
+	hpas, err := b.list(hpaKind, ns)
+	if err != nil {
+		return err
+	}
+	for _, hpa := range hpas {
+		kind, _, _ := unstructured.NestedString(hpa.Object, "spec", "scaleTargetRef", "kind")
+		name, _, _ := unstructured.NestedString(hpa.Object, "spec", "scaleTargetRef", "name")
+		if kind == item.kind.Kind && name == item.ref.Name {
+			b.link(item, false, EdgeScales, hpaKind, ns, hpa.GetName(), hpa)
+		}
+	}
+	return nil
+}
+
+// expandClaimTemplates follows a StatefulSet to the PVCs created from its claim templates
+func (b *graphBuilder) expandClaimTemplates(item graphItem) error {
+	templates, _, _ := unstructured.NestedSlice(item.obj.Object, "spec", "volumeClaimTemplates")
+	if len(templates) == 0 {
+		return nil
+	}
+	claims, err := b.list(pvcKind, item.ref.Namespace)
+	if err != nil {
+		return err
+	}
+	for _, template := range templates {
+		template, ok := template.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(template, "metadata", "name")
+		// Claims are named <template>-<statefulset>-<ordinal>
+		prefix := name + "-" + item.ref.Name + "-"
+		for _, claim := range claims {
+			if strings.HasPrefix(claim.GetName(), prefix) {
+				b.link(item, true, EdgeClaims, pvcKind, item.ref.Namespace, claim.GetName(), claim)
+			}
+		}
+	}
+	return nil
+}
+
+// expandService follows a Service to the pods and workloads it selects and to the
+// Ingresses routing to it
+func (b *graphBuilder) expandService(item graphItem) error {
+	ns := item.ref.Namespace
+	if selector := serviceSelector(item.obj); selector != nil {
+		for _, kind := range podSpecKinds {
+			objs, err := b.list(kind, ns)
+			if err != nil {
+				return err
+			}
+			for _, obj := range objs {
+				if kind != podKind && metav1.GetControllerOf(obj) != nil {
+					continue
+				}
+				if selector.Matches(labels.Set(podTemplateLabels(obj, kind))) {
+					b.link(item, true, EdgeSelects, kind, ns, obj.GetName(), obj)
+				}
+			}
+		}
+	}
+
+	ingresses, err := b.list(ingressKind, ns)
+	if err != nil {
+		return err
+	}
+	for _, ingress := range ingresses {
+		for _, name := range ingressBackends(ingress) {
+			if name == item.ref.Name {
+				b.link(item, false, EdgeRoutes, ingressKind, ns, ingress.GetName(), ingress)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// expandReferenced follows a ConfigMap, Secret or PVC to the pods and workloads whose pod
+// specs reference it
+func (b *graphBuilder) expandReferenced(item graphItem) error {
+	ns := item.ref.Namespace
+	for _, kind := range podSpecKinds {
+		objs, err := b.list(kind, ns)
+		if err != nil {
+			return err
+		}
+		for _, obj := range objs {
+			if kind != podKind && metav1.GetControllerOf(obj) != nil {
+				continue
+			}
+			spec := podSpec(obj, kind)
+			if spec == nil {
+				continue
+			}
+			for _, ref := range podSpecRefs(spec) {
+				if ref.kind == item.kind && ref.name == item.ref.Name {
+					b.link(item, false, ref.edge, kind, ns, obj.GetName(), obj)
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// podSpecPath returns the path of the pod spec in objects of a kind, nil if they have none
+func podSpecPath(kind schema.GroupKind) []string {
+	switch kind.Kind {
+	case "Pod":
+		return []string{"spec"}
+	case "CronJob":
+		return []string{"spec", "jobTemplate", "spec", "template", "spec"}
+	}
+	for _, k := range podSpecKinds {
+		if k == kind {
+			return []string{"spec", "template", "spec"}
+		}
+	}
+	return nil
+}
+
+// podSpec returns the pod spec of an object, nil if it has none
+func podSpec(obj *unstructured.Unstructured, kind schema.GroupKind) map[string]interface{} {
+	path := podSpecPath(kind)
+	if path == nil {
+		return nil
+	}
+	spec, _, _ := unstructured.NestedMap(obj.Object, path...)
+	return spec
+}
+
+// podTemplateLabels returns the labels of a pod, or of the pods a workload creates
+func podTemplateLabels(obj *unstructured.Unstructured, kind schema.GroupKind) map[string]string {
+	path := podSpecPath(kind)
+	if len(path) <= 1 {
+		return obj.GetLabels()
+	}
+	path = append(append([]string(nil), path[:len(path)-1]...), "metadata", "labels")
+	podLabels, _, _ := unstructured.NestedStringMap(obj.Object, path...)
+	return podLabels
+}
+
+// serviceSelector returns the pod selector of a Service, nil if it selects no pods itself
+func serviceSelector(svc *unstructured.Unstructured) labels.Selector {
+	selector, _, _ := unstructured.NestedStringMap(svc.Object, "spec", "selector")
+	if len(selector) == 0 {
+		return nil
+	}
+	return labels.SelectorFromSet(selector)
+}
+
+// ingressBackends returns the names of the Services an Ingress routes to
+func ingressBackends(ingress *unstructured.Unstructured) []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(backend map[string]interface{}) {
+		name, _, _ := unstructured.NestedString(backend, "service", "name")
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	if backend, ok, _ := unstructured.NestedMap(ingress.Object, "spec", "defaultBackend"); ok {
+		add(backend)
+	}
+	rules, _, _ := unstructured.NestedSlice(ingress.Object, "spec", "rules")
+	for _, rule := range rules {
+		rule, _ := rule.(map[string]interface{})
+		paths, _, _ := unstructured.NestedSlice(rule, "http", "paths")
+		for _, path := range paths {
+			path, _ := path.(map[string]interface{})
+			if backend, ok, _ := unstructured.NestedMap(path, "backend"); ok {
+				add(backend)
+			}
+		}
+	}
+	return names
+}
+
+// podSpecRef is an object a pod spec references
+type podSpecRef struct {
+	kind schema.GroupKind
+	name string
+	// edge is EdgeMounts for volumes and EdgeUses for environment and pull secrets
+	edge string
+}
+
+// podSpecRefs returns the ConfigMaps, Secrets and PVCs a pod spec references, each once
+func podSpecRefs(spec map[string]interface{}) []podSpecRef {
+	var refs []podSpecRef
+	seen := make(map[podSpecRef]bool)
+	add := func(kind schema.GroupKind, name, edge string) {
+		ref := podSpecRef{kind, name, edge}
+		if name != "" && !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	volumes, _, _ := unstructured.NestedSlice(spec, "volumes")
+	for _, volume := range volumes {
+		volume, _ := volume.(map[string]interface{})
+		name, _, _ := unstructured.NestedString(volume, "configMap", "name")
+		add(configMapKind, name, EdgeMounts)
+		name, _, _ = unstructured.NestedString(volume, "secret", "secretName")
+		add(secretKind, name, EdgeMounts)
+		name, _, _ = unstructured.NestedString(volume, "persistentVolumeClaim", "claimName")
+		add(pvcKind, name, EdgeMounts)
+		sources, _, _ := unstructured.NestedSlice(volume, "projected", "sources")
+		for _, source := range sources {
+			source, _ := source.(map[string]interface{})
+			name, _, _ := unstructured.NestedString(source, "configMap", "name")
+			add(configMapKind, name, EdgeMounts)
+			name, _, _ = unstructured.NestedString(source, "secret", "name")
+			add(secretKind, name, EdgeMounts)
+		}
+	}
+
+	for _, field := range []string{"initContainers", "containers"} {
+		containers, _, _ := unstructured.NestedSlice(spec, field)
+		for _, container := range containers {
+			container, _ := container.(map[string]interface{})
+			env, _, _ := unstructured.NestedSlice(container, "env")
+			for _, v := range env {
+				v, _ := v.(map[string]interface{})
+				name, _, _ := unstructured.NestedString(v, "valueFrom", "configMapKeyRef", "name")
+				add(configMapKind, name, EdgeUses)
+				name, _, _ = unstructured.NestedString(v, "valueFrom", "secretKeyRef", "name")
+				add(secretKind, name, EdgeUses)
+			}
+			envFrom, _, _ := unstructured.NestedSlice(container, "envFrom")
+			for _, source := range envFrom {
+				source, _ := source.(map[string]interface{})
+				name, _, _ := unstructured.NestedString(source, "configMapRef", "name")
+				add(configMapKind, name, EdgeUses)
+				name, _, _ = unstructured.NestedString(source, "secretRef", "name")
+				add(secretKind, name, EdgeUses)
+			}
+		}
+	}
+
+	pullSecrets, _, _ := unstructured.NestedSlice(spec, "imagePullSecrets")
+	for _, secret := range pullSecrets {
+		secret, _ := secret.(map[string]interface{})
+		name, _, _ := unstructured.NestedString(secret, "name")
+		add(secretKind, name, EdgeUses)
+	}
+	return refs
+}
+
+// objectStatus summarizes the state of pods, workloads, claims and autoscalers, empty for
+// other kinds
+func objectStatus(obj *unstructured.Unstructured, kind schema.GroupKind) string {
+	switch kind.Kind {
+	case "Pod":
+		statuses, _, _ := unstructured.NestedSlice(obj.Object, "status", "containerStatuses")
+		for _, status := range statuses {
+			status, _ := status.(map[string]interface{})
+			if reason, _, _ := unstructured.NestedString(status, "state", "waiting", "reason"); reason != "" {
+				return reason
+			}
+			if reason, _, _ := unstructured.NestedString(status, "state", "terminated", "reason"); reason != "" && reason != "Completed" {
+				return reason
+			}
+		}
+		phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+		return phase
+	case "Deployment", "ReplicaSet", "StatefulSet":
+		replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+		if !found {
+			replicas = 1
+		}
+		ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+		return fmt.Sprintf("%d/%d ready", ready, replicas)
+	case "DaemonSet":
+		desired, _, _ := unstructured.NestedInt64(obj.Object, "status", "desiredNumberScheduled")
+		ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "numberReady")
+		return fmt.Sprintf("%d/%d ready", ready, desired)
+	case "PersistentVolumeClaim":
+		phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+		return phase
+	case "HorizontalPodAutoscaler":
+		current, _, _ := unstructured.NestedInt64(obj.Object, "status", "currentReplicas")
+		desired, _, _ := unstructured.NestedInt64(obj.Object, "status", "desiredReplicas")
+		return fmt.Sprintf("%d replicas, %d desired", current, desired)
+	}
+	return ""
+}
+
+// Text renders the graph compactly, one relation per line. Objects in the root's namespace
+// are shown without it, and the status of an object only where it is first named.
+func (g *Graph) Text() string {
+	nodes := make(map[string]GraphNode, len(g.Nodes))
+	for _, node := range g.Nodes {
+		nodes[node.ID] = node
+	}
+	root := nodes[g.Root]
+	named := make(map[string]bool, len(g.Nodes))
+	label := func(id string) string {
+		node := nodes[id]
+		name := node.Name
+		if node.Namespace != "" && node.Namespace != root.Namespace {
+			name = node.Namespace + "/" + name
+		}
+		s := node.Kind + " " + name
+		if named[id] {
+			return s
+		}
+		named[id] = true
+		switch {
+		case node.Missing:
+			s += " [missing]"
+		case node.Status != "":
+			s += " [" + node.Status + "]"
+		}
+		return s
+	}
+
+	var b strings.Builder
+	b.WriteString("root: " + label(g.Root))
+	if root.Namespace != "" {
+		b.WriteString(" in namespace " + root.Namespace)
+	}
+	b.WriteByte('\n')
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&b, "%s --%s--> %s\n", label(edge.From), edge.Type, label(edge.To))
+	}
+	if len(g.Edges) == 0 {
+		b.WriteString("no related objects\n")
+	}
+	if g.Truncated {
+		fmt.Fprintf(&b, "(truncated at %d objects; ask for a smaller depth or a more specific object)\n", maxGraphNodes)
+	}
+	return b.String()
+}
@@ -0,0 +1,514 @@
+package services
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// fixtureObjects decodes a manifest into objects the way the API server returns them, with
+// integer numbers
+func fixtureObjects(t *testing.T, manifest string) []*unstructured.Unstructured {
+	t.Helper()
+	var objs []*unstructured.Unstructured
+	for _, doc := range strings.Split(manifest, "\n---\n") {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		data, err := yaml.YAMLToJSON([]byte(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(data); err != nil {
+			t.Fatalf("invalid fixture %s: %v", doc, err)
+		}
+		objs = append(objs, obj)
+	}
+	return objs
+}
+
+// fixtureKinds are the kinds a fixture lister answers for, with no objects if the
+// fixtures hold none
+var fixtureKinds = []schema.GroupKind{
+	podKind, serviceKind, configMapKind, secretKind, pvcKind, ingressKind, hpaKind,
+	{Group: "apps", Kind: "Deployment"},
+	{Group: "apps", Kind: "ReplicaSet"},
+	statefulSetKind,
+	{Group: "apps", Kind: "DaemonSet"},
+	{Group: "batch", Kind: "Job"},
+	{Group: "batch", Kind: "CronJob"},
+	{Kind: "ReplicationController"},
+	{Kind: "Event"},
+	{Group: "discovery.k8s.io", Kind: "EndpointSlice"},
+}
+
+// fixtureLister returns a kindLister listing objs in ns, or in all namespaces if ns is
+// empty, instead of reading the cluster
+func fixtureLister(ns string, objs []*unstructured.Unstructured) *kindLister {
+	l := newKindLister(nil)
+	for _, kind := range fixtureKinds {
+		l.lists[kind.String()+"/"+ns] = nil
+	}
+	for _, obj := range objs {
+		if ns != "" && obj.GetNamespace() != ns {
+			continue
+		}
+		kind := obj.GroupVersionKind().GroupKind()
+		key := kind.String() + "/" + ns
+		l.lists[key] = append(l.lists[key], obj)
+	}
+	return l
+}
+
+const graphFixtures = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: shop
+  uid: deploy-web
+spec:
+  replicas: 2
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: app
+        image: shop/web:1.0
+        env:
+        - name: DB_PASSWORD
+          valueFrom:
+            secretKeyRef:
+              name: db-creds
+              key: password
+      volumes:
+      - name: settings
+        configMap:
+          name: settings
+status:
+  readyReplicas: 1
+---
+apiVersion: apps/v1
+kind: ReplicaSet
+metadata:
+  name: web-7f9c
+  namespace: shop
+  uid: rs-web
+  ownerReferences:
+  - apiVersion: apps/v1
+    kind: Deployment
+    name: web
+    uid: deploy-web
+    controller: true
+spec:
+  replicas: 2
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: app
+        image: shop/web:1.0
+status:
+  readyReplicas: 1
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-7f9c-x2x9p
+  namespace: shop
+  labels:
+    app: web
+  ownerReferences:
+  - apiVersion: apps/v1
+    kind: ReplicaSet
+    name: web-7f9c
+    uid: rs-web
+    controller: true
+spec:
+  containers:
+  - name: app
+    image: shop/web:1.0
+status:
+  phase: Running
+  containerStatuses:
+  - name: app
+    state:
+      waiting:
+        reason: CrashLoopBackOff
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: shop
+spec:
+  selector:
+    app: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: api
+  namespace: shop
+spec:
+  selector:
+    app: api
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: shop
+  namespace: shop
+spec:
+  rules:
+  - http:
+      paths:
+      - path: /
+        backend:
+          service:
+            name: web
+      - path: /api
+        backend:
+          service:
+            name: api
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: shop
+---
+apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: web
+  namespace: shop
+spec:
+  scaleTargetRef:
+    apiVersion: apps/v1
+    kind: Deployment
+    name: web
+status:
+  currentReplicas: 2
+  desiredReplicas: 3
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: other
+spec:
+  selector:
+    app: web
+`
+
+func TestBuildGraph(t *testing.T) {
+	objs := fixtureObjects(t, graphFixtures)
+	find := func(kind, name string) *unstructured.Unstructured {
+		for _, obj := range objs {
+			if obj.GetKind() == kind && obj.GetName() == name && obj.GetNamespace() == "shop" {
+				return obj
+			}
+		}
+		t.Fatalf("no fixture %s %s", kind, name)
+		return nil
+	}
+
+	tests := []struct {
+		name  string
+		kind  schema.GroupKind
+		root  *unstructured.Unstructured
+		depth int
+		// wantEdges are the relations, sorted
+		wantEdges []string
+		// wantNodes are the objects with their status, sorted
+		wantNodes []string
+	}{
+		{
+			name:  "deployment, one relation away",
+			kind:  schema.GroupKind{Group: "apps", Kind: "Deployment"},
+			root:  find("Deployment", "web"),
+			depth: 1,
+			wantEdges: []string{
+				"Deployment/shop/web --mounts--> ConfigMap/shop/settings",
+				"Deployment/shop/web --owns--> ReplicaSet/shop/web-7f9c",
+				"Deployment/shop/web --uses--> Secret/shop/db-creds",
+				"HorizontalPodAutoscaler/shop/web --scales--> Deployment/shop/web",
+				"Service/shop/web --selects--> Deployment/shop/web",
+			},
+			wantNodes: []string{
+				"ConfigMap/shop/settings",
+				"Deployment/shop/web [1/2 ready]",
+				"HorizontalPodAutoscaler/shop/web [2 replicas, 3 desired]",
+				"ReplicaSet/shop/web-7f9c [1/2 ready]",
+				"Secret/shop/db-creds [missing]",
+				"Service/shop/web",
+			},
+		},
+		{
+			name:  "deployment, two relations away",
+			kind:  schema.GroupKind{Group: "apps", Kind: "Deployment"},
+			root:  find("Deployment", "web"),
+			depth: 2,
+			wantEdges: []string{
+				"Deployment/shop/web --mounts--> ConfigMap/shop/settings",
+				"Deployment/shop/web --owns--> ReplicaSet/shop/web-7f9c",
+				"Deployment/shop/web --uses--> Secret/shop/db-creds",
+				"HorizontalPodAutoscaler/shop/web --scales--> Deployment/shop/web",
+				"Ingress/shop/shop --routes--> Service/shop/web",
+				"ReplicaSet/shop/web-7f9c --owns--> Pod/shop/web-7f9c-x2x9p",
+				"Service/shop/web --selects--> Deployment/shop/web",
+				"Service/shop/web --selects--> Pod/shop/web-7f9c-x2x9p",
+			},
+			wantNodes: []string{
+				"ConfigMap/shop/settings",
+				"Deployment/shop/web [1/2 ready]",
+				"HorizontalPodAutoscaler/shop/web [2 replicas, 3 desired]",
+				"Ingress/shop/shop",
+				"Pod/shop/web-7f9c-x2x9p [CrashLoopBackOff]",
+				"ReplicaSet/shop/web-7f9c [1/2 ready]",
+				"Secret/shop/db-creds [missing]",
+				"Service/shop/web",
+			},
+		},
+		{
+			name:  "pod, up to its owners",
+			kind:  podKind,
+			root:  find("Pod", "web-7f9c-x2x9p"),
+			depth: 2,
+			wantEdges: []string{
+				"Deployment/shop/web --owns--> ReplicaSet/shop/web-7f9c",
+				"Ingress/shop/shop --routes--> Service/shop/web",
+				"ReplicaSet/shop/web-7f9c --owns--> Pod/shop/web-7f9c-x2x9p",
+				"Service/shop/web --selects--> Deployment/shop/web",
+				"Service/shop/web --selects--> Pod/shop/web-7f9c-x2x9p",
+			},
+			wantNodes: []string{
+				"Deployment/shop/web [1/2 ready]",
+				"Ingress/shop/shop",
+				"Pod/shop/web-7f9c-x2x9p [CrashLoopBackOff]",
+				"ReplicaSet/shop/web-7f9c [1/2 ready]",
+				"Service/shop/web",
+			},
+		},
+		{
+			name:  "configmap, to the workloads mounting it",
+			kind:  configMapKind,
+			root:  find("ConfigMap", "settings"),
+			depth: 1,
+			wantEdges: []string{
+				"Deployment/shop/web --mounts--> ConfigMap/shop/settings",
+			},
+			wantNodes: []string{
+				"ConfigMap/shop/settings",
+				"Deployment/shop/web [1/2 ready]",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rootRef := ObjectRef{Kind: tt.kind.Kind, Namespace: "shop", Name: tt.root.GetName()}
+			graph, err := buildGraph(fixtureLister("shop", objs), rootRef, tt.kind, tt.root, tt.depth)
+			if err != nil {
+				t.Fatalf("buildGraph() error = %v", err)
+			}
+			if graph.Root != rootRef.String() {
+				t.Errorf("buildGraph() root = %s, want %s", graph.Root, rootRef)
+			}
+
+			var edges, nodes []string
+			for _, edge := range graph.Edges {
+				edges = append(edges, edge.From+" --"+edge.Type+"--> "+edge.To)
+			}
+			for _, node := range graph.Nodes {
+				switch {
+				case node.Missing:
+					nodes = append(nodes, node.ID+" [missing]")
+				case node.Status != "":
+					nodes = append(nodes, node.ID+" ["+node.Status+"]")
+				default:
+					nodes = append(nodes, node.ID)
+				}
+			}
+			sort.Strings(edges)
+			sort.Strings(nodes)
+			if !reflect.DeepEqual(edges, tt.wantEdges) {
+				t.Errorf("buildGraph() edges =\n%s\nwant\n%s", strings.Join(edges, "\n"), strings.Join(tt.wantEdges, "\n"))
+			}
+			if !reflect.DeepEqual(nodes, tt.wantNodes) {
+				t.Errorf("buildGraph() nodes =\n%s\nwant\n%s", strings.Join(nodes, "\n"), strings.Join(tt.wantNodes, "\n"))
+			}
+		})
+	}
+}
+
+func TestPodSpecRefs(t *testing.T) {
+	objs := fixtureObjects(t, `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  volumes:
+  - name: settings
+    configMap:
+      name: settings
+  - name: tls
+    secret:
+      secretName: tls
+  - name: data
+    persistentVolumeClaim:
+      claimName: data
+  - name: bundle
+    projected:
+      sources:
+      - configMap:
+          name: ca
+      - secret:
+          name: tls
+  initContainers:
+  - name: migrate
+    envFrom:
+    - secretRef:
+        name: db-creds
+  containers:
+  - name: app
+    env:
+    - name: MODE
+      valueFrom:
+        configMapKeyRef:
+          name: settings
+          key: mode
+    - name: PASSWORD
+      valueFrom:
+        secretKeyRef:
+          name: db-creds
+          key: password
+    envFrom:
+    - configMapRef:
+        name: features
+  imagePullSecrets:
+  - name: registry
+`)
+	var got []string
+	for _, ref := range podSpecRefs(podSpec(objs[0], podKind)) {
+		got = append(got, ref.edge+" "+ref.kind.Kind+"/"+ref.name)
+	}
+	want := []string{
+		"mounts ConfigMap/settings",
+		"mounts Secret/tls",
+		"mounts PersistentVolumeClaim/data",
+		"mounts ConfigMap/ca",
+		"uses Secret/db-creds",
+		"uses ConfigMap/settings",
+		"uses ConfigMap/features",
+		"uses Secret/registry",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("podSpecRefs() = %v, want %v", got, want)
+	}
+}
+
+func TestIngressBackends(t *testing.T) {
+	objs := fixtureObjects(t, `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: shop
+spec:
+  defaultBackend:
+    service:
+      name: fallback
+  rules:
+  - http:
+      paths:
+      - backend:
+          service:
+            name: web
+      - backend:
+          resource:
+            kind: StorageBucket
+            name: assets
+  - host: api.example.com
+    http:
+      paths:
+      - backend:
+          service:
+            name: web
+      - backend:
+          service:
+            name: api
+`)
+	got := ingressBackends(objs[0])
+	want := []string{"fallback", "web", "api"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ingressBackends() = %v, want %v", got, want)
+	}
+}
+
+func TestGraphText(t *testing.T) {
+	node := func(kind, ns, name, status string, missing bool) GraphNode {
+		ref := ObjectRef{Kind: kind, Namespace: ns, Name: name}
+		return GraphNode{ID: ref.String(), ObjectRef: ref, Status: status, Missing: missing}
+	}
+
+	tests := []struct {
+		name  string
+		graph Graph
+		want  string
+	}{
+		{
+			name: "relations",
+			graph: Graph{
+				Root: "Deployment/shop/web",
+				Nodes: []GraphNode{
+					node("Deployment", "shop", "web", "1/2 ready", false),
+					node("ReplicaSet", "shop", "web-7f9c", "1/2 ready", false),
+					node("Secret", "shop", "db-creds", "", true),
+					node("Service", "edge", "web", "", false),
+				},
+				Edges: []GraphEdge{
+					{From: "Deployment/shop/web", To: "ReplicaSet/shop/web-7f9c", Type: EdgeOwns},
+					{From: "Deployment/shop/web", To: "Secret/shop/db-creds", Type: EdgeUses},
+					{From: "Service/edge/web", To: "Deployment/shop/web", Type: EdgeSelects},
+				},
+			},
+			want: `root: Deployment web [1/2 ready] in namespace shop
+Deployment web --owns--> ReplicaSet web-7f9c [1/2 ready]
+Deployment web --uses--> Secret db-creds [missing]
+Service edge/web --selects--> Deployment web
+`,
+		},
+		{
+			name: "no relations, truncated",
+			graph: Graph{
+				Root:      "Namespace/shop",
+				Nodes:     []GraphNode{node("Namespace", "", "shop", "", false)},
+				Truncated: true,
+			},
+			want: `root: Namespace shop
+no related objects
+(truncated at 200 objects; ask for a smaller depth or a more specific object)
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.graph.Text(); got != tt.want {
+				t.Errorf("Text() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
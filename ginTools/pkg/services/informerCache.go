@@ -0,0 +1,143 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// DefaultInformerTTL is how long an informer is kept running without being used
+const DefaultInformerTTL = 10 * time.Minute
+
+// InformerCache watches the resources that are being listed. The informer of a resource
+// is started the first time it is listed and stopped once it has not been used for the
+// TTL, so memory stays bounded to what clients actually look at. Resources that cannot be
+// watched, because they lack the list and watch verbs or the service account may not list
+// them, are listed from the API server for a TTL before the informer is tried again.
+type InformerCache struct {
+	client dynamic.Interface
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	entries map[schema.GroupVersionResource]*informerEntry
+	// unwatchable holds when the informer of each resource that cannot be watched gave up
+	unwatchable map[schema.GroupVersionResource]time.Time
+}
+
+// informerEntry is a running informer. Each has a factory of its own because a shared
+// factory can only stop all of its informers at once.
+type informerEntry struct {
+	factory  dynamicinformer.DynamicSharedInformerFactory
+	informer informers.GenericInformer
+	stop     chan struct{}
+	lastUsed time.Time
+}
+
+// NewInformerCache creates an InformerCache evicting informers idle for ttl, or for
+// DefaultInformerTTL if ttl is not positive
+func NewInformerCache(client dynamic.Interface, ttl time.Duration) *InformerCache {
+	if ttl <= 0 {
+		ttl = DefaultInformerTTL
+	}
+	return &InformerCache{
+		client:      client,
+		ttl:         ttl,
+		entries:     make(map[schema.GroupVersionResource]*informerEntry),
+		unwatchable: make(map[schema.GroupVersionResource]time.Time),
+	}
+}
+
+// Lister returns the lister of gvr's informer, starting the informer on first use.
+// synced is false until the informer has listed the resource once, and always for
+// resources that cannot be watched; callers then list from the API server instead.
+func (c *InformerCache) Lister(gvr schema.GroupVersionResource) (lister cache.GenericLister, synced bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if since, ok := c.unwatchable[gvr]; ok {
+		if time.Since(since) < c.ttl {
+			return nil, false
+		}
+		delete(c.unwatchable, gvr)
+	}
+
+	e, ok := c.entries[gvr]
+	if !ok {
+		factory := dynamicinformer.NewDynamicSharedInformerFactory(c.client, 0)
+		e = &informerEntry{
+			factory:  factory,
+			informer: factory.ForResource(gvr),
+			stop:     make(chan struct{}),
+		}
+		entry := e
+		// The informer has not started, so setting the handler cannot fail
+		_ = e.informer.Informer().SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+			if apierrors.IsForbidden(err) || apierrors.IsMethodNotSupported(err) {
+				// Stopping waits for this goroutine of the informer, so it runs in another
+				go c.giveUp(gvr, entry)
+				return
+			}
+			cache.DefaultWatchErrorHandler(r, err)
+		})
+		factory.Start(e.stop)
+		c.entries[gvr] = e
+	}
+	e.lastUsed = time.Now()
+	return e.informer.Lister(), e.informer.Informer().HasSynced()
+}
+
+// giveUp stops e, the informer of gvr, which may not list or watch it
+func (c *InformerCache) giveUp(gvr schema.GroupVersionResource, e *informerEntry) {
+	c.mu.Lock()
+	if c.entries[gvr] != e {
+		// Already evicted
+		c.mu.Unlock()
+		return
+	}
+	delete(c.entries, gvr)
+	c.unwatchable[gvr] = time.Now()
+	c.mu.Unlock()
+
+	close(e.stop)
+	e.factory.Shutdown()
+}
+
+// Run evicts idle informers until stop is closed, then stops all informers
+func (c *InformerCache) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(c.ttl / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			c.evict(func(*informerEntry) bool { return true })
+			return
+		case now := <-ticker.C:
+			c.evict(func(e *informerEntry) bool { return now.Sub(e.lastUsed) > c.ttl })
+		}
+	}
+}
+
+// evict stops the informers for which idle returns true
+func (c *InformerCache) evict(idle func(*informerEntry) bool) {
+	var stopped []*informerEntry
+	c.mu.Lock()
+	for gvr, e := range c.entries {
+		if idle(e) {
+			delete(c.entries, gvr)
+			stopped = append(stopped, e)
+		}
+	}
+	c.mu.Unlock()
+
+	// Shutdown waits for the informer goroutines, so it runs outside the lock
+	for _, e := range stopped {
+		close(e.stop)
+		e.factory.Shutdown()
+	}
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubetesting "k8s.io/client-go/testing"
+)
+
+var (
+	podsGVR     = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	podsMapping = &meta.RESTMapping{Resource: podsGVR, GroupVersionKind: podsGVR.GroupVersion().WithKind("Pod"), Scope: meta.RESTScopeNamespace}
+)
+
+// fakePodClient returns a fake dynamic client serving the pods of listPods
+func fakePodClient() *dynamicfake.FakeDynamicClient {
+	var objs []runtime.Object
+	for _, pod := range listPods() {
+		pod.SetLabels(map[string]string{"app": pod.GetName()})
+		objs = append(objs, pod)
+	}
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{podsGVR: "PodList"}, objs...)
+}
+
+// eventually fails the test unless cond becomes true within a few seconds
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// entry returns the running informer of gvr, nil if there is none
+func (c *InformerCache) entry(gvr schema.GroupVersionResource) *informerEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[gvr]
+}
+
+func TestInformerCacheStartsLazily(t *testing.T) {
+	informers := NewInformerCache(fakePodClient(), time.Minute)
+	defer informers.evict(func(*informerEntry) bool { return true })
+
+	if informers.entry(podsGVR) != nil {
+		t.Fatal("NewInformerCache() started an informer")
+	}
+	informers.Lister(podsGVR)
+	if informers.entry(podsGVR) == nil {
+		t.Fatal("Lister() did not start the informer")
+	}
+	eventually(t, "the informer has synced", func() bool {
+		_, synced := informers.Lister(podsGVR)
+		return synced
+	})
+	lister, _ := informers.Lister(podsGVR)
+	if all, _ := lister.List(labels.Everything()); len(all) != len(listPods()) {
+		t.Errorf("informer lists %d pods, want %d", len(all), len(listPods()))
+	}
+	if informers.entry(schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}) != nil {
+		t.Error("Lister() started informers of resources nobody listed")
+	}
+}
+
+func TestListObjectsBeforeSync(t *testing.T) {
+	client := fakePodClient()
+	// Hold the informer's list, which has no label selector, so it cannot sync
+	release := make(chan struct{})
+	client.PrependReactor("list", "pods", func(action kubetesting.Action) (bool, runtime.Object, error) {
+		if action.(kubetesting.ListAction).GetListRestrictions().Labels.Empty() {
+			<-release
+		}
+		return false, nil, nil
+	})
+	informers := NewInformerCache(client, time.Minute)
+	defer informers.evict(func(*informerEntry) bool { return true })
+	defer close(release)
+	r := NewResourceService(nil, nil, client, informers)
+
+	selector := labels.SelectorFromSet(labels.Set{"app": "api"})
+	objs, err := r.listObjects(podsMapping, "default", selector)
+	if err != nil {
+		t.Fatalf("listObjects() error = %v", err)
+	}
+	if _, synced := informers.Lister(podsGVR); synced {
+		t.Fatal("the informer synced although its list is held")
+	}
+	if got, want := namespacedNames(objs), []string{"default/api"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("listObjects() before sync = %v, want %v", got, want)
+	}
+}
+
+func TestInformerCacheEvictsIdleInformers(t *testing.T) {
+	informers := NewInformerCache(fakePodClient(), 50*time.Millisecond)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		informers.Run(stop)
+		close(done)
+	}()
+
+	informers.Lister(podsGVR)
+	idle := informers.entry(podsGVR)
+	eventually(t, "the idle informer is evicted", func() bool { return informers.entry(podsGVR) == nil })
+	select {
+	case <-idle.stop:
+	default:
+		t.Error("evicting the informer did not stop it")
+	}
+
+	informers.Lister(podsGVR)
+	running := informers.entry(podsGVR)
+	close(stop)
+	<-done
+	if informers.entry(podsGVR) != nil {
+		t.Error("Run() returned with an informer running")
+	}
+	select {
+	case <-running.stop:
+	default:
+		t.Error("Run() did not stop the informer when stopped")
+	}
+}
+
+func TestInformerCacheGivesUpOnForbidden(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "forbidden", err: apierrors.NewForbidden(podsGVR.GroupResource(), "", nil)},
+		{name: "no list verb", err: apierrors.NewMethodNotSupported(podsGVR.GroupResource(), "list")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fakePodClient()
+			client.PrependReactor("list", "pods", func(kubetesting.Action) (bool, runtime.Object, error) {
+				return true, nil, tt.err
+			})
+			informers := NewInformerCache(client, time.Minute)
+			defer informers.evict(func(*informerEntry) bool { return true })
+			r := NewResourceService(nil, nil, client, informers)
+
+			if _, err := r.listObjects(podsMapping, "default", labels.Everything()); err == nil {
+				t.Fatal("listObjects() succeeded, want the list error")
+			}
+			started := informers.entry(podsGVR)
+			if started == nil {
+				t.Fatal("listObjects() did not start the informer")
+			}
+			eventually(t, "the informer gives up", func() bool { return informers.entry(podsGVR) == nil })
+			<-started.stop
+
+			informers.Lister(podsGVR)
+			if informers.entry(podsGVR) != nil {
+				t.Error("Lister() restarted the informer of a resource it may not watch")
+			}
+		})
+	}
+}
@@ -78,9 +78,14 @@ type PodSummary struct {
 	Node   string `json:"node"`
 }
 
-// GetJobDebugInfo returns comprehensive debug information for a job
+// GetJobDebugInfo returns comprehensive debug information for a job. An empty namespace or
+// AllNamespaces finds the job by name in every namespace.
 func (s *JobDebugService) GetJobDebugInfo(namespace, name string) (*JobDebugInfo, error) {
 	ctx := context.Background()
+	namespace, err := s.jobNamespace(namespace, name)
+	if err != nil {
+		return nil, err
+	}
 
 	// Get the job
 	job, err := s.clientset.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
@@ -140,9 +145,7 @@ func (s *JobDebugService) GetJobByUUID(uuid, namespace string) (*v1.Job, error)
 	}.AsSelector().String()
 
 	// If no namespace specified, search all namespaces
-	if namespace == "" {
-		namespace = metav1.NamespaceAll
-	}
+	namespace = listNamespace(namespace)
 
 	// List jobs with the UUID label
 	jobs, err := s.clientset.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{
@@ -174,6 +177,10 @@ func (s *JobDebugService) GetJobByUUID(uuid, namespace string) (*v1.Job, error)
 // GetJobTraces extracts trace information from job annotations
 func (s *JobDebugService) GetJobTraces(namespace, name string) (*TraceInfo, error) {
 	ctx := context.Background()
+	namespace, err := s.jobNamespace(namespace, name)
+	if err != nil {
+		return nil, err
+	}
 
 	job, err := s.clientset.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
@@ -186,6 +193,10 @@ func (s *JobDebugService) GetJobTraces(namespace, name string) (*TraceInfo, erro
 // GetJobErrors returns error information for a job
 func (s *JobDebugService) GetJobErrors(namespace, name string) (*ErrorInfo, error) {
 	ctx := context.Background()
+	namespace, err := s.jobNamespace(namespace, name)
+	if err != nil {
+		return nil, err
+	}
 
 	job, err := s.clientset.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
@@ -197,6 +208,10 @@ func (s *JobDebugService) GetJobErrors(namespace, name string) (*ErrorInfo, erro
 
 // GetJobSandboxLogs returns sandbox logs for a job
 func (s *JobDebugService) GetJobSandboxLogs(namespace, name string) (*LogInfo, error) {
+	namespace, err := s.jobNamespace(namespace, name)
+	if err != nil {
+		return nil, err
+	}
 	pods, err := s.GetJobPods(namespace, name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get job pods: %w", err)
@@ -208,6 +223,10 @@ func (s *JobDebugService) GetJobSandboxLogs(namespace, name string) (*LogInfo, e
 // GetJobPods returns all pods associated with a job
 func (s *JobDebugService) GetJobPods(namespace, name string) ([]corev1.Pod, error) {
 	ctx := context.Background()
+	namespace, err := s.jobNamespace(namespace, name)
+	if err != nil {
+		return nil, err
+	}
 
 	// Get the job to extract selector
 	job, err := s.clientset.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
@@ -234,6 +253,22 @@ func (s *JobDebugService) GetJobPods(namespace, name string) ([]corev1.Pod, erro
 
 // Helper functions
 
+// jobNamespace returns namespace, or the namespace of the only job named name if namespace
+// is empty or AllNamespaces
+func (s *JobDebugService) jobNamespace(namespace, name string) (string, error) {
+	return resolveNamespace(namespace, "job", name, func(ctx context.Context, opts metav1.ListOptions) ([]string, error) {
+		jobs, err := s.clientset.BatchV1().Jobs(metav1.NamespaceAll).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		namespaces := make([]string, 0, len(jobs.Items))
+		for _, job := range jobs.Items {
+			namespaces = append(namespaces, job.Namespace)
+		}
+		return namespaces, nil
+	})
+}
+
 func (s *JobDebugService) getJobSummary(job *v1.Job) *JobSummary {
 	status := "Unknown"
 	if job.Status.Succeeded > 0 {
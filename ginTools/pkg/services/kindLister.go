@@ -0,0 +1,71 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// kindLister lists objects by kind from the informer cache, reading each kind and namespace
+// once. It serves one request.
+type kindLister struct {
+	r     *ResourceService
+	lists map[string][]*unstructured.Unstructured
+	// clusterScoped records the kinds listed that are not namespaced
+	clusterScoped map[schema.GroupKind]bool
+}
+
+func newKindLister(r *ResourceService) *kindLister {
+	return &kindLister{
+		r:             r,
+		lists:         make(map[string][]*unstructured.Unstructured),
+		clusterScoped: make(map[schema.GroupKind]bool),
+	}
+}
+
+// list returns the objects of a kind in a namespace, none if the cluster does not serve it
+func (l *kindLister) list(kind schema.GroupKind, ns string) ([]*unstructured.Unstructured, error) {
+	key := kind.String() + "/" + ns
+	if objs, ok := l.lists[key]; ok {
+		return objs, nil
+	}
+	mapping, err := (*l.r.restMapper).RESTMapping(kind)
+	if meta.IsNoMatchError(err) {
+		l.lists[key] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.clusterScoped[kind] = mapping.Scope.Name() != meta.RESTScopeNameNamespace
+	objs, err := l.r.listObjects(mapping, ns, labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", mapping.Resource.Resource, err)
+	}
+	sort.Slice(objs, func(i, j int) bool {
+		if objs[i].GetNamespace() != objs[j].GetNamespace() {
+			return objs[i].GetNamespace() < objs[j].GetNamespace()
+		}
+		return objs[i].GetName() < objs[j].GetName()
+	})
+	l.lists[key] = objs
+	return objs, nil
+}
+
+// find returns the named object of a kind in a namespace, nil if it does not exist
+func (l *kindLister) find(kind schema.GroupKind, ns, name string) (*unstructured.Unstructured, error) {
+	objs, err := l.list(kind, ns)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objs {
+		if obj.GetName() == name {
+			return obj, nil
+		}
+	}
+	return nil, nil
+}
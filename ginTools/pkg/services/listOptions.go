@@ -0,0 +1,211 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// ErrInvalidListOptions is wrapped by the errors of list parameters that do not parse
+var ErrInvalidListOptions = errors.New("invalid list options")
+
+// Orders accepted by ListOptions.SortBy; a leading "-" reverses them
+const (
+	SortByCreationTimestamp = "creationTimestamp"
+	SortByName              = "name"
+	SortByRestartCount      = "restartCount"
+)
+
+// ListOptions selects, orders and pages the items of a list
+type ListOptions struct {
+	// LabelSelector and FieldSelector use the kubectl syntax, e.g. app=web,tier!=cache
+	// and status.phase=Running. Field selectors may name any field of the object.
+	LabelSelector string
+	FieldSelector string
+	// Limit caps the number of items returned, 0 returns all
+	Limit int64
+	// Continue is the ListMeta.Continue of the previous page
+	Continue string
+	SortBy   string
+	// Fields projects every item to these paths, e.g. metadata.name and status.phase
+	Fields []string
+}
+
+// ListMeta describes the page of a list
+type ListMeta struct {
+	// Total counts the items matching the selectors over all pages
+	Total int `json:"total"`
+	// Continue is passed to get the next page, empty on the last page
+	Continue string `json:"continue,omitempty"`
+}
+
+// labelSelector parses the label selector, matching everything when it is empty
+func (o ListOptions) labelSelector() (labels.Selector, error) {
+	selector, err := labels.Parse(o.LabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("%w: labelSelector: %v", ErrInvalidListOptions, err)
+	}
+	return selector, nil
+}
+
+// page filters objs by the field selector, sorts them and cuts out the requested page
+func (o ListOptions) page(objs []*unstructured.Unstructured) ([]*unstructured.Unstructured, ListMeta, error) {
+	var meta ListMeta
+
+	selector, err := fields.ParseSelector(o.FieldSelector)
+	if err != nil {
+		return nil, meta, fmt.Errorf("%w: fieldSelector: %v", ErrInvalidListOptions, err)
+	}
+	if !selector.Empty() {
+		matched := objs[:0:0]
+		for _, obj := range objs {
+			if matchFields(obj, selector.Requirements()) {
+				matched = append(matched, obj)
+			}
+		}
+		objs = matched
+	}
+
+	if err := sortObjects(objs, o.SortBy); err != nil {
+		return nil, meta, err
+	}
+
+	offset, err := decodeContinue(o.Continue)
+	if err != nil {
+		return nil, meta, err
+	}
+	meta.Total = len(objs)
+	if offset > len(objs) {
+		offset = len(objs)
+	}
+	end := len(objs)
+	if o.Limit > 0 && int64(end-offset) > o.Limit {
+		end = offset + int(o.Limit)
+		meta.Continue = encodeContinue(end)
+	}
+	return objs[offset:end], meta, nil
+}
+
+// project returns the Fields of obj keyed by their path; fields obj lacks are null. The
+// namespace of a namespaced object is always included, so items listed across all
+// namespaces can be told apart.
+func (o ListOptions) project(obj *unstructured.Unstructured) map[string]interface{} {
+	projection := make(map[string]interface{}, len(o.Fields)+1)
+	for _, path := range o.Fields {
+		value, _, _ := unstructured.NestedFieldNoCopy(obj.Object, strings.Split(path, ".")...)
+		projection[path] = value
+	}
+	if ns := obj.GetNamespace(); ns != "" {
+		projection["metadata.namespace"] = ns
+	}
+	return projection
+}
+
+// matchFields reports whether obj meets every field selector requirement
+func matchFields(obj *unstructured.Unstructured, requirements fields.Requirements) bool {
+	for _, r := range requirements {
+		value, found, _ := unstructured.NestedFieldNoCopy(obj.Object, strings.Split(r.Field, ".")...)
+		actual := ""
+		if found && value != nil {
+			actual = fmt.Sprint(value)
+		}
+		switch r.Operator {
+		case selection.Equals, selection.DoubleEquals:
+			if actual != r.Value {
+				return false
+			}
+		case selection.NotEquals:
+			if actual == r.Value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sortObjects orders objs by sortBy, then by namespace and name. Without sortBy objs are
+// still ordered by namespace and name, so offsets into them stay valid across pages read
+// from an informer, whose lister returns items in map order, or from the API server.
+func sortObjects(objs []*unstructured.Unstructured, sortBy string) error {
+	if sortBy == "" {
+		sortBy = SortByName
+	}
+	reverse := strings.HasPrefix(sortBy, "-")
+	sortBy = strings.TrimPrefix(sortBy, "-")
+
+	key := func(obj *unstructured.Unstructured) string {
+		return obj.GetNamespace() + "/" + obj.GetName()
+	}
+	var less func(a, b *unstructured.Unstructured) bool
+	switch sortBy {
+	case SortByName:
+		less = func(a, b *unstructured.Unstructured) bool { return key(a) < key(b) }
+	case SortByCreationTimestamp:
+		less = func(a, b *unstructured.Unstructured) bool {
+			ta, tb := a.GetCreationTimestamp(), b.GetCreationTimestamp()
+			if ta.Equal(&tb) {
+				return key(a) < key(b)
+			}
+			return ta.Before(&tb)
+		}
+	case SortByRestartCount:
+		less = func(a, b *unstructured.Unstructured) bool {
+			ra, rb := restartCount(a), restartCount(b)
+			if ra == rb {
+				return key(a) < key(b)
+			}
+			return ra < rb
+		}
+	default:
+		return fmt.Errorf("%w: sortBy must be creationTimestamp, name or restartCount, optionally prefixed with -", ErrInvalidListOptions)
+	}
+
+	sort.SliceStable(objs, func(i, j int) bool {
+		if reverse {
+			return less(objs[j], objs[i])
+		}
+		return less(objs[i], objs[j])
+	})
+	return nil
+}
+
+// restartCount sums the restarts of a pod's containers, 0 for other objects
+func restartCount(obj *unstructured.Unstructured) int64 {
+	statuses, _, _ := unstructured.NestedSlice(obj.Object, "status", "containerStatuses")
+	var count int64
+	for _, status := range statuses {
+		if status, ok := status.(map[string]interface{}); ok {
+			restarts, _, _ := unstructured.NestedInt64(status, "restartCount")
+			count += restarts
+		}
+	}
+	return count
+}
+
+// encodeContinue and decodeContinue convert the offset of the next page to an opaque token
+func encodeContinue(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeContinue(token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, fmt.Errorf("%w: continue token is malformed", ErrInvalidListOptions)
+	}
+	offset, err := strconv.Atoi(string(data))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("%w: continue token is malformed", ErrInvalidListOptions)
+	}
+	return offset, nil
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+// listPod builds a pod for the list tests
+func listPod(ns, name, phase string, created time.Time, restarts ...int64) *unstructured.Unstructured {
+	var statuses []interface{}
+	for i, r := range restarts {
+		statuses = append(statuses, map[string]interface{}{"name": fmt.Sprintf("c%d", i), "restartCount": r})
+	}
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"spec":       map[string]interface{}{"nodeName": "node-1"},
+		"status":     map[string]interface{}{"phase": phase, "containerStatuses": statuses},
+	}}
+	obj.SetNamespace(ns)
+	obj.SetName(name)
+	obj.SetCreationTimestamp(metav1.NewTime(created))
+	return obj
+}
+
+// namespacedNames names objs as namespace/name for comparisons
+func namespacedNames(objs []*unstructured.Unstructured) []string {
+	names := []string{}
+	for _, obj := range objs {
+		names = append(names, obj.GetNamespace()+"/"+obj.GetName())
+	}
+	return names
+}
+
+func listPods() []*unstructured.Unstructured {
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return []*unstructured.Unstructured{
+		listPod("shop", "web-b", "Running", t0.Add(2*time.Hour), 0),
+		listPod("shop", "web-a", "Pending", t0.Add(time.Hour)),
+		listPod("default", "job-x", "Failed", t0, 3, 4),
+		listPod("default", "api", "Running", t0.Add(time.Hour), 2),
+	}
+}
+
+func TestSortObjects(t *testing.T) {
+	tests := []struct {
+		sortBy  string
+		want    []string
+		wantErr bool
+	}{
+		// Without sortBy items are still ordered by namespace and name
+		{sortBy: "", want: []string{"default/api", "default/job-x", "shop/web-a", "shop/web-b"}},
+		{sortBy: "name", want: []string{"default/api", "default/job-x", "shop/web-a", "shop/web-b"}},
+		{sortBy: "-name", want: []string{"shop/web-b", "shop/web-a", "default/job-x", "default/api"}},
+		// Equal timestamps fall back to namespace and name
+		{sortBy: "creationTimestamp", want: []string{"default/job-x", "default/api", "shop/web-a", "shop/web-b"}},
+		{sortBy: "-creationTimestamp", want: []string{"shop/web-b", "shop/web-a", "default/api", "default/job-x"}},
+		{sortBy: "restartCount", want: []string{"shop/web-a", "shop/web-b", "default/api", "default/job-x"}},
+		{sortBy: "-restartCount", want: []string{"default/job-x", "default/api", "shop/web-b", "shop/web-a"}},
+		{sortBy: "age", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sortBy, func(t *testing.T) {
+			objs := listPods()
+			err := sortObjects(objs, tt.sortBy)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidListOptions) {
+					t.Fatalf("sortObjects() error = %v, want ErrInvalidListOptions", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("sortObjects() error = %v", err)
+			}
+			if got := namespacedNames(objs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sortObjects() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchFields(t *testing.T) {
+	pod := listPod("shop", "web-a", "Running", time.Now(), 1)
+
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{selector: "status.phase=Running", want: true},
+		{selector: "status.phase==Running,metadata.namespace=shop", want: true},
+		{selector: "status.phase!=Running", want: false},
+		{selector: "spec.nodeName=node-2", want: false},
+		// A field the object lacks compares as empty
+		{selector: "spec.schedulerName=", want: true},
+		{selector: "spec.schedulerName!=default-scheduler", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			selector, err := fields.ParseSelector(tt.selector)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := matchFields(pod, selector.Requirements()); got != tt.want {
+				t.Errorf("matchFields(%s) = %v, want %v", tt.selector, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeContinue(t *testing.T) {
+	tests := []struct {
+		token   string
+		want    int
+		wantErr bool
+	}{
+		{token: "", want: 0},
+		{token: encodeContinue(0), want: 0},
+		{token: encodeContinue(25), want: 25},
+		{token: "not base64!", wantErr: true},
+		{token: encodeContinue(-1), wantErr: true},
+		{token: "YWJj", wantErr: true}, // "abc"
+	}
+
+	for _, tt := range tests {
+		got, err := decodeContinue(tt.token)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidListOptions) {
+				t.Errorf("decodeContinue(%q) error = %v, want ErrInvalidListOptions", tt.token, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("decodeContinue(%q) = %d, %v, want %d", tt.token, got, err, tt.want)
+		}
+	}
+}
+
+func TestPage(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      ListOptions
+		want      []string
+		wantTotal int
+		// wantNext is the offset the continue token points at, -1 for the last page
+		wantNext int
+		wantErr  bool
+	}{
+		{
+			name:      "everything",
+			opts:      ListOptions{},
+			want:      []string{"default/api", "default/job-x", "shop/web-a", "shop/web-b"},
+			wantTotal: 4,
+			wantNext:  -1,
+		},
+		{
+			name:      "first page",
+			opts:      ListOptions{SortBy: "name", Limit: 3},
+			want:      []string{"default/api", "default/job-x", "shop/web-a"},
+			wantTotal: 4,
+			wantNext:  3,
+		},
+		{
+			name:      "last page",
+			opts:      ListOptions{SortBy: "name", Limit: 3, Continue: encodeContinue(3)},
+			want:      []string{"shop/web-b"},
+			wantTotal: 4,
+			wantNext:  -1,
+		},
+		{
+			name:      "limit equal to what is left",
+			opts:      ListOptions{SortBy: "name", Limit: 2, Continue: encodeContinue(2)},
+			want:      []string{"shop/web-a", "shop/web-b"},
+			wantTotal: 4,
+			wantNext:  -1,
+		},
+		{
+			name:      "continue past the end",
+			opts:      ListOptions{Limit: 2, Continue: encodeContinue(10)},
+			want:      []string{},
+			wantTotal: 4,
+			wantNext:  -1,
+		},
+		{
+			name:      "field selector counts only matches",
+			opts:      ListOptions{FieldSelector: "status.phase=Running", SortBy: "-name", Limit: 1},
+			want:      []string{"shop/web-b"},
+			wantTotal: 2,
+			wantNext:  1,
+		},
+		{
+			name:    "invalid field selector",
+			opts:    ListOptions{FieldSelector: "status.phase"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid continue token",
+			opts:    ListOptions{Continue: "%%%"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, meta, err := tt.opts.page(listPods())
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidListOptions) {
+					t.Fatalf("page() error = %v, want ErrInvalidListOptions", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("page() error = %v", err)
+			}
+			if names := namespacedNames(got); !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("page() = %v, want %v", names, tt.want)
+			}
+			if meta.Total != tt.wantTotal {
+				t.Errorf("page() total = %d, want %d", meta.Total, tt.wantTotal)
+			}
+			next := -1
+			if meta.Continue != "" {
+				if next, err = decodeContinue(meta.Continue); err != nil {
+					t.Fatalf("page() continue = %q: %v", meta.Continue, err)
+				}
+			}
+			if next != tt.wantNext {
+				t.Errorf("page() continues at %d, want %d", next, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestListPageFromInformer(t *testing.T) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	mapping := &meta.RESTMapping{
+		Resource:         gvr,
+		GroupVersionKind: gvr.GroupVersion().WithKind("Pod"),
+		Scope:            meta.RESTScopeNamespace,
+	}
+
+	// The pods are created in random order; the informer's lister returns them in map
+	// order on top of that
+	var want []string
+	var objs []runtime.Object
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, i := range rand.Perm(25) {
+		pod := listPod(fmt.Sprintf("ns-%d", i%3), fmt.Sprintf("pod-%02d", i), "Running", t0)
+		objs = append(objs, pod)
+		want = append(want, pod.GetNamespace()+"/"+pod.GetName())
+	}
+	sort.Strings(want)
+
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{gvr: "PodList"}, objs...)
+	informers := NewInformerCache(client, time.Minute)
+	defer informers.evict(func(*informerEntry) bool { return true })
+	r := NewResourceService(nil, nil, client, informers)
+
+	lister, synced := informers.Lister(gvr)
+	for !synced {
+		time.Sleep(10 * time.Millisecond)
+		lister, synced = informers.Lister(gvr)
+	}
+	if all, _ := lister.List(labels.Everything()); len(all) != len(objs) {
+		t.Fatalf("informer lists %d pods, want %d", len(all), len(objs))
+	}
+
+	var got []string
+	opts := ListOptions{Limit: 4}
+	for pages := 0; ; pages++ {
+		if pages > len(objs) {
+			t.Fatalf("listPage() did not reach the last page")
+		}
+		items, meta, err := r.listPage(mapping, AllNamespaces, opts)
+		if err != nil {
+			t.Fatalf("listPage() error = %v", err)
+		}
+		if meta.Total != len(objs) {
+			t.Errorf("listPage() total = %d, want %d", meta.Total, len(objs))
+		}
+		got = append(got, namespacedNames(items)...)
+		if meta.Continue == "" {
+			break
+		}
+		opts.Continue = meta.Continue
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("paged through %v, want every pod once: %v", got, want)
+	}
+}
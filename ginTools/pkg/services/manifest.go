@@ -0,0 +1,175 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// ObjectResult is the outcome for one object of a manifest
+type ObjectResult struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	// Action is created, configured or unchanged, empty when Error is set
+	Action string `json:"action,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// DryRun describes what a dry run would store
+	DryRun *DryRunResult `json:"dryRun,omitempty"`
+}
+
+// ManifestResult reports every object of a manifest in the order they were written
+type ManifestResult struct {
+	DryRun  bool           `json:"dryRun,omitempty"`
+	Results []ObjectResult `json:"results"`
+	// Failed counts the results with an error
+	Failed int `json:"failed"`
+}
+
+func (m *ManifestResult) add(result ObjectResult) {
+	if result.Error != "" {
+		m.Failed++
+	}
+	m.Results = append(m.Results, result)
+}
+
+// decodeManifest splits a manifest into its objects. It accepts YAML with several
+// documents separated by "---", JSON objects, JSON arrays and List kinds, whose items
+// are expanded. Empty documents are skipped.
+func decodeManifest(manifest string) ([]*unstructured.Unstructured, error) {
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(strings.NewReader(manifest)))
+
+	var objs []*unstructured.Unstructured
+	for doc := 1; ; doc++ {
+		data, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read document %d: %w", doc, err)
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+
+		jsonData, err := yaml.YAMLToJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode document %d: %w", doc, err)
+		}
+		var value interface{}
+		if err := json.Unmarshal(jsonData, &value); err != nil {
+			return nil, fmt.Errorf("failed to decode document %d: %w", doc, err)
+		}
+		if value == nil {
+			// A document holding only comments
+			continue
+		}
+
+		docObjs, err := manifestObjects(value)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", doc, err)
+		}
+		objs = append(objs, docObjs...)
+	}
+
+	if len(objs) == 0 {
+		return nil, fmt.Errorf("the manifest contains no objects")
+	}
+	return objs, nil
+}
+
+// manifestObjects turns a decoded document into objects, expanding arrays and lists
+func manifestObjects(value interface{}) ([]*unstructured.Unstructured, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		var objs []*unstructured.Unstructured
+		for i, item := range v {
+			itemObjs, err := manifestObjects(item)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %w", i, err)
+			}
+			objs = append(objs, itemObjs...)
+		}
+		return objs, nil
+	case map[string]interface{}:
+		obj := &unstructured.Unstructured{Object: v}
+		if obj.GetKind() == "" || obj.GetAPIVersion() == "" {
+			return nil, fmt.Errorf("object has no apiVersion or kind")
+		}
+		if obj.IsList() {
+			items, _, _ := unstructured.NestedSlice(v, "items")
+			return manifestObjects(items)
+		}
+		if obj.GetName() == "" && obj.GetGenerateName() == "" {
+			return nil, fmt.Errorf("%s has no name", obj.GetKind())
+		}
+		return []*unstructured.Unstructured{obj}, nil
+	default:
+		return nil, fmt.Errorf("expected an object or a list, got %T", value)
+	}
+}
+
+// applyOrder ranks kinds so that what others depend on is written first: namespaces and
+// CRDs, then identities, configuration and storage, then workloads. Unknown kinds,
+// typically custom resources, come last.
+var applyOrder = []string{
+	"Namespace",
+	"CustomResourceDefinition",
+	"PriorityClass",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"NetworkPolicy",
+	"ServiceAccount",
+	"Secret",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"Role",
+	"RoleBinding",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"IngressClass",
+	"Ingress",
+	"APIService",
+	"MutatingWebhookConfiguration",
+	"ValidatingWebhookConfiguration",
+}
+
+// sortForApply orders objs by applyOrder, keeping the manifest order within a kind
+func sortForApply(objs []*unstructured.Unstructured) {
+	rank := make(map[string]int, len(applyOrder))
+	for i, kind := range applyOrder {
+		rank[kind] = i
+	}
+	rankOf := func(obj *unstructured.Unstructured) int {
+		if r, ok := rank[obj.GetKind()]; ok {
+			return r
+		}
+		return len(applyOrder)
+	}
+
+	sort.SliceStable(objs, func(i, j int) bool {
+		return rankOf(objs[i]) < rankOf(objs[j])
+	})
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// objectKeys names objs as kind/name for comparisons
+func objectKeys(objs []*unstructured.Unstructured) []string {
+	var keys []string
+	for _, obj := range objs {
+		keys = append(keys, obj.GetKind()+"/"+obj.GetName())
+	}
+	return keys
+}
+
+func TestDecodeManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     []string
+		// wantErr is a substring of the expected error, empty if none
+		wantErr string
+	}{
+		{
+			name: "documents, empty and comment-only ones skipped",
+			manifest: `
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+---
+# only a comment
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+`,
+			want: []string{"ConfigMap/settings", "Deployment/web"},
+		},
+		{
+			name:     "JSON object",
+			manifest: `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "web"}}`,
+			want:     []string{"Service/web"},
+		},
+		{
+			name:     "JSON array",
+			manifest: `[{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "a"}}, {"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "b"}}]`,
+			want:     []string{"Secret/a", "Secret/b"},
+		},
+		{
+			name: "List kind is expanded",
+			manifest: `
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ServiceAccount
+  metadata:
+    name: deployer
+- apiVersion: v1
+  kind: ConfigMapList
+  items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: nested
+`,
+			want: []string{"ServiceAccount/deployer", "ConfigMap/nested"},
+		},
+		{
+			name: "generateName is enough",
+			manifest: `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  generateName: migrate-
+`,
+			want: []string{"Job/"},
+		},
+		{
+			name:     "empty manifest",
+			manifest: "---\n# nothing here\n",
+			wantErr:  "contains no objects",
+		},
+		{
+			name: "missing kind names the document",
+			manifest: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ok
+---
+apiVersion: v1
+metadata:
+  name: broken
+`,
+			wantErr: "document 2: object has no apiVersion or kind",
+		},
+		{
+			name: "missing name",
+			manifest: `
+apiVersion: v1
+kind: ConfigMap
+metadata: {}
+`,
+			wantErr: "ConfigMap has no name",
+		},
+		{
+			name:     "scalar document",
+			manifest: "just a string\n",
+			wantErr:  "expected an object or a list",
+		},
+		{
+			name:     "invalid YAML",
+			manifest: "kind: [ConfigMap\n",
+			wantErr:  "failed to decode document 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs, err := decodeManifest(tt.manifest)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("decodeManifest() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeManifest() error = %v", err)
+			}
+			if got := objectKeys(objs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeManifest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortForApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		objects []string
+		want    []string
+	}{
+		{
+			name:    "dependencies first",
+			objects: []string{"Deployment/web", "Service/web", "ConfigMap/settings", "Namespace/shop"},
+			want:    []string{"Namespace/shop", "ConfigMap/settings", "Service/web", "Deployment/web"},
+		},
+		{
+			name:    "manifest order kept within a kind",
+			objects: []string{"ConfigMap/b", "Secret/s", "ConfigMap/a"},
+			want:    []string{"Secret/s", "ConfigMap/b", "ConfigMap/a"},
+		},
+		{
+			name:    "unknown kinds last",
+			objects: []string{"Certificate/tls", "CustomResourceDefinition/certificates", "Ingress/web"},
+			want:    []string{"CustomResourceDefinition/certificates", "Ingress/web", "Certificate/tls"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var objs []*unstructured.Unstructured
+			for _, key := range tt.objects {
+				kind, name, _ := strings.Cut(key, "/")
+				obj := &unstructured.Unstructured{}
+				obj.SetKind(kind)
+				obj.SetName(name)
+				objs = append(objs, obj)
+			}
+			sortForApply(objs)
+			if got := objectKeys(objs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sortForApply() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// AllNamespaces selects every namespace wherever a namespace is taken, as does an empty
+// namespace. Cluster-scoped resources ignore the namespace.
+const AllNamespaces = "*"
+
+// listNamespace returns the namespace to list in, metav1.NamespaceAll for all namespaces
+func listNamespace(ns string) string {
+	if ns == AllNamespaces {
+		return metav1.NamespaceAll
+	}
+	return ns
+}
+
+// isAllNamespaces reports whether ns selects every namespace
+func isAllNamespaces(ns string) bool {
+	return listNamespace(ns) == metav1.NamespaceAll
+}
+
+// namespacesOf lists the namespaces holding an object of some kind with the name in opts
+type namespacesOf func(ctx context.Context, opts metav1.ListOptions) ([]string, error)
+
+// resolveNamespace returns ns, or when ns selects all namespaces the namespace of the
+// only object named name. It fails if no namespace or several hold such an object.
+func resolveNamespace(ns string, kind string, name string, list namespacesOf) (string, error) {
+	if !isAllNamespaces(ns) {
+		return ns, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	namespaces, err := list(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", name).String(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to find %s %s: %w", kind, name, err)
+	}
+	switch len(namespaces) {
+	case 0:
+		return "", apierrors.NewNotFound(schema.GroupResource{Resource: kind}, name)
+	case 1:
+		return namespaces[0], nil
+	default:
+		return "", fmt.Errorf("%s %s exists in namespaces %s, name one of them", kind, name, strings.Join(namespaces, ", "))
+	}
+}
+
+// objectNamespace returns the namespace of the object of mapping named name: ns, the only
+// namespace holding such an object when ns selects all namespaces, or none if the
+// resource is cluster-scoped.
+func (r *ResourceService) objectNamespace(mapping *meta.RESTMapping, ns string, name string) (string, error) {
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return "", nil
+	}
+	return resolveNamespace(ns, strings.ToLower(mapping.GroupVersionKind.Kind), name, func(ctx context.Context, opts metav1.ListOptions) ([]string, error) {
+		list, err := r.client.Resource(mapping.Resource).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		namespaces := make([]string, 0, len(list.Items))
+		for _, item := range list.Items {
+			namespaces = append(namespaces, item.GetNamespace())
+		}
+		return namespaces, nil
+	})
+}
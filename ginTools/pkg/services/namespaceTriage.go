@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+)
+
+// ErrorLogPattern matches log lines that look like errors
+const ErrorLogPattern = `(?i)\b(error|err|fatal|panic|exception|fail(ed|ure)?|traceback|crash(ed)?|oomkilled|denied|refused|timeout|timed out)\b`
+
+// maxNamespaceLogPods caps the pods whose logs are read at once; the pods with the most
+// restarts are read first
+const maxNamespaceLogPods = 50
+
+// namespaceLogWorkers is the number of container logs read concurrently
+const namespaceLogWorkers = 8
+
+// NamespaceLogOptions selects the log lines of the pods of a namespace
+type NamespaceLogOptions struct {
+	// LogOptions apply to every container read. If Container is set only containers of that
+	// name are read, otherwise every container that has started. AllContainers is ignored.
+	LogOptions
+	// LabelSelector selects the pods, all pods if empty
+	LabelSelector string
+	// Lines caps the number of lines returned across all pods, keeping the most recent ones.
+	// Not positive means no limit.
+	Lines int
+}
+
+// NamespaceLogs are the log lines of the pods of a namespace, ordered by time
+type NamespaceLogs struct {
+	Namespace string `json:"namespace"`
+	// Pods is the number of pods read, SkippedPods the number of selected pods that were not
+	// because of maxNamespaceLogPods
+	Pods        int          `json:"pods"`
+	SkippedPods int          `json:"skippedPods,omitempty"`
+	Lines       []PodLogLine `json:"lines"`
+	// Truncated is set when older lines were dropped to stay within Lines or LimitBytes
+	Truncated bool `json:"truncated"`
+	// Errors holds the containers, as namespace/pod/container, whose logs could not be read
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// GetNamespaceLogs reads the logs of the containers of the pods selected by opts in ns, or
+// in all namespaces if ns is empty or AllNamespaces, and merges the lines by time
+func (s *PodLogEventService) GetNamespaceLogs(ctx context.Context, ns string, opts NamespaceLogOptions) (*NamespaceLogs, error) {
+	re, err := opts.compile()
+	if err != nil {
+		return nil, err
+	}
+	selector, err := ListOptions{LabelSelector: opts.LabelSelector}.labelSelector()
+	if err != nil {
+		return nil, err
+	}
+	pods, err := s.client.CoreV1().Pods(listNamespace(ns)).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	items := pods.Items
+	sort.SliceStable(items, func(i, j int) bool {
+		if ri, rj := podRestarts(&items[i]), podRestarts(&items[j]); ri != rj {
+			return ri > rj
+		}
+		return items[i].Namespace+"/"+items[i].Name < items[j].Namespace+"/"+items[j].Name
+	})
+	logs := &NamespaceLogs{Namespace: ns}
+	if len(items) > maxNamespaceLogPods {
+		logs.SkippedPods = len(items) - maxNamespaceLogPods
+		items = items[:maxNamespaceLogPods]
+	}
+	logs.Pods = len(items)
+
+	type target struct {
+		pod       *v1.Pod
+		container string
+	}
+	targets := make(chan target)
+	go func() {
+		defer close(targets)
+		for i := range items {
+			for _, container := range loggedContainers(&items[i], opts.LogOptions) {
+				targets <- target{&items[i], container}
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var read int
+	for i := 0; i < namespaceLogWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range targets {
+				lines, err := s.readContainerLogs(ctx, t.pod, t.container, opts.LogOptions, re)
+				mu.Lock()
+				read++
+				if err != nil {
+					if logs.Errors == nil {
+						logs.Errors = make(map[string]string)
+					}
+					logs.Errors[t.pod.Namespace+"/"+t.pod.Name+"/"+t.container] = err.Error()
+				}
+				for _, line := range lines {
+					line.Namespace, line.Pod = t.pod.Namespace, t.pod.Name
+					logs.Lines = append(logs.Lines, line)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if read > 0 && len(logs.Errors) == read {
+		return nil, fmt.Errorf("failed to read the logs of all %d containers, e.g. %s", read, firstError(logs.Errors))
+	}
+	logs.Lines, logs.Truncated = newestLines(logs.Lines, opts.Lines, opts.LimitBytes)
+	if logs.Lines == nil {
+		logs.Lines = []PodLogLine{}
+	}
+	return logs, nil
+}
+
+// loggedContainers returns the containers of a pod that have logs to read for opts
+func loggedContainers(pod *v1.Pod, opts LogOptions) []string {
+	var containers []string
+	statuses := append(append([]v1.ContainerStatus(nil), pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if opts.Container != "" && status.Name != opts.Container {
+			continue
+		}
+		started := status.State.Running != nil || status.State.Terminated != nil
+		if opts.Previous {
+			started = status.LastTerminationState.Terminated != nil
+		}
+		if started {
+			containers = append(containers, status.Name)
+		}
+	}
+	return containers
+}
+
+// podRestarts sums the restarts of a pod's containers
+func podRestarts(pod *v1.Pod) int32 {
+	var restarts int32
+	for _, status := range pod.Status.ContainerStatuses {
+		restarts += status.RestartCount
+	}
+	return restarts
+}
+
+// firstError returns the error of the alphabetically first key of errs
+func firstError(errs map[string]string) string {
+	keys := make([]string, 0, len(errs))
+	for key := range errs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys[0] + ": " + errs[keys[0]]
+}
+
+// ListNamespaceEvents returns the events in ns, or in all namespaces if ns is empty or
+// AllNamespaces, ordered by the time they were last seen
+// Parameters:
+//   - eventType: optional filter for event type (e.g., "Warning", "Normal")
+//   - since: if positive, only events seen within this duration
+//   - limit: if positive, the number of most recent events returned
+func (s *PodLogEventService) ListNamespaceEvents(ns string, eventType string, since time.Duration, limit int) (*EventTimeline, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var opts metav1.ListOptions
+	if eventType != "" {
+		opts.FieldSelector = fields.OneTermEqualSelector("type", eventType).String()
+	}
+	list, err := s.client.EventsV1().Events(listNamespace(ns)).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	events := make([]Event, 0, len(list.Items))
+	for i := range list.Items {
+		events = append(events, convertEvent(&list.Items[i]))
+	}
+	timeline := &EventTimeline{}
+	timeline.Events, timeline.Truncated = recentEvents(events, since, limit)
+	return timeline, nil
+}
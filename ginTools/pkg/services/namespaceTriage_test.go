@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	fakerest "k8s.io/client-go/rest/fake"
+	kubetesting "k8s.io/client-go/testing"
+)
+
+// logClientset is a fake clientset serving container logs from logs, keyed by
+// namespace/pod/container. Reading a container without an entry fails, as for a
+// container that is waiting to start.
+type logClientset struct {
+	*fake.Clientset
+	logs map[string]string
+}
+
+func (c *logClientset) CoreV1() corev1client.CoreV1Interface {
+	return &logCoreV1{CoreV1Interface: c.Clientset.CoreV1(), logs: c.logs}
+}
+
+type logCoreV1 struct {
+	corev1client.CoreV1Interface
+	logs map[string]string
+}
+
+func (c *logCoreV1) Pods(ns string) corev1client.PodInterface {
+	return &logPods{PodInterface: c.CoreV1Interface.Pods(ns), ns: ns, logs: c.logs}
+}
+
+type logPods struct {
+	corev1client.PodInterface
+	ns   string
+	logs map[string]string
+}
+
+func (p *logPods) GetLogs(name string, opts *v1.PodLogOptions) *rest.Request {
+	client := &fakerest.RESTClient{
+		Client: fakerest.CreateHTTPClient(func(*http.Request) (*http.Response, error) {
+			text, ok := p.logs[p.ns+"/"+name+"/"+opts.Container]
+			if !ok {
+				return &http.Response{
+					StatusCode: http.StatusBadRequest,
+					Body:       io.NopCloser(strings.NewReader(fmt.Sprintf("container %q in pod %q is waiting to start", opts.Container, name))),
+				}, nil
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(text))}, nil
+		}),
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		GroupVersion:         v1.SchemeGroupVersion,
+		VersionedAPIPath:     fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/log", p.ns, name),
+	}
+	return client.Request()
+}
+
+// triagePod builds a pod whose containers are running, with restarts restarts in total
+func triagePod(ns, name string, restarts int32, containers ...string) *v1.Pod {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}
+	for i, container := range containers {
+		status := v1.ContainerStatus{Name: container, State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}}
+		if i == 0 {
+			status.RestartCount = restarts
+		}
+		pod.Spec.Containers = append(pod.Spec.Containers, v1.Container{Name: container})
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, status)
+	}
+	return pod
+}
+
+// logAt renders a log line as the API server returns it, second seconds into the test
+func logAt(second int, text string) string {
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return t0.Add(time.Duration(second)*time.Second).Format(time.RFC3339Nano) + " " + text + "\n"
+}
+
+// podLines names lines as pod/container: text for comparisons
+func podLines(lines []PodLogLine) []string {
+	names := []string{}
+	for _, line := range lines {
+		names = append(names, line.Pod+"/"+line.Container+": "+line.Text)
+	}
+	return names
+}
+
+func TestGetNamespaceLogs(t *testing.T) {
+	pods := []runtime.Object{
+		triagePod("shop", "web", 0, "app", "proxy"),
+		triagePod("shop", "db", 3, "postgres"),
+		triagePod("other", "api", 1, "app"),
+	}
+	logs := map[string]string{
+		"shop/web/app":      logAt(1, "GET /") + logAt(4, "error: db unreachable"),
+		"shop/web/proxy":    logAt(2, "upstream timeout"),
+		"shop/db/postgres":  logAt(3, "FATAL: out of memory") + logAt(5, "database system is ready"),
+		"other/api/app":     logAt(6, "listening"),
+		"shop/web/no-such":  logAt(7, "never read"),
+		"shop/db/unrelated": logAt(8, "never read"),
+	}
+
+	tests := []struct {
+		name          string
+		ns            string
+		opts          NamespaceLogOptions
+		logs          map[string]string
+		want          []string
+		wantTruncated bool
+		wantErrors    []string
+		wantErr       bool
+	}{
+		{
+			name: "merged by time",
+			ns:   "shop",
+			want: []string{"web/app: GET /", "web/proxy: upstream timeout", "db/postgres: FATAL: out of memory", "web/app: error: db unreachable", "db/postgres: database system is ready"},
+		},
+		{
+			name: "all namespaces",
+			want: []string{"web/app: GET /", "web/proxy: upstream timeout", "db/postgres: FATAL: out of memory", "web/app: error: db unreachable", "db/postgres: database system is ready", "api/app: listening"},
+		},
+		{
+			name:          "newest lines",
+			ns:            "shop",
+			opts:          NamespaceLogOptions{Lines: 2},
+			want:          []string{"web/app: error: db unreachable", "db/postgres: database system is ready"},
+			wantTruncated: true,
+		},
+		{
+			name:          "newest bytes",
+			ns:            "shop",
+			opts:          NamespaceLogOptions{LogOptions: LogOptions{LimitBytes: 30}},
+			want:          []string{"db/postgres: database system is ready"},
+			wantTruncated: true,
+		},
+		{
+			name: "grep",
+			ns:   "shop",
+			opts: NamespaceLogOptions{LogOptions: LogOptions{Grep: ErrorLogPattern}},
+			want: []string{"web/proxy: upstream timeout", "db/postgres: FATAL: out of memory", "web/app: error: db unreachable"},
+		},
+		{
+			name: "container",
+			ns:   "shop",
+			opts: NamespaceLogOptions{LogOptions: LogOptions{Container: "app"}},
+			want: []string{"web/app: GET /", "web/app: error: db unreachable"},
+		},
+		{
+			name:       "some containers failed",
+			ns:         "shop",
+			logs:       map[string]string{"shop/db/postgres": logAt(3, "FATAL: out of memory")},
+			want:       []string{"db/postgres: FATAL: out of memory"},
+			wantErrors: []string{"shop/web/app", "shop/web/proxy"},
+		},
+		{
+			name:    "all containers failed",
+			ns:      "shop",
+			logs:    map[string]string{},
+			wantErr: true,
+		},
+		{
+			name: "no pods",
+			ns:   "empty",
+			want: []string{},
+		},
+		{
+			name:    "invalid grep",
+			ns:      "shop",
+			opts:    NamespaceLogOptions{LogOptions: LogOptions{Grep: "("}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			containerLogs := logs
+			if tt.logs != nil {
+				containerLogs = tt.logs
+			}
+			service := NewPodLogEventService(&logClientset{Clientset: fake.NewClientset(pods...), logs: containerLogs})
+
+			got, err := service.GetNamespaceLogs(context.Background(), tt.ns, tt.opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("GetNamespaceLogs() = %v, want an error", podLines(got.Lines))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetNamespaceLogs() error = %v", err)
+			}
+			if lines := podLines(got.Lines); !reflect.DeepEqual(lines, tt.want) || got.Truncated != tt.wantTruncated {
+				t.Errorf("GetNamespaceLogs() = %q, truncated %v, want %q, truncated %v", lines, got.Truncated, tt.want, tt.wantTruncated)
+			}
+			var failed []string
+			for container := range got.Errors {
+				failed = append(failed, container)
+			}
+			sort.Strings(failed)
+			if !reflect.DeepEqual(failed, tt.wantErrors) {
+				t.Errorf("GetNamespaceLogs() errors = %v, want %v", got.Errors, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestGetNamespaceLogsReadsPodsWithMostRestarts(t *testing.T) {
+	var pods []runtime.Object
+	logs := map[string]string{}
+	for i := 0; i < maxNamespaceLogPods+2; i++ {
+		name := fmt.Sprintf("pod-%02d", i)
+		// pod-00 and pod-01 have restarted least
+		pods = append(pods, triagePod("shop", name, int32(i), "app"))
+		logs["shop/"+name+"/app"] = logAt(i, "started")
+	}
+	service := NewPodLogEventService(&logClientset{Clientset: fake.NewClientset(pods...), logs: logs})
+
+	got, err := service.GetNamespaceLogs(context.Background(), "shop", NamespaceLogOptions{})
+	if err != nil {
+		t.Fatalf("GetNamespaceLogs() error = %v", err)
+	}
+	if got.Pods != maxNamespaceLogPods || got.SkippedPods != 2 {
+		t.Errorf("GetNamespaceLogs() read %d pods and skipped %d, want %d and 2", got.Pods, got.SkippedPods, maxNamespaceLogPods)
+	}
+	if len(got.Lines) != maxNamespaceLogPods {
+		t.Fatalf("GetNamespaceLogs() returned %d lines, want %d", len(got.Lines), maxNamespaceLogPods)
+	}
+	if first := got.Lines[0].Pod; first != "pod-02" {
+		t.Errorf("GetNamespaceLogs() read %s first, want the pods with the fewest restarts skipped", first)
+	}
+}
+
+func TestLoggedContainers(t *testing.T) {
+	terminated := v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 1}}
+	pod := &v1.Pod{Status: v1.PodStatus{
+		InitContainerStatuses: []v1.ContainerStatus{
+			{Name: "migrate", State: terminated},
+		},
+		ContainerStatuses: []v1.ContainerStatus{
+			{Name: "app", State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}, LastTerminationState: terminated},
+			{Name: "proxy", State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}},
+			{Name: "sidecar", State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ContainerCreating"}}},
+			{Name: "worker", State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}, LastTerminationState: terminated},
+		},
+	}}
+
+	tests := []struct {
+		name string
+		opts LogOptions
+		want []string
+	}{
+		{name: "started containers", want: []string{"migrate", "app", "proxy"}},
+		{name: "previous instances", opts: LogOptions{Previous: true}, want: []string{"app", "worker"}},
+		{name: "container", opts: LogOptions{Container: "proxy"}, want: []string{"proxy"}},
+		{name: "container not started", opts: LogOptions{Container: "sidecar"}},
+		{name: "previous instance of container", opts: LogOptions{Container: "worker", Previous: true}, want: []string{"worker"}},
+		{name: "no previous instance", opts: LogOptions{Container: "proxy", Previous: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := loggedContainers(pod, tt.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loggedContainers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListNamespaceEvents(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	event := func(ns, name, eventType string, age time.Duration) runtime.Object {
+		return &eventsv1.Event{
+			ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+			Regarding:  v1.ObjectReference{Kind: "Pod", Namespace: ns, Name: "web"},
+			Type:       eventType,
+			Reason:     name,
+			EventTime:  metav1.NewMicroTime(now.Add(-age)),
+		}
+	}
+	clientset := fake.NewClientset(
+		event("shop", "Scheduled", "Normal", time.Hour),
+		event("shop", "BackOff", "Warning", time.Minute),
+		event("shop", "Pulled", "Normal", 10*time.Minute),
+		event("other", "Failed", "Warning", 2*time.Minute),
+	)
+	var fieldSelector string
+	clientset.PrependReactor("list", "events", func(action kubetesting.Action) (bool, runtime.Object, error) {
+		fieldSelector = action.(kubetesting.ListAction).GetListRestrictions().Fields.String()
+		return false, nil, nil
+	})
+	service := NewPodLogEventService(clientset)
+
+	tests := []struct {
+		name          string
+		ns            string
+		eventType     string
+		since         time.Duration
+		limit         int
+		want          []string
+		wantSelector  string
+		wantTruncated bool
+	}{
+		{name: "namespace", ns: "shop", want: []string{"Scheduled", "Pulled", "BackOff"}},
+		{name: "all namespaces", want: []string{"Scheduled", "Pulled", "Failed", "BackOff"}},
+		{name: "since", ns: "shop", since: 30 * time.Minute, want: []string{"Pulled", "BackOff"}},
+		{name: "limit", limit: 2, want: []string{"Failed", "BackOff"}, wantTruncated: true},
+		// The fake clientset does not apply field selectors, the API server does
+		{name: "type", ns: "shop", eventType: "Warning", want: []string{"Scheduled", "Pulled", "BackOff"}, wantSelector: "type=Warning"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeline, err := service.ListNamespaceEvents(tt.ns, tt.eventType, tt.since, tt.limit)
+			if err != nil {
+				t.Fatalf("ListNamespaceEvents() error = %v", err)
+			}
+			var got []string
+			for _, e := range timeline.Events {
+				got = append(got, e.Reason)
+			}
+			if !reflect.DeepEqual(got, tt.want) || timeline.Truncated != tt.wantTruncated {
+				t.Errorf("ListNamespaceEvents() = %v, truncated %v, want %v, truncated %v", got, timeline.Truncated, tt.want, tt.wantTruncated)
+			}
+			if fieldSelector != tt.wantSelector {
+				t.Errorf("ListNamespaceEvents() field selector = %q, want %q", fieldSelector, tt.wantSelector)
+			}
+		})
+	}
+}
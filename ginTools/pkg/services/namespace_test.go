@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResolveNamespace(t *testing.T) {
+	listErr := errors.New("connection refused")
+
+	tests := []struct {
+		name string
+		ns   string
+		// found are the namespaces holding a pod named web, nil if listing fails
+		found []string
+		want  string
+		// wantErr is a substring of the expected error, empty if none
+		wantErr      string
+		wantNotFound bool
+		wantListed   bool
+	}{
+		{name: "namespace given", ns: "shop", found: []string{"default"}, want: "shop"},
+		{name: "one match", ns: "", found: []string{"shop"}, want: "shop", wantListed: true},
+		{name: "one match, all namespaces", ns: AllNamespaces, found: []string{"shop"}, want: "shop", wantListed: true},
+		{name: "no match", ns: "", found: []string{}, wantNotFound: true, wantListed: true},
+		{name: "several matches", ns: "*", found: []string{"default", "shop"}, wantErr: "pod web exists in namespaces default, shop, name one of them", wantListed: true},
+		{name: "list fails", ns: "", wantErr: "failed to find pod web: connection refused", wantListed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listed := false
+			list := func(ctx context.Context, opts metav1.ListOptions) ([]string, error) {
+				listed = true
+				if opts.FieldSelector != "metadata.name=web" {
+					t.Errorf("listed with field selector %q, want metadata.name=web", opts.FieldSelector)
+				}
+				if tt.found == nil {
+					return nil, listErr
+				}
+				return tt.found, nil
+			}
+
+			got, err := resolveNamespace(tt.ns, "pod", "web", list)
+			if listed != tt.wantListed {
+				t.Errorf("resolveNamespace() listed = %v, want %v", listed, tt.wantListed)
+			}
+			switch {
+			case tt.wantNotFound:
+				if !apierrors.IsNotFound(err) {
+					t.Errorf("resolveNamespace() error = %v, want not found", err)
+				}
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("resolveNamespace() error = %v, want one containing %q", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Errorf("resolveNamespace() error = %v", err)
+			case got != tt.want:
+				t.Errorf("resolveNamespace() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListNamespace(t *testing.T) {
+	for ns, want := range map[string]string{"": "", AllNamespaces: "", "shop": "shop"} {
+		if got := listNamespace(ns); got != want {
+			t.Errorf("listNamespace(%q) = %q, want %q", ns, got, want)
+		}
+		if got := isAllNamespaces(ns); got != (want == "") {
+			t.Errorf("isAllNamespaces(%q) = %v, want %v", ns, got, want == "")
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+// ErrInvalidPatch is wrapped by the errors of patches rejected before they are sent
+var ErrInvalidPatch = errors.New("invalid patch")
+
+// Patch types accepted by PatchResource, named as kubectl patch --type names them
+const (
+	PatchTypeJSON      = "json"
+	PatchTypeMerge     = "merge"
+	PatchTypeStrategic = "strategic"
+	PatchTypeApply     = "apply"
+)
+
+var patchTypes = map[string]types.PatchType{
+	PatchTypeJSON:      types.JSONPatchType,
+	PatchTypeMerge:     types.MergePatchType,
+	PatchTypeStrategic: types.StrategicMergePatchType,
+	PatchTypeApply:     types.ApplyPatchType,
+}
+
+// jsonPatchOps lists the RFC 6902 operations and whether they take a value or a from path
+var jsonPatchOps = map[string]struct{ value, from bool }{
+	"add":     {value: true},
+	"remove":  {},
+	"replace": {value: true},
+	"move":    {from: true},
+	"copy":    {from: true},
+	"test":    {value: true},
+}
+
+// parsePatchType maps a patch type name to its PatchType, json when empty
+func parsePatchType(name string) (types.PatchType, error) {
+	if name == "" {
+		name = PatchTypeJSON
+	}
+	pt, ok := patchTypes[strings.ToLower(name)]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown patch type %q, use json, merge, strategic or apply", ErrInvalidPatch, name)
+	}
+	return pt, nil
+}
+
+// validatePatch checks that patch is well formed for pt and for the object it targets,
+// and returns it as JSON. Merge, strategic and apply patches may also be written as YAML.
+func validatePatch(pt types.PatchType, patch string, gvk schema.GroupVersionKind, name string) ([]byte, error) {
+	data, err := yaml.YAMLToJSON([]byte(patch))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	if pt == types.JSONPatchType {
+		if err := validateJSONPatch(data); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		return data, nil
+	}
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil || obj == nil {
+		return nil, fmt.Errorf("%w: a %s patch must be an object holding the fields to change", ErrInvalidPatch, patchTypeName(pt))
+	}
+	if len(obj) == 0 {
+		return nil, fmt.Errorf("%w: the patch changes nothing", ErrInvalidPatch)
+	}
+
+	switch pt {
+	case types.StrategicMergePatchType:
+		if !scheme.Scheme.Recognizes(gvk) {
+			return nil, fmt.Errorf("%w: strategic merge patches need a built-in kind, %s is not one; use a merge patch", ErrInvalidPatch, gvk.Kind)
+		}
+	case types.ApplyPatchType:
+		// An apply patch is a partial object naming what it applies to; fill in what it omits
+		for field, want := range map[string]string{"apiVersion": gvk.GroupVersion().String(), "kind": gvk.Kind} {
+			if got, ok := obj[field]; !ok {
+				obj[field] = want
+			} else if got != want {
+				return nil, fmt.Errorf("%w: the apply patch has %s %v, the resource needs %s", ErrInvalidPatch, field, got, want)
+			}
+		}
+		metadata, _ := obj["metadata"].(map[string]interface{})
+		if metadata == nil {
+			metadata = map[string]interface{}{}
+			obj["metadata"] = metadata
+		}
+		if patchName, ok := metadata["name"]; !ok {
+			metadata["name"] = name
+		} else if patchName != name {
+			return nil, fmt.Errorf("%w: the apply patch names %v, not %s", ErrInvalidPatch, patchName, name)
+		}
+		return json.Marshal(obj)
+	}
+	return data, nil
+}
+
+// validateJSONPatch checks that data is a list of RFC 6902 operations
+func validateJSONPatch(data []byte) error {
+	var ops []map[string]interface{}
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return fmt.Errorf("a json patch must be a list of operations such as [{\"op\": \"replace\", \"path\": \"/spec/replicas\", \"value\": 3}]")
+	}
+	if len(ops) == 0 {
+		return fmt.Errorf("the patch changes nothing")
+	}
+	for i, op := range ops {
+		name, _ := op["op"].(string)
+		kind, ok := jsonPatchOps[name]
+		if !ok {
+			return fmt.Errorf("operation %d: unknown op %q", i, op["op"])
+		}
+		if path, ok := op["path"].(string); !ok || (path != "" && !strings.HasPrefix(path, "/")) {
+			return fmt.Errorf("operation %d: path must be a JSON pointer such as /spec/replicas", i)
+		}
+		if _, ok := op["value"]; kind.value && !ok {
+			return fmt.Errorf("operation %d: %s needs a value", i, name)
+		}
+		if from, ok := op["from"].(string); kind.from && (!ok || (from != "" && !strings.HasPrefix(from, "/"))) {
+			return fmt.Errorf("operation %d: %s needs a from path", i, name)
+		}
+	}
+	return nil
+}
+
+// patchTypeName is the name of pt as accepted by parsePatchType
+func patchTypeName(pt types.PatchType) string {
+	for name, t := range patchTypes {
+		if t == pt {
+			return name
+		}
+	}
+	return string(pt)
+}
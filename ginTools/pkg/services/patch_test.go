@@ -0,0 +1,195 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestParsePatchType(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    types.PatchType
+		wantErr bool
+	}{
+		{name: "", want: types.JSONPatchType},
+		{name: "merge", want: types.MergePatchType},
+		{name: "Strategic", want: types.StrategicMergePatchType},
+		{name: "apply", want: types.ApplyPatchType},
+		{name: "replace", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parsePatchType(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parsePatchType(%q) error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil && !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("parsePatchType(%q) error = %v, want ErrInvalidPatch", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("parsePatchType(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidatePatch(t *testing.T) {
+	deployment := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	certificate := schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+	tests := []struct {
+		name  string
+		pt    types.PatchType
+		patch string
+		gvk   schema.GroupVersionKind
+		// want is the JSON sent to the API server
+		want string
+		// wantErr is a substring of the expected error, empty if none
+		wantErr string
+	}{
+		{
+			name:  "json patch",
+			pt:    types.JSONPatchType,
+			patch: `[{"op": "replace", "path": "/spec/replicas", "value": 3}]`,
+			gvk:   deployment,
+			want:  `[{"op":"replace","path":"/spec/replicas","value":3}]`,
+		},
+		{
+			name:  "json patch written as YAML",
+			pt:    types.JSONPatchType,
+			patch: "- op: remove\n  path: /metadata/labels/tier\n",
+			gvk:   deployment,
+			want:  `[{"op":"remove","path":"/metadata/labels/tier"}]`,
+		},
+		{
+			name:    "json patch that is an object",
+			pt:      types.JSONPatchType,
+			patch:   `{"spec": {"replicas": 3}}`,
+			gvk:     deployment,
+			wantErr: "must be a list of operations",
+		},
+		{
+			name:    "empty json patch",
+			pt:      types.JSONPatchType,
+			patch:   `[]`,
+			gvk:     deployment,
+			wantErr: "changes nothing",
+		},
+		{
+			name:    "unknown op",
+			pt:      types.JSONPatchType,
+			patch:   `[{"op": "set", "path": "/spec/replicas", "value": 3}]`,
+			gvk:     deployment,
+			wantErr: `operation 0: unknown op "set"`,
+		},
+		{
+			name:    "path that is not a JSON pointer",
+			pt:      types.JSONPatchType,
+			patch:   `[{"op": "replace", "path": "spec.replicas", "value": 3}]`,
+			gvk:     deployment,
+			wantErr: "path must be a JSON pointer",
+		},
+		{
+			name:    "replace without a value",
+			pt:      types.JSONPatchType,
+			patch:   `[{"op": "test", "path": "/spec/replicas", "value": 1}, {"op": "replace", "path": "/spec/replicas"}]`,
+			gvk:     deployment,
+			wantErr: "operation 1: replace needs a value",
+		},
+		{
+			name:    "move without a from path",
+			pt:      types.JSONPatchType,
+			patch:   `[{"op": "move", "path": "/metadata/labels/new"}]`,
+			gvk:     deployment,
+			wantErr: "move needs a from path",
+		},
+		{
+			name:  "merge patch written as YAML",
+			pt:    types.MergePatchType,
+			patch: "spec:\n  replicas: 3\n",
+			gvk:   certificate,
+			want:  `{"spec":{"replicas":3}}`,
+		},
+		{
+			name:    "merge patch that is a list",
+			pt:      types.MergePatchType,
+			patch:   `[{"op": "remove", "path": "/spec"}]`,
+			gvk:     deployment,
+			wantErr: "a merge patch must be an object",
+		},
+		{
+			name:    "empty merge patch",
+			pt:      types.MergePatchType,
+			patch:   `{}`,
+			gvk:     deployment,
+			wantErr: "changes nothing",
+		},
+		{
+			name:  "strategic patch of a built-in kind",
+			pt:    types.StrategicMergePatchType,
+			patch: `{"spec": {"template": {"spec": {"containers": [{"name": "app", "image": "nginx:1.27"}]}}}}`,
+			gvk:   deployment,
+			want:  `{"spec":{"template":{"spec":{"containers":[{"image":"nginx:1.27","name":"app"}]}}}}`,
+		},
+		{
+			name:    "strategic patch of a custom resource",
+			pt:      types.StrategicMergePatchType,
+			patch:   `{"spec": {"dnsNames": ["example.com"]}}`,
+			gvk:     certificate,
+			wantErr: "Certificate is not one; use a merge patch",
+		},
+		{
+			name:  "apply patch gets apiVersion, kind and name",
+			pt:    types.ApplyPatchType,
+			patch: "spec:\n  replicas: 3\n",
+			gvk:   deployment,
+			want:  `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"web"},"spec":{"replicas":3}}`,
+		},
+		{
+			name:    "apply patch of another kind",
+			pt:      types.ApplyPatchType,
+			patch:   `{"kind": "StatefulSet", "spec": {"replicas": 3}}`,
+			gvk:     deployment,
+			wantErr: "the apply patch has kind StatefulSet, the resource needs Deployment",
+		},
+		{
+			name:    "apply patch naming another object",
+			pt:      types.ApplyPatchType,
+			patch:   `{"metadata": {"name": "api"}, "spec": {"replicas": 3}}`,
+			gvk:     deployment,
+			wantErr: "the apply patch names api, not web",
+		},
+		{
+			name:    "invalid YAML",
+			pt:      types.MergePatchType,
+			patch:   "spec: [replicas\n",
+			gvk:     deployment,
+			wantErr: "invalid patch",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validatePatch(tt.pt, tt.patch, tt.gvk, "web")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("validatePatch() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				if !errors.Is(err, ErrInvalidPatch) {
+					t.Errorf("validatePatch() error = %v, want ErrInvalidPatch", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validatePatch() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("validatePatch() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
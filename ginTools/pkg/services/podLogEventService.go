@@ -7,42 +7,24 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
 )
 
 // PodLogEventService handles operations related to pod logs and events
 type PodLogEventService struct {
-	client *kubernetes.Clientset
+	client kubernetes.Interface
 }
 
 // NewPodLogEventService creates a new instance of PodLogEventService
-func NewPodLogEventService(client *kubernetes.Clientset) *PodLogEventService {
+func NewPodLogEventService(client kubernetes.Interface) *PodLogEventService {
 	return &PodLogEventService{client: client}
 }
 
-// GetLogs returns a request to retrieve logs from a specific pod
-// Parameters:
-//   - ns: namespace where the pod is located
-//   - podName: name of the pod
-//   - tailLine: number of lines to show from the end of the logs
-//   - containerName: optional container name (if empty, uses the first container)
-func (s *PodLogEventService) GetLogs(ns, podName string, tailLine int64, containerName string) *rest.Request {
-	options := &v1.PodLogOptions{
-		Follow:    false,
-		TailLines: &tailLine,
-	}
-
-	// Only set the container name if it's provided
-	if containerName != "" {
-		options.Container = containerName
-	}
-
-	return s.client.CoreV1().Pods(ns).GetLogs(podName, options)
-}
-
 // PodEvent represents a filtered Kubernetes event
 type PodEvent struct {
+	Namespace string    `json:"namespace"`
 	Type      string    `json:"type"`
 	Reason    string    `json:"reason"`
 	Message   string    `json:"message"`
@@ -51,14 +33,14 @@ type PodEvent struct {
 
 // GetEvents retrieves events related to a specific pod
 // Parameters:
-//   - ns: namespace where the pod is located
+//   - ns: namespace where the pod is located, empty or AllNamespaces for every namespace
 //   - podName: name of the pod
 //   - eventType: optional filter for event type (e.g., "Warning", "Normal")
 func (s *PodLogEventService) GetEvents(ns, podName string, eventType string) ([]PodEvent, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	events, err := s.client.CoreV1().Events(ns).List(ctx, metav1.ListOptions{
+	events, err := s.client.CoreV1().Events(listNamespace(ns)).List(ctx, metav1.ListOptions{
 		FieldSelector: fmt.Sprintf("involvedObject.name=%s,involvedObject.kind=Pod", podName),
 	})
 	if err != nil {
@@ -73,6 +55,7 @@ func (s *PodLogEventService) GetEvents(ns, podName string, eventType string) ([]
 		}
 
 		podEvents = append(podEvents, PodEvent{
+			Namespace: event.Namespace,
 			Type:      event.Type,
 			Reason:    event.Reason,
 			Message:   event.Message,
@@ -96,26 +79,57 @@ type Pod struct {
 	Labels     map[string]string `json:"labels"`
 }
 
-// ListPods returns all pods in the specified namespace
-func (s *PodLogEventService) ListPods(ns string) ([]Pod, error) {
+// ListPods returns the pods in the specified namespace, or in all namespaces if ns is empty
+// or AllNamespaces, selected, ordered and paged by opts
+func (s *PodLogEventService) ListPods(ns string, opts ListOptions) (*ListResult, error) {
+	selector, err := opts.labelSelector()
+	if err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	pods, err := s.client.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{})
+	pods, err := s.client.CoreV1().Pods(listNamespace(ns)).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list pods: %w", err)
 	}
 
-	result := make([]Pod, 0, len(pods.Items))
-	for _, pod := range pods.Items {
-		result = append(result, convertToPod(&pod))
+	// Field selectors, sorting and projections work on the unstructured form
+	objs := make([]*unstructured.Unstructured, 0, len(pods.Items))
+	byKey := make(map[string]*v1.Pod, len(pods.Items))
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(pod)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert pod %s: %w", pod.Name, err)
+		}
+		objs = append(objs, &unstructured.Unstructured{Object: obj})
+		byKey[pod.Namespace+"/"+pod.Name] = pod
+	}
+	page, meta, err := opts.page(objs)
+	if err != nil {
+		return nil, err
 	}
 
-	return result, nil
+	if len(opts.Fields) > 0 {
+		return &ListResult{Items: resourceInfos(page, opts, false), Meta: meta}, nil
+	}
+	result := make([]Pod, 0, len(page))
+	for _, obj := range page {
+		result = append(result, convertToPod(byKey[obj.GetNamespace()+"/"+obj.GetName()]))
+	}
+	return &ListResult{Items: result, Meta: meta}, nil
 }
 
-// GetPod retrieves a specific pod by name and namespace
+// GetPod retrieves a specific pod by name and namespace, finding the pod by name if ns is
+// empty or AllNamespaces
 func (s *PodLogEventService) GetPod(ns, podName string) (*Pod, error) {
+	ns, err := s.podNamespace(ns, podName)
+	if err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -128,6 +142,22 @@ func (s *PodLogEventService) GetPod(ns, podName string) (*Pod, error) {
 	return &result, nil
 }
 
+// podNamespace returns ns, or the namespace of the only pod named podName if ns selects
+// all namespaces
+func (s *PodLogEventService) podNamespace(ns, podName string) (string, error) {
+	return resolveNamespace(ns, "pod", podName, func(ctx context.Context, opts metav1.ListOptions) ([]string, error) {
+		pods, err := s.client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		namespaces := make([]string, 0, len(pods.Items))
+		for _, pod := range pods.Items {
+			namespaces = append(namespaces, pod.Namespace)
+		}
+		return namespaces, nil
+	})
+}
+
 // convertToPod creates a simplified Pod representation from a Kubernetes Pod
 func convertToPod(pod *v1.Pod) Pod {
 	var status string
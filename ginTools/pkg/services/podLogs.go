@@ -0,0 +1,353 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ErrInvalidLogOptions is wrapped by the errors of log parameters that do not parse
+var ErrInvalidLogOptions = errors.New("invalid log options")
+
+// DefaultLogLimitBytes caps the logs returned at once unless a limit is given
+const DefaultLogLimitBytes = 64 * 1024
+
+// defaultContainerAnnotation names the container kubectl shows the logs of by default
+const defaultContainerAnnotation = "kubectl.kubernetes.io/default-container"
+
+// maxLogLineBytes is the longest log line read; longer lines fail the read
+const maxLogLineBytes = 1024 * 1024
+
+// LogOptions selects the log lines of a pod
+type LogOptions struct {
+	// Container names the container to read. If empty, the pod's default container is read,
+	// or every container if AllContainers is set.
+	Container     string
+	AllContainers bool
+	// Previous reads the logs of the previous, terminated instance of the containers
+	Previous bool
+	// TailLines reads only the last lines of each container, all lines if negative
+	TailLines int64
+	// SinceSeconds and SinceTime read only lines written after a point in time; at most
+	// one of them may be set
+	SinceSeconds int64
+	SinceTime    *metav1.Time
+	// Grep is a regular expression lines must match
+	Grep string
+	// LimitBytes caps the text of the returned lines, keeping the most recent ones. When
+	// following, the stream ends once it is reached. Not positive means no limit.
+	LimitBytes int64
+}
+
+// PodLogLine is one line of a container's log
+type PodLogLine struct {
+	// Namespace and Pod are set on lines gathered from several pods
+	Namespace string    `json:"namespace,omitempty"`
+	Pod       string    `json:"pod,omitempty"`
+	Container string    `json:"container"`
+	Time      time.Time `json:"time"`
+	Text      string    `json:"text"`
+}
+
+// Format renders the line, prefixed with its timestamp if timestamps is set and with its
+// container, and pod if known, if container is set
+func (l PodLogLine) Format(timestamps bool, container bool) string {
+	var b strings.Builder
+	if timestamps && !l.Time.IsZero() {
+		b.WriteString(l.Time.Format(time.RFC3339Nano))
+		b.WriteByte(' ')
+	}
+	if container {
+		if l.Pod != "" {
+			b.WriteString("[" + l.Pod + "/" + l.Container + "] ")
+		} else {
+			b.WriteString("[" + l.Container + "] ")
+		}
+	}
+	b.WriteString(l.Text)
+	return b.String()
+}
+
+// PodLogs are the log lines of one or more containers of a pod, ordered by time
+type PodLogs struct {
+	Namespace  string       `json:"namespace"`
+	Pod        string       `json:"pod"`
+	Containers []string     `json:"containers"`
+	Lines      []PodLogLine `json:"lines"`
+	// Truncated is set when older lines were dropped to stay within LimitBytes
+	Truncated bool `json:"truncated"`
+	// Errors holds the containers whose logs could not be read, when others could
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// compile checks the options and compiles the Grep expression, nil if it is empty
+func (o LogOptions) compile() (*regexp.Regexp, error) {
+	if o.SinceSeconds > 0 && o.SinceTime != nil {
+		return nil, fmt.Errorf("%w: only one of sinceSeconds and sinceTime may be set", ErrInvalidLogOptions)
+	}
+	if o.Grep == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(o.Grep)
+	if err != nil {
+		return nil, fmt.Errorf("%w: grep: %v", ErrInvalidLogOptions, err)
+	}
+	return re, nil
+}
+
+// podLogOptions converts the options to the API's options for one container
+func (o LogOptions) podLogOptions(container string, follow bool) *v1.PodLogOptions {
+	options := &v1.PodLogOptions{
+		Container:  container,
+		Follow:     follow,
+		Previous:   o.Previous,
+		Timestamps: true,
+		SinceTime:  o.SinceTime,
+	}
+	if o.TailLines >= 0 {
+		tail := o.TailLines
+		options.TailLines = &tail
+	}
+	if o.SinceSeconds > 0 {
+		since := o.SinceSeconds
+		options.SinceSeconds = &since
+	}
+	return options
+}
+
+// GetLogs reads the logs of a pod's containers selected by opts. The lines of several
+// containers are interleaved by their timestamps. If ns is empty or AllNamespaces the pod
+// is found by name.
+func (s *PodLogEventService) GetLogs(ctx context.Context, ns, podName string, opts LogOptions) (*PodLogs, error) {
+	re, err := opts.compile()
+	if err != nil {
+		return nil, err
+	}
+	pod, containers, err := s.logTargets(ctx, ns, podName, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	logs := &PodLogs{Namespace: pod.Namespace, Pod: pod.Name, Containers: containers}
+	var lastErr error
+	for _, container := range containers {
+		lines, err := s.readContainerLogs(ctx, pod, container, opts, re)
+		if err != nil {
+			if logs.Errors == nil {
+				logs.Errors = make(map[string]string)
+			}
+			logs.Errors[container] = err.Error()
+			lastErr = err
+			continue
+		}
+		logs.Lines = append(logs.Lines, lines...)
+	}
+	if len(logs.Errors) == len(containers) {
+		return nil, fmt.Errorf("failed to read logs of pod %s: %w", pod.Name, lastErr)
+	}
+
+	logs.Lines, logs.Truncated = newestLines(logs.Lines, 0, opts.LimitBytes)
+	return logs, nil
+}
+
+// newestLines orders lines by time and keeps the most recent ones, at most maxLines of
+// them and maxBytes of text; zero or less means no limit. truncated is set if any were
+// dropped.
+func newestLines(lines []PodLogLine, maxLines int, maxBytes int64) (kept []PodLogLine, truncated bool) {
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].Time.Before(lines[j].Time) })
+	var size int64
+	start := len(lines)
+	for start > 0 {
+		if maxLines > 0 && len(lines)-start >= maxLines {
+			break
+		}
+		lineSize := int64(len(lines[start-1].Text)) + 1
+		if maxBytes > 0 && size+lineSize > maxBytes {
+			break
+		}
+		start--
+		size += lineSize
+	}
+	return lines[start:], start > 0
+}
+
+// LogStream follows the logs of a pod's containers, see FollowLogs
+type LogStream struct {
+	Namespace  string
+	Pod        string
+	Containers []string
+
+	service *PodLogEventService
+	pod     *v1.Pod
+	opts    LogOptions
+	re      *regexp.Regexp
+}
+
+// FollowLogs prepares to follow the logs of a pod's containers selected by opts, failing if
+// the options are invalid or the pod does not exist. Run streams the lines.
+func (s *PodLogEventService) FollowLogs(ctx context.Context, ns, podName string, opts LogOptions) (*LogStream, error) {
+	re, err := opts.compile()
+	if err != nil {
+		return nil, err
+	}
+	pod, containers, err := s.logTargets(ctx, ns, podName, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &LogStream{
+		Namespace:  pod.Namespace,
+		Pod:        pod.Name,
+		Containers: containers,
+		service:    s,
+		pod:        pod,
+		opts:       opts,
+		re:         re,
+	}, nil
+}
+
+// Run passes the lines to emit as they are written, until ctx is done, every container has
+// terminated or LimitBytes is reached. It fails if no container's logs can be read or emit
+// fails.
+func (l *LogStream) Run(ctx context.Context, emit func(PodLogLine) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lines := make(chan PodLogLine)
+	done := make(chan error, len(l.Containers))
+	for _, container := range l.Containers {
+		go func(container string) {
+			if err := l.service.followContainerLogs(ctx, l.pod, container, l.opts, l.re, lines); err != nil {
+				done <- fmt.Errorf("container %s: %w", container, err)
+				return
+			}
+			done <- nil
+		}(container)
+	}
+
+	var sent int64
+	var failed []error
+	for running := len(l.Containers); running > 0; {
+		select {
+		case line := <-lines:
+			if err := emit(line); err != nil {
+				return err
+			}
+			sent += int64(len(line.Text)) + 1
+			if l.opts.LimitBytes > 0 && sent >= l.opts.LimitBytes {
+				return nil
+			}
+		case err := <-done:
+			running--
+			if err != nil && ctx.Err() == nil {
+				failed = append(failed, err)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	if len(failed) == len(l.Containers) {
+		return fmt.Errorf("failed to follow logs of pod %s: %w", l.Pod, failed[0])
+	}
+	return nil
+}
+
+// logTargets gets the pod and the containers opts selects
+func (s *PodLogEventService) logTargets(ctx context.Context, ns, podName string, opts LogOptions) (*v1.Pod, []string, error) {
+	ns, err := s.podNamespace(ns, podName)
+	if err != nil {
+		return nil, nil, err
+	}
+	pod, err := s.client.CoreV1().Pods(ns).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get pod %s in namespace %s: %w", podName, ns, err)
+	}
+
+	switch {
+	case opts.AllContainers:
+		var containers []string
+		for _, c := range pod.Spec.InitContainers {
+			containers = append(containers, c.Name)
+		}
+		for _, c := range pod.Spec.Containers {
+			containers = append(containers, c.Name)
+		}
+		return pod, containers, nil
+	case opts.Container != "":
+		return pod, []string{opts.Container}, nil
+	case pod.Annotations[defaultContainerAnnotation] != "":
+		return pod, []string{pod.Annotations[defaultContainerAnnotation]}, nil
+	case len(pod.Spec.Containers) > 0:
+		return pod, []string{pod.Spec.Containers[0].Name}, nil
+	}
+	return nil, nil, fmt.Errorf("pod %s has no containers", podName)
+}
+
+// readContainerLogs reads the lines of one container matching re
+func (s *PodLogEventService) readContainerLogs(ctx context.Context, pod *v1.Pod, container string, opts LogOptions, re *regexp.Regexp) ([]PodLogLine, error) {
+	rc, err := s.client.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opts.podLogOptions(container, false)).Stream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var lines []PodLogLine
+	err = scanLogLines(rc, container, re, func(line PodLogLine) bool {
+		lines = append(lines, line)
+		return true
+	})
+	return lines, err
+}
+
+// followContainerLogs sends the lines of one container matching re to lines as they are
+// written, until the container terminates or ctx is done
+func (s *PodLogEventService) followContainerLogs(ctx context.Context, pod *v1.Pod, container string, opts LogOptions, re *regexp.Regexp, lines chan<- PodLogLine) error {
+	rc, err := s.client.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opts.podLogOptions(container, true)).Stream(ctx)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	return scanLogLines(rc, container, re, func(line PodLogLine) bool {
+		select {
+		case lines <- line:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
+
+// scanLogLines passes the lines of r matching re to yield until it returns false
+func scanLogLines(r io.Reader, container string, re *regexp.Regexp, yield func(PodLogLine) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLogLineBytes)
+	for scanner.Scan() {
+		line := parseLogLine(container, scanner.Text())
+		if re != nil && !re.MatchString(line.Text) {
+			continue
+		}
+		if !yield(line) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// parseLogLine splits the timestamp the API server puts in front of a line off its text
+func parseLogLine(container, raw string) PodLogLine {
+	if ts, text, ok := strings.Cut(raw, " "); ok {
+		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			return PodLogLine{Container: container, Time: t, Text: text}
+		}
+	}
+	return PodLogLine{Container: container, Text: raw}
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseLogLine(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want PodLogLine
+	}{
+		{
+			name: "timestamped",
+			raw:  "2024-05-01T12:00:00.123456789Z starting server on :8080",
+			want: PodLogLine{Container: "web", Time: time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC), Text: "starting server on :8080"},
+		},
+		{
+			name: "time zone offset",
+			raw:  "2024-05-01T14:00:00+02:00 ready",
+			want: PodLogLine{Container: "web", Time: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), Text: "ready"},
+		},
+		{
+			name: "empty text",
+			raw:  "2024-05-01T12:00:00Z ",
+			want: PodLogLine{Container: "web", Time: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)},
+		},
+		{
+			name: "no timestamp",
+			raw:  "panic: runtime error: invalid memory address",
+			want: PodLogLine{Container: "web", Text: "panic: runtime error: invalid memory address"},
+		},
+		{
+			name: "single word",
+			raw:  "Killed",
+			want: PodLogLine{Container: "web", Text: "Killed"},
+		},
+		{
+			name: "empty line",
+			raw:  "",
+			want: PodLogLine{Container: "web"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseLogLine("web", tt.raw)
+			if !got.Time.Equal(tt.want.Time) || got.Text != tt.want.Text || got.Container != tt.want.Container {
+				t.Errorf("parseLogLine(%q) = %+v, want %+v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewestLines(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	line := func(second int, text string) PodLogLine {
+		return PodLogLine{Container: "web", Time: t0.Add(time.Duration(second) * time.Second), Text: text}
+	}
+	// Out of order, as the lines of several containers are gathered
+	lines := func() []PodLogLine {
+		return []PodLogLine{line(3, "ccc"), line(1, "a"), line(4, "dddd"), line(2, "bb")}
+	}
+
+	tests := []struct {
+		name          string
+		maxLines      int
+		maxBytes      int64
+		want          []string
+		wantTruncated bool
+	}{
+		{name: "no limit", want: []string{"a", "bb", "ccc", "dddd"}},
+		{name: "line limit", maxLines: 2, want: []string{"ccc", "dddd"}, wantTruncated: true},
+		{name: "line limit above the lines", maxLines: 10, want: []string{"a", "bb", "ccc", "dddd"}},
+		// Each line counts its newline
+		{name: "byte limit", maxBytes: 9, want: []string{"ccc", "dddd"}, wantTruncated: true},
+		{name: "byte limit exactly fits", maxBytes: 14, want: []string{"a", "bb", "ccc", "dddd"}},
+		{name: "byte limit below the newest line", maxBytes: 4, want: []string{}, wantTruncated: true},
+		{name: "both limits, bytes first", maxLines: 3, maxBytes: 5, want: []string{"dddd"}, wantTruncated: true},
+		{name: "both limits, lines first", maxLines: 1, maxBytes: 100, want: []string{"dddd"}, wantTruncated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, truncated := newestLines(lines(), tt.maxLines, tt.maxBytes)
+			got := []string{}
+			for _, l := range kept {
+				got = append(got, l.Text)
+			}
+			if !reflect.DeepEqual(got, tt.want) || truncated != tt.wantTruncated {
+				t.Errorf("newestLines() = %v, %v, want %v, %v", got, truncated, tt.want, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestLogOptionsCompile(t *testing.T) {
+	since := metav1.NewTime(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name      string
+		opts      LogOptions
+		wantErr   bool
+		wantNil   bool
+		matches   string
+		unmatched string
+	}{
+		{name: "no grep", opts: LogOptions{}, wantNil: true},
+		{name: "grep", opts: LogOptions{Grep: "error|panic"}, matches: "panic: boom", unmatched: "ready"},
+		{name: "case-insensitive grep", opts: LogOptions{Grep: "(?i)timeout"}, matches: "dial TIMEOUT", unmatched: "dial ok"},
+		{name: "invalid grep", opts: LogOptions{Grep: "error("}, wantErr: true},
+		{name: "since seconds", opts: LogOptions{SinceSeconds: 60}, wantNil: true},
+		{name: "since time", opts: LogOptions{SinceTime: &since}, wantNil: true},
+		{name: "both since options", opts: LogOptions{SinceSeconds: 60, SinceTime: &since}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re, err := tt.opts.compile()
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidLogOptions) {
+					t.Errorf("compile() error = %v, want ErrInvalidLogOptions", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("compile() error = %v", err)
+			}
+			if tt.wantNil {
+				if re != nil {
+					t.Errorf("compile() = %v, want nil", re)
+				}
+				return
+			}
+			if !re.MatchString(tt.matches) || re.MatchString(tt.unmatched) {
+				t.Errorf("compile() = %v, want it to match %q and not %q", re, tt.matches, tt.unmatched)
+			}
+		})
+	}
+}
+
+func TestScanLogLines(t *testing.T) {
+	logs := "2024-05-01T12:00:00Z starting\n2024-05-01T12:00:01Z error: db unreachable\nno timestamp error\n2024-05-01T12:00:02Z error: retrying\n"
+
+	var got []string
+	err := scanLogLines(strings.NewReader(logs), "web", regexp.MustCompile("error"), func(l PodLogLine) bool {
+		got = append(got, l.Text)
+		return len(got) < 2
+	})
+	if err != nil {
+		t.Fatalf("scanLogLines() error = %v", err)
+	}
+	if want := []string{"error: db unreachable", "no timestamp error"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("scanLogLines() = %v, want %v", got, want)
+	}
+
+	long := strings.Repeat("x", maxLogLineBytes+1)
+	if err := scanLogLines(strings.NewReader(long), "web", nil, func(PodLogLine) bool { return true }); err == nil {
+		t.Error("scanLogLines() read a line longer than maxLogLineBytes")
+	}
+}
@@ -2,30 +2,56 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/retry"
+	sigsyaml "sigs.k8s.io/yaml"
 )
 
+// ErrUnknownResource is wrapped by the errors of resources and kinds the cluster does not serve
+var ErrUnknownResource = errors.New("unknown resource type")
+
+// DefaultFieldManager owns the fields written by server-side apply unless configured otherwise
+const DefaultFieldManager = "ginTools"
+
 // ResourceService provides methods to interact with Kubernetes resources
 type ResourceService struct {
-	restMapper *meta.RESTMapper
-	client     *dynamic.DynamicClient
-	fact       informers.SharedInformerFactory
+	restMapper   *meta.RESTMapper
+	discovery    discovery.CachedDiscoveryInterface
+	client       dynamic.Interface
+	informers    *InformerCache
+	fieldManager string
+	// analyzers are the analyzers added to the built-in ones
+	analyzers []Analyzer
 }
 
 // NewResourceService creates a new ResourceService
-func NewResourceService(restMapper *meta.RESTMapper, client *dynamic.DynamicClient, fact informers.SharedInformerFactory) *ResourceService {
-	return &ResourceService{restMapper: restMapper, client: client, fact: fact}
+func NewResourceService(restMapper *meta.RESTMapper, discovery discovery.CachedDiscoveryInterface, client dynamic.Interface, informers *InformerCache) *ResourceService {
+	return &ResourceService{restMapper: restMapper, discovery: discovery, client: client, informers: informers, fieldManager: DefaultFieldManager}
+}
+
+// SetFieldManager sets the default field manager of server-side apply
+func (r *ResourceService) SetFieldManager(name string) {
+	if name != "" {
+		r.fieldManager = name
+	}
 }
 
 // ResourceInfo contains information about a Kubernetes resource
@@ -42,30 +68,54 @@ type ResourceInfo struct {
 	Status      map[string]interface{} `json:"status,omitempty"`
 }
 
-// ListResource lists resources of the specified type in the given namespace
-func (r *ResourceService) ListResource(resourceOrKindArg string, ns string) ([]ResourceInfo, error) {
+// ListResult is one page of a list
+type ListResult struct {
+	// Items holds ResourceInfo or Pod values, or the projections when Fields are set
+	Items interface{}
+	Meta  ListMeta
+}
+
+// ListResource lists resources of the specified type in the given namespace, or in all
+// namespaces if ns is empty or AllNamespaces, selected, ordered and paged by opts
+func (r *ResourceService) ListResource(resourceOrKindArg string, ns string, opts ListOptions) (*ListResult, error) {
 	restMapping, err := r.mappingFor(resourceOrKindArg, r.restMapper)
 	if err != nil {
 		return nil, fmt.Errorf("failed to map resource '%s': %w", resourceOrKindArg, err)
 	}
 
-	informer, err := r.fact.ForResource(restMapping.Resource)
+	list, meta, err := r.listPage(restMapping, ns, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get informer for resource '%s': %w", restMapping.Resource.String(), err)
+		return nil, err
 	}
+	return &ListResult{Items: resourceInfos(list, opts, true), Meta: meta}, nil
+}
 
-	list, err := informer.Lister().ByNamespace(ns).List(labels.Everything())
+// listPage lists the objects of mapping in ns and applies opts
+func (r *ResourceService) listPage(mapping *meta.RESTMapping, ns string, opts ListOptions) ([]*unstructured.Unstructured, ListMeta, error) {
+	selector, err := opts.labelSelector()
+	if err != nil {
+		return nil, ListMeta{}, err
+	}
+	list, err := r.listObjects(mapping, ns, selector)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list resources: %w", err)
+		return nil, ListMeta{}, fmt.Errorf("failed to list resources: %w", err)
 	}
+	return opts.page(list)
+}
 
-	result := make([]ResourceInfo, 0, len(list))
-	for _, obj := range list {
-		unstructObj, ok := obj.(*unstructured.Unstructured)
-		if !ok {
-			continue
+// resourceInfos summarizes objs, with their spec and status if detailed, or projects
+// them to opts.Fields
+func resourceInfos(objs []*unstructured.Unstructured, opts ListOptions, detailed bool) interface{} {
+	if len(opts.Fields) > 0 {
+		projections := make([]map[string]interface{}, 0, len(objs))
+		for _, obj := range objs {
+			projections = append(projections, opts.project(obj))
 		}
+		return projections
+	}
 
+	result := make([]ResourceInfo, 0, len(objs))
+	for _, unstructObj := range objs {
 		resourceInfo := ResourceInfo{
 			Name:        unstructObj.GetName(),
 			Namespace:   unstructObj.GetNamespace(),
@@ -77,18 +127,62 @@ func (r *ResourceService) ListResource(resourceOrKindArg string, ns string) ([]R
 			Annotations: unstructObj.GetAnnotations(),
 		}
 
-		// Extract spec and status if available
-		if spec, found, _ := unstructured.NestedMap(unstructObj.Object, "spec"); found {
-			resourceInfo.Spec = spec
-		}
-		if status, found, _ := unstructured.NestedMap(unstructObj.Object, "status"); found {
-			resourceInfo.Status = status
+		if detailed {
+			// Extract spec and status if available
+			if spec, found, _ := unstructured.NestedMap(unstructObj.Object, "spec"); found {
+				resourceInfo.Spec = spec
+			}
+			if status, found, _ := unstructured.NestedMap(unstructObj.Object, "status"); found {
+				resourceInfo.Status = status
+			}
 		}
 
 		result = append(result, resourceInfo)
 	}
+	return result
+}
 
-	return result, nil
+// listObjects lists the objects of mapping matching selector in ns, or in all namespaces
+// if ns is empty or AllNamespaces or the resource is cluster-scoped. Objects are read from
+// the informer cache, or from the API server while the resource's informer is still syncing.
+func (r *ResourceService) listObjects(mapping *meta.RESTMapping, ns string, selector labels.Selector) ([]*unstructured.Unstructured, error) {
+	ns = listNamespace(ns)
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		ns = ""
+	}
+
+	lister, synced := r.informers.Lister(mapping.Resource)
+	if !synced {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		list, err := r.client.Resource(mapping.Resource).Namespace(ns).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+		if err != nil {
+			return nil, err
+		}
+		objs := make([]*unstructured.Unstructured, 0, len(list.Items))
+		for i := range list.Items {
+			objs = append(objs, &list.Items[i])
+		}
+		return objs, nil
+	}
+
+	var list []runtime.Object
+	var err error
+	if ns != "" {
+		list, err = lister.ByNamespace(ns).List(selector)
+	} else {
+		list, err = lister.List(selector)
+	}
+	if err != nil {
+		return nil, err
+	}
+	objs := make([]*unstructured.Unstructured, 0, len(list))
+	for _, obj := range list {
+		if unstructObj, ok := obj.(*unstructured.Unstructured); ok {
+			objs = append(objs, unstructObj)
+		}
+	}
+	return objs, nil
 }
 
 // DeleteResource deletes a resource by name
@@ -112,29 +206,167 @@ func (r *ResourceService) DeleteResource(resourceOrKindArg string, ns string, na
 	return nil
 }
 
-// CreateResource creates a resource from YAML
-func (r *ResourceService) CreateResource(resourceOrKindArg string, yaml string) error {
-	if yaml == "" {
-		return fmt.Errorf("YAML content cannot be empty")
+// CreateResource creates every object of a manifest, see decodeManifest for the accepted
+// formats. Objects are created in dependency order and placed in ns unless they name
+// their own namespace. A failed object does not stop the others; each gets its result.
+// With dryRun the API server only validates and defaults the objects.
+func (r *ResourceService) CreateResource(ns string, manifest string, dryRun bool) (*ManifestResult, error) {
+	if manifest == "" {
+		return nil, fmt.Errorf("YAML content cannot be empty")
 	}
+	objs, err := decodeManifest(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode YAML: %w", err)
+	}
+	sortForApply(objs)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result := &ManifestResult{DryRun: dryRun}
+	for _, obj := range objs {
+		objResult := ObjectResult{Kind: obj.GetKind(), Name: obj.GetName()}
+		ri, err := r.objectInterface(obj, ns)
+		objResult.Namespace = obj.GetNamespace()
+		if err != nil {
+			objResult.Error = err.Error()
+			result.add(objResult)
+			continue
+		}
+
+		created, err := ri.Create(ctx, obj, metav1.CreateOptions{DryRun: dryRunOption(dryRun)})
+		if err != nil {
+			objResult.Error = fmt.Sprintf("failed to create resource '%s': %v", obj.GetName(), err)
+			result.add(objResult)
+			continue
+		}
+		objResult.Name, objResult.Action = created.GetName(), "created"
+		if dryRun {
+			if objResult.DryRun, err = newDryRunResult(nil, created); err != nil {
+				objResult.Error = err.Error()
+			}
+		}
+		result.add(objResult)
+	}
+	return result, nil
+}
 
-	obj := &unstructured.Unstructured{}
-	_, _, err := scheme.Codecs.UniversalDeserializer().Decode([]byte(yaml), nil, obj)
+// ApplyOptions configures ApplyManifest
+type ApplyOptions struct {
+	// FieldManager owns the applied fields, the service's field manager if empty
+	FieldManager string
+	// Force takes over fields owned by other managers instead of failing with a conflict
+	Force  bool
+	DryRun bool
+}
+
+// ApplyManifest applies every object of a manifest with server-side apply, in dependency
+// order. Applying the same manifest again leaves the objects unchanged instead of failing.
+func (r *ResourceService) ApplyManifest(ns string, manifest string, opts ApplyOptions) (*ManifestResult, error) {
+	if manifest == "" {
+		return nil, fmt.Errorf("YAML content cannot be empty")
+	}
+	objs, err := decodeManifest(manifest)
 	if err != nil {
-		return fmt.Errorf("failed to decode YAML: %w", err)
+		return nil, fmt.Errorf("failed to decode YAML: %w", err)
+	}
+	sortForApply(objs)
+	if opts.FieldManager == "" {
+		opts.FieldManager = r.fieldManager
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	ri, err := r.getResourceInterface(resourceOrKindArg, obj.GetNamespace(), r.client, r.restMapper)
+	result := &ManifestResult{DryRun: opts.DryRun}
+	for _, obj := range objs {
+		objResult := ObjectResult{Kind: obj.GetKind(), Name: obj.GetName()}
+		ri, err := r.objectInterface(obj, ns)
+		objResult.Namespace = obj.GetNamespace()
+		if err == nil {
+			err = r.applyObject(ctx, ri, obj, opts, &objResult)
+		}
+		if err != nil {
+			objResult.Error = err.Error()
+		}
+		result.add(objResult)
+	}
+	return result, nil
+}
+
+// applyObject applies obj and fills in the action taken, comparing with the live object
+func (r *ResourceService) applyObject(ctx context.Context, ri dynamic.ResourceInterface, obj *unstructured.Unstructured,
+	opts ApplyOptions, objResult *ObjectResult) error {
+	if obj.GetName() == "" {
+		return fmt.Errorf("server-side apply needs a name, generateName is not supported")
+	}
+	data, err := json.Marshal(obj.Object)
 	if err != nil {
-		return fmt.Errorf("failed to get resource interface: %w", err)
+		return fmt.Errorf("failed to encode %s '%s': %w", obj.GetKind(), obj.GetName(), err)
 	}
 
-	_, err = ri.Create(ctx, obj, metav1.CreateOptions{})
+	live, err := ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		live = nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get resource '%s': %w", obj.GetName(), err)
+	}
+
+	applied, err := ri.Patch(ctx, obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+		FieldManager: opts.FieldManager,
+		Force:        &opts.Force,
+		DryRun:       dryRunOption(opts.DryRun),
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create resource '%s': %w", obj.GetName(), err)
+		return fmt.Errorf("failed to apply resource '%s': %w", obj.GetName(), err)
+	}
+
+	dryRun, err := newDryRunResult(live, applied)
+	if err != nil {
+		return err
+	}
+	switch {
+	case live == nil:
+		objResult.Action = "created"
+	case dryRun.Diff == "":
+		objResult.Action = "unchanged"
+	default:
+		objResult.Action = "configured"
+	}
+	if opts.DryRun {
+		objResult.DryRun = dryRun
+	}
+	return nil
+}
+
+// objectInterface returns the ResourceInterface for obj's own kind. A namespaced object
+// without a namespace is placed in ns.
+func (r *ResourceService) objectInterface(obj *unstructured.Unstructured, ns string) (dynamic.ResourceInterface, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := (*r.restMapper).RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		// The kind may come from a CRD created earlier in the same manifest
+		meta.MaybeResetRESTMapper(*r.restMapper)
+		mapping, err = (*r.restMapper).RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get RESTMapping for %s: %w", gvk.String(), err)
+	}
+
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		obj.SetNamespace("")
+		return r.client.Resource(mapping.Resource), nil
+	}
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(ns)
+	}
+	return r.client.Resource(mapping.Resource).Namespace(obj.GetNamespace()), nil
+}
+
+// dryRunOption is the DryRun field of write options
+func dryRunOption(dryRun bool) []string {
+	if dryRun {
+		return []string{metav1.DryRunAll}
 	}
 	return nil
 }
@@ -168,6 +400,72 @@ func (r *ResourceService) GetGVR(resourceOrKindArg string) (*GVRInfo, error) {
 	}, nil
 }
 
+// APIResourceInfo describes a resource type served by the cluster
+type APIResourceInfo struct {
+	Name       string   `json:"name"`
+	ShortNames []string `json:"shortNames,omitempty"`
+	Group      string   `json:"group"`
+	Version    string   `json:"version"`
+	Kind       string   `json:"kind"`
+	Namespaced bool     `json:"namespaced"`
+	Verbs      []string `json:"verbs"`
+}
+
+// APIResourceList is the resource types served by the cluster
+type APIResourceList struct {
+	Resources []APIResourceInfo `json:"resources"`
+	// FailedGroups holds the error of each group version whose discovery failed, e.g. an
+	// aggregated API whose server is down; their resource types are missing from Resources
+	FailedGroups map[string]string `json:"failedGroups"`
+}
+
+// ListAPIResources lists the resource types of the preferred version of every API group,
+// like kubectl api-resources. Groups whose discovery fails are left out and recorded in
+// FailedGroups.
+func (r *ResourceService) ListAPIResources() (*APIResourceList, error) {
+	result := &APIResourceList{FailedGroups: map[string]string{}}
+	lists, err := r.discovery.ServerPreferredResources()
+	var failed *discovery.ErrGroupDiscoveryFailed
+	if errors.As(err, &failed) {
+		for gv, groupErr := range failed.Groups {
+			result.FailedGroups[gv.String()] = groupErr.Error()
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to discover API resources: %w", err)
+	}
+
+	var resources []APIResourceInfo
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, res := range list.APIResources {
+			// Subresources such as pods/log are not resource types of their own
+			if strings.Contains(res.Name, "/") {
+				continue
+			}
+			resources = append(resources, APIResourceInfo{
+				Name:       res.Name,
+				ShortNames: res.ShortNames,
+				Group:      gv.Group,
+				Version:    gv.Version,
+				Kind:       res.Kind,
+				Namespaced: res.Namespaced,
+				Verbs:      res.Verbs,
+			})
+		}
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].Group != resources[j].Group {
+			return resources[i].Group < resources[j].Group
+		}
+		return resources[i].Name < resources[j].Name
+	})
+	result.Resources = resources
+	return result, nil
+}
+
 // getResourceInterface returns the appropriate ResourceInterface for the resource type
 func (r *ResourceService) getResourceInterface(resourceOrKindArg string, ns string, client dynamic.Interface, restMapper *meta.RESTMapper) (dynamic.ResourceInterface, error) {
 	restMapping, err := r.mappingFor(resourceOrKindArg, restMapper)
@@ -184,19 +482,35 @@ func (r *ResourceService) getResourceInterface(resourceOrKindArg string, ns stri
 
 // mappingFor gets the REST mapping for a resource or kind
 func (r *ResourceService) mappingFor(resourceOrKindArg string, restMapper *meta.RESTMapper) (*meta.RESTMapping, error) {
+	mapping, err := lookupMapping(resourceOrKindArg, *restMapper)
+	if meta.IsNoMatchError(err) {
+		// The resource may belong to a CRD installed since the API groups were discovered
+		meta.MaybeResetRESTMapper(*restMapper)
+		mapping, err = lookupMapping(resourceOrKindArg, *restMapper)
+	}
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			_, groupResource := schema.ParseResourceArg(resourceOrKindArg)
+			return nil, fmt.Errorf("%w: the server doesn't have a resource type %q", ErrUnknownResource, groupResource.Resource)
+		}
+		return nil, err
+	}
+	return mapping, nil
+}
+
+// lookupMapping resolves a resource or kind, in any of the forms kubectl accepts, with restMapper
+func lookupMapping(resourceOrKindArg string, restMapper meta.RESTMapper) (*meta.RESTMapping, error) {
 	fullySpecifiedGVR, groupResource := schema.ParseResourceArg(resourceOrKindArg)
 	gvk := schema.GroupVersionKind{}
 
 	if fullySpecifiedGVR != nil {
-		gvk, _ = (*restMapper).KindFor(*fullySpecifiedGVR)
+		gvk, _ = restMapper.KindFor(*fullySpecifiedGVR)
 	}
 	if gvk.Empty() {
-		fmt.Println("groupResource: ", groupResource)
-		gvk, _ = (*restMapper).KindFor(groupResource.WithVersion(""))
-		fmt.Println("gvk: ", gvk)
+		gvk, _ = restMapper.KindFor(groupResource.WithVersion(""))
 	}
 	if !gvk.Empty() {
-		return (*restMapper).RESTMapping(gvk.GroupKind(), gvk.Version)
+		return restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
 	}
 
 	fullySpecifiedGVK, groupKind := schema.ParseKindArg(resourceOrKindArg)
@@ -206,30 +520,25 @@ func (r *ResourceService) mappingFor(resourceOrKindArg string, restMapper *meta.
 	}
 
 	if !fullySpecifiedGVK.Empty() {
-		if mapping, err := (*restMapper).RESTMapping(fullySpecifiedGVK.GroupKind(), fullySpecifiedGVK.Version); err == nil {
+		if mapping, err := restMapper.RESTMapping(fullySpecifiedGVK.GroupKind(), fullySpecifiedGVK.Version); err == nil {
 			return mapping, nil
 		}
 	}
 
-	mapping, err := (*restMapper).RESTMapping(groupKind, gvk.Version)
-	if err != nil {
-		if meta.IsNoMatchError(err) {
-			return nil, fmt.Errorf("the server doesn't have a resource type %q", groupResource.Resource)
-		}
-		return nil, err
-	}
-
-	return mapping, nil
+	return restMapper.RESTMapping(groupKind, gvk.Version)
 }
 
 // ResourceList contains information about a list of resources and their type
 type ResourceList struct {
-	Resources []ResourceInfo `json:"resources"`
-	GVR       GVRInfo        `json:"gvr"`
+	// Resources holds ResourceInfo values, or the projections when Fields are set
+	Resources interface{} `json:"resources"`
+	GVR       GVRInfo     `json:"gvr"`
+	Meta      ListMeta    `json:"meta"`
 }
 
-// GetResource returns all resources of the specified type across all namespaces
-func (r *ResourceService) GetResource(resource string) (*ResourceList, error) {
+// GetResource returns resources of the specified type in ns, or across all namespaces if
+// ns is empty or AllNamespaces, selected, ordered and paged by opts
+func (r *ResourceService) GetResource(resource string, ns string, opts ListOptions) (*ResourceList, error) {
 	if resource == "" {
 		return nil, fmt.Errorf("resource argument cannot be empty")
 	}
@@ -239,39 +548,13 @@ func (r *ResourceService) GetResource(resource string) (*ResourceList, error) {
 		return nil, fmt.Errorf("failed to get mapping for '%s': %w", resource, err)
 	}
 
-	informer, err := r.fact.ForResource(restMapping.Resource)
+	list, meta, err := r.listPage(restMapping, ns, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get informer for resource '%s': %w", restMapping.Resource.String(), err)
-	}
-
-	list, err := informer.Lister().List(labels.Everything())
-	if err != nil {
-		return nil, fmt.Errorf("failed to list resources: %w", err)
-	}
-
-	resources := make([]ResourceInfo, 0, len(list))
-	for _, obj := range list {
-		unstructObj, ok := obj.(*unstructured.Unstructured)
-		if !ok {
-			continue
-		}
-
-		resourceInfo := ResourceInfo{
-			Name:        unstructObj.GetName(),
-			Namespace:   unstructObj.GetNamespace(),
-			Kind:        unstructObj.GetKind(),
-			APIVersion:  unstructObj.GetAPIVersion(),
-			UID:         string(unstructObj.GetUID()),
-			CreatedAt:   unstructObj.GetCreationTimestamp(),
-			Labels:      unstructObj.GetLabels(),
-			Annotations: unstructObj.GetAnnotations(),
-		}
-
-		resources = append(resources, resourceInfo)
+		return nil, err
 	}
 
 	return &ResourceList{
-		Resources: resources,
+		Resources: resourceInfos(list, opts, false),
 		GVR: GVRInfo{
 			Group:      restMapping.Resource.Group,
 			Version:    restMapping.Resource.Version,
@@ -279,115 +562,300 @@ func (r *ResourceService) GetResource(resource string) (*ResourceList, error) {
 			Kind:       restMapping.GroupVersionKind.Kind,
 			Namespaced: restMapping.Scope.Name() == "namespace",
 		},
+		Meta: meta,
 	}, nil
 }
 
-// GetResourceByType returns resources filtered by a specific type
-func (r *ResourceService) GetResourceByType(resource string, resourceType string) (*ResourceList, error) {
-	if resource == "" {
-		return nil, fmt.Errorf("resource argument cannot be empty")
+// GetResourceByType returns resources labelled type=resourceType, in addition to the
+// label selector of opts
+func (r *ResourceService) GetResourceByType(resource string, ns string, resourceType string, opts ListOptions) (*ResourceList, error) {
+	if resourceType != "" {
+		typeSelector := labels.SelectorFromSet(labels.Set{"type": resourceType}).String()
+		if opts.LabelSelector == "" {
+			opts.LabelSelector = typeSelector
+		} else {
+			opts.LabelSelector += "," + typeSelector
+		}
 	}
+	return r.GetResource(resource, ns, opts)
+}
+
+// ErrInvalidUpdate is wrapped by the errors of updates rejected before they are sent
+var ErrInvalidUpdate = errors.New("invalid update")
 
-	// If resourceType is empty, delegate to GetResource
-	if resourceType == "" {
-		return r.GetResource(resource)
+// UpdateOptions configures UpdateResource
+type UpdateOptions struct {
+	// ResourceVersion is the version the update was written against. When set, the update
+	// fails with a conflict if the object changed since, instead of being retried.
+	ResourceVersion string
+	DryRun          bool
+}
+
+// WriteResult is the object a write produced. On a dry run DryRun describes what would
+// be stored.
+type WriteResult struct {
+	Object *unstructured.Unstructured
+	DryRun *DryRunResult
+}
+
+// UpdateResource merges the fields of a YAML document into the live object and stores
+// it: maps are merged key by key, the items of lists of built-in kinds by their merge key,
+// such as the name of a container, other values replace the live ones and null removes a
+// field. Of the metadata only labels and annotations are merged, and a name or namespace
+// in the YAML must match the one of the path. A conflicting write is retried on a fresh
+// copy of the live object unless opts.ResourceVersion pins the version. With dryRun
+// nothing is stored and the result holds the diff against the live object.
+func (r *ResourceService) UpdateResource(resourceOrKindArg string, ns string, name string, yaml string, opts UpdateOptions) (*WriteResult, error) {
+	if strings.TrimSpace(yaml) == "" {
+		return nil, fmt.Errorf("%w: YAML content cannot be empty", ErrInvalidUpdate)
+	}
+	mapping, err := r.mappingFor(resourceOrKindArg, r.restMapper)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get RESTMapping for %s: %w", resourceOrKindArg, err)
+	}
+	desired, err := decodeUpdate(yaml, mapping, ns, name)
+	if err != nil {
+		return nil, err
 	}
 
-	restMapping, err := r.mappingFor(resource, r.restMapper)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	ri, err := r.getResourceInterface(resourceOrKindArg, ns, r.client, r.restMapper)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get mapping for '%s': %w", resource, err)
+		return nil, fmt.Errorf("failed to get resource interface: %w", err)
 	}
 
-	informer, err := r.fact.ForResource(restMapping.Resource)
+	backoff := retry.DefaultRetry
+	if opts.ResourceVersion != "" {
+		backoff.Steps = 1
+	}
+	var result *WriteResult
+	err = retry.RetryOnConflict(backoff, func() error {
+		live, err := ri.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if opts.ResourceVersion != "" && live.GetResourceVersion() != opts.ResourceVersion {
+			return apierrors.NewConflict(mapping.Resource.GroupResource(), name,
+				fmt.Errorf("it changed since resourceVersion %s and is now at %s", opts.ResourceVersion, live.GetResourceVersion()))
+		}
+
+		merged, err := mergeUpdate(live, desired)
+		if err != nil {
+			return err
+		}
+		updated, err := ri.Update(ctx, merged, metav1.UpdateOptions{DryRun: dryRunOption(opts.DryRun)})
+		if err != nil {
+			return err
+		}
+		result = &WriteResult{Object: updated}
+		if opts.DryRun {
+			result.DryRun, err = newDryRunResult(live, updated)
+		}
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get informer for resource '%s': %w", restMapping.Resource.String(), err)
+		return nil, fmt.Errorf("failed to update resource '%s': %w", name, err)
 	}
+	return result, nil
+}
 
-	// Create a label selector for the resource type if provided
-	var selector labels.Selector
-	if resourceType != "" {
-		selector = labels.SelectorFromSet(labels.Set{"type": resourceType})
-	} else {
-		selector = labels.Everything()
+// decodeUpdate decodes the YAML of an update and checks that it describes the object of
+// the path. It returns the fields to merge into the live object.
+func decodeUpdate(yaml string, mapping *meta.RESTMapping, ns string, name string) (map[string]interface{}, error) {
+	data, err := sigsyaml.YAMLToJSON([]byte(yaml))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decode YAML: %v", ErrInvalidUpdate, err)
+	}
+	var desired map[string]interface{}
+	if err := json.Unmarshal(data, &desired); err != nil || desired == nil {
+		return nil, fmt.Errorf("%w: the YAML must be an object holding the fields to change", ErrInvalidUpdate)
+	}
+
+	obj := &unstructured.Unstructured{Object: desired}
+	gvk := mapping.GroupVersionKind
+	if obj.GetAPIVersion() != "" && obj.GetAPIVersion() != gvk.GroupVersion().String() {
+		return nil, fmt.Errorf("%w: the YAML has apiVersion %s, %s is served as %s", ErrInvalidUpdate, obj.GetAPIVersion(), gvk.Kind, gvk.GroupVersion())
+	}
+	if obj.GetKind() != "" && obj.GetKind() != gvk.Kind {
+		return nil, fmt.Errorf("%w: the YAML describes a %s, not a %s", ErrInvalidUpdate, obj.GetKind(), gvk.Kind)
+	}
+	if obj.GetName() != "" && obj.GetName() != name {
+		return nil, fmt.Errorf("%w: the YAML names %s, not %s", ErrInvalidUpdate, obj.GetName(), name)
+	}
+	if obj.GetNamespace() != "" && mapping.Scope.Name() == meta.RESTScopeNameNamespace && obj.GetNamespace() != ns {
+		return nil, fmt.Errorf("%w: the YAML is in namespace %s, not %s", ErrInvalidUpdate, obj.GetNamespace(), ns)
+	}
+
+	fields := make(map[string]interface{}, len(desired))
+	for key, value := range desired {
+		switch key {
+		case "apiVersion", "kind", "status":
+		case "metadata":
+			metadata := map[string]interface{}{}
+			for _, key := range []string{"labels", "annotations"} {
+				if value, ok := obj.Object["metadata"].(map[string]interface{})[key]; ok {
+					metadata[key] = value
+				}
+			}
+			if len(metadata) > 0 {
+				fields["metadata"] = metadata
+			}
+		default:
+			fields[key] = value
+		}
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%w: the YAML changes no field; status and metadata other than labels and annotations are not updated", ErrInvalidUpdate)
 	}
+	return fields, nil
+}
 
-	list, err := informer.Lister().List(selector)
+// mergeUpdate returns a copy of live with the fields of an update merged in. Objects of
+// built-in kinds are merged as a strategic merge patch, so the items of lists such as
+// containers are merged by their key and keep the fields the update leaves out. Other
+// kinds, which declare no merge keys, are merged with mergeFields.
+func mergeUpdate(live *unstructured.Unstructured, fields map[string]interface{}) (*unstructured.Unstructured, error) {
+	typed, err := scheme.Scheme.New(live.GroupVersionKind())
 	if err != nil {
-		return nil, fmt.Errorf("failed to list resources: %w", err)
+		merged := live.DeepCopy()
+		mergeFields(merged.Object, fields)
+		return merged, nil
 	}
 
-	resources := make([]ResourceInfo, 0, len(list))
-	for _, obj := range list {
-		unstructObj, ok := obj.(*unstructured.Unstructured)
-		if !ok {
+	original, err := live.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	patch, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	data, err := strategicpatch.StrategicMergePatch(original, patch, typed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUpdate, err)
+	}
+	merged := &unstructured.Unstructured{}
+	if err := merged.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// mergeFields merges src into dst: maps key by key, other values replace those of dst
+// and nil removes the key
+func mergeFields(dst, src map[string]interface{}) {
+	for key, value := range src {
+		if value == nil {
+			delete(dst, key)
 			continue
 		}
-
-		resourceInfo := ResourceInfo{
-			Name:        unstructObj.GetName(),
-			Namespace:   unstructObj.GetNamespace(),
-			Kind:        unstructObj.GetKind(),
-			APIVersion:  unstructObj.GetAPIVersion(),
-			UID:         string(unstructObj.GetUID()),
-			CreatedAt:   unstructObj.GetCreationTimestamp(),
-			Labels:      unstructObj.GetLabels(),
-			Annotations: unstructObj.GetAnnotations(),
+		srcMap, srcOK := value.(map[string]interface{})
+		dstMap, dstOK := dst[key].(map[string]interface{})
+		if srcOK && dstOK {
+			mergeFields(dstMap, srcMap)
+			continue
 		}
+		dst[key] = value
+	}
+}
 
-		resources = append(resources, resourceInfo)
+// GetObject returns the named object without its managed fields. If ns is empty or
+// AllNamespaces the object is found by name in all namespaces.
+func (r *ResourceService) GetObject(resourceOrKindArg string, ns string, name string) (*unstructured.Unstructured, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	mapping, err := r.mappingFor(resourceOrKindArg, r.restMapper)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get RESTMapping for %s: %w", resourceOrKindArg, err)
+	}
+	ns, err = r.objectNamespace(mapping, ns, name)
+	if err != nil {
+		return nil, err
 	}
+	obj, err := r.client.Resource(mapping.Resource).Namespace(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resource '%s': %w", name, err)
+	}
+	unstructured.RemoveNestedField(obj.Object, "metadata", "managedFields")
+	return obj, nil
+}
 
-	return &ResourceList{
-		Resources: resources,
-		GVR: GVRInfo{
-			Group:      restMapping.Resource.Group,
-			Version:    restMapping.Resource.Version,
-			Resource:   restMapping.Resource.Resource,
-			Kind:       restMapping.GroupVersionKind.Kind,
-			Namespaced: restMapping.Scope.Name() == "namespace",
-		},
-	}, nil
+// PatchOptions configures PatchResource
+type PatchOptions struct {
+	// Type is json, merge, strategic or apply, json if empty
+	Type string
+	// FieldManager owns the fields of an apply patch, the service's field manager if empty
+	FieldManager string
+	// Force lets an apply patch take over fields owned by other managers
+	Force  bool
+	DryRun bool
 }
 
-// UpdateResource updates an existing resource using the provided YAML
-func (r *ResourceService) UpdateResource(resourceOrKindArg string, ns string, name string, yaml string) error {
-	if yaml == "" {
-		return fmt.Errorf("YAML content cannot be empty")
+// PatchResource patches a resource. The patch is validated for its type before it is
+// sent; errors from that validation wrap ErrInvalidPatch. With dryRun nothing is stored
+// and the result holds the diff against the live object.
+func (r *ResourceService) PatchResource(resourceOrKindArg string, ns string, name string, patch string, opts PatchOptions) (*WriteResult, error) {
+	if strings.TrimSpace(patch) == "" {
+		return nil, fmt.Errorf("%w: patch content cannot be empty", ErrInvalidPatch)
+	}
+	pt, err := parsePatchType(opts.Type)
+	if err != nil {
+		return nil, err
 	}
-	obj := &unstructured.Unstructured{}
-	_, _, err := scheme.Codecs.UniversalDeserializer().Decode([]byte(yaml), nil, obj)
+	mapping, err := r.mappingFor(resourceOrKindArg, r.restMapper)
 	if err != nil {
-		return fmt.Errorf("failed to decode YAML: %w", err)
+		return nil, fmt.Errorf("failed to get RESTMapping for %s: %w", resourceOrKindArg, err)
 	}
+	data, err := validatePatch(pt, patch, mapping.GroupVersionKind, name)
+	if err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 	ri, err := r.getResourceInterface(resourceOrKindArg, ns, r.client, r.restMapper)
 	if err != nil {
-		return fmt.Errorf("failed to get resource interface: %w", err)
+		return nil, fmt.Errorf("failed to get resource interface: %w", err)
 	}
-	_, err = ri.Update(ctx, obj, metav1.UpdateOptions{})
+	live, err := r.liveForDiff(ctx, ri, name, opts.DryRun)
 	if err != nil {
-		return fmt.Errorf("failed to update resource '%s': %w", name, err)
+		return nil, err
 	}
-	return nil
-}
 
-// PatchResource patches a resource using the provided patch string
-func (r *ResourceService) PatchResource(resourceOrKindArg string, ns string, name string, patch string) error {
-	if patch == "" {
-		return fmt.Errorf("patch content cannot be empty")
+	patchOpts := metav1.PatchOptions{DryRun: dryRunOption(opts.DryRun)}
+	if pt == types.ApplyPatchType {
+		patchOpts.FieldManager = opts.FieldManager
+		if patchOpts.FieldManager == "" {
+			patchOpts.FieldManager = r.fieldManager
+		}
+		patchOpts.Force = &opts.Force
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-	ri, err := r.getResourceInterface(resourceOrKindArg, ns, r.client, r.restMapper)
+	patched, err := ri.Patch(ctx, name, pt, data, patchOpts)
 	if err != nil {
-		return fmt.Errorf("failed to get resource interface: %w", err)
+		return nil, fmt.Errorf("failed to patch resource '%s': %w", name, err)
+	}
+
+	result := &WriteResult{Object: patched}
+	if opts.DryRun {
+		if result.DryRun, err = newDryRunResult(live, patched); err != nil {
+			return nil, err
+		}
 	}
-	_, err = ri.Patch(ctx, name, types.JSONPatchType, []byte(patch), metav1.PatchOptions{})
+	unstructured.RemoveNestedField(patched.Object, "metadata", "managedFields")
+	return result, nil
+}
+
+// liveForDiff fetches the object a dry run is compared with; real writes need no copy
+func (r *ResourceService) liveForDiff(ctx context.Context, ri dynamic.ResourceInterface, name string, dryRun bool) (*unstructured.Unstructured, error) {
+	if !dryRun {
+		return nil, nil
+	}
+	live, err := ri.Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
-		return fmt.Errorf("failed to patch resource '%s': %w", name, err)
+		return nil, fmt.Errorf("failed to get resource '%s': %w", name, err)
 	}
-	return nil
+	return live, nil
 }
 
 // GetResourceStatus returns the status of a resource
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	kubetesting "k8s.io/client-go/testing"
+
+	"github.com/lexieqin/Geek/ginTools/pkg/config"
+)
+
+// failingDiscovery is a fake discovery client whose failed group versions cannot be discovered
+type failingDiscovery struct {
+	*fakediscovery.FakeDiscovery
+	failed map[string]error
+}
+
+func (d *failingDiscovery) ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error) {
+	if err, ok := d.failed[groupVersion]; ok {
+		return nil, err
+	}
+	return d.FakeDiscovery.ServerResourcesForGroupVersion(groupVersion)
+}
+
+func (d *failingDiscovery) ServerGroupsAndResources() ([]*metav1.APIGroup, []*metav1.APIResourceList, error) {
+	return discovery.ServerGroupsAndResources(d)
+}
+
+func (d *failingDiscovery) ServerPreferredResources() ([]*metav1.APIResourceList, error) {
+	return discovery.ServerPreferredResources(d)
+}
+
+var podResources = &metav1.APIResourceList{
+	GroupVersion: "v1",
+	APIResources: []metav1.APIResource{
+		{Name: "pods", Kind: "Pod", Namespaced: true, ShortNames: []string{"po"}, Verbs: []string{"get", "list", "watch"}},
+		{Name: "pods/log", Kind: "Pod", Namespaced: true, Verbs: []string{"get"}},
+	},
+}
+
+var widgetResources = &metav1.APIResourceList{
+	GroupVersion: "example.com/v1",
+	APIResources: []metav1.APIResource{
+		{Name: "widgets", Kind: "Widget", Namespaced: true, Verbs: []string{"get", "list", "watch"}},
+	},
+}
+
+// discoveryResourceService returns a ResourceService whose REST mapper discovers the
+// resources of fake the way the server's mapper does
+func discoveryResourceService(fake discovery.DiscoveryInterface) *ResourceService {
+	cached := memory.NewMemCacheClient(fake)
+	restMapper := (&config.K8sConfig{}).InitRestMapper(cached)
+	return NewResourceService(&restMapper, cached, nil, nil)
+}
+
+func TestMappingForResetsMapper(t *testing.T) {
+	fake := &fakediscovery.FakeDiscovery{Fake: &kubetesting.Fake{Resources: []*metav1.APIResourceList{podResources}}}
+	service := discoveryResourceService(fake)
+
+	if _, err := service.mappingFor("pods", service.restMapper); err != nil {
+		t.Fatalf("mappingFor(pods) error = %v", err)
+	}
+	if _, err := service.mappingFor("widgets", service.restMapper); !errors.Is(err, ErrUnknownResource) {
+		t.Fatalf("mappingFor(widgets) error = %v, want ErrUnknownResource", err)
+	}
+
+	// A CRD installed after the API groups were discovered
+	fake.Resources = append(fake.Resources, widgetResources)
+	mapping, err := service.mappingFor("widgets", service.restMapper)
+	if err != nil {
+		t.Fatalf("mappingFor(widgets) after installing the CRD error = %v", err)
+	}
+	want := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+	if mapping.Resource != want {
+		t.Errorf("mappingFor(widgets) = %v, want %v", mapping.Resource, want)
+	}
+}
+
+func TestListAPIResources(t *testing.T) {
+	fake := &failingDiscovery{
+		FakeDiscovery: &fakediscovery.FakeDiscovery{Fake: &kubetesting.Fake{Resources: []*metav1.APIResourceList{
+			podResources,
+			widgetResources,
+			{GroupVersion: "metrics.k8s.io/v1beta1", APIResources: []metav1.APIResource{{Name: "pods", Kind: "PodMetrics", Namespaced: true}}},
+		}}},
+		failed: map[string]error{"metrics.k8s.io/v1beta1": errors.New("the server is currently unable to handle the request")},
+	}
+
+	got, err := discoveryResourceService(fake).ListAPIResources()
+	if err != nil {
+		t.Fatalf("ListAPIResources() error = %v", err)
+	}
+	var names []string
+	for _, res := range got.Resources {
+		names = append(names, res.Group+"/"+res.Name)
+	}
+	if want := []string{"/pods", "example.com/widgets"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("ListAPIResources() resources = %v, want %v", names, want)
+	}
+	if _, ok := got.FailedGroups["metrics.k8s.io/v1beta1"]; !ok || len(got.FailedGroups) != 1 {
+		t.Errorf("ListAPIResources() failed groups = %v, want metrics.k8s.io/v1beta1", got.FailedGroups)
+	}
+}
+
+func TestUpdateResourceMergesListItems(t *testing.T) {
+	objs := fixtureObjects(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {namespace: default, name: web}
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:v1
+        env: [{name: MODE, value: prod}]
+        ports: [{containerPort: 8080}]
+        readinessProbe: {httpGet: {path: /ready, port: 8080}}
+        resources: {limits: {memory: 256Mi}}
+      - name: proxy
+        image: proxy:v1
+---
+apiVersion: example.com/v1
+kind: Database
+metadata: {namespace: default, name: orders}
+spec:
+  users:
+  - name: app
+    connections: 3
+  - name: report
+    connections: 5
+`)
+	r, _ := fakeResourceService(t, objs...)
+
+	tests := []struct {
+		name     string
+		resource string
+		objName  string
+		yaml     string
+		path     []string
+		want     []interface{}
+	}{
+		{
+			// Built-in kinds merge containers by name
+			name:     "built-in kind",
+			resource: "deployment",
+			objName:  "web",
+			yaml:     "spec:\n  template:\n    spec:\n      containers:\n      - name: app\n        image: app:v2\n",
+			path:     []string{"spec", "template", "spec", "containers"},
+			want: []interface{}{
+				map[string]interface{}{
+					"name":           "app",
+					"image":          "app:v2",
+					"env":            []interface{}{map[string]interface{}{"name": "MODE", "value": "prod"}},
+					"ports":          []interface{}{map[string]interface{}{"containerPort": int64(8080)}},
+					"readinessProbe": map[string]interface{}{"httpGet": map[string]interface{}{"path": "/ready", "port": int64(8080)}},
+					"resources":      map[string]interface{}{"limits": map[string]interface{}{"memory": "256Mi"}},
+				},
+				map[string]interface{}{"name": "proxy", "image": "proxy:v1"},
+			},
+		},
+		{
+			// Custom kinds declare no merge keys, the list replaces the live one, with
+			// numbers as the update's JSON decodes them
+			name:     "custom kind",
+			resource: "database",
+			objName:  "orders",
+			yaml:     "spec:\n  users:\n  - name: app\n    connections: 4\n",
+			path:     []string{"spec", "users"},
+			want:     []interface{}{map[string]interface{}{"name": "app", "connections": float64(4)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := r.UpdateResource(tt.resource, "default", tt.objName, tt.yaml, UpdateOptions{})
+			if err != nil {
+				t.Fatalf("UpdateResource() error = %v", err)
+			}
+			got, _, _ := unstructured.NestedSlice(result.Object.Object, tt.path...)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UpdateResource() %s = %v, want %v", strings.Join(tt.path, "."), got, tt.want)
+			}
+		})
+	}
+}
@@ -2,10 +2,10 @@ package ai
 
 import (
 	"context"
-	"log"
-	"os"
+	"sync"
 
 	openai "github.com/sashabaranov/go-openai"
+	"github.com/lexieqin/Geek/llm"
 )
 
 var MessageStore ChatMessages
@@ -16,29 +16,50 @@ func init() {
 
 }
 
-func NewOpenAiClient() *openai.Client {
-	token := os.Getenv("DashScope")
-	dashscope_url := "https://dashscope.aliyuncs.com/compatible-mode/v1"
+// defaultModel is the model k8sCheck talks to unless LLM_MODEL names another
+const defaultModel = "qwen-max-0403"
 
-	config := openai.DefaultConfig(token)
-	config.BaseURL = dashscope_url
+var (
+	providerMu sync.Mutex
+	provider   llm.Provider
+)
+
+// SetProvider replaces the model NormalChat talks to, e.g. with a scripted one in tests
+func SetProvider(p llm.Provider) {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	provider = p
+}
 
-	return openai.NewClientWithConfig(config)
+// Provider returns the model NormalChat talks to, by default an OpenAI-compatible client
+// configured from the environment
+func Provider() (llm.Provider, error) {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	if provider == nil {
+		cfg, err := llm.ConfigFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Model == "" {
+			cfg.Model = defaultModel
+		}
+		p, err := llm.NewOpenAI(cfg)
+		if err != nil {
+			return nil, err
+		}
+		provider = p
+	}
+	return provider, nil
 }
 
 // chat对话
-func NormalChat(message []openai.ChatCompletionMessage) openai.ChatCompletionMessage {
-	c := NewOpenAiClient()
-	rsp, err := c.CreateChatCompletion(context.TODO(), openai.ChatCompletionRequest{
-		Model:    "qwen-max-0403",
-		Messages: message,
-	})
+func NormalChat(ctx context.Context, message []openai.ChatCompletionMessage) (openai.ChatCompletionMessage, error) {
+	p, err := Provider()
 	if err != nil {
-		log.Println(err)
-		return openai.ChatCompletionMessage{}
+		return openai.ChatCompletionMessage{}, err
 	}
-
-	return rsp.Choices[0].Message
+	return p.Chat(ctx, llm.Request{Messages: message})
 }
 
 // 定义chat模型
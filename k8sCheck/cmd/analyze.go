@@ -57,7 +57,11 @@ to quickly create a Cobra application.`,
 			ai.MessageStore.AddForUser(prompt)
 			i := 1
 			for {
-				first_response := ai.NormalChat(ai.MessageStore.ToMessage())
+				first_response, err := ai.NormalChat(cmd.Context(), ai.MessageStore.ToMessage())
+				if err != nil {
+					fmt.Println("调用大模型失败:", err)
+					break
+				}
 				fmt.Printf("========第%d轮回答========\n", i)
 				fmt.Println(first_response.Content)
 
@@ -5,7 +5,9 @@ package cmd
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"regexp"
 	"strings"
 
@@ -26,93 +28,102 @@ Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		kubeTool := tools.NewKubeTool()
-		kubeToolDef := "Name: " + kubeTool.Name + "\nDescription: " + kubeTool.Description + "\nArgsSchema: " + fmt.Sprintf("%+v", kubeTool.ArgsSchema.Commands) + "\n"
+		runKubeCheck(cmd.Context(), cmd.InOrStdin(), cmd.OutOrStdout())
+	},
+}
+
+// runKubeCheck answers the questions read from in until exit or the end of input
+func runKubeCheck(ctx context.Context, in io.Reader, out io.Writer) {
+	kubeTool := tools.NewKubeTool()
+	kubeToolDef := "Name: " + kubeTool.Name + "\nDescription: " + kubeTool.Description + "\nArgsSchema: " + fmt.Sprintf("%+v", kubeTool.ArgsSchema.Commands) + "\n"
+
+	searchTool := tools.NewTavilyTool()
+	searchToolDef := "Name: " + searchTool.Name + "\nDescription: " + searchTool.Description + "\nArgsSchema: " + searchTool.ArgsSchema + "\n"
+
+	requestTool := tools.NewRequestsTool()
+	requestToolDef := "Name: " + requestTool.Name + "\nDescription: " + requestTool.Description + "\nArgsSchema: " + requestTool.ArgsSchema + "\n"
 
-		searchTool := tools.NewTavilyTool()
-		searchToolDef := "Name: " + searchTool.Name + "\nDescription: " + searchTool.Description + "\nArgsSchema: " + searchTool.ArgsSchema + "\n"
+	toolsList := make([]string, 0)
+	toolsList = append(toolsList, kubeToolDef, searchToolDef, requestToolDef)
 
-		requestTool := tools.NewRequestsTool()
-		requestToolDef := "Name: " + requestTool.Name + "\nDescription: " + requestTool.Description + "\nArgsSchema: " + requestTool.ArgsSchema + "\n"
+	tool_names := make([]string, 0)
+	tool_names = append(tool_names, kubeTool.Name, searchTool.Name, requestTool.Name)
 
-		toolsList := make([]string, 0)
-		toolsList = append(toolsList, kubeToolDef, searchToolDef, requestToolDef)
+	scanner := bufio.NewScanner(in)
+	fmt.Fprintln(out, "你好，我是k8s助手，请问有什么可以帮你？（输入 'exit' 退出程序）:")
+	for {
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			break
+		}
+		input := scanner.Text()
+		if input == "exit" {
+			fmt.Fprintln(out, "再见！")
+			return
+		}
 
-		tool_names := make([]string, 0)
-		tool_names = append(tool_names, kubeTool.Name, searchTool.Name, requestTool.Name)
+		prompt := fmt.Sprintf(promptTpl.Template, toolsList, tool_names, "", input)
 
-		scanner := bufio.NewScanner(cmd.InOrStdin())
-		fmt.Println("你好，我是k8s助手，请问有什么可以帮你？（输入 'exit' 退出程序）:")
+		//注入用户prompt
+		ai.MessageStore.AddForUser(prompt)
+		i := 1
 		for {
-			fmt.Print("> ")
-			if !scanner.Scan() {
+			first_response, err := ai.NormalChat(ctx, ai.MessageStore.ToMessage())
+			if err != nil {
+				fmt.Fprintln(out, "调用大模型失败:", err)
 				break
 			}
-			input := scanner.Text()
-			if input == "exit" {
-				fmt.Println("再见！")
-				return
+			fmt.Fprintf(out, "========第%d轮回答========\n", i)
+			fmt.Fprintln(out, first_response.Content)
+
+			regexPattern := regexp.MustCompile(`Final Answer:\s*(.*)`)
+			finalAnswer := regexPattern.FindStringSubmatch(first_response.Content)
+			if len(finalAnswer) > 1 {
+				fmt.Fprintln(out, "========最终 GPT 回复========")
+				fmt.Fprintln(out, first_response.Content)
+				break
 			}
 
-			prompt := fmt.Sprintf(promptTpl.Template, toolsList, tool_names, "", input)
-
-			//注入用户prompt
-			ai.MessageStore.AddForUser(prompt)
-			i := 1
-			for {
-				first_response := ai.NormalChat(ai.MessageStore.ToMessage())
-				fmt.Printf("========第%d轮回答========\n", i)
-				fmt.Println(first_response.Content)
-
-				regexPattern := regexp.MustCompile(`Final Answer:\s*(.*)`)
-				finalAnswer := regexPattern.FindStringSubmatch(first_response.Content)
-				if len(finalAnswer) > 1 {
-					fmt.Println("========最终 GPT 回复========")
-					fmt.Println(first_response.Content)
-					break
+			ai.MessageStore.AddForAssistant(first_response.Content)
+
+			regexAction := regexp.MustCompile(`Action:\s*(.*?)[\n]`)
+			regexActionInput := regexp.MustCompile(`Action Input:\s*(.*?)[\n]`)
+
+			action := regexAction.FindStringSubmatch(first_response.Content)
+			actionInput := regexActionInput.FindStringSubmatch(first_response.Content)
+
+			if len(action) > 1 && len(actionInput) > 1 {
+				i++
+				Observation := "Observation: %s"
+				if action[1] == kubeTool.Name {
+					actionInputProcessed := strings.Trim(actionInput[1], "\"")
+					fmt.Fprintln(out, "actionInputProcessed: ", actionInputProcessed)
+					output, _ := kubeTool.Run(actionInputProcessed)
+					fmt.Fprintln(out, "========函数返回结果========")
+					fmt.Fprintln(out, "output: ", output)
+					Observation = fmt.Sprintf(Observation, output)
+				} else if action[1] == searchTool.Name {
+					output, _ := searchTool.Run(actionInput[1])
+					fmt.Fprintln(out, "========函数返回结果========")
+					fmt.Fprintln(out, "output: ", output)
+					Observation = fmt.Sprintf(Observation, output)
+				} else if action[1] == requestTool.Name {
+					fmt.Fprintln(out, "actionInput[1]: ", actionInput[1])
+					actionInputProcessed := strings.Trim(actionInput[1], "\"")
+					fmt.Fprintln(out, "actionInputProcessed: ", actionInputProcessed)
+					output, _ := requestTool.Run(actionInputProcessed)
+					fmt.Fprintln(out, "========函数返回结果========")
+					fmt.Fprintln(out, "output: ", output)
+					Observation = fmt.Sprintf(Observation, output)
 				}
 
-				ai.MessageStore.AddForAssistant(first_response.Content)
-
-				regexAction := regexp.MustCompile(`Action:\s*(.*?)[\n]`)
-				regexActionInput := regexp.MustCompile(`Action Input:\s*(.*?)[\n]`)
-
-				action := regexAction.FindStringSubmatch(first_response.Content)
-				actionInput := regexActionInput.FindStringSubmatch(first_response.Content)
-
-				if len(action) > 1 && len(actionInput) > 1 {
-					i++
-					Observation := "Observation: %s"
-					if action[1] == kubeTool.Name {
-						actionInputProcessed := strings.Trim(actionInput[1], "\"")
-						fmt.Println("actionInputProcessed: ", actionInputProcessed)
-						output, _ := kubeTool.Run(actionInputProcessed)
-						fmt.Println("========函数返回结果========")
-						fmt.Println("output: ", output)
-						Observation = fmt.Sprintf(Observation, output)
-					} else if action[1] == searchTool.Name {
-						output, _ := searchTool.Run(actionInput[1])
-						fmt.Println("========函数返回结果========")
-						fmt.Println("output: ", output)
-						Observation = fmt.Sprintf(Observation, output)
-					} else if action[1] == requestTool.Name {
-						fmt.Println("actionInput[1]: ", actionInput[1])
-						actionInputProcessed := strings.Trim(actionInput[1], "\"")
-						fmt.Println("actionInputProcessed: ", actionInputProcessed)
-						output, _ := requestTool.Run(actionInputProcessed)
-						fmt.Println("========函数返回结果========")
-						fmt.Println("output: ", output)
-						Observation = fmt.Sprintf(Observation, output)
-					}
-
-					prompt = first_response.Content + Observation
-					fmt.Printf("========第%d轮的prompt========\n", i)
-					fmt.Println(prompt)
-					ai.MessageStore.AddForUser(prompt)
-				}
+				prompt = first_response.Content + Observation
+				fmt.Fprintf(out, "========第%d轮的prompt========\n", i)
+				fmt.Fprintln(out, prompt)
+				ai.MessageStore.AddForUser(prompt)
 			}
 		}
-	},
+	}
 }
 
 func init() {
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lexieqin/Geek/llm"
+	"github.com/lexieqin/Geek/llm/replay"
+	"github.com/xingyunyang01/k8sCheck/cmd/ai"
+)
+
+// fakeGinTools stands in for the ginTools endpoints RequestsTool is pointed at
+type fakeGinTools struct {
+	*httptest.Server
+
+	mu   sync.Mutex
+	hits []string
+}
+
+func startFakeGinTools(t *testing.T) *fakeGinTools {
+	t.Helper()
+	f := &fakeGinTools{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.hits = append(f.hits, r.URL.RequestURI())
+		f.mu.Unlock()
+
+		if r.URL.Path != "/pods" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`<html><body>web-1 CrashLoopBackOff: back-off restarting failed container</body></html>`))
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeGinTools) Hits() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.hits...)
+}
+
+// fakeKubectl puts a kubectl on PATH that logs its arguments and prints a pod list
+func fakeKubectl(t *testing.T) func() []string {
+	t.Helper()
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "kubectl.log")
+	script := "#!/bin/sh\necho \"$@\" >> " + logPath + "\necho 'NAME READY STATUS'\necho 'web-1 0/1 CrashLoopBackOff'\n"
+	if err := os.WriteFile(filepath.Join(dir, "kubectl"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	return func() []string {
+		data, err := os.ReadFile(logPath)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+}
+
+type kubeCheckRun struct {
+	output  string
+	kubectl []string
+	hits    []string
+	keys    []string
+}
+
+// runKubeCheckE2E answers the input through a replay server on dir. With a nil upstream
+// the server only replays recorded fixtures.
+func runKubeCheckE2E(t *testing.T, ginTools *fakeGinTools, input, dir string, upstream llm.Provider) kubeCheckRun {
+	t.Helper()
+	kubectlCalls := fakeKubectl(t)
+
+	replayServer, err := replay.NewServer(dir, upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	llmServer := httptest.NewServer(replayServer)
+	defer llmServer.Close()
+
+	provider, err := llm.NewOpenAI(llm.Config{APIKey: "test", BaseURL: llmServer.URL + "/v1", MaxRetries: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ai.SetProvider(provider)
+	defer ai.SetProvider(nil)
+	ai.MessageStore.Clear()
+
+	hitsBefore := len(ginTools.Hits())
+	var out strings.Builder
+	runKubeCheck(context.Background(), strings.NewReader(input), &out)
+
+	return kubeCheckRun{
+		output:  out.String(),
+		kubectl: kubectlCalls(),
+		hits:    ginTools.Hits()[hitsBefore:],
+		keys:    replayServer.Keys(),
+	}
+}
+
+func TestKubeCheckEndToEnd(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		// script is what the model answers, in order, when recording; url is the fake ginTools
+		script      func(url string) []llm.Step
+		wantKubectl []string
+		wantHits    []string
+		wantOutput  []string
+	}{
+		{
+			name:  "kubectl then ginTools then answer",
+			input: "为什么 web-1 起不来\nexit\n",
+			script: func(url string) []llm.Step {
+				return []llm.Step{
+					llm.Reply("Thought: 先看看 pod 的状态\nAction: KubeTool\nAction Input: \"kubectl get pods -n default\"\n"),
+					llm.Reply("Thought: 再看看 ginTools 的 pod 列表\nAction: RequestsTool\nAction Input: \"" + url + "/pods?ns=default\"\n"),
+					llm.Reply("Thought: I now know the final answer\nFinal Answer: web-1 处于 CrashLoopBackOff，容器在不断重启"),
+				}
+			},
+			wantKubectl: []string{"get pods -n default"},
+			wantHits:    []string{"/pods?ns=default"},
+			wantOutput:  []string{"Final Answer: web-1 处于 CrashLoopBackOff", "再见！"},
+		},
+		{
+			name:  "a failed model call ends the question, not the session",
+			input: "列出 pod\n列出 pod\nexit\n",
+			script: func(url string) []llm.Step {
+				return []llm.Step{
+					llm.Fail(errors.New("model overloaded")),
+					llm.Reply("Thought: I now know the final answer\nFinal Answer: default 里只有 web-1"),
+				}
+			},
+			wantOutput: []string{"调用大模型失败:", "Final Answer: default 里只有 web-1", "再见！"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ginTools := startFakeGinTools(t)
+			dir := t.TempDir()
+
+			check := func(mode string, run kubeCheckRun) {
+				t.Helper()
+				if !reflect.DeepEqual(run.kubectl, tt.wantKubectl) {
+					t.Errorf("%s: kubectl calls = %q, want %q", mode, run.kubectl, tt.wantKubectl)
+				}
+				if !reflect.DeepEqual(run.hits, tt.wantHits) {
+					t.Errorf("%s: ginTools hits = %q, want %q", mode, run.hits, tt.wantHits)
+				}
+				for _, want := range tt.wantOutput {
+					if !strings.Contains(run.output, want) {
+						t.Errorf("%s: output does not contain %q:\n%s", mode, want, run.output)
+					}
+				}
+			}
+
+			upstream := llm.NewScripted(tt.script(ginTools.URL)...)
+			recorded := runKubeCheckE2E(t, ginTools, tt.input, dir, upstream)
+			check("record", recorded)
+			if upstream.Remaining() != 0 {
+				t.Errorf("record: %d scripted replies left unused", upstream.Remaining())
+			}
+
+			replayed := runKubeCheckE2E(t, ginTools, tt.input, dir, nil)
+			check("replay", replayed)
+			if !reflect.DeepEqual(replayed.keys, recorded.keys) {
+				t.Errorf("replay asked for conversations %v, recorded %v", replayed.keys, recorded.keys)
+			}
+		})
+	}
+}
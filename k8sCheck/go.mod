@@ -6,6 +6,7 @@ toolchain go1.23.4
 
 require (
 	github.com/PuerkitoBio/goquery v1.10.0
+	github.com/lexieqin/Geek/llm v0.0.0
 	github.com/sashabaranov/go-openai v1.36.0
 	github.com/spf13/cobra v1.8.1
 )
@@ -16,3 +17,5 @@ require (
 	github.com/spf13/pflag v1.0.5 // indirect
 	golang.org/x/net v0.29.0 // indirect
 )
+
+replace github.com/lexieqin/Geek/llm => ../llm
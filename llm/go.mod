@@ -0,0 +1,5 @@
+module github.com/lexieqin/Geek/llm
+
+go 1.19
+
+require github.com/sashabaranov/go-openai v1.35.6
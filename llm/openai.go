@@ -0,0 +1,230 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// DefaultBaseURL is Alibaba DashScope's OpenAI-compatible endpoint.
+	DefaultBaseURL = "https://dashscope.aliyuncs.com/compatible-mode/v1"
+	// DefaultModel is used when the config names no model.
+	DefaultModel = "qwen-max"
+
+	defaultTimeout      = 120 * time.Second
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = time.Second
+)
+
+// OpenAIProvider talks to any OpenAI-compatible chat completion API.
+type OpenAIProvider struct {
+	client       *openai.Client
+	model        string
+	temperature  *float32
+	maxTokens    int
+	timeout      time.Duration
+	maxRetries   int
+	retryBackoff time.Duration
+}
+
+// NewOpenAI creates an OpenAIProvider. An empty API key or base URL falls back to the
+// environment, everything else to the defaults.
+func NewOpenAI(cfg Config) (*OpenAIProvider, error) {
+	apiKey := cfg.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("OPENAI_API_KEY")
+	}
+	if apiKey == "" {
+		apiKey = os.Getenv("DashScope")
+	}
+	if apiKey == "" {
+		return nil, errors.New("OPENAI_API_KEY or DashScope environment variable is required")
+	}
+
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = os.Getenv("OPENAI_BASE_URL")
+	}
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	clientConfig := openai.DefaultConfig(apiKey)
+	clientConfig.BaseURL = baseURL
+
+	p := &OpenAIProvider{
+		client:       openai.NewClientWithConfig(clientConfig),
+		model:        cfg.Model,
+		temperature:  cfg.Temperature,
+		maxTokens:    cfg.MaxTokens,
+		timeout:      cfg.Timeout,
+		maxRetries:   cfg.MaxRetries,
+		retryBackoff: cfg.RetryBackoff,
+	}
+	if p.model == "" {
+		p.model = DefaultModel
+	}
+	if p.timeout <= 0 {
+		p.timeout = defaultTimeout
+	}
+	if p.maxRetries < 0 {
+		p.maxRetries = 0
+	} else if p.maxRetries == 0 {
+		p.maxRetries = defaultMaxRetries
+	}
+	if p.retryBackoff <= 0 {
+		p.retryBackoff = defaultRetryBackoff
+	}
+	return p, nil
+}
+
+func (p *OpenAIProvider) request(req Request, stream bool) openai.ChatCompletionRequest {
+	ret := openai.ChatCompletionRequest{
+		Model:     p.model,
+		Messages:  req.Messages,
+		MaxTokens: p.maxTokens,
+		Stream:    stream,
+	}
+	if p.temperature != nil {
+		ret.Temperature = *p.temperature
+	}
+	if len(req.Tools) > 0 {
+		ret.Tools = req.Tools
+		ret.ToolChoice = "auto"
+	}
+	return ret
+}
+
+func (p *OpenAIProvider) Chat(ctx context.Context, req Request) (openai.ChatCompletionMessage, error) {
+	var rsp openai.ChatCompletionResponse
+	err := p.retry(ctx, func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+
+		var err error
+		rsp, err = p.client.CreateChatCompletion(ctx, p.request(req, false))
+		return err
+	})
+	if err != nil {
+		return openai.ChatCompletionMessage{}, fmt.Errorf("llm: chat completion failed: %w", err)
+	}
+	if len(rsp.Choices) == 0 {
+		return openai.ChatCompletionMessage{}, ErrEmptyResponse
+	}
+	return rsp.Choices[0].Message, nil
+}
+
+// ChatStream retries only while opening the stream; once tokens have been sent to
+// onDelta a failure is returned together with the partial reply.
+func (p *OpenAIProvider) ChatStream(ctx context.Context, req Request, onDelta func(string)) (openai.ChatCompletionMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	var stream *openai.ChatCompletionStream
+	err := p.retry(ctx, func(ctx context.Context) error {
+		var err error
+		stream, err = p.client.CreateChatCompletionStream(ctx, p.request(req, true))
+		return err
+	})
+	if err != nil {
+		return openai.ChatCompletionMessage{}, fmt.Errorf("llm: chat completion failed: %w", err)
+	}
+	defer stream.Close()
+
+	ret := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant}
+	for {
+		rsp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return ret, fmt.Errorf("llm: stream interrupted: %w", err)
+		}
+		if len(rsp.Choices) == 0 {
+			continue
+		}
+
+		delta := rsp.Choices[0].Delta
+		if delta.Content != "" {
+			ret.Content += delta.Content
+			if onDelta != nil {
+				onDelta(delta.Content)
+			}
+		}
+		ret.ToolCalls = mergeToolCallDeltas(ret.ToolCalls, delta.ToolCalls)
+	}
+
+	if ret.Content == "" && len(ret.ToolCalls) == 0 {
+		return ret, ErrEmptyResponse
+	}
+	return ret, nil
+}
+
+// retry runs fn until it succeeds, fails with an error that is not worth retrying,
+// or maxRetries is reached. The wait doubles after every attempt, with jitter.
+func (p *OpenAIProvider) retry(ctx context.Context, fn func(ctx context.Context) error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || !retryable(err) || attempt >= p.maxRetries {
+			return err
+		}
+
+		delay := p.retryBackoff << attempt
+		delay += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		case <-time.After(delay):
+		}
+	}
+}
+
+// retryable reports whether err is a rate limit or a server error.
+func retryable(err error) bool {
+	status := 0
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		status = apiErr.HTTPStatusCode
+	case errors.As(err, &reqErr):
+		status = reqErr.HTTPStatusCode
+	}
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// mergeToolCallDeltas appends streamed tool call fragments to the calls assembled so far.
+func mergeToolCallDeltas(calls []openai.ToolCall, deltas []openai.ToolCall) []openai.ToolCall {
+	for _, d := range deltas {
+		index := len(calls) - 1
+		if d.Index != nil {
+			index = *d.Index
+		} else if d.ID != "" {
+			index = len(calls)
+		}
+		if index < 0 {
+			index = 0
+		}
+		for len(calls) <= index {
+			calls = append(calls, openai.ToolCall{Type: openai.ToolTypeFunction})
+		}
+
+		if d.ID != "" {
+			calls[index].ID = d.ID
+		}
+		if d.Type != "" {
+			calls[index].Type = d.Type
+		}
+		calls[index].Function.Name += d.Function.Name
+		calls[index].Function.Arguments += d.Function.Arguments
+	}
+	return calls
+}
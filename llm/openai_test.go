@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const completion = `{"id":"1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`
+
+// newTestProvider serves the given status codes in order, then successful completions.
+func newTestProvider(t *testing.T, statuses ...int) (*OpenAIProvider, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&calls, 1))
+		w.Header().Set("Content-Type", "application/json")
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+			fmt.Fprintf(w, `{"error":{"message":"status %d","type":"test"}}`, statuses[n-1])
+			return
+		}
+		fmt.Fprint(w, completion)
+	}))
+	t.Cleanup(srv.Close)
+
+	p, err := NewOpenAI(Config{
+		APIKey:       "test",
+		BaseURL:      srv.URL,
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, &calls
+}
+
+func chatRequest() Request {
+	return Request{Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}}}
+}
+
+func TestChatRetriesRateLimitsAndServerErrors(t *testing.T) {
+	p, calls := newTestProvider(t, http.StatusTooManyRequests, http.StatusBadGateway)
+
+	msg, err := p.Chat(context.Background(), chatRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Content != "ok" || *calls != 3 {
+		t.Errorf("Chat() = %q after %d calls, want \"ok\" after 3", msg.Content, *calls)
+	}
+}
+
+func TestChatGivesUpAfterMaxRetries(t *testing.T) {
+	p, calls := newTestProvider(t, 500, 500, 500, 500)
+
+	_, err := p.Chat(context.Background(), chatRequest())
+	var apiErr *openai.APIError
+	if !errors.As(err, &apiErr) || apiErr.HTTPStatusCode != 500 {
+		t.Fatalf("Chat() error = %v, want the 500 API error", err)
+	}
+	if *calls != 3 {
+		t.Errorf("got %d calls, want 3", *calls)
+	}
+}
+
+func TestChatDoesNotRetryClientErrors(t *testing.T) {
+	p, calls := newTestProvider(t, http.StatusBadRequest)
+
+	if _, err := p.Chat(context.Background(), chatRequest()); err == nil {
+		t.Fatal("Chat() succeeded, want the 400 error")
+	}
+	if *calls != 1 {
+		t.Errorf("got %d calls, want 1", *calls)
+	}
+}
+
+func TestChatStopsRetryingWhenContextIsDone(t *testing.T) {
+	p, _ := newTestProvider(t, 503, 503, 503)
+	p.retryBackoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Chat(ctx, chatRequest()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Chat() error = %v, want DeadlineExceeded", err)
+	}
+}
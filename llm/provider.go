@@ -0,0 +1,97 @@
+// Package llm is the chat model client shared by the agents of this repository: an
+// OpenAI-compatible Provider configured by Config, and a scripted Provider for tests.
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ErrEmptyResponse is returned when the model answered without any choice.
+var ErrEmptyResponse = errors.New("llm: empty response")
+
+// Request is one chat completion call. Tools may be nil for plain chat.
+type Request struct {
+	Messages []openai.ChatCompletionMessage
+	Tools    []openai.Tool
+}
+
+// Provider talks to a chat model. Implementations must be safe for concurrent use.
+type Provider interface {
+	// Chat returns the model's reply, including any tool calls.
+	Chat(ctx context.Context, req Request) (openai.ChatCompletionMessage, error)
+	// ChatStream is like Chat but calls onDelta with every content token as it arrives.
+	ChatStream(ctx context.Context, req Request, onDelta func(string)) (openai.ChatCompletionMessage, error)
+}
+
+// Config selects the chat model. Empty values fall back to the environment and defaults.
+type Config struct {
+	// Provider is "openai" (default), i.e. any OpenAI-compatible API
+	Provider string `yaml:"provider"`
+	// Model defaults to DefaultModel
+	Model string `yaml:"model"`
+	// BaseURL defaults to $OPENAI_BASE_URL, then to DashScope
+	BaseURL string `yaml:"base_url"`
+	// APIKey defaults to $OPENAI_API_KEY, then to $DashScope
+	APIKey      string   `yaml:"api_key,omitempty"`
+	Temperature *float32 `yaml:"temperature"`
+	MaxTokens   int      `yaml:"max_tokens"`
+	// Timeout bounds each model call
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxRetries is how often a call failing with 429 or 5xx is retried, -1 disables retries
+	MaxRetries   int           `yaml:"max_retries"`
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+}
+
+// ConfigFromEnv reads the model settings from LLM_MODEL, LLM_TEMPERATURE, LLM_MAX_TOKENS,
+// LLM_TIMEOUT and LLM_MAX_RETRIES. NewOpenAI reads the API key and base URL.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{Model: os.Getenv("LLM_MODEL")}
+	if s := os.Getenv("LLM_TEMPERATURE"); s != "" {
+		t, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return cfg, fmt.Errorf("LLM_TEMPERATURE must be a number: %w", err)
+		}
+		temperature := float32(t)
+		cfg.Temperature = &temperature
+	}
+	if s := os.Getenv("LLM_MAX_TOKENS"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return cfg, fmt.Errorf("LLM_MAX_TOKENS must be an integer: %w", err)
+		}
+		cfg.MaxTokens = n
+	}
+	if s := os.Getenv("LLM_TIMEOUT"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return cfg, fmt.Errorf("LLM_TIMEOUT must be a duration: %w", err)
+		}
+		cfg.Timeout = d
+	}
+	if s := os.Getenv("LLM_MAX_RETRIES"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return cfg, fmt.Errorf("LLM_MAX_RETRIES must be an integer: %w", err)
+		}
+		cfg.MaxRetries = n
+	}
+	return cfg, nil
+}
+
+// New creates the Provider selected by cfg.
+func New(cfg Config) (Provider, error) {
+	switch strings.ToLower(cfg.Provider) {
+	case "", "openai":
+		return NewOpenAI(cfg)
+	default:
+		return nil, fmt.Errorf("unknown llm provider %q: must be openai", cfg.Provider)
+	}
+}
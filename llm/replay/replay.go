@@ -0,0 +1,253 @@
+// Package replay serves recorded model responses over the OpenAI chat completions
+// wire format, so the agent loops can run without a real model.
+//
+// Every request is keyed by a hash of its conversation. In replay mode the response
+// recorded for that key is returned; in record mode the request is forwarded to an
+// upstream Provider and its answer is saved as a fixture file before being returned.
+package replay
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lexieqin/Geek/llm"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Fixture is one recorded model call, stored as <Key>.json.
+type Fixture struct {
+	Key string `json:"key"`
+	// Messages and Tools are kept for reviewing fixtures, only Key is used for lookups
+	Messages []openai.ChatCompletionMessage `json:"messages"`
+	Tools    []string                       `json:"tools,omitempty"`
+	Response openai.ChatCompletionMessage   `json:"response"`
+}
+
+// Key hashes what determines the model's answer: the messages and the offered tools.
+// The model name and sampling parameters are ignored so fixtures survive config changes.
+func Key(req openai.ChatCompletionRequest) string {
+	type call struct {
+		ID        string `json:"id"`
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	}
+	type message struct {
+		Role       string `json:"role"`
+		Content    string `json:"content"`
+		ToolCallID string `json:"toolCallId,omitempty"`
+		ToolCalls  []call `json:"toolCalls,omitempty"`
+	}
+
+	conversation := struct {
+		Messages []message `json:"messages"`
+		Tools    []string  `json:"tools"`
+	}{Tools: toolNames(req.Tools)}
+
+	for _, m := range req.Messages {
+		msg := message{Role: m.Role, Content: m.Content, ToolCallID: m.ToolCallID}
+		for _, c := range m.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, call{ID: c.ID, Name: c.Function.Name, Arguments: c.Function.Arguments})
+		}
+		conversation.Messages = append(conversation.Messages, msg)
+	}
+
+	data, _ := json.Marshal(conversation)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+func toolNames(tools []openai.Tool) []string {
+	var names []string
+	for _, t := range tools {
+		if t.Function != nil {
+			names = append(names, t.Function.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Server answers chat completion requests from fixtures in a directory.
+type Server struct {
+	dir string
+	// upstream is set in record mode
+	upstream llm.Provider
+
+	mu   sync.Mutex
+	keys []string
+}
+
+// NewServer creates a Server reading fixtures from dir. If upstream is not nil the
+// server records: every request is answered by upstream and saved to dir.
+func NewServer(dir string, upstream llm.Provider) (*Server, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create fixture directory: %v", err)
+	}
+	return &Server{dir: dir, upstream: upstream}, nil
+}
+
+// Keys returns the conversation keys served so far, in order.
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.keys...)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+		writeError(w, http.StatusNotFound, "only POST /chat/completions is served")
+		return
+	}
+
+	var req openai.ChatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	key := Key(req)
+	s.mu.Lock()
+	s.keys = append(s.keys, key)
+	s.mu.Unlock()
+
+	var msg openai.ChatCompletionMessage
+	var err error
+	if s.upstream != nil {
+		msg, err = s.record(r, key, req)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+	} else {
+		fixture, err := s.load(key)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		msg = fixture.Response
+	}
+
+	if req.Stream {
+		writeStream(w, key, req.Model, msg)
+		return
+	}
+	writeJSON(w, http.StatusOK, openai.ChatCompletionResponse{
+		ID:      "replay-" + key,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+		Choices: []openai.ChatCompletionChoice{{Message: msg, FinishReason: finishReason(msg)}},
+	})
+}
+
+// record asks upstream and saves the answer as a fixture.
+func (s *Server) record(r *http.Request, key string, req openai.ChatCompletionRequest) (openai.ChatCompletionMessage, error) {
+	msg, err := s.upstream.Chat(r.Context(), llm.Request{Messages: req.Messages, Tools: req.Tools})
+	if err != nil {
+		return msg, err
+	}
+	if msg.Role == "" {
+		msg.Role = openai.ChatMessageRoleAssistant
+	}
+
+	fixture := Fixture{Key: key, Messages: req.Messages, Tools: toolNames(req.Tools), Response: msg}
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return msg, err
+	}
+	if err := os.WriteFile(s.path(key), data, 0o644); err != nil {
+		return msg, fmt.Errorf("failed to save fixture: %v", err)
+	}
+	return msg, nil
+}
+
+func (s *Server) load(key string) (*Fixture, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no recorded response for conversation %s in %s, run in record mode to capture it", key, s.dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %v", s.path(key), err)
+	}
+	return &fixture, nil
+}
+
+func (s *Server) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
+
+func finishReason(msg openai.ChatCompletionMessage) openai.FinishReason {
+	if len(msg.ToolCalls) > 0 {
+		return openai.FinishReasonToolCalls
+	}
+	return openai.FinishReasonStop
+}
+
+// writeStream sends msg as chat.completion.chunk events: the content word by word,
+// then every tool call, then the finish reason and [DONE].
+func writeStream(w http.ResponseWriter, key, model string, msg openai.ChatCompletionMessage) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher, _ := w.(http.Flusher)
+
+	send := func(delta openai.ChatCompletionStreamChoiceDelta, reason openai.FinishReason) {
+		data, _ := json.Marshal(openai.ChatCompletionStreamResponse{
+			ID:      "replay-" + key,
+			Object:  "chat.completion.chunk",
+			Created: time.Now().Unix(),
+			Model:   model,
+			Choices: []openai.ChatCompletionStreamChoice{{Delta: delta, FinishReason: reason}},
+		})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	send(openai.ChatCompletionStreamChoiceDelta{Role: openai.ChatMessageRoleAssistant}, "")
+	for _, word := range strings.SplitAfter(msg.Content, " ") {
+		if word != "" {
+			send(openai.ChatCompletionStreamChoiceDelta{Content: word}, "")
+		}
+	}
+	for i, call := range msg.ToolCalls {
+		index := i
+		call.Index = &index
+		send(openai.ChatCompletionStreamChoiceDelta{ToolCalls: []openai.ToolCall{call}}, "")
+	}
+	send(openai.ChatCompletionStreamChoiceDelta{}, finishReason(msg))
+
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError answers in the OpenAI error format, which clients turn into an APIError.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]string{"message": message, "type": "replay_error"},
+	})
+}
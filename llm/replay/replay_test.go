@@ -0,0 +1,34 @@
+package replay
+
+import (
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestKey(t *testing.T) {
+	base := openai.ChatCompletionRequest{
+		Model:    "qwen-max",
+		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "list pods"}},
+	}
+
+	sameConversation := base
+	sameConversation.Model = "gpt-4o"
+	sameConversation.Temperature = 0.7
+	sameConversation.Stream = true
+	if Key(sameConversation) != Key(base) {
+		t.Error("Key() depends on the model or sampling parameters")
+	}
+
+	otherContent := base
+	otherContent.Messages = []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "list services"}}
+	if Key(otherContent) == Key(base) {
+		t.Error("Key() ignores the message content")
+	}
+
+	withTools := base
+	withTools.Tools = []openai.Tool{{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{Name: "ListTool"}}}
+	if Key(withTools) == Key(base) {
+		t.Error("Key() ignores the offered tools")
+	}
+}
@@ -0,0 +1,134 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ErrScriptExhausted is returned by ScriptedProvider when no step is left.
+var ErrScriptExhausted = errors.New("llm: scripted provider has no more responses")
+
+// ErrUnansweredToolCall is returned by ScriptedProvider for a conversation in which a tool
+// call is not followed by the tool's reply, which OpenAI-compatible APIs reject.
+var ErrUnansweredToolCall = errors.New("llm: tool call without a tool reply")
+
+// Step is one scripted model reply, or the error the call fails with.
+type Step struct {
+	Message openai.ChatCompletionMessage
+	Err     error
+}
+
+// Reply scripts a plain text answer.
+func Reply(content string) Step {
+	return Step{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: content}}
+}
+
+// CallTools scripts an answer that calls the given tools.
+func CallTools(calls ...openai.ToolCall) Step {
+	return Step{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, ToolCalls: calls}}
+}
+
+// ToolCall builds a function call for CallTools.
+func ToolCall(id, name, arguments string) openai.ToolCall {
+	return openai.ToolCall{
+		ID:       id,
+		Type:     openai.ToolTypeFunction,
+		Function: openai.FunctionCall{Name: name, Arguments: arguments},
+	}
+}
+
+// Fail scripts a failed call.
+func Fail(err error) Step {
+	return Step{Err: err}
+}
+
+// ScriptedProvider is a deterministic Provider for tests. It answers every call with
+// the next step of its script and records the requests it received.
+type ScriptedProvider struct {
+	mu       sync.Mutex
+	steps    []Step
+	requests []Request
+}
+
+// NewScripted creates a ScriptedProvider that replies with steps in order.
+func NewScripted(steps ...Step) *ScriptedProvider {
+	return &ScriptedProvider{steps: steps}
+}
+
+func (s *ScriptedProvider) Chat(ctx context.Context, req Request) (openai.ChatCompletionMessage, error) {
+	if err := ctx.Err(); err != nil {
+		return openai.ChatCompletionMessage{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, req)
+	if err := checkToolReplies(req.Messages); err != nil {
+		return openai.ChatCompletionMessage{}, err
+	}
+	if len(s.steps) == 0 {
+		return openai.ChatCompletionMessage{}, ErrScriptExhausted
+	}
+	step := s.steps[0]
+	s.steps = s.steps[1:]
+	return step.Message, step.Err
+}
+
+// checkToolReplies makes sure every tool call of an assistant message is answered by the
+// tool messages that directly follow it.
+func checkToolReplies(messages []openai.ChatCompletionMessage) error {
+	for i, msg := range messages {
+		if len(msg.ToolCalls) == 0 {
+			continue
+		}
+		answered := make(map[string]bool)
+		for _, reply := range messages[i+1:] {
+			if reply.Role != openai.ChatMessageRoleTool {
+				break
+			}
+			answered[reply.ToolCallID] = true
+		}
+		for _, call := range msg.ToolCalls {
+			if !answered[call.ID] {
+				return fmt.Errorf("%w: %s %s", ErrUnansweredToolCall, call.Function.Name, call.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// ChatStream sends the scripted content to onDelta word by word.
+func (s *ScriptedProvider) ChatStream(ctx context.Context, req Request, onDelta func(string)) (openai.ChatCompletionMessage, error) {
+	msg, err := s.Chat(ctx, req)
+	if err != nil || onDelta == nil {
+		return msg, err
+	}
+	for _, word := range strings.SplitAfter(msg.Content, " ") {
+		if word != "" {
+			onDelta(word)
+		}
+	}
+	return msg, nil
+}
+
+// Requests returns the requests received so far.
+func (s *ScriptedProvider) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+// Remaining returns how many steps have not been used yet.
+func (s *ScriptedProvider) Remaining() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.steps)
+}